@@ -1,97 +1,970 @@
 package cmd
 
 import (
+	"encoding/base64"
 	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
 	"os"
+	"regexp"
 	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 var EnvVars *EnvConfig
 
+// E.164 calling codes, up to four digits for codes such as +1268
+var smsCountryCodePattern = regexp.MustCompile(`^\+[1-9][0-9]{0,3}$`)
+
 type EnvConfig struct {
 	Environment    string
 	Port           int
+	AdminPort      int // serves admin routes and metrics apart when set
 	DBUrl          string
 	TokenSecret    string
 	SmtpHost       string
 	SmtpPort       int
 	GmailUser      string
 	AppPassword    string
+	MailFromName   string
+	MailFromAddr   string
+	MailReplyTo    string // optional
 	GhClientId     string // github
 	GhClientSecret string
 	GhRedirectUrl  string
+	GhOrg          string // restricts login to members when set
+	GhBaseUrl      string // web URL, differs for GitHub Enterprise
+	GhApiUrl       string
+	GhTokenKey     []byte // encrypts stored GitHub tokens, which are not kept when unset
+
+	GhRedirectUrls []string // allowed redirect_uri values, GhRedirectUrl first
+
+	MailWebhookSecret string // mail webhook is rejected when unset
+
+	GoogleClientId     string // google, only used for account linking
+	GoogleClientSecret string
+	GoogleRedirectUrl  string
+
+	WebAuthnRPID      string   // passkeys are disabled when unset
+	WebAuthnRPOrigins []string // fully qualified origins allowed to use passkeys
+
+	TLSCertFile           string // served over HTTPS when both are set
+	TLSKeyFile            string
+	HSTSMaxAge            time.Duration // sent over HTTPS only, 0 disables
+	HSTSIncludeSubdomains bool          // extends HSTS to every subdomain, off by default
+
+	AppName      string            // available to mail subject templates
+	MailSubjects map[string]string // subject templates keyed by mail type
+
+	OAuthResponseMode string // "json" or "redirect"
+	OAuthAutoRegister bool   // unknown GitHub users are registered instead of rejected
+	FrontendUrl       string
+
+	RefreshTokenCookie bool
+
+	RefreshTokenExpiry  string        // "fixed" or "sliding"
+	RefreshTokenMaxAge  time.Duration // absolute lifetime of a session
+	RefreshTokenIdleTTL time.Duration // sliding only, renewed on every refresh
+
+	TokenFingerprintBinding bool // refresh rejected from a different client
+
+	ClientIds      []string // client applications tokens may be issued to
+	AdminClientIds []string // admin routes only accept tokens of these, any when empty
+
+	DBReplicaUrl string // optional, serves read-only queries
+
+	UnavailableRetryAfter time.Duration // hinted on 503s while a dependency is down
+
+	DBStatementTimeout time.Duration // 0 disables
+	DBAcquireTimeout   time.Duration // 0 waits for the request timeout
+
+	RequestTimeout  time.Duration            // default for all handlers
+	RequestTimeouts map[string]time.Duration // per-route overrides
+
+	BountyMaxPerTransaction int32
+	BountyMaxTotal          int32
+
+	BountyReconcileInterval time.Duration // 0 disables reconciling against the ledger
+	BountyReconcileDryRun   bool          // only logs drift when set
+
+	RequestLogging bool
+
+	RateLimitBackend string // "memory" or "postgres"
+
+	StaffNotifyEmails   []string // staff alerts are off when both are unset
+	StaffNotifyWebhook  string
+	StaffNotifyDebounce time.Duration // per event
+
+	FeatureFlags        map[string]bool // overridden by rows in feature_flag
+	FeatureFlagsRefresh time.Duration   // how often rows are reloaded
+
+	MailProbeInterval time.Duration // 0 disables probing the mail provider
+
+	TrustedProxies []string // IPs or CIDRs allowed to set X-Forwarded-For
+
+	CompressionMinSize int // bytes, negative disables compression
+
+	ErrorFormat string // "envelope" or "problem" (RFC 7807)
+
+	CacheMaxAge time.Duration // of cacheable read endpoints, 0 forces revalidation
+
+	MaintenanceMode bool // initial state, can be toggled at runtime
+
+	LoginAnomalyDetection bool // audits logins from new IP addresses
+	LoginAlertMail        bool // also mails the user about them
+
+	CollapseGmailDots bool
+
+	EmailDomainAllowlist []string // empty allows every domain
+	EmailDomainDenylist  []string
+	EmailBlockDisposable bool // rejects domains of the bundled disposable list
+
+	OtpAlphanumeric   bool          // letters are matched case-insensitively
+	OtpMaxResends     int32         // per registration
+	OtpResendCooldown time.Duration // between two sends of a registration's OTP
+
+	OtpMailFormat string // "text", "html" or "multipart"
+
+	MailPreview bool // serves rendered mails to admins, off in production by default
+
+	SmsProvider      string // "twilio", SMS OTP delivery is disabled when unset
+	TwilioAccountSid string
+	TwilioAuthToken  string
+	TwilioFromNumber string // E.164
+	TwilioApiUrl     string
+	SmsCountryCodes  []string // E.164 calling codes SMS is sent to, empty allows every code
+
+	OnboardingTTL             time.Duration // unverified and completed registrations are purged after
+	OnboardingCleanupInterval time.Duration
 }
 
-func NewEnvConfig() (*EnvConfig, error) {
-	err := godotenv.Load()
+// Routes REQUEST_TIMEOUT_OVERRIDES may name, one per TimeoutFor caller
+var timeoutRoutes = []string{
+	"register", "otp_verify", "otp_resend", "oauth", "refresh", "profile",
+	"projects", "issues", "available", "bounty", "session", "admin", "audit",
+	"sync_github", "export", "webhook", "onboarded", "bounty_batch",
+	"rate_limit", "users_export", "leaderboard", "passkey",
+}
+
+// Returns the configured timeout for the named route, falling back to the
+// default request timeout when no override is present
+func (e *EnvConfig) TimeoutFor(route string) time.Duration {
+	if timeout, ok := e.RequestTimeouts[route]; ok {
+		return timeout
+	}
+	return e.RequestTimeout
+}
+
+// Lifetime of a session from its last refresh. Fixed sessions are never
+// renewed, so they last for the absolute maximum.
+func (e *EnvConfig) RefreshSessionTTL() time.Duration {
+	if e.RefreshTokenExpiry == "sliding" {
+		return e.RefreshTokenIdleTTL
+	}
+	return e.RefreshTokenMaxAge
+}
+
+// Reports whether the server terminates TLS itself
+func (e *EnvConfig) TLSEnabled() bool {
+	return e.TLSCertFile != "" && e.TLSKeyFile != ""
+}
+
+// ValidateConfig reads the configuration at boot, before anything connects.
+// Settings missing from the environment are taken from .env, then from the
+// dotenv formatted file at CONFIG_FILE. Every missing or invalid setting is
+// reported at once in a ConfigError.
+func ValidateConfig() (*EnvConfig, error) {
+	dotenv, err := godotenv.Read()
 	if err != nil {
 		return nil, fmt.Errorf(".env file not found")
 	}
 
+	source := Sources(os.Getenv, MapSource(dotenv))
+	if configFile := source("CONFIG_FILE"); configFile != "" {
+		values, err := godotenv.Read(configFile)
+		if err != nil {
+			return nil, fmt.Errorf("Could not read CONFIG_FILE %s: %w", configFile, err)
+		}
+		source = Sources(source, MapSource(values))
+	}
+	return LoadConfig(source)
+}
+
+// ConfigSource looks up a setting by its environment variable name and
+// returns "" when it is not set
+type ConfigSource func(key string) string
+
+func MapSource(values map[string]string) ConfigSource {
+	return func(key string) string {
+		return values[key]
+	}
+}
+
+// Sources layers several sources, the first one to set a key wins
+func Sources(sources ...ConfigSource) ConfigSource {
+	return func(key string) string {
+		for _, source := range sources {
+			if value := source(key); value != "" {
+				return value
+			}
+		}
+		return ""
+	}
+}
+
+// ConfigError lists every missing or invalid setting found at startup
+type ConfigError []error
+
+func (e ConfigError) Error() string {
+	var sb strings.Builder
+	sb.WriteString("Invalid configuration:")
+	for _, err := range e {
+		sb.WriteString("\n  - ")
+		sb.WriteString(err.Error())
+	}
+	return sb.String()
+}
+
+func (e ConfigError) Unwrap() []error {
+	return e
+}
+
+// LoadConfig reads the configuration from source, applying defaults to unset
+// settings. Problems are collected instead of stopping at the first one so
+// that a misconfigured deployment can be fixed in one go.
+func LoadConfig(getenv ConfigSource) (*EnvConfig, error) {
 	cfg := &EnvConfig{}
+	var problems ConfigError
+	var err error
 	validEnvs := []string{"development", "testing", "production"}
 
-	environment := os.Getenv("ENVIRONMENT")
-	port := os.Getenv("PORT")
-	dbUrl := os.Getenv("DATABASE_URL")
-	tokenSecret := os.Getenv("JWT_SECRET")
-	smtpHost := os.Getenv("SMTP_HOST")
-	smtpPort := os.Getenv("SMTP_PORT")
-	gmailUser := os.Getenv("GMAIL_USERNAME")
-	appPwd := os.Getenv("GMAIL_APP_PASSWORD")
+	environment := getenv("ENVIRONMENT")
+	port := getenv("PORT")
+	adminPort := getenv("ADMIN_PORT")
+	tlsCertFile := getenv("TLS_CERT_FILE")
+	tlsKeyFile := getenv("TLS_KEY_FILE")
+	hstsMaxAge := getenv("HSTS_MAX_AGE")
+	hstsIncludeSubdomains := getenv("HSTS_INCLUDE_SUBDOMAINS")
+	dbUrl := getenv("DATABASE_URL")
+	dbReplicaUrl := getenv("DB_REPLICA_URL")
+	tokenSecret := getenv("JWT_SECRET")
+	smtpHost := getenv("SMTP_HOST")
+	smtpPort := getenv("SMTP_PORT")
+	gmailUser := getenv("GMAIL_USERNAME")
+	appPwd := getenv("GMAIL_APP_PASSWORD")
+	mailFromName := getenv("MAIL_FROM_NAME")
+	mailFromAddr := getenv("MAIL_FROM_ADDRESS")
+	mailReplyTo := getenv("MAIL_REPLY_TO")
+	mailWebhookSecret := getenv("MAIL_WEBHOOK_SECRET")
+	appName := getenv("APP_NAME")
+	mailSubjects := getenv("MAIL_SUBJECTS")
+	ghClientId := getenv("GITHUB_CLIENT_ID")
+	ghClientSecret := getenv("GITHUB_CLIENT_SECRET")
+	ghRedirectUrl := getenv("GITHUB_REDIRECT_URL")
+	ghRedirectUrls := getenv("GITHUB_REDIRECT_URLS")
+	ghOrg := getenv("GITHUB_ORG")
+	ghBaseUrl := getenv("GITHUB_BASE_URL")
+	ghApiUrl := getenv("GITHUB_API_URL")
+	ghTokenKey := getenv("GITHUB_TOKEN_KEY")
+	googleClientId := getenv("GOOGLE_CLIENT_ID")
+	googleClientSecret := getenv("GOOGLE_CLIENT_SECRET")
+	googleRedirectUrl := getenv("GOOGLE_REDIRECT_URL")
+	webauthnRPID := getenv("WEBAUTHN_RP_ID")
+	webauthnRPOrigins := getenv("WEBAUTHN_RP_ORIGINS")
+	oauthMode := getenv("OAUTH_RESPONSE_MODE")
+	oauthUnregistered := getenv("OAUTH_UNREGISTERED")
+	frontendUrl := getenv("FRONTEND_URL")
+	refreshCookie := getenv("REFRESH_TOKEN_COOKIE")
+	refreshExpiry := getenv("REFRESH_TOKEN_EXPIRY")
+	refreshMaxAge := getenv("REFRESH_TOKEN_MAX_AGE")
+	refreshIdleTTL := getenv("REFRESH_TOKEN_IDLE_TTL")
+	reqTimeout := getenv("REQUEST_TIMEOUT")
+	reqTimeoutOverrides := getenv("REQUEST_TIMEOUT_OVERRIDES")
+	bountyMaxTx := getenv("BOUNTY_MAX_PER_TRANSACTION")
+	bountyMaxTotal := getenv("BOUNTY_MAX_TOTAL")
+	bountyReconcileInterval := getenv("BOUNTY_RECONCILE_INTERVAL")
+	bountyReconcileDryRun := getenv("BOUNTY_RECONCILE_DRY_RUN")
+	reqLogging := getenv("REQUEST_LOGGING")
+	rateLimitBackend := getenv("RATE_LIMIT_BACKEND")
+	featureFlags := getenv("FEATURE_FLAGS")
+	staffEmails := getenv("STAFF_NOTIFY_EMAILS")
+	staffWebhook := getenv("STAFF_NOTIFY_WEBHOOK")
+	staffDebounce := getenv("STAFF_NOTIFY_DEBOUNCE")
+	featureFlagsRefresh := getenv("FEATURE_FLAGS_REFRESH")
+	collapseGmailDots := getenv("EMAIL_COLLAPSE_GMAIL_DOTS")
+	otpMode := getenv("OTP_MODE")
+	otpMaxResends := getenv("OTP_MAX_RESENDS")
+	otpResendCooldown := getenv("OTP_RESEND_COOLDOWN")
+	otpMailFormat := getenv("OTP_MAIL_FORMAT")
+	mailPreview := getenv("MAIL_PREVIEW")
+	smsProvider := getenv("SMS_PROVIDER")
+	twilioAccountSid := getenv("TWILIO_ACCOUNT_SID")
+	twilioAuthToken := getenv("TWILIO_AUTH_TOKEN")
+	twilioFromNumber := getenv("TWILIO_FROM_NUMBER")
+	twilioApiUrl := getenv("TWILIO_API_URL")
+	smsCountryCodes := getenv("SMS_COUNTRY_CODES")
+	trustedProxies := getenv("TRUSTED_PROXIES")
+	compressionMinSize := getenv("COMPRESSION_MIN_SIZE")
+	errorFormat := getenv("ERROR_FORMAT")
+	cacheMaxAge := getenv("CACHE_MAX_AGE")
+	mailProbeInterval := getenv("MAIL_PROBE_INTERVAL")
+	emailAllowlist := getenv("EMAIL_DOMAIN_ALLOWLIST")
+	emailDenylist := getenv("EMAIL_DOMAIN_DENYLIST")
+	emailBlockDisposable := getenv("EMAIL_BLOCK_DISPOSABLE")
+	loginAnomaly := getenv("LOGIN_ANOMALY_DETECTION")
+	maintenanceMode := getenv("MAINTENANCE_MODE")
+	fingerprintBinding := getenv("TOKEN_FINGERPRINT_BINDING")
+	clientIds := getenv("CLIENT_IDS")
+	adminClientIds := getenv("ADMIN_CLIENT_IDS")
+	loginAlertMail := getenv("LOGIN_ALERT_MAIL")
+	dbStatementTimeout := getenv("DB_STATEMENT_TIMEOUT")
+	dbAcquireTimeout := getenv("DB_ACQUIRE_TIMEOUT")
+	unavailableRetryAfter := getenv("UNAVAILABLE_RETRY_AFTER")
+	onboardingTTL := getenv("ONBOARDING_TTL")
+	onboardingCleanup := getenv("ONBOARDING_CLEANUP_INTERVAL")
 
 	// Environment
 	environment = strings.ToLower(environment)
 	isValid := slices.Contains(validEnvs, environment)
 	if !isValid {
-		return nil, fmt.Errorf("Invalid ENVIRONMENT value: %s", environment)
+		problems = append(problems, fmt.Errorf("Invalid ENVIRONMENT value: %s", environment))
 	}
 	cfg.Environment = environment
 	// Port
 	if port == "" {
-		return nil, fmt.Errorf("PORT environment variable is missing.")
+		problems = append(problems, fmt.Errorf("PORT environment variable is missing."))
+	} else if cfg.Port, err = strconv.Atoi(port); err != nil {
+		problems = append(problems, fmt.Errorf("Invalid PORT value: %w", err))
 	}
-	portNum, err := strconv.Atoi(port)
-	if err != nil {
-		return nil, fmt.Errorf("Invalid PORT value: %w", err)
+	// Admin port (defaults to serving admin routes on PORT)
+	if adminPort != "" {
+		cfg.AdminPort, err = strconv.Atoi(adminPort)
+		if err != nil || cfg.AdminPort <= 0 || cfg.AdminPort == cfg.Port {
+			problems = append(problems, fmt.Errorf("Invalid ADMIN_PORT value: %s", adminPort))
+		}
+	}
+	// TLS (defaults to plain HTTP, HSTS for a year once enabled)
+	if (tlsCertFile == "") != (tlsKeyFile == "") {
+		problems = append(problems, fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together."))
+	}
+	cfg.TLSCertFile = tlsCertFile
+	cfg.TLSKeyFile = tlsKeyFile
+	cfg.HSTSMaxAge = 365 * 24 * time.Hour
+	if hstsMaxAge != "" {
+		cfg.HSTSMaxAge, err = time.ParseDuration(hstsMaxAge)
+		if err != nil || cfg.HSTSMaxAge < 0 {
+			problems = append(problems, fmt.Errorf("Invalid HSTS_MAX_AGE value: %s", hstsMaxAge))
+		}
+	}
+	// Subdomains may still be served over plain HTTP (defaults to false)
+	if hstsIncludeSubdomains != "" {
+		cfg.HSTSIncludeSubdomains, err = strconv.ParseBool(hstsIncludeSubdomains)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("Invalid HSTS_INCLUDE_SUBDOMAINS value: %s", hstsIncludeSubdomains))
+		}
 	}
-	cfg.Port = portNum
 	// Database URL
 	if dbUrl == "" {
-		return nil, fmt.Errorf("DATABASE_URL environment variable is missing.")
+		problems = append(problems, fmt.Errorf("DATABASE_URL environment variable is missing."))
 	}
 	cfg.DBUrl = dbUrl
+	// Read replica (optional)
+	cfg.DBReplicaUrl = dbReplicaUrl
 	// Token secret
 	if tokenSecret == "" {
-		return nil, fmt.Errorf("TOKEN_SECRET environment variable is missing.")
+		problems = append(problems, fmt.Errorf("JWT_SECRET environment variable is missing."))
 	}
 	cfg.TokenSecret = tokenSecret
 	// SMTP host and port
 	if smtpHost == "" {
-		return nil, fmt.Errorf("GMAIL_USERNAME environment variable is missing.")
+		problems = append(problems, fmt.Errorf("SMTP_HOST environment variable is missing."))
 	}
 	cfg.SmtpHost = smtpHost
 	if smtpPort == "" {
-		return nil, fmt.Errorf("SMTP_PORT environment variable is missing.")
-	}
-	cfg.SmtpPort, err = strconv.Atoi(smtpPort)
-	if err != nil {
-		return nil, err
+		problems = append(problems, fmt.Errorf("SMTP_PORT environment variable is missing."))
+	} else if cfg.SmtpPort, err = strconv.Atoi(smtpPort); err != nil {
+		problems = append(problems, fmt.Errorf("Invalid SMTP_PORT value: %w", err))
 	}
 	// Gmail user
 	if gmailUser == "" {
-		return nil, fmt.Errorf("GMAIL_USERNAME environment variable is missing.")
+		problems = append(problems, fmt.Errorf("GMAIL_USERNAME environment variable is missing."))
 	}
 	cfg.GmailUser = gmailUser
 	// App password
 	if appPwd == "" {
-		return nil, fmt.Errorf("GMAIL_APP_PASSWORD environment variable is missing.")
+		problems = append(problems, fmt.Errorf("GMAIL_APP_PASSWORD environment variable is missing."))
 	}
 	cfg.AppPassword = appPwd
+	// Mail sender identity (defaults to the Gmail user). The address may be
+	// given as "Name <addr>", in which case the name is used unless
+	// MAIL_FROM_NAME is set.
+	if mailFromAddr == "" {
+		mailFromAddr = gmailUser
+	}
+	// A missing GMAIL_USERNAME is already reported above
+	if mailFromAddr != "" {
+		if from, err := mail.ParseAddress(mailFromAddr); err != nil {
+			problems = append(problems, fmt.Errorf("Invalid MAIL_FROM_ADDRESS value: %w", err))
+		} else {
+			mailFromAddr = from.Address
+			if mailFromName == "" {
+				mailFromName = from.Name
+			}
+		}
+	}
+	cfg.MailFromAddr = mailFromAddr
+	if mailFromName == "" {
+		mailFromName = "ACM Season of Code"
+	}
+	cfg.MailFromName = mailFromName
+	if mailReplyTo != "" {
+		if _, err := mail.ParseAddress(mailReplyTo); err != nil {
+			problems = append(problems, fmt.Errorf("Invalid MAIL_REPLY_TO value: %w", err))
+		}
+	}
+	cfg.MailReplyTo = mailReplyTo
+	cfg.MailWebhookSecret = mailWebhookSecret
+	// Mail subjects (defaults to the built-in template of each mail type)
+	if appName == "" {
+		appName = "Season of Code"
+	}
+	cfg.AppName = appName
+	// Overrides are of the form "otp=Your {{.AppName}} code;login_alert=..."
+	cfg.MailSubjects = map[string]string{}
+	for _, pair := range strings.Split(mailSubjects, ";") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		kind, subject, found := strings.Cut(pair, "=")
+		subject = strings.TrimSpace(subject)
+		if !found || subject == "" {
+			problems = append(problems, fmt.Errorf("Invalid MAIL_SUBJECTS entry: %s", pair))
+		}
+		cfg.MailSubjects[strings.TrimSpace(kind)] = subject
+	}
+	// GitHub OAuth application
+	if ghClientId == "" {
+		problems = append(problems, fmt.Errorf("GITHUB_CLIENT_ID environment variable is missing."))
+	}
+	cfg.GhClientId = ghClientId
+	if ghClientSecret == "" {
+		problems = append(problems, fmt.Errorf("GITHUB_CLIENT_SECRET environment variable is missing."))
+	}
+	cfg.GhClientSecret = ghClientSecret
+	if ghRedirectUrl == "" {
+		problems = append(problems, fmt.Errorf("GITHUB_REDIRECT_URL environment variable is missing."))
+	}
+	cfg.GhRedirectUrl = ghRedirectUrl
+	// Further redirect URIs clients may pick, e.g. for staging (defaults to none)
+	cfg.GhRedirectUrls = []string{ghRedirectUrl}
+	for _, redirect := range strings.Split(ghRedirectUrls, ",") {
+		redirect = strings.TrimSpace(redirect)
+		if redirect == "" || slices.Contains(cfg.GhRedirectUrls, redirect) {
+			continue
+		}
+		u, err := url.Parse(redirect)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, fmt.Errorf("Invalid GITHUB_REDIRECT_URLS entry: %s", redirect))
+			continue
+		}
+		cfg.GhRedirectUrls = append(cfg.GhRedirectUrls, redirect)
+	}
+	// GitHub organization (optional)
+	cfg.GhOrg = strings.TrimSpace(ghOrg)
+	// GitHub URLs (defaults to public GitHub)
+	if ghBaseUrl == "" {
+		ghBaseUrl = "https://github.com"
+	}
+	cfg.GhBaseUrl = strings.TrimSuffix(ghBaseUrl, "/")
+	if ghApiUrl == "" {
+		ghApiUrl = "https://api.github.com"
+	}
+	cfg.GhApiUrl = strings.TrimSuffix(ghApiUrl, "/")
+	// GitHub token encryption key (optional, base64 of 32 bytes for AES-256)
+	if ghTokenKey != "" {
+		key, err := base64.StdEncoding.DecodeString(ghTokenKey)
+		if err != nil || len(key) != 32 {
+			problems = append(problems, fmt.Errorf(
+				"Invalid GITHUB_TOKEN_KEY value: must be 32 bytes encoded in base64"))
+		}
+		cfg.GhTokenKey = key
+	}
+	// Google OAuth application (optional, all or nothing)
+	if googleClientId != "" || googleClientSecret != "" || googleRedirectUrl != "" {
+		if googleClientId == "" || googleClientSecret == "" || googleRedirectUrl == "" {
+			problems = append(problems, fmt.Errorf(
+				"GOOGLE_CLIENT_ID, GOOGLE_CLIENT_SECRET and GOOGLE_REDIRECT_URL must be set together."))
+		}
+	}
+	cfg.GoogleClientId = googleClientId
+	cfg.GoogleClientSecret = googleClientSecret
+	cfg.GoogleRedirectUrl = googleRedirectUrl
+	// Passkeys (optional, the relying party and its origins are set together)
+	for _, origin := range strings.Split(webauthnRPOrigins, ",") {
+		origin = strings.TrimSuffix(strings.TrimSpace(origin), "/")
+		if origin == "" {
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, fmt.Errorf("Invalid WEBAUTHN_RP_ORIGINS entry: %s", origin))
+			continue
+		}
+		cfg.WebAuthnRPOrigins = append(cfg.WebAuthnRPOrigins, origin)
+	}
+	if (webauthnRPID == "") != (len(cfg.WebAuthnRPOrigins) == 0) {
+		problems = append(problems, fmt.Errorf(
+			"WEBAUTHN_RP_ID and WEBAUTHN_RP_ORIGINS must be set together."))
+	}
+	cfg.WebAuthnRPID = webauthnRPID
+	// OAuth response mode (defaults to JSON for API clients)
+	oauthMode = strings.ToLower(oauthMode)
+	if oauthMode == "" {
+		oauthMode = "json"
+	}
+	if !slices.Contains([]string{"json", "redirect"}, oauthMode) {
+		problems = append(problems, fmt.Errorf("Invalid OAUTH_RESPONSE_MODE value: %s", oauthMode))
+	}
+	cfg.OAuthResponseMode = oauthMode
+	// Unregistered OAuth users (defaults to reject)
+	switch strings.ToLower(oauthUnregistered) {
+	case "", "reject":
+	case "register":
+		cfg.OAuthAutoRegister = true
+	default:
+		problems = append(problems, fmt.Errorf("Invalid OAUTH_UNREGISTERED value: %s", oauthUnregistered))
+	}
+	// Frontend URL is mandatory only when redirecting after OAuth
+	if oauthMode == "redirect" && frontendUrl == "" {
+		problems = append(problems, fmt.Errorf("FRONTEND_URL environment variable is missing."))
+	}
+	cfg.FrontendUrl = frontendUrl
+	// Refresh token as HttpOnly cookie (defaults to false)
+	if refreshCookie != "" {
+		cfg.RefreshTokenCookie, err = strconv.ParseBool(refreshCookie)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("Invalid REFRESH_TOKEN_COOKIE value: %w", err))
+		}
+	}
+	// Refresh token expiry (defaults to a fixed lifetime of 90 days)
+	switch refreshExpiry {
+	case "", "fixed":
+		cfg.RefreshTokenExpiry = "fixed"
+	case "sliding":
+		cfg.RefreshTokenExpiry = "sliding"
+	default:
+		problems = append(problems, fmt.Errorf("Invalid REFRESH_TOKEN_EXPIRY value: %s", refreshExpiry))
+	}
+	cfg.RefreshTokenMaxAge = 90 * 24 * time.Hour
+	if refreshMaxAge != "" {
+		cfg.RefreshTokenMaxAge, err = time.ParseDuration(refreshMaxAge)
+		if err != nil || cfg.RefreshTokenMaxAge <= 0 {
+			problems = append(problems, fmt.Errorf("Invalid REFRESH_TOKEN_MAX_AGE value: %s", refreshMaxAge))
+		}
+	}
+	// Idle timeout of sliding sessions (defaults to 14 days)
+	cfg.RefreshTokenIdleTTL = 14 * 24 * time.Hour
+	if refreshIdleTTL != "" {
+		cfg.RefreshTokenIdleTTL, err = time.ParseDuration(refreshIdleTTL)
+		if err != nil || cfg.RefreshTokenIdleTTL <= 0 {
+			problems = append(problems, fmt.Errorf("Invalid REFRESH_TOKEN_IDLE_TTL value: %s", refreshIdleTTL))
+		}
+	}
+	cfg.RefreshTokenIdleTTL = min(cfg.RefreshTokenIdleTTL, cfg.RefreshTokenMaxAge)
+	// Request timeouts (defaults to 10s)
+	cfg.RequestTimeout = 10 * time.Second
+	if reqTimeout != "" {
+		cfg.RequestTimeout, err = time.ParseDuration(reqTimeout)
+		if err != nil || cfg.RequestTimeout <= 0 {
+			problems = append(problems, fmt.Errorf("Invalid REQUEST_TIMEOUT value: %s", reqTimeout))
+		}
+	}
+	// Overrides are of the form "oauth=20s,register=30s"
+	cfg.RequestTimeouts = map[string]time.Duration{}
+	for _, pair := range strings.Split(reqTimeoutOverrides, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		route, value, found := strings.Cut(pair, "=")
+		route = strings.TrimSpace(route)
+		timeout, err := time.ParseDuration(strings.TrimSpace(value))
+		if !found || err != nil || timeout <= 0 {
+			problems = append(problems, fmt.Errorf("Invalid REQUEST_TIMEOUT_OVERRIDES entry: %s", pair))
+		} else if !slices.Contains(timeoutRoutes, route) {
+			// A typo would otherwise leave the route on the default timeout
+			problems = append(problems, fmt.Errorf("Unknown REQUEST_TIMEOUT_OVERRIDES route: %s", route))
+		}
+		cfg.RequestTimeouts[route] = timeout
+	}
+	// Bounty limits (defaults to 1000 per transaction and 1,000,000 total)
+	cfg.BountyMaxPerTransaction = 1000
+	if bountyMaxTx != "" {
+		limit, err := strconv.ParseInt(bountyMaxTx, 10, 32)
+		if err != nil || limit <= 0 {
+			problems = append(problems, fmt.Errorf("Invalid BOUNTY_MAX_PER_TRANSACTION value: %s", bountyMaxTx))
+		}
+		cfg.BountyMaxPerTransaction = int32(limit)
+	}
+	cfg.BountyMaxTotal = 1_000_000
+	if bountyMaxTotal != "" {
+		limit, err := strconv.ParseInt(bountyMaxTotal, 10, 32)
+		if err != nil || limit <= 0 {
+			problems = append(problems, fmt.Errorf("Invalid BOUNTY_MAX_TOTAL value: %s", bountyMaxTotal))
+		}
+		cfg.BountyMaxTotal = int32(limit)
+	}
+	// Bounty reconciliation (defaults to daily, dry run)
+	cfg.BountyReconcileInterval = 24 * time.Hour
+	if bountyReconcileInterval != "" {
+		cfg.BountyReconcileInterval, err = time.ParseDuration(bountyReconcileInterval)
+		if err != nil || cfg.BountyReconcileInterval < 0 {
+			problems = append(problems, fmt.Errorf("Invalid BOUNTY_RECONCILE_INTERVAL value: %s", bountyReconcileInterval))
+		}
+	}
+	cfg.BountyReconcileDryRun = true
+	if bountyReconcileDryRun != "" {
+		cfg.BountyReconcileDryRun, err = strconv.ParseBool(bountyReconcileDryRun)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("Invalid BOUNTY_RECONCILE_DRY_RUN value: %w", err))
+		}
+	}
+	// Request logging (defaults to false)
+	if reqLogging != "" {
+		cfg.RequestLogging, err = strconv.ParseBool(reqLogging)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("Invalid REQUEST_LOGGING value: %w", err))
+		}
+	}
+	// Rate limit backend (defaults to memory)
+	switch strings.ToLower(rateLimitBackend) {
+	case "", "memory":
+		cfg.RateLimitBackend = "memory"
+	case "postgres":
+		cfg.RateLimitBackend = "postgres"
+	default:
+		problems = append(problems, fmt.Errorf("Invalid RATE_LIMIT_BACKEND value: %s", rateLimitBackend))
+	}
+	// Staff notifications (defaults to off, debounced for 15m when on)
+	for _, addr := range strings.Split(staffEmails, ",") {
+		addr = strings.TrimSpace(addr)
+		if addr == "" {
+			continue
+		}
+		if _, err := mail.ParseAddress(addr); err != nil {
+			problems = append(problems, fmt.Errorf("Invalid STAFF_NOTIFY_EMAILS entry: %s", addr))
+			continue
+		}
+		cfg.StaffNotifyEmails = append(cfg.StaffNotifyEmails, addr)
+	}
+	if staffWebhook != "" {
+		u, err := url.Parse(staffWebhook)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, fmt.Errorf("Invalid STAFF_NOTIFY_WEBHOOK value: %s", staffWebhook))
+		}
+	}
+	cfg.StaffNotifyWebhook = staffWebhook
+	cfg.StaffNotifyDebounce = 15 * time.Minute
+	if staffDebounce != "" {
+		cfg.StaffNotifyDebounce, err = time.ParseDuration(staffDebounce)
+		if err != nil || cfg.StaffNotifyDebounce < 0 {
+			problems = append(problems, fmt.Errorf("Invalid STAFF_NOTIFY_DEBOUNCE value: %s", staffDebounce))
+		}
+	}
+	// Feature flags are of the form "bounty_batch=false,export=true"
+	cfg.FeatureFlags = map[string]bool{}
+	for _, pair := range strings.Split(featureFlags, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, value, found := strings.Cut(pair, "=")
+		enabled, err := strconv.ParseBool(strings.TrimSpace(value))
+		if !found || err != nil {
+			problems = append(problems, fmt.Errorf("Invalid FEATURE_FLAGS entry: %s", pair))
+		}
+		cfg.FeatureFlags[strings.TrimSpace(name)] = enabled
+	}
+	// Feature flag reload from the database (defaults to 30s)
+	cfg.FeatureFlagsRefresh = 30 * time.Second
+	if featureFlagsRefresh != "" {
+		cfg.FeatureFlagsRefresh, err = time.ParseDuration(featureFlagsRefresh)
+		if err != nil || cfg.FeatureFlagsRefresh <= 0 {
+			problems = append(problems, fmt.Errorf("Invalid FEATURE_FLAGS_REFRESH value: %s", featureFlagsRefresh))
+		}
+	}
+	// Mail provider probe (defaults to 1m, 0 disables)
+	cfg.MailProbeInterval = time.Minute
+	if mailProbeInterval != "" {
+		cfg.MailProbeInterval, err = time.ParseDuration(mailProbeInterval)
+		if err != nil || cfg.MailProbeInterval < 0 {
+			problems = append(problems, fmt.Errorf("Invalid MAIL_PROBE_INTERVAL value: %s", mailProbeInterval))
+		}
+	}
+	// Gmail dot collapsing during email normalization (defaults to false)
+	if collapseGmailDots != "" {
+		cfg.CollapseGmailDots, err = strconv.ParseBool(collapseGmailDots)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("Invalid EMAIL_COLLAPSE_GMAIL_DOTS value: %w", err))
+		}
+	}
+	// Registration email domains (defaults to the campus domain, "*" allows any)
+	if emailAllowlist == "" {
+		emailAllowlist = "cb.students.amrita.edu"
+	}
+	if strings.TrimSpace(emailAllowlist) != "*" {
+		cfg.EmailDomainAllowlist, err = parseDomains(emailAllowlist)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("Invalid EMAIL_DOMAIN_ALLOWLIST entry: %w", err))
+		}
+	}
+	cfg.EmailDomainDenylist, err = parseDomains(emailDenylist)
+	if err != nil {
+		problems = append(problems, fmt.Errorf("Invalid EMAIL_DOMAIN_DENYLIST entry: %w", err))
+	}
+	// Disposable email domains are blocked (defaults to true)
+	cfg.EmailBlockDisposable = true
+	if emailBlockDisposable != "" {
+		cfg.EmailBlockDisposable, err = strconv.ParseBool(emailBlockDisposable)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("Invalid EMAIL_BLOCK_DISPOSABLE value: %w", err))
+		}
+	}
+	// Server-side statement timeout (defaults to 30s, 0 disables)
+	cfg.DBStatementTimeout = 30 * time.Second
+	if dbStatementTimeout != "" {
+		cfg.DBStatementTimeout, err = time.ParseDuration(dbStatementTimeout)
+		if err != nil || cfg.DBStatementTimeout < 0 {
+			problems = append(problems, fmt.Errorf("Invalid DB_STATEMENT_TIMEOUT value: %s", dbStatementTimeout))
+		}
+	}
+	// Trusted proxies (defaults to none, i.e. the peer address is the client)
+	for _, proxy := range strings.Split(trustedProxies, ",") {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+		_, _, cidrErr := net.ParseCIDR(proxy)
+		if cidrErr != nil && net.ParseIP(proxy) == nil {
+			problems = append(problems, fmt.Errorf("Invalid TRUSTED_PROXIES entry: %s", proxy))
+		}
+		cfg.TrustedProxies = append(cfg.TrustedProxies, proxy)
+	}
+	// Response compression threshold (defaults to 1KB)
+	cfg.CompressionMinSize = 1024
+	if compressionMinSize != "" {
+		cfg.CompressionMinSize, err = strconv.Atoi(compressionMinSize)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("Invalid COMPRESSION_MIN_SIZE value: %w", err))
+		}
+	}
+	// Error response format (defaults to envelope)
+	switch strings.ToLower(errorFormat) {
+	case "", "envelope":
+		cfg.ErrorFormat = "envelope"
+	case "problem":
+		cfg.ErrorFormat = "problem"
+	default:
+		problems = append(problems, fmt.Errorf("Invalid ERROR_FORMAT value: %s", errorFormat))
+	}
+	// Cache lifetime of cacheable read endpoints (defaults to 60s)
+	cfg.CacheMaxAge = time.Minute
+	if cacheMaxAge != "" {
+		cfg.CacheMaxAge, err = time.ParseDuration(cacheMaxAge)
+		if err != nil || cfg.CacheMaxAge < 0 {
+			problems = append(problems, fmt.Errorf("Invalid CACHE_MAX_AGE value: %s", cacheMaxAge))
+		}
+	}
+	// Refresh token fingerprint binding (defaults to false)
+	if fingerprintBinding != "" {
+		cfg.TokenFingerprintBinding, err = strconv.ParseBool(fingerprintBinding)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("Invalid TOKEN_FINGERPRINT_BINDING value: %w", err))
+		}
+	}
+	// Client applications selectable with client_id at login (defaults to
+	// none, leaving tokens unbound to a client)
+	cfg.ClientIds, err = parseClientIds(clientIds)
+	if err != nil {
+		problems = append(problems, fmt.Errorf("Invalid CLIENT_IDS entry: %w", err))
+	}
+	cfg.AdminClientIds, err = parseClientIds(adminClientIds)
+	if err != nil {
+		problems = append(problems, fmt.Errorf("Invalid ADMIN_CLIENT_IDS entry: %w", err))
+	}
+	for _, clientId := range cfg.AdminClientIds {
+		if !slices.Contains(cfg.ClientIds, clientId) {
+			problems = append(problems, fmt.Errorf("ADMIN_CLIENT_IDS entry %s is missing from CLIENT_IDS", clientId))
+		}
+	}
+	// Maintenance mode (defaults to false)
+	if maintenanceMode != "" {
+		cfg.MaintenanceMode, err = strconv.ParseBool(maintenanceMode)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("Invalid MAINTENANCE_MODE value: %w", err))
+		}
+	}
+	// Login anomaly detection and alert mails (both default to false)
+	if loginAnomaly != "" {
+		cfg.LoginAnomalyDetection, err = strconv.ParseBool(loginAnomaly)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("Invalid LOGIN_ANOMALY_DETECTION value: %w", err))
+		}
+	}
+	if loginAlertMail != "" {
+		cfg.LoginAlertMail, err = strconv.ParseBool(loginAlertMail)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("Invalid LOGIN_ALERT_MAIL value: %w", err))
+		}
+	}
+	// Wait for a pooled connection (defaults to 2s, 0 disables)
+	cfg.DBAcquireTimeout = 2 * time.Second
+	if dbAcquireTimeout != "" {
+		cfg.DBAcquireTimeout, err = time.ParseDuration(dbAcquireTimeout)
+		if err != nil || cfg.DBAcquireTimeout < 0 {
+			problems = append(problems, fmt.Errorf("Invalid DB_ACQUIRE_TIMEOUT value: %s", dbAcquireTimeout))
+		}
+	}
+	// Retry-After of 503s caused by dependency outages (defaults to 5s)
+	cfg.UnavailableRetryAfter = 5 * time.Second
+	if unavailableRetryAfter != "" {
+		cfg.UnavailableRetryAfter, err = time.ParseDuration(unavailableRetryAfter)
+		if err != nil || cfg.UnavailableRetryAfter <= 0 {
+			problems = append(problems, fmt.Errorf("Invalid UNAVAILABLE_RETRY_AFTER value: %s", unavailableRetryAfter))
+		}
+	}
+	// OTP mode (defaults to numeric)
+	switch strings.ToLower(otpMode) {
+	case "", "numeric":
+	case "alphanumeric":
+		cfg.OtpAlphanumeric = true
+	default:
+		problems = append(problems, fmt.Errorf("Invalid OTP_MODE value: %s", otpMode))
+	}
+	// OTP resends per registration (defaults to 5)
+	cfg.OtpMaxResends = 5
+	if otpMaxResends != "" {
+		limit, err := strconv.ParseInt(otpMaxResends, 10, 32)
+		if err != nil || limit < 0 {
+			problems = append(problems, fmt.Errorf("Invalid OTP_MAX_RESENDS value: %s", otpMaxResends))
+		}
+		cfg.OtpMaxResends = int32(limit)
+	}
+	// OTP resend cooldown (defaults to 1m, 0 disables it)
+	cfg.OtpResendCooldown = time.Minute
+	if otpResendCooldown != "" {
+		cfg.OtpResendCooldown, err = time.ParseDuration(otpResendCooldown)
+		if err != nil || cfg.OtpResendCooldown < 0 {
+			problems = append(problems, fmt.Errorf("Invalid OTP_RESEND_COOLDOWN value: %s", otpResendCooldown))
+		}
+	}
+	// OTP mail format (defaults to multipart/alternative)
+	switch strings.ToLower(otpMailFormat) {
+	case "", "multipart":
+		cfg.OtpMailFormat = "multipart"
+	case "text", "html":
+		cfg.OtpMailFormat = strings.ToLower(otpMailFormat)
+	default:
+		problems = append(problems, fmt.Errorf("Invalid OTP_MAIL_FORMAT value: %s", otpMailFormat))
+	}
+	// Mail previews (defaults to true outside production)
+	cfg.MailPreview = cfg.Environment != "production"
+	if mailPreview != "" {
+		cfg.MailPreview, err = strconv.ParseBool(mailPreview)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("Invalid MAIL_PREVIEW value: %w", err))
+		}
+	}
+	// SMS OTP delivery (defaults to disabled)
+	switch strings.ToLower(smsProvider) {
+	case "":
+	case "twilio":
+		cfg.SmsProvider = "twilio"
+		if twilioAccountSid == "" || twilioAuthToken == "" || twilioFromNumber == "" {
+			problems = append(problems, fmt.Errorf("TWILIO_ACCOUNT_SID, TWILIO_AUTH_TOKEN and TWILIO_FROM_NUMBER are required for SMS_PROVIDER=twilio."))
+		}
+		cfg.TwilioAccountSid = twilioAccountSid
+		cfg.TwilioAuthToken = twilioAuthToken
+		cfg.TwilioFromNumber = twilioFromNumber
+	default:
+		problems = append(problems, fmt.Errorf("Invalid SMS_PROVIDER value: %s", smsProvider))
+	}
+	if twilioApiUrl == "" {
+		twilioApiUrl = "https://api.twilio.com/2010-04-01"
+	}
+	if u, err := url.Parse(twilioApiUrl); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		problems = append(problems, fmt.Errorf("Invalid TWILIO_API_URL value: %s", twilioApiUrl))
+	}
+	cfg.TwilioApiUrl = strings.TrimSuffix(twilioApiUrl, "/")
+	// Calling codes are of the form "+91,+1"
+	for _, code := range strings.Split(smsCountryCodes, ",") {
+		code = strings.TrimSpace(code)
+		if code == "" {
+			continue
+		}
+		if !smsCountryCodePattern.MatchString(code) {
+			problems = append(problems, fmt.Errorf("Invalid SMS_COUNTRY_CODES entry: %s", code))
+			continue
+		}
+		cfg.SmsCountryCodes = append(cfg.SmsCountryCodes, code)
+	}
+	// Abandoned onboarding cleanup (defaults to purging after 24h, hourly)
+	cfg.OnboardingTTL = 24 * time.Hour
+	if onboardingTTL != "" {
+		cfg.OnboardingTTL, err = time.ParseDuration(onboardingTTL)
+		if err != nil || cfg.OnboardingTTL <= 0 {
+			problems = append(problems, fmt.Errorf("Invalid ONBOARDING_TTL value: %s", onboardingTTL))
+		}
+	}
+	cfg.OnboardingCleanupInterval = time.Hour
+	if onboardingCleanup != "" {
+		cfg.OnboardingCleanupInterval, err = time.ParseDuration(onboardingCleanup)
+		if err != nil || cfg.OnboardingCleanupInterval <= 0 {
+			problems = append(problems, fmt.Errorf("Invalid ONBOARDING_CLEANUP_INTERVAL value: %s", onboardingCleanup))
+		}
+	}
 
+	if len(problems) > 0 {
+		return nil, problems
+	}
 	return cfg, nil
 }
+
+// Parses a comma separated list of domains, lowercased and without any
+// leading "@"
+func parseDomains(list string) ([]string, error) {
+	var domains []string
+	for _, domain := range strings.Split(list, ",") {
+		domain = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "@"))
+		if domain == "" {
+			continue
+		}
+		if strings.ContainsAny(domain, "@/ ") || !strings.Contains(domain, ".") {
+			return nil, fmt.Errorf("%s", domain)
+		}
+		domains = append(domains, domain)
+	}
+	return domains, nil
+}
+
+// Parses a comma separated list of client ids, made of lowercase letters,
+// digits, "-" and "_"
+func parseClientIds(list string) ([]string, error) {
+	var clientIds []string
+	for _, clientId := range strings.Split(list, ",") {
+		clientId = strings.TrimSpace(clientId)
+		if clientId == "" || slices.Contains(clientIds, clientId) {
+			continue
+		}
+		if strings.Trim(clientId, "abcdefghijklmnopqrstuvwxyz0123456789-_") != "" {
+			return nil, fmt.Errorf("%s", clientId)
+		}
+		clientIds = append(clientIds, clientId)
+	}
+	return clientIds, nil
+}
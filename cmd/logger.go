@@ -64,6 +64,8 @@ func (l *LoggerService) Error(msg string, err error) {
 	l.log.WithLevel(zerolog.ErrorLevel).Err(err).Msgf("%s", msg)
 }
 
+// Logs at fatal level without exiting. Request paths must use Error instead,
+// so that a single failed request is never mistaken for a crash.
 func (l *LoggerService) Fatal(msg string, err error) {
 	l.log.WithLevel(zerolog.FatalLevel).Err(err).Msgf("%s", msg)
 }
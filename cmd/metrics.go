@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+var MetricsRegistry *prometheus.Registry
+
+var (
+	OtpSentTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "pulse_otp_sent_total",
+		Help: "Number of OTP mails sent successfully.",
+	})
+	OtpVerifyTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pulse_otp_verify_total",
+		Help: "Number of OTP verification attempts by result.",
+	}, []string{"result"})
+	OtpTimeToVerify = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "pulse_otp_time_to_verify_seconds",
+		Help:    "Time between sending an OTP and its successful verification.",
+		Buckets: []float64{15, 30, 60, 90, 120, 180, 240, 300},
+	})
+	DbAcquireWait = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "pulse_db_acquire_wait_seconds",
+		Help:    "Time spent waiting for a connection from the pool.",
+		Buckets: []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2, 5},
+	})
+	DbAcquireTimeoutTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "pulse_db_acquire_timeouts_total",
+		Help: "Number of connection acquires which timed out or were cancelled.",
+	})
+	MailProviderUp = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "pulse_mail_provider_up",
+		Help: "Whether the last probe of the SMTP server succeeded.",
+	})
+	BreakerTransitionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pulse_breaker_transitions_total",
+		Help: "Number of circuit breaker state changes by breaker and new state.",
+	}, []string{"breaker", "state"})
+)
+
+func InitMetrics() *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		OtpSentTotal,
+		OtpVerifyTotal,
+		OtpTimeToVerify,
+		DbAcquireWait,
+		DbAcquireTimeoutTotal,
+		MailProviderUp,
+		BreakerTransitionsTotal,
+	)
+	return registry
+}
@@ -1,20 +1,54 @@
 package cmd
 
 import (
+	"net/http"
+	"time"
+
 	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/github"
 )
 
 var GithubOAuthConfig *oauth2.Config
 
+// Nil unless a Google OAuth application is configured
+var GoogleOAuthConfig *oauth2.Config
+
+// HTTP client used for all calls to GitHub
+var GithubHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
 func OAuthInit() {
+	scopes := []string{"user:email", "read:user"}
+	if EnvVars.GhOrg != "" {
+		// Required to see private organization memberships
+		scopes = append(scopes, "read:org")
+	}
+
 	cfg := &oauth2.Config{
 		ClientID:     EnvVars.GhClientId,
 		ClientSecret: EnvVars.GhClientSecret,
 		RedirectURL:  EnvVars.GhRedirectUrl,
-		Scopes:       []string{"user:email", "read:user"},
-		Endpoint:     github.Endpoint,
+		Scopes:       scopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:       EnvVars.GhBaseUrl + "/login/oauth/authorize",
+			TokenURL:      EnvVars.GhBaseUrl + "/login/oauth/access_token",
+			DeviceAuthURL: EnvVars.GhBaseUrl + "/login/device/code",
+		},
 	}
 
 	GithubOAuthConfig = cfg
 }
+
+func GoogleOAuthInit() {
+	if EnvVars.GoogleClientId == "" {
+		return
+	}
+	GoogleOAuthConfig = &oauth2.Config{
+		ClientID:     EnvVars.GoogleClientId,
+		ClientSecret: EnvVars.GoogleClientSecret,
+		RedirectURL:  EnvVars.GoogleRedirectUrl,
+		Scopes:       []string{"openid", "email", "profile"},
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  "https://accounts.google.com/o/oauth2/v2/auth",
+			TokenURL: "https://oauth2.googleapis.com/token",
+		},
+	}
+}
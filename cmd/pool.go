@@ -2,14 +2,19 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 var DBPool *pgxpool.Pool
 
+// Read-only queries are sent here, nil unless DB_REPLICA_URL is set
+var DBReplicaPool *pgxpool.Pool
+
 const (
 	defaultMaxConns          = int32(100)
 	defaultMinConns          = int32(5)
@@ -20,9 +25,20 @@ const (
 )
 
 func InitDB() (*pgxpool.Pool, error) {
+	return newPool(EnvVars.DBUrl, false)
+}
 
-	connStr := EnvVars.DBUrl
+// Returns nil when no replica is configured, leaving reads on the primary
+func InitReplicaDB() (*pgxpool.Pool, error) {
+	if EnvVars.DBReplicaUrl == "" {
+		return nil, nil
+	}
+	return newPool(EnvVars.DBReplicaUrl, true)
+}
 
+// Connections of a read-only pool refuse writes, so that a query routed to the
+// replica by mistake fails loudly instead of being attempted on a standby
+func newPool(connStr string, readOnly bool) (*pgxpool.Pool, error) {
 	dbConfig, err := pgxpool.ParseConfig(connStr)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to parse connection string: %w", err)
@@ -34,6 +50,18 @@ func InitDB() (*pgxpool.Pool, error) {
 	dbConfig.MaxConnIdleTime = defaultMaxConnIdleTime
 	dbConfig.HealthCheckPeriod = defaultHealthCheckPeriod
 	dbConfig.ConnConfig.ConnectTimeout = defaultConnectTimeout
+	dbConfig.ConnConfig.Tracer = &acquireTracer{timeout: EnvVars.DBAcquireTimeout}
+
+	if settings := connSettings(EnvVars.DBStatementTimeout, readOnly); len(settings) > 0 {
+		dbConfig.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+			for _, setting := range settings {
+				if _, err := conn.Exec(ctx, setting); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
 
 	pool, err := pgxpool.NewWithConfig(context.Background(), dbConfig)
 	if err != nil {
@@ -54,3 +82,64 @@ func InitDB() (*pgxpool.Pool, error) {
 	}
 	return pool, nil
 }
+
+// Statements run on every new connection of a pool. Runaway queries are
+// killed by Postgres even if the caller's context never expires.
+func connSettings(statementTimeout time.Duration, readOnly bool) []string {
+	var settings []string
+	if statementTimeout > 0 {
+		settings = append(settings,
+			fmt.Sprintf("SET statement_timeout = %d", statementTimeout.Milliseconds()))
+	}
+	if readOnly {
+		settings = append(settings, "SET default_transaction_read_only = on")
+	}
+	return settings
+}
+
+type acquireStartKey struct{}
+
+type acquireStart struct {
+	at     time.Time
+	cancel context.CancelFunc
+}
+
+// acquireTracer bounds the time spent waiting for a free connection, so that
+// requests fail fast under load instead of queueing for their whole timeout,
+// and records the wait in metrics
+type acquireTracer struct {
+	timeout time.Duration
+}
+
+func (t *acquireTracer) TraceAcquireStart(ctx context.Context, _ *pgxpool.Pool,
+	_ pgxpool.TraceAcquireStartData) context.Context {
+	start := &acquireStart{at: time.Now()}
+	if t.timeout > 0 {
+		// Only used for the acquire itself, the caller's context is untouched
+		ctx, start.cancel = context.WithTimeout(ctx, t.timeout)
+	}
+	return context.WithValue(ctx, acquireStartKey{}, start)
+}
+
+func (t *acquireTracer) TraceAcquireEnd(ctx context.Context, _ *pgxpool.Pool,
+	data pgxpool.TraceAcquireEndData) {
+	start, ok := ctx.Value(acquireStartKey{}).(*acquireStart)
+	if !ok {
+		return
+	}
+	if start.cancel != nil {
+		defer start.cancel()
+	}
+	DbAcquireWait.Observe(time.Since(start.at).Seconds())
+	if errors.Is(data.Err, context.DeadlineExceeded) || errors.Is(data.Err, context.Canceled) {
+		DbAcquireTimeoutTotal.Inc()
+	}
+}
+
+// Required for the tracer to be accepted as ConnConfig.Tracer
+func (t *acquireTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn,
+	_ pgx.TraceQueryStartData) context.Context {
+	return ctx
+}
+
+func (t *acquireTracer) TraceQueryEnd(context.Context, *pgx.Conn, pgx.TraceQueryEndData) {}
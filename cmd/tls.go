@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// Serves the certificate at TLS_CERT_FILE, which can be swapped on renewal
+// without restarting the server
+type CertReloader struct {
+	certFile string
+	keyFile  string
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+}
+
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Loads the key pair from disk again. The previous certificate stays in use
+// when the new one cannot be loaded.
+func (r *CertReloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.cert = &cert
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// Reloads the certificate on every SIGHUP until ctx is cancelled
+func (r *CertReloader) WatchSignals(ctx context.Context, log Logger) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			if err := r.Reload(); err != nil {
+				log.Error("[TLS]: Failed to reload certificate, keeping the previous one", err)
+				continue
+			}
+			log.Info("[TLS]: Certificate reloaded successfully")
+		}
+	}
+}
+
+func NewTLSConfig(reloader *CertReloader) *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+}
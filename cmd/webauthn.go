@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+// Nil unless passkeys are configured
+var WebAuthnConfig *webauthn.WebAuthn
+
+func WebAuthnInit() error {
+	if EnvVars.WebAuthnRPID == "" {
+		return nil
+	}
+	cfg, err := webauthn.New(&webauthn.Config{
+		RPID:          EnvVars.WebAuthnRPID,
+		RPDisplayName: EnvVars.AppName,
+		RPOrigins:     EnvVars.WebAuthnRPOrigins,
+	})
+	if err != nil {
+		return err
+	}
+	WebAuthnConfig = cfg
+	return nil
+}
@@ -2,20 +2,26 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
-	"time"
+	"strings"
 
-	"github.com/IAmRiteshKoushik/pulse/cmd"
-	db "github.com/IAmRiteshKoushik/pulse/db/gen"
 	"github.com/IAmRiteshKoushik/pulse/pkg"
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
 )
 
-func FetchUserAccount(c *gin.Context) {
+// Frontends send users with an incomplete profile to a profile completion
+// screen. Both fields are synced from GitHub on login.
+func profileComplete(fullName string, avatarUrl string) bool {
+	return strings.TrimSpace(fullName) != "" && strings.TrimSpace(avatarUrl) != ""
+}
+
+func (a *App) FetchUserAccount(c *gin.Context) {
 	username, ok := pkg.GrabUsername(c)
 	if ok != true {
-		cmd.Log.Warn(
+		a.Log.Warn(
 			fmt.Sprintf(
 				"Username did not set in Gin-Context post Authentication at %s %s",
 				c.Request.Method,
@@ -28,17 +34,20 @@ func FetchUserAccount(c *gin.Context) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), a.Env.TimeoutFor("profile"))
 	defer cancel()
 
-	conn, err := cmd.DBPool.Acquire(ctx)
+	// Read from the primary, as users expect to see their own changes (a
+	// profile sync, a new bounty) right away, which a lagging replica may not
+	// have yet
+	conn, err := a.DB.Acquire(ctx)
 	if err != nil {
-		pkg.DbError(c, err)
+		pkg.DbAcquireError(c, err)
 		return
 	}
 	defer conn.Release()
 
-	q := db.New()
+	q := a.Queries
 	userProfile, err := q.FetchProfileQuery(ctx, conn, username)
 	if err != nil {
 		pkg.DbError(c, err)
@@ -46,7 +55,7 @@ func FetchUserAccount(c *gin.Context) {
 	}
 	if userProfile.Ghusername == "" {
 		// Could not locate profile despite valid token ???
-		cmd.Log.Warn(
+		a.Log.Warn(
 			fmt.Sprintf("Failed to retrive user profile at %s %s",
 				c.Request.Method, c.FullPath()))
 		c.JSON(http.StatusForbidden, gin.H{
@@ -61,12 +70,58 @@ func FetchUserAccount(c *gin.Context) {
 		return
 	}
 
-	cmd.Log.Info(
+	a.Log.Info(
 		fmt.Sprintf("Successfully retrived user profile at %s %s", c.Request.Method, c.FullPath()))
+	c.JSON(http.StatusOK, gin.H{
+		"message":          "User profile retrived successfully",
+		"profile":          userProfile,
+		"profile_complete": profileComplete(userProfile.FullName, userProfile.AvatarUrl),
+		"badges":           userBadges,
+	})
+	return
+}
+
+// Public profile for leaderboard pages. Only fields safe to publish are
+// selected, so email never leaves the database here.
+func (a *App) FetchPublicProfile(c *gin.Context) {
+	ghUsername := pkg.NormalizeGhUsername(c.Param("ghusername"))
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), a.Env.TimeoutFor("profile"))
+	defer cancel()
+
+	conn, err := a.Replica.Acquire(ctx)
+	if err != nil {
+		pkg.DbAcquireError(c, err)
+		return
+	}
+	defer conn.Release()
+
+	q := a.Queries
+	profile, err := q.FetchPublicProfileQuery(ctx, conn, ghUsername)
+	// Suspended users are indistinguishable from unknown ones
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "User not found",
+		})
+		return
+	}
+	if err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "User profile retrived successfully",
-		"profile": userProfile,
-		"badges":  userBadges,
+		"profile": gin.H{
+			"ghusername":   profile.Ghusername,
+			"display_name": profile.FullName,
+			"avatar_url":   profile.AvatarUrl,
+			"bounty":       profile.Bounty,
+		},
 	})
+	a.Log.Info(fmt.Sprintf(
+		"[SUCCESS]: Processed request at %s %s",
+		c.Request.Method, c.FullPath(),
+	))
 	return
 }
@@ -0,0 +1,388 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IAmRiteshKoushik/pulse/cmd"
+	db "github.com/IAmRiteshKoushik/pulse/db/gen"
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestProfileReadsPool(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		handler  func(a *App) gin.HandlerFunc
+		wantPool string
+	}{
+		{
+			name:     "own profile reads the primary",
+			path:     "/api/v1/profile",
+			handler:  func(a *App) gin.HandlerFunc { return a.FetchUserAccount },
+			wantPool: "primary",
+		},
+		{
+			name:     "public profile reads the replica",
+			path:     "/api/v1/users/octocat",
+			handler:  func(a *App) gin.HandlerFunc { return a.FetchPublicProfile },
+			wantPool: "replica",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var used []string
+			q := &fakeQuerier{
+				fetchProfile: func(conn db.DBTX, ghUsername string) (db.FetchProfileQueryRow, error) {
+					used = append(used, poolOf(conn))
+					return db.FetchProfileQueryRow{Ghusername: ghUsername}, nil
+				},
+				fetchBadges: func(conn db.DBTX, ghUsername string) ([]db.FetchBadgesQueryRow, error) {
+					used = append(used, poolOf(conn))
+					return nil, nil
+				},
+				fetchPublicProfile: func(conn db.DBTX, ghUsername string) (db.FetchPublicProfileQueryRow, error) {
+					used = append(used, poolOf(conn))
+					return db.FetchPublicProfileQueryRow{Ghusername: ghUsername}, nil
+				},
+			}
+			a := newTestApp(q)
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, tt.path, nil)
+			c.Params = gin.Params{{Key: "ghusername", Value: "octocat"}}
+			c.Set("username", "octocat")
+			tt.handler(a)(c)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+			}
+			if len(used) == 0 {
+				t.Fatal("no query was run")
+			}
+			for _, pool := range used {
+				if pool != tt.wantPool {
+					t.Errorf("query ran against %q, want %q", pool, tt.wantPool)
+				}
+			}
+		})
+	}
+}
+
+// Records the deadline of the context connections are acquired with
+type deadlinePool struct {
+	fakePool
+	deadline *time.Time
+}
+
+func (p deadlinePool) Acquire(ctx context.Context) (pkg.Conn, error) {
+	*p.deadline, _ = ctx.Deadline()
+	return p.fakePool.Acquire(ctx)
+}
+
+func TestProfileTimeout(t *testing.T) {
+	tests := []struct {
+		name      string
+		overrides map[string]time.Duration
+		want      time.Duration
+	}{
+		{name: "default", want: 5 * time.Second},
+		{name: "route override", overrides: map[string]time.Duration{"profile": 2 * time.Second}, want: 2 * time.Second},
+		{name: "other route override", overrides: map[string]time.Duration{"oauth": 20 * time.Second}, want: 5 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &fakeQuerier{
+				fetchPublicProfile: func(_ db.DBTX, ghUsername string) (db.FetchPublicProfileQueryRow, error) {
+					return db.FetchPublicProfileQueryRow{Ghusername: ghUsername}, nil
+				},
+			}
+			a := newTestApp(q)
+			a.Env = testEnv()
+			a.Env.RequestTimeouts = tt.overrides
+			var deadline time.Time
+			a.Replica = deadlinePool{fakePool: fakePool{name: "replica"}, deadline: &deadline}
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/users/octocat", nil)
+			c.Params = gin.Params{{Key: "ghusername", Value: "octocat"}}
+			start := time.Now()
+			a.FetchPublicProfile(c)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+			}
+			if deadline.IsZero() {
+				t.Fatal("connection acquired without a deadline")
+			}
+			if got := deadline.Sub(start); got < tt.want || got > tt.want+time.Second {
+				t.Errorf("deadline in %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// The Querier is mocked, so every outcome of a query can be played back
+// without Postgres
+var _ db.Querier = (*fakeQuerier)(nil)
+
+func TestFetchUserAccountQueryOutcomes(t *testing.T) {
+	tests := []struct {
+		name       string
+		profile    db.FetchProfileQueryRow
+		err        error
+		wantStatus int
+	}{
+		{name: "found", profile: db.FetchProfileQueryRow{Ghusername: "octocat"}, wantStatus: http.StatusOK},
+		{name: "missing profile", wantStatus: http.StatusForbidden},
+		{name: "query failed", err: errors.New("connection reset"), wantStatus: http.StatusInternalServerError},
+		{name: "query timed out", err: context.DeadlineExceeded, wantStatus: http.StatusRequestTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &fakeQuerier{
+				fetchProfile: func(_ db.DBTX, ghUsername string) (db.FetchProfileQueryRow, error) {
+					if ghUsername != "octocat" {
+						t.Errorf("profile fetched for %q", ghUsername)
+					}
+					return tt.profile, tt.err
+				},
+				fetchBadges: func(db.DBTX, string) ([]db.FetchBadgesQueryRow, error) {
+					return []db.FetchBadgesQueryRow{{}}, nil
+				},
+			}
+			a := newTestApp(q)
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/profile", nil)
+			c.Set("username", "octocat")
+			a.FetchUserAccount(c)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestFetchPublicProfile(t *testing.T) {
+	type account struct {
+		email  string
+		status bool // false once suspended
+	}
+	accounts := map[string]account{
+		"octocat": {email: "octocat@example.com", status: true},
+		"hubot":   {email: "hubot@example.com", status: false},
+	}
+
+	tests := []struct {
+		name       string
+		ghusername string
+		wantStatus int
+	}{
+		{name: "active user", ghusername: "octocat", wantStatus: http.StatusOK},
+		{name: "mixed case username", ghusername: "OctoCat", wantStatus: http.StatusOK},
+		{name: "suspended user", ghusername: "hubot", wantStatus: http.StatusNotFound},
+		{name: "unknown user", ghusername: "mona", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &fakeQuerier{
+				// Mirrors the query, which only selects active accounts
+				fetchPublicProfile: func(_ db.DBTX, ghUsername string) (db.FetchPublicProfileQueryRow, error) {
+					user, ok := accounts[ghUsername]
+					if !ok || !user.status {
+						return db.FetchPublicProfileQueryRow{}, pgx.ErrNoRows
+					}
+					return db.FetchPublicProfileQueryRow{
+						Ghusername: ghUsername,
+						FullName:   "The Octocat",
+						AvatarUrl:  "https://avatars.githubusercontent.com/u/583231",
+						Bounty:     120,
+					}, nil
+				},
+			}
+			a := newTestApp(q)
+			a.Env = testEnv()
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/users/"+tt.ghusername, nil)
+			c.Params = gin.Params{{Key: "ghusername", Value: tt.ghusername}}
+			a.FetchPublicProfile(c)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if strings.Contains(w.Body.String(), "@example.com") {
+				t.Errorf("body leaks an email: %s", w.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var body struct {
+				Profile map[string]any `json:"profile"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := body.Profile["email"]; ok {
+				t.Errorf("profile has an email field: %v", body.Profile)
+			}
+			want := map[string]any{
+				"ghusername":   "octocat",
+				"display_name": "The Octocat",
+				"avatar_url":   "https://avatars.githubusercontent.com/u/583231",
+				"bounty":       float64(120),
+			}
+			if len(body.Profile) != len(want) {
+				t.Errorf("profile has fields %v, want %v", body.Profile, want)
+			}
+			for field, value := range want {
+				if body.Profile[field] != value {
+					t.Errorf("%s = %v, want %v", field, body.Profile[field], value)
+				}
+			}
+		})
+	}
+}
+
+func TestPoolExhaustion(t *testing.T) {
+	saved := cmd.EnvVars
+	cmd.EnvVars = testEnv()
+	cmd.EnvVars.DBUrl = fakePostgres(t)
+	cmd.EnvVars.DBAcquireTimeout = 50 * time.Millisecond
+	cmd.EnvVars.UnavailableRetryAfter = 5 * time.Second
+	t.Cleanup(func() { cmd.EnvVars = saved })
+
+	pool, err := cmd.InitDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	// Every connection is held, as by requests running long queries
+	for range pool.Config().MaxConns {
+		conn, err := pool.Acquire(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Release()
+	}
+
+	a := newTestApp(&fakeQuerier{})
+	a.DB = pkg.WrapPool(pool)
+	timeouts := testutil.ToFloat64(cmd.DbAcquireTimeoutTotal)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/profile", nil)
+	c.Set("username", "octocat")
+	start := time.Now()
+	a.FetchUserAccount(c)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusServiceUnavailable, w.Body.String())
+	}
+	if got := w.Header().Get("Retry-After"); got != "5" {
+		t.Errorf("Retry-After = %q, want %q", got, "5")
+	}
+	// Gives up after the acquire timeout rather than the request timeout
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("request failed after %s", elapsed)
+	}
+	if got := testutil.ToFloat64(cmd.DbAcquireTimeoutTotal); got != timeouts+1 {
+		t.Errorf("acquire timeouts = %v, want %v", got, timeouts+1)
+	}
+}
+
+func TestProfileComplete(t *testing.T) {
+	tests := []struct {
+		name      string
+		fullName  string
+		avatarUrl string
+		want      bool
+	}{
+		{name: "complete", fullName: "The Octocat", avatarUrl: "https://example.com/octocat.png", want: true},
+		{name: "name missing", avatarUrl: "https://example.com/octocat.png"},
+		{name: "name blank", fullName: "  ", avatarUrl: "https://example.com/octocat.png"},
+		{name: "avatar missing", fullName: "The Octocat"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// As a login response
+			mux := http.NewServeMux()
+			mux.Handle("/", fakeGithubMux())
+			mux.HandleFunc("GET /user", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(map[string]any{
+					"id": 583231, "login": "octocat", "email": "octo@example.com",
+					"name": tt.fullName, "avatar_url": tt.avatarUrl,
+				})
+			})
+			github := httptest.NewServer(mux)
+			defer github.Close()
+			a := newTestApp(loginQuerier())
+			a.Env = testEnv()
+			a.Env.OAuthResponseMode = "json"
+
+			w := githubLogin(t, a, github)
+			if w.Code != http.StatusOK {
+				t.Fatalf("login status = %d: %s", w.Code, w.Body.String())
+			}
+			var login struct {
+				ProfileComplete *bool `json:"profile_complete"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &login); err != nil {
+				t.Fatal(err)
+			}
+			if login.ProfileComplete == nil || *login.ProfileComplete != tt.want {
+				t.Errorf("login profile_complete = %v, want %v", login.ProfileComplete, tt.want)
+			}
+
+			// As a profile response, from the synced profile
+			a = newTestApp(&fakeQuerier{
+				fetchProfile: func(db.DBTX, string) (db.FetchProfileQueryRow, error) {
+					return db.FetchProfileQueryRow{Ghusername: "octocat", FullName: tt.fullName, AvatarUrl: tt.avatarUrl}, nil
+				},
+				fetchBadges: func(db.DBTX, string) ([]db.FetchBadgesQueryRow, error) { return nil, nil },
+			})
+			a.Env = testEnv()
+			w = httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/profile", nil)
+			c.Set("username", "octocat")
+			a.FetchUserAccount(c)
+			if w.Code != http.StatusOK {
+				t.Fatalf("profile status = %d: %s", w.Code, w.Body.String())
+			}
+			var profile struct {
+				ProfileComplete *bool `json:"profile_complete"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &profile); err != nil {
+				t.Fatal(err)
+			}
+			if profile.ProfileComplete == nil || *profile.ProfileComplete != tt.want {
+				t.Errorf("profile profile_complete = %v, want %v", profile.ProfileComplete, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,347 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	db "github.com/IAmRiteshKoushik/pulse/db/gen"
+	mw "github.com/IAmRiteshKoushik/pulse/middleware"
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	"github.com/IAmRiteshKoushik/pulse/types"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Onboards a user who started registration but could not receive the OTP,
+// bypassing verification. The acting admin is recorded in the audit log.
+func (a *App) ForceOnboardUser(c *gin.Context) {
+	username, ok := pkg.GrabUsername(c)
+	if !ok {
+		a.Log.Warn(
+			fmt.Sprintf("Failed to extract username from token at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Oops! Something happened. Please try again later.",
+		})
+		return
+	}
+	email := pkg.NormalizeEmail(c.Param("email"), a.Env.CollapseGmailDots)
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), a.Env.TimeoutFor("admin"))
+	defer cancel()
+
+	tx, err := a.DB.Begin(ctx)
+	if err != nil {
+		pkg.DbAcquireError(c, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	q := a.Queries
+	existing, err := q.CheckUserExistByEmailQuery(ctx, tx, email)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		pkg.DbError(c, err)
+		return
+	}
+	pending, err := q.FetchPendingRegistrationByEmailQuery(ctx, tx, email)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		pkg.DbError(c, err)
+		return
+	}
+
+	// Expired OTPs are deliberately overridden, so the state is derived here
+	// rather than through the verification rules
+	state := pkg.StateUnregistered
+	if existing.Ghusername != "" {
+		state = pkg.StateOnboarded
+	} else if pending.Ghusername != "" {
+		state = pkg.StatePendingOtp
+	}
+	_, err = pkg.OnboardingTransition(state, pkg.EventForceOnboard)
+	if errors.Is(err, pkg.ErrAlreadyOnboarded) {
+		a.forceOnboardConflict(c, existing.Ghusername)
+		return
+	}
+	if err != nil {
+		a.Log.Warn(
+			fmt.Sprintf("Force onboard without pending registration at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "No registration found for the given email",
+		})
+		return
+	}
+
+	err = q.MarkRegistrationsOnboardedQuery(ctx, tx, pending.Ghusername)
+	if err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+	onboardGhUsername, err := q.CreateUserAccountQuery(ctx, tx,
+		db.CreateUserAccountQueryParams{
+			Email:             pending.Email,
+			Ghusername:        pending.Ghusername,
+			DisplayGhusername: pending.Ghusername,
+			Timezone:          pending.Timezone,
+			Locale:            pending.Locale,
+		})
+	if pkg.IsUniqueViolation(err) {
+		a.forceOnboardConflict(c, pending.Ghusername)
+		return
+	}
+	if err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+
+	err = q.AddAuditLogQuery(ctx, tx, db.AddAuditLogQueryParams{
+		Actor:   username,
+		Action:  "user.force_onboard",
+		Target:  onboardGhUsername,
+		Details: fmt.Sprintf("email=%s", pending.Email),
+	})
+	if err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":         "User onboarded successfully.",
+		"github_username": onboardGhUsername,
+	})
+	a.Log.Info(fmt.Sprintf(
+		"[SUCCESS]: Processed request at %s %s",
+		c.Request.Method, c.FullPath(),
+	))
+	return
+}
+
+// Regenerates and mails the OTP of a pending registration for users who did
+// not receive theirs. The new OTP counts towards the same resend cap.
+func (a *App) ResendUserOtp(c *gin.Context) {
+	username, ok := pkg.GrabUsername(c)
+	if !ok {
+		a.Log.Warn(
+			fmt.Sprintf("Failed to extract username from token at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Oops! Something happened. Please try again later.",
+		})
+		return
+	}
+	email := pkg.NormalizeEmail(c.Param("email"), a.Env.CollapseGmailDots)
+
+	otp, err := pkg.GenerateOTP(a.Env.OtpAlphanumeric)
+	if err != nil {
+		a.Log.Error(
+			fmt.Sprintf("Failed to generate OTP at %s %s", c.Request.Method, c.FullPath()), err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Oops! Something happened. Please try again later.",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), a.Env.TimeoutFor("admin"))
+	defer cancel()
+
+	tx, err := a.DB.Begin(ctx)
+	if err != nil {
+		pkg.DbAcquireError(c, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	q := a.Queries
+	pending, err := q.FetchPendingRegistrationByEmailQuery(ctx, tx, email)
+	if errors.Is(err, pgx.ErrNoRows) {
+		a.Log.Warn(
+			fmt.Sprintf("OTP resend without pending registration at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "No registration found for the given email",
+		})
+		return
+	}
+	if err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+	if !a.checkOtpResend(c, pending.ResendCount, pending.SentSecondsAgo,
+		"OTP resend limit reached. The user must restart registration.") {
+		return
+	}
+	result, err := q.RegenerateOtpQuery(ctx, tx, db.RegenerateOtpQueryParams{
+		Otp:        otp,
+		Ghusername: pending.Ghusername,
+		Email:      pending.Email,
+		MaxResends: a.Env.OtpMaxResends,
+		Cooldown:   a.otpResendCooldown(),
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		a.otpResendTooSoon(c)
+		return
+	}
+	if err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+
+	// The OTP goes out over the channel chosen at registration
+	if result.OtpChannel == pkg.OtpChannelEmail && !a.checkMailable(ctx, c, tx, pending.Email) {
+		return
+	}
+
+	err = q.AddAuditLogQuery(ctx, tx, db.AddAuditLogQueryParams{
+		Actor:   username,
+		Action:  "user.resend_otp",
+		Target:  pending.Ghusername,
+		Details: fmt.Sprintf("email=%s", pending.Email),
+	})
+	if err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+
+	// Database transaction fails if the OTP is not sent
+	err = a.sendOtp(ctx, c, tx, result.OtpChannel, result.Email, result.Phone, result.Otp)
+	if err != nil {
+		pkg.TxError(c, err)
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":         "User OTP resent over the registered channel",
+		"github_username": pending.Ghusername,
+		"channel":         result.OtpChannel,
+	})
+	a.Log.Info(fmt.Sprintf(
+		"[SUCCESS]: Processed request at %s %s",
+		c.Request.Method, c.FullPath(),
+	))
+	return
+}
+
+func (a *App) forceOnboardConflict(c *gin.Context, ghUsername string) {
+	a.Log.Warn(
+		fmt.Sprintf("Force onboard of already onboarded user at %s %s",
+			c.Request.Method, c.FullPath()))
+	c.JSON(http.StatusConflict, gin.H{
+		"message":         "User already registered.",
+		"github_username": ghUsername,
+	})
+}
+
+func (a *App) FetchMaintenanceMode(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Maintenance mode retrieved successfully",
+		"enabled": mw.InMaintenance(),
+	})
+	a.Log.Info(fmt.Sprintf(
+		"[SUCCESS]: Processed request at %s %s",
+		c.Request.Method, c.FullPath(),
+	))
+	return
+}
+
+// Registered without the Maintenance middleware so that it can be lifted
+func (a *App) UpdateMaintenanceMode(c *gin.Context) {
+	username, ok := pkg.GrabUsername(c)
+	if !ok {
+		a.Log.Warn(
+			fmt.Sprintf("Failed to extract username from token at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Oops! Something happened. Please try again later.",
+		})
+		return
+	}
+
+	var body types.MaintenanceRequest
+	if err := c.BindJSON(&body); err != nil {
+		pkg.JSONUnmarshallError(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), a.Env.TimeoutFor("admin"))
+	defer cancel()
+
+	conn, err := a.DB.Acquire(ctx)
+	if err != nil {
+		pkg.DbAcquireError(c, err)
+		return
+	}
+	defer conn.Release()
+
+	q := a.Queries
+	err = q.AddAuditLogQuery(ctx, conn, db.AddAuditLogQueryParams{
+		Actor:   username,
+		Action:  "maintenance.update",
+		Target:  "server",
+		Details: fmt.Sprintf("enabled=%t", body.Enabled),
+	})
+	if err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+	mw.SetMaintenance(body.Enabled)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Maintenance mode updated successfully",
+		"enabled": body.Enabled,
+	})
+	a.Log.Info(fmt.Sprintf(
+		"[SUCCESS]: Processed request at %s %s",
+		c.Request.Method, c.FullPath(),
+	))
+	return
+}
+
+// Renders a mail with sample data so that branding changes can be checked
+// without sending anything. Only registered when MAIL_PREVIEW is on.
+func (a *App) PreviewMail(c *gin.Context) {
+	var query types.MailPreviewQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		pkg.RequestValidatorError(c, err)
+		return
+	}
+	kind := pkg.MailType(query.Template)
+	if kind == "" {
+		kind = pkg.MailOtp
+	}
+	preview, err := pkg.RenderMailPreview(kind)
+	if errors.Is(err, pkg.ErrNoMailPreview) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message":  "No preview for this template",
+			"template": kind,
+		})
+		return
+	}
+	if err != nil {
+		a.Log.Error(
+			fmt.Sprintf("Failed to render mail preview at %s %s", c.Request.Method, c.FullPath()),
+			err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Oops! Something happened. Please try again later.",
+		})
+		return
+	}
+
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(preview))
+	a.Log.Info(fmt.Sprintf(
+		"[SUCCESS]: Processed request at %s %s",
+		c.Request.Method, c.FullPath(),
+	))
+	return
+}
@@ -0,0 +1,272 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	db "github.com/IAmRiteshKoushik/pulse/db/gen"
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestForceOnboardUser(t *testing.T) {
+	tests := []struct {
+		name         string
+		email        string
+		onboarded    bool  // octo@example.com already has an account
+		pending      bool  // octo@example.com has a registration awaiting its OTP
+		createErr    error // of creating the account
+		wantStatus   int
+		wantUsername string
+		wantAudit    bool
+	}{
+		{
+			name:         "pending registration",
+			email:        "octo@example.com",
+			pending:      true,
+			wantStatus:   http.StatusOK,
+			wantUsername: "octocat",
+			wantAudit:    true,
+		},
+		{
+			name:         "email normalized",
+			email:        "Octo@Example.com",
+			pending:      true,
+			wantStatus:   http.StatusOK,
+			wantUsername: "octocat",
+			wantAudit:    true,
+		},
+		{
+			name:         "already onboarded",
+			email:        "octo@example.com",
+			onboarded:    true,
+			wantStatus:   http.StatusConflict,
+			wantUsername: "octocat",
+		},
+		{
+			name:         "onboarded concurrently",
+			email:        "octo@example.com",
+			pending:      true,
+			createErr:    &pgconn.PgError{Code: "23505"},
+			wantStatus:   http.StatusConflict,
+			wantUsername: "octocat",
+		},
+		{
+			name:       "no registration",
+			email:      "hubot@example.com",
+			pending:    true,
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var created []db.CreateUserAccountQueryParams
+			var marked []string
+			var audits []db.AddAuditLogQueryParams
+			q := &fakeQuerier{
+				checkUserExistByEmail: func(_ db.DBTX, email string) (db.CheckUserExistByEmailQueryRow, error) {
+					if !tt.onboarded || email != "octo@example.com" {
+						return db.CheckUserExistByEmailQueryRow{}, pgx.ErrNoRows
+					}
+					return db.CheckUserExistByEmailQueryRow{Ghusername: "octocat", Email: email}, nil
+				},
+				fetchPendingRegByEmail: func(_ db.DBTX, email string) (db.FetchPendingRegistrationByEmailQueryRow, error) {
+					if !tt.pending || email != "octo@example.com" {
+						return db.FetchPendingRegistrationByEmailQueryRow{}, pgx.ErrNoRows
+					}
+					return db.FetchPendingRegistrationByEmailQueryRow{
+						Email: email, Ghusername: "octocat", Timezone: "Asia/Kolkata", Locale: "en",
+					}, nil
+				},
+				markRegistrationsOnboarded: func(_ db.DBTX, ghusername string) error {
+					marked = append(marked, ghusername)
+					return nil
+				},
+				createUserAccount: func(_ db.DBTX, arg db.CreateUserAccountQueryParams) (string, error) {
+					if tt.createErr != nil {
+						return "", tt.createErr
+					}
+					created = append(created, arg)
+					return arg.Ghusername, nil
+				},
+				addAuditLog: func(_ db.DBTX, arg db.AddAuditLogQueryParams) error {
+					audits = append(audits, arg)
+					return nil
+				},
+			}
+			a := newTestApp(q)
+			a.Env = testEnv()
+
+			router := gin.New()
+			router.POST("/api/v1/admin/users/:email/force-onboard", func(c *gin.Context) {
+				c.Set("username", "maintainer")
+			}, a.ForceOnboardUser)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost,
+				"/api/v1/admin/users/"+tt.email+"/force-onboard", nil))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			var resp struct {
+				GithubUsername string `json:"github_username"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.GithubUsername != tt.wantUsername {
+				t.Errorf("github_username = %q, want %q", resp.GithubUsername, tt.wantUsername)
+			}
+
+			if !tt.wantAudit {
+				if len(audits) != 0 {
+					t.Errorf("audited %+v", audits)
+				}
+				return
+			}
+			if len(created) != 1 || created[0].Email != "octo@example.com" || created[0].Timezone != "Asia/Kolkata" {
+				t.Errorf("accounts created = %+v", created)
+			}
+			if len(marked) != 1 || marked[0] != "octocat" {
+				t.Errorf("registrations marked onboarded = %v", marked)
+			}
+			want := db.AddAuditLogQueryParams{
+				Actor:   "maintainer",
+				Action:  "user.force_onboard",
+				Target:  "octocat",
+				Details: "email=octo@example.com",
+			}
+			if len(audits) != 1 || audits[0] != want {
+				t.Errorf("audit records = %+v, want %+v", audits, want)
+			}
+		})
+	}
+}
+
+// A registration in user_onboarding, read and updated the way the admin OTP
+// queries do
+type onboardingRow struct {
+	email       string
+	ghUsername  string
+	state       string
+	otp         string
+	phone       string
+	resendCount int32
+	sentAt      time.Time
+}
+
+func (r *onboardingRow) querier(audits *[]db.AddAuditLogQueryParams) *fakeQuerier {
+	return &fakeQuerier{
+		fetchPendingRegByEmail: func(_ db.DBTX, email string) (db.FetchPendingRegistrationByEmailQueryRow, error) {
+			if r.email != email || r.state != "pending_otp" {
+				return db.FetchPendingRegistrationByEmailQueryRow{}, pgx.ErrNoRows
+			}
+			return db.FetchPendingRegistrationByEmailQueryRow{
+				Email:          r.email,
+				Ghusername:     r.ghUsername,
+				ResendCount:    r.resendCount,
+				SentSecondsAgo: int32(time.Since(r.sentAt).Seconds()),
+			}, nil
+		},
+		regenerateOtp: func(_ db.DBTX, arg db.RegenerateOtpQueryParams) (db.RegenerateOtpQueryRow, error) {
+			cooldown := time.Duration(arg.Cooldown.Microseconds) * time.Microsecond
+			if r.ghUsername != arg.Ghusername || r.email != arg.Email || r.state != "pending_otp" ||
+				r.resendCount >= arg.MaxResends || r.sentAt.After(time.Now().Add(-cooldown)) {
+				return db.RegenerateOtpQueryRow{}, pgx.ErrNoRows
+			}
+			r.otp, r.resendCount, r.sentAt = arg.Otp, r.resendCount+1, time.Now()
+			return db.RegenerateOtpQueryRow{Email: r.email, Otp: r.otp, Phone: r.phone, OtpChannel: "sms"}, nil
+		},
+		addAuditLog: func(_ db.DBTX, arg db.AddAuditLogQueryParams) error {
+			*audits = append(*audits, arg)
+			return nil
+		},
+	}
+}
+
+func TestResendUserOtp(t *testing.T) {
+	tests := []struct {
+		name        string
+		email       string
+		state       string
+		resendCount int32
+		sentAgo     time.Duration
+		wantStatus  int
+	}{
+		{name: "pending registration", email: "octo@example.com", state: "pending_otp",
+			sentAgo: 5 * time.Minute, wantStatus: http.StatusOK},
+		{name: "email normalized", email: "Octo@Example.com", state: "pending_otp",
+			sentAgo: 5 * time.Minute, wantStatus: http.StatusOK},
+		{name: "already onboarded", email: "octo@example.com", state: "onboarded",
+			sentAgo: 5 * time.Minute, wantStatus: http.StatusNotFound},
+		{name: "no registration", email: "hubot@example.com", state: "pending_otp",
+			sentAgo: 5 * time.Minute, wantStatus: http.StatusNotFound},
+		// Staff are held to the cooldown and cap of the user
+		{name: "within the cooldown", email: "octo@example.com", state: "pending_otp",
+			sentAgo: 10 * time.Second, wantStatus: http.StatusTooManyRequests},
+		{name: "resend cap reached", email: "octo@example.com", state: "pending_otp",
+			resendCount: 3, sentAgo: 5 * time.Minute, wantStatus: http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row := &onboardingRow{
+				email:       "octo@example.com",
+				ghUsername:  "octocat",
+				state:       tt.state,
+				otp:         "123456",
+				phone:       "+919876543210",
+				resendCount: tt.resendCount,
+				sentAt:      time.Now().Add(-tt.sentAgo),
+			}
+			var audits []db.AddAuditLogQueryParams
+			sms := &fakeSMS{}
+			a := newTestApp(row.querier(&audits))
+			a.Env = testEnv()
+			a.Env.SmsProvider = "twilio"
+			a.Env.OtpMaxResends = 3
+			a.Env.OtpResendCooldown = time.Minute
+			a.SMS = sms
+
+			router := gin.New()
+			router.POST("/api/v1/admin/users/:email/resend-otp", func(c *gin.Context) {
+				c.Set("username", "maintainer")
+			}, a.ResendUserOtp)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost,
+				"/api/v1/admin/users/"+tt.email+"/resend-otp", nil))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				if len(sms.sent) != 0 || len(audits) != 0 || row.otp != "123456" {
+					t.Errorf("refused resend sent %v, audited %+v, OTP %s", sms.sent, audits, row.otp)
+				}
+				return
+			}
+			if row.otp == "123456" || row.resendCount != tt.resendCount+1 {
+				t.Errorf("OTP %s, resend count %d: not regenerated", row.otp, row.resendCount)
+			}
+			want := fakeMessage{to: "+919876543210", body: pkg.OtpSmsBody(row.otp)}
+			if len(sms.sent) != 1 || sms.sent[0] != want {
+				t.Errorf("sent %v, want [%v]", sms.sent, want)
+			}
+			wantAudit := db.AddAuditLogQueryParams{
+				Actor:   "maintainer",
+				Action:  "user.resend_otp",
+				Target:  "octocat",
+				Details: "email=octo@example.com",
+			}
+			if len(audits) != 1 || audits[0] != wantAudit {
+				t.Errorf("audited %+v, want %+v", audits, wantAudit)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/IAmRiteshKoushik/pulse/cmd"
+	db "github.com/IAmRiteshKoushik/pulse/db/gen"
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"golang.org/x/oauth2"
+)
+
+// App holds the dependencies shared by every controller. Handlers are
+// methods on it so that tests can construct an App with fakes. As a replica
+// may lag behind, only handlers that do not read their own writes use it.
+type App struct {
+	Env     *cmd.EnvConfig
+	Log     cmd.Logger
+	DB      pkg.Pool
+	Replica pkg.Pool   // read-only queries, DB when no replica is configured
+	Queries db.Querier // replaceable with a mock in tests
+	OAuth   *oauth2.Config
+	Google  *oauth2.Config // nil unless Google is configured
+	Client  *http.Client   // used for all calls to GitHub
+
+	WebAuthn *webauthn.WebAuthn // nil unless passkeys are configured
+	SMS      pkg.SMSSender      // nil unless SMS delivery is configured
+}
+
+func NewApp(
+	env *cmd.EnvConfig,
+	log cmd.Logger,
+	pool pkg.Pool,
+	replica pkg.Pool,
+	oauthCfg *oauth2.Config,
+	googleCfg *oauth2.Config,
+	webAuthn *webauthn.WebAuthn,
+	client *http.Client,
+	sms pkg.SMSSender,
+) *App {
+	if replica == nil {
+		replica = pool
+	}
+	return &App{
+		Env:     env,
+		Log:     log,
+		DB:      pool,
+		Replica: replica,
+		Queries: db.New(),
+		OAuth:   oauthCfg,
+		Google:  googleCfg,
+		Client:  client,
+
+		WebAuthn: webAuthn,
+		SMS:      sms,
+	}
+}
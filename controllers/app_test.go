@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/IAmRiteshKoushik/pulse/cmd"
+	db "github.com/IAmRiteshKoushik/pulse/db/gen"
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	"github.com/gin-gonic/gin"
+)
+
+func TestNewApp(t *testing.T) {
+	tests := []struct {
+		name     string
+		replica  pkg.Pool
+		wantPool string
+	}{
+		{name: "with a replica", replica: fakePool{name: "replica"}, wantPool: "replica"},
+		{name: "without a replica", replica: nil, wantPool: "primary"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewApp(testEnv(), cmd.Log, fakePool{name: "primary"}, tt.replica,
+				nil, nil, nil, http.DefaultClient, nil)
+			if a.Queries == nil {
+				t.Fatal("NewApp() left Queries unset")
+			}
+			var used string
+			a.Queries = &fakeQuerier{
+				fetchPublicProfile: func(conn db.DBTX, ghUsername string) (db.FetchPublicProfileQueryRow, error) {
+					used = poolOf(conn)
+					return db.FetchPublicProfileQueryRow{Ghusername: ghUsername}, nil
+				},
+			}
+
+			router := gin.New()
+			router.GET("/api/v1/users/:ghusername", a.FetchPublicProfile)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/users/octocat", nil))
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+			}
+			if used != tt.wantPool {
+				t.Errorf("profile read from %q, want %q", used, tt.wantPool)
+			}
+		})
+	}
+}
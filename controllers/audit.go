@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	db "github.com/IAmRiteshKoushik/pulse/db/gen"
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	"github.com/IAmRiteshKoushik/pulse/types"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func (a *App) FetchAuditLogs(c *gin.Context) {
+	var query types.FetchAuditLogsRequest
+	if err := c.ShouldBindQuery(&query); err != nil {
+		pkg.RequestValidatorError(c, err)
+		return
+	}
+	if err := query.Validate(); err != nil {
+		pkg.RequestValidatorError(c, err)
+		return
+	}
+
+	params := db.FetchAuditLogsQueryParams{
+		Actor:    pgtype.Text{String: query.Actor, Valid: query.Actor != ""},
+		Action:   pgtype.Text{String: query.Action, Valid: query.Action != ""},
+		FromTime: pkg.NewTimestamp(query.From),
+		ToTime:   pkg.NewTimestamp(query.To),
+		PageSize: int32(query.PageSize),
+	}
+	if query.Cursor != "" {
+		cursorTime, cursorId, err := pkg.DecodeCursor(query.Cursor)
+		if err != nil {
+			pkg.RequestValidatorError(c, err)
+			return
+		}
+		params.CursorTime = pkg.NewTimestamp(cursorTime)
+		params.CursorID = pgtype.Int4{Int32: cursorId, Valid: true}
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), a.Env.TimeoutFor("audit"))
+	defer cancel()
+
+	conn, err := a.Replica.Acquire(ctx)
+	if err != nil {
+		pkg.DbAcquireError(c, err)
+		return
+	}
+	defer conn.Release()
+
+	q := a.Queries
+	results, err := q.FetchAuditLogsQuery(ctx, conn, params)
+	if err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+
+	// A full page implies there may be more entries after the last one
+	nextCursor := ""
+	if len(results) == query.PageSize {
+		last := results[len(results)-1]
+		nextCursor = pkg.EncodeCursor(last.CreatedAt.Time, last.ID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Audit logs retrived successfully",
+		"logs":        results,
+		"next_cursor": nextCursor,
+	})
+	a.Log.Info(fmt.Sprintf(
+		"[SUCCESS]: Processed request at %s %s",
+		c.Request.Method, c.FullPath(),
+	))
+	return
+}
@@ -2,34 +2,65 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/IAmRiteshKoushik/pulse/cmd"
 	db "github.com/IAmRiteshKoushik/pulse/db/gen"
+	mw "github.com/IAmRiteshKoushik/pulse/middleware"
 	"github.com/IAmRiteshKoushik/pulse/pkg"
 	"github.com/IAmRiteshKoushik/pulse/types"
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
-func RegisterUserAccount(c *gin.Context) {
+// Keys registrations by the phone number their OTP may be sent to, so that
+// SMS cannot be pumped to one number from many IPs
+func registrationPhone(c *gin.Context) string {
+	var body struct {
+		Phone string `json:"phone"`
+	}
+	if err := mw.PeekJSON(c, &body); err != nil {
+		return ""
+	}
+	phone := pkg.NormalizePhone(strings.TrimSpace(body.Phone))
+	if pkg.CheckPhone(phone) != nil {
+		return ""
+	}
+	return "phone:" + phone
+}
+
+// Keys registrations by the number range their phone number belongs to
+func registrationPhonePrefix(c *gin.Context) string {
+	key := registrationPhone(c)
+	if key == "" {
+		return ""
+	}
+	return "prefix:" + pkg.PhonePrefix(strings.TrimPrefix(key, "phone:"))
+}
+
+func (a *App) RegisterUserAccount(c *gin.Context) {
 	var body types.RegisterUserRequest
 	if err := c.BindJSON(&body); err != nil {
 		pkg.JSONUnmarshallError(c, err)
 		return
 	}
-	if err := body.Validate(); err != nil {
+	if err := body.Validate(a.Env); err != nil {
 		pkg.RequestValidatorError(c, err)
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), a.Env.TimeoutFor("register"))
 	defer cancel()
 
-	otp, err := pkg.GenerateOTP()
+	otp, err := pkg.GenerateOTP(a.Env.OtpAlphanumeric)
 	if err != nil {
-		cmd.Log.Error(
+		a.Log.Error(
 			fmt.Sprintf("Failed to generate OTP at %s %s", c.Request.Method, c.FullPath()), err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"message": "Oops! Something happened. Please try again later.",
@@ -37,10 +68,10 @@ func RegisterUserAccount(c *gin.Context) {
 		return
 	}
 
-	tempToken, err := pkg.CreateToken(body.GhUsername, body.Email, "temp_token")
+	tempToken, err := pkg.CreateToken(a.Env, body.GhUsername, body.Email, "temp_token")
 	if err != nil {
-		cmd.Log.Fatal(
-			fmt.Sprintf("Failed to generate access token at %s %s.",
+		a.Log.Error(
+			fmt.Sprintf("Failed to generate temp token at %s %s",
 				c.Request.Method, c.FullPath()), err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"message": "Oops! Something happened. Please try again later.",
@@ -48,51 +79,123 @@ func RegisterUserAccount(c *gin.Context) {
 		return
 	}
 
-	tx, err := cmd.DBPool.Begin(ctx)
+	q := a.Queries
+	err = pkg.RunInTx(ctx, a.DB, func(tx pgx.Tx) error {
+		if !a.checkOnboardingTransition(ctx, c, tx, body.GhUsername, pkg.EventRegister) {
+			return pkg.ErrTxAborted
+		}
+		if body.OtpChannel == pkg.OtpChannelEmail && !a.checkMailable(ctx, c, tx, body.Email) {
+			return pkg.ErrTxAborted
+		}
+		result, err := q.BeginUserRegistrationQuery(ctx, tx,
+			db.BeginUserRegistrationQueryParams{
+				Email:      body.Email,
+				Ghusername: body.GhUsername,
+				Otp:        otp,
+				Timezone:   body.Timezone,
+				Locale:     body.Locale,
+				Phone:      body.Phone,
+				OtpChannel: body.OtpChannel,
+			})
+		if err != nil {
+			return err
+		}
+
+		// Database transaction fails if the OTP is not sent
+		return a.sendOtp(ctx, c, tx, result.OtpChannel, result.Email, result.Phone, result.Otp)
+	})
 	if err != nil {
-		pkg.DbError(c, err)
+		pkg.TxError(c, err)
 		return
 	}
-	defer tx.Rollback(ctx)
 
-	q := db.New()
-	result, err := q.BeginUserRegistrationQuery(ctx, tx,
-		db.BeginUserRegistrationQueryParams{
-			Email:      body.Email,
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "User onboarding has been initiated.",
+		"access_key": tempToken,
+	})
+	a.Log.Info(fmt.Sprintf(
+		"[SUCCESS]: Processed request at %s %s",
+		c.Request.Method, c.FullPath(),
+	))
+	return
+}
+
+// Issues a fresh temp token for a registration whose OTP is still valid,
+// without sending another OTP. The OTP itself is required, as the token would
+// otherwise go to anyone who knows the email and username.
+func (a *App) ReissueTempToken(c *gin.Context) {
+	var body types.ReissueTempTokenRequest
+	if err := c.BindJSON(&body); err != nil {
+		pkg.JSONUnmarshallError(c, err)
+		return
+	}
+	if err := body.Validate(a.Env); err != nil {
+		pkg.RequestValidatorError(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), a.Env.TimeoutFor("register"))
+	defer cancel()
+
+	conn, err := a.DB.Acquire(ctx)
+	if err != nil {
+		pkg.DbAcquireError(c, err)
+		return
+	}
+	defer conn.Release()
+
+	q := a.Queries
+	pending, err := q.CheckPendingRegistrationQuery(ctx, conn,
+		db.CheckPendingRegistrationQueryParams{
 			Ghusername: body.GhUsername,
-			Otp:        otp,
+			Email:      body.Email,
+			Otp:        body.Otp,
 		})
 	if err != nil {
 		pkg.DbError(c, err)
 		return
 	}
-
-	// Database transaction fails if mail is not sent
-	err = pkg.SendMail([]string{result.Email}, result.Otp)
-	if err != nil {
+	// A wrong OTP is indistinguishable from a missing registration
+	if !pending {
+		a.recordOtpFailure()
+		a.Log.Warn(
+			fmt.Sprintf("No pending registration for temp token reissue at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "No pending registration found. Please register again.",
+		})
 		return
 	}
 
-	if err = tx.Commit(ctx); err != nil {
-		pkg.DbError(c, err)
+	tempToken, err := pkg.CreateToken(a.Env, body.GhUsername, body.Email, "temp_token")
+	if err != nil {
+		a.Log.Error(
+			fmt.Sprintf("Failed to generate temp token at %s %s",
+				c.Request.Method, c.FullPath()), err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Oops! Something happened. Please try again later.",
+		})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message":    "User onboarding has been initiated.",
+		"message":    "Temp token reissued successfully.",
 		"access_key": tempToken,
 	})
-	cmd.Log.Info(fmt.Sprintf(
+	a.Log.Info(fmt.Sprintf(
 		"[SUCCESS]: Processed request at %s %s",
 		c.Request.Method, c.FullPath(),
 	))
 	return
 }
 
-func RegisterUserOtpVerify(c *gin.Context) {
+// Resubmitting an already verified OTP succeeds again for this long
+const otpReplayWindow = 10 * time.Minute
+
+func (a *App) RegisterUserOtpVerify(c *gin.Context) {
 	username, ok := pkg.GrabUsername(c)
 	if !ok {
-		cmd.Log.Warn(
+		a.Log.Warn(
 			fmt.Sprintf("Failed to extract username from token at %s %s",
 				c.Request.Method, c.FullPath()))
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -106,77 +209,224 @@ func RegisterUserOtpVerify(c *gin.Context) {
 		pkg.JSONUnmarshallError(c, err)
 		return
 	}
-	if err := body.Validate(); err != nil {
+	if err := body.Validate(a.Env); err != nil {
 		pkg.RequestValidatorError(c, err)
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), a.Env.TimeoutFor("otp_verify"))
 	defer cancel()
 
-	tx, err := cmd.DBPool.Begin(ctx)
+	q := a.Queries
+	var verifiedUser db.VerifyOtpQueryRow
+	var onboardGhUsername string
+	var onboardedToday int64
+	err := pkg.RunInTx(ctx, a.DB, func(tx pgx.Tx) error {
+		// A double submitted form waits here for the first attempt to finish,
+		// and is then answered as a replay of it
+		if err := q.LockOnboardingQuery(ctx, tx, username); err != nil {
+			return err
+		}
+		// Clients retrying after a lost response get the original success
+		replayed, err := q.CheckOtpReplayQuery(ctx, tx, db.CheckOtpReplayQueryParams{
+			Ghusername: username,
+			Otp:        body.Otp,
+			Window:     pgtype.Interval{Microseconds: otpReplayWindow.Microseconds(), Valid: true},
+		})
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+		if replayed != "" {
+			a.Log.Info(
+				fmt.Sprintf("Repeated OTP verification answered from earlier success at %s %s",
+					c.Request.Method, c.FullPath()))
+			c.JSON(http.StatusOK, gin.H{
+				"message":         "User Registration successful.",
+				"github_username": replayed,
+			})
+			return pkg.ErrTxAborted
+		}
+		if !a.checkOnboardingTransition(ctx, c, tx, username, pkg.EventVerifyOtp) {
+			return pkg.ErrTxAborted
+		}
+		verifiedUser, err = q.VerifyOtpQuery(ctx, tx, db.VerifyOtpQueryParams{
+			Ghusername: username,
+			Otp:        body.Otp,
+		})
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+		if verifiedUser.Email == "" {
+			cmd.OtpVerifyTotal.WithLabelValues("failure").Inc()
+			a.recordOtpFailure()
+			a.Log.Warn(
+				fmt.Sprintf("Invalid OTP submitted at %s %s",
+					c.Request.Method, c.FullPath()))
+			c.JSON(http.StatusForbidden, gin.H{
+				"message": "Server refused to process the request",
+			})
+			return pkg.ErrTxAborted
+		}
+
+		onboardGhUsername, err = q.CreateUserAccountQuery(ctx, tx,
+			db.CreateUserAccountQueryParams{
+				Email:             verifiedUser.Email,
+				Ghusername:        verifiedUser.Ghusername,
+				DisplayGhusername: verifiedUser.Ghusername,
+				Timezone:          verifiedUser.Timezone,
+				Locale:            verifiedUser.Locale,
+			})
+		if pkg.IsUniqueViolation(err) {
+			a.alreadyRegistered(c, verifiedUser.Ghusername)
+			return pkg.ErrTxAborted
+		}
+		if err != nil {
+			return err
+		}
+		if onboardGhUsername == "" {
+			a.Log.Warn(
+				fmt.Sprintf("Failed to onboard user at %s %s", c.Request.Method, c.FullPath()))
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Oops! Something happened. Please try again later.",
+			})
+			return pkg.ErrTxAborted
+		}
+
+		onboardedToday, err = q.CountOnboardedTodayQuery(ctx, tx)
+		return err
+	})
 	if err != nil {
-		pkg.DbError(c, err)
+		pkg.TxError(c, err)
 		return
 	}
-	tx.Rollback(ctx)
+	if onboardedToday == 1 {
+		pkg.NotifyStaff(pkg.StaffFirstOfDay,
+			fmt.Sprintf("%s is the first user onboarded today.", onboardGhUsername))
+	}
+	cmd.OtpVerifyTotal.WithLabelValues("success").Inc()
+	if verifiedUser.CreatedAt.Valid {
+		cmd.OtpTimeToVerify.Observe(time.Since(verifiedUser.CreatedAt.Time).Seconds())
+	}
 
-	q := db.New()
-	verifiedUser, err := q.VerifyOtpQuery(ctx, tx, db.VerifyOtpQueryParams{
-		Ghusername: username,
-		Otp:        body.Otp,
+	c.JSON(http.StatusOK, gin.H{
+		"message":         "User Registration successful.",
+		"github_username": onboardGhUsername,
 	})
-	if err != nil {
-		pkg.DbError(c, err)
-		return
+	a.Log.Info(fmt.Sprintf(
+		"[SUCCESS]: Processed request at %s %s",
+		c.Request.Method, c.FullPath()))
+	return
+}
+
+// Failed verifications are counted across users in fixed windows, a burst
+// usually means someone is guessing OTPs
+const (
+	otpFailureAlertThreshold = 20
+	otpFailureAlertWindow    = 10 * time.Minute
+)
+
+var otpFailures struct {
+	sync.Mutex
+	count     int
+	windowEnd time.Time
+}
+
+func (a *App) recordOtpFailure() {
+	now := time.Now()
+	otpFailures.Lock()
+	if now.After(otpFailures.windowEnd) {
+		otpFailures.count = 0
+		otpFailures.windowEnd = now.Add(otpFailureAlertWindow)
 	}
-	if verifiedUser.Email == "" {
-		cmd.Log.Warn(
-			fmt.Sprintf("Username grabbed from token not found in DB at %s %s",
-				c.Request.Method, c.FullPath()))
-		c.JSON(http.StatusForbidden, gin.H{
-			"message": "Server refused to process the request",
-		})
-		return
+	otpFailures.count++
+	count := otpFailures.count
+	otpFailures.Unlock()
+
+	if count >= otpFailureAlertThreshold {
+		pkg.NotifyStaff(pkg.StaffOtpFailures,
+			fmt.Sprintf("%d failed OTP verifications in the last %s.", count, otpFailureAlertWindow))
 	}
+}
 
-	onboardGhUsername, err := q.CreateUserAccountQuery(ctx, tx,
-		db.CreateUserAccountQueryParams{
-			Email:      verifiedUser.Email,
-			Ghusername: verifiedUser.Ghusername,
-		})
+func (a *App) alreadyRegistered(c *gin.Context, ghUsername string) {
+	a.Log.Info(
+		fmt.Sprintf("Already onboarded user attempted onboarding at %s %s",
+			c.Request.Method, c.FullPath()))
+	c.JSON(http.StatusConflict, gin.H{
+		"message":         "User already registered.",
+		"github_username": ghUsername,
+	})
+}
+
+// Responds and returns false when event is not allowed in the current
+// onboarding state of ghUsername
+func (a *App) checkOnboardingTransition(ctx context.Context, c *gin.Context, conn db.DBTX,
+	ghUsername string, event pkg.OnboardingEvent) bool {
+	state, err := a.Queries.FetchOnboardingStateQuery(ctx, conn, ghUsername)
 	if err != nil {
 		pkg.DbError(c, err)
-		return
+		return false
 	}
-	if onboardGhUsername == "" {
-		cmd.Log.Warn(
-			fmt.Sprintf("Failed to onboard user at %s %s", c.Request.Method, c.FullPath()))
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": "Oops! Something happened. Please try again later.",
+
+	_, err = pkg.OnboardingTransition(pkg.OnboardingState(state), event)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, pkg.ErrAlreadyOnboarded):
+		a.alreadyRegistered(c, ghUsername)
+	case errors.Is(err, pkg.ErrNotRegistered):
+		a.Log.Warn(
+			fmt.Sprintf("No pending registration for %s at %s %s",
+				event, c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "No pending registration found. Please register again.",
+		})
+	default:
+		a.Log.Error(
+			fmt.Sprintf("Invalid onboarding transition at %s %s",
+				c.Request.Method, c.FullPath()), err)
+		c.JSON(http.StatusConflict, gin.H{
+			"message": "Request not allowed at this stage of registration.",
 		})
-		return
 	}
+	return false
+}
 
-	if err := tx.Commit(ctx); err != nil {
-		pkg.DbError(c, err)
-		return
+// Responds and returns false when the OTP of a registration may not be sent
+// again yet. The cap is final, so it is a conflict rather than a rate limit.
+func (a *App) checkOtpResend(c *gin.Context, resendCount, sentSecondsAgo int32, capMessage string) bool {
+	if resendCount >= a.Env.OtpMaxResends {
+		a.Log.Warn(
+			fmt.Sprintf("OTP resend limit reached at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusConflict, gin.H{
+			"message": capMessage,
+		})
+		return false
 	}
+	wait := a.Env.OtpResendCooldown - time.Duration(sentSecondsAgo)*time.Second
+	if wait > 0 {
+		pkg.RespondRateLimitedMessage(c, wait, "Please wait before requesting another OTP.")
+		return false
+	}
+	return true
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message":         "User Registration successful.",
-		"github_username": onboardGhUsername,
-	})
-	cmd.Log.Info(fmt.Sprintf(
-		"[SUCCESS]: Processed request at %s %s",
-		c.Request.Method, c.FullPath()))
-	return
+func (a *App) otpResendTooSoon(c *gin.Context) {
+	a.Log.Warn(
+		fmt.Sprintf("Concurrent OTP resend at %s %s",
+			c.Request.Method, c.FullPath()))
+	pkg.RespondRateLimitedMessage(c, a.Env.OtpResendCooldown, "Please wait before requesting another OTP.")
+}
+
+func (a *App) otpResendCooldown() pgtype.Interval {
+	return pgtype.Interval{Microseconds: a.Env.OtpResendCooldown.Microseconds(), Valid: true}
 }
 
-func RegisterUserOtpResend(c *gin.Context) {
+func (a *App) RegisterUserOtpResend(c *gin.Context) {
 	username, ok := pkg.GrabUsername(c)
 	if !ok {
-		cmd.Log.Warn(
+		a.Log.Warn(
 			fmt.Sprintf("Failed to extract username from token at %s %s",
 				c.Request.Method, c.FullPath()))
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -185,24 +435,27 @@ func RegisterUserOtpResend(c *gin.Context) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), a.Env.TimeoutFor("otp_resend"))
 	defer cancel()
 
-	conn, err := cmd.DBPool.Acquire(ctx)
+	conn, err := a.DB.Acquire(ctx)
 	if err != nil {
-		pkg.DbError(c, err)
+		pkg.DbAcquireError(c, err)
 		return
 	}
-	conn.Release()
+	defer conn.Release()
 
-	q := db.New()
+	q := a.Queries
+	if !a.checkOnboardingTransition(ctx, c, conn, username, pkg.EventResendOtp) {
+		return
+	}
 	result, err := q.CheckForExistingOtpQuery(ctx, conn, username)
-	if err != nil {
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
 		pkg.DbError(c, err)
 		return
 	}
 	if result.Email == "" {
-		cmd.Log.Info(
+		a.Log.Info(
 			fmt.Sprintf("Request processed successfully at %s %s",
 				c.Request.Method, c.FullPath()))
 		c.JSON(http.StatusNotFound, gin.H{
@@ -210,22 +463,178 @@ func RegisterUserOtpResend(c *gin.Context) {
 		})
 		return
 	}
+	if !a.checkOtpResend(c, result.ResendCount, result.SentSecondsAgo,
+		"OTP resend limit reached. Please restart registration.") {
+		return
+	}
+	// Registrations sent their OTP by SMS may fall back to mail. The other way
+	// round is refused, as a phone number is only verified by the OTP sent to
+	// it, and SMS would otherwise go to numbers nobody has proven to own.
+	channel := result.OtpChannel
+	switch c.Query("channel") {
+	case "":
+	case pkg.OtpChannelEmail:
+		channel = pkg.OtpChannelEmail
+	case pkg.OtpChannelSms:
+		if result.OtpChannel != pkg.OtpChannelSms || pkg.CheckOtpChannel(a.Env, pkg.OtpChannelSms) != nil ||
+			pkg.CheckSmsCountry(a.Env, result.Phone) != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"message": "OTP cannot be sent by SMS for this registration.",
+			})
+			return
+		}
+		channel = pkg.OtpChannelSms
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Unknown OTP channel",
+		})
+		return
+	}
+	if channel == pkg.OtpChannelEmail && !a.checkMailable(ctx, c, conn, result.Email) {
+		return
+	}
 
-	err = pkg.SendMail([]string{result.Email}, result.Otp)
+	// Counted before sending so that concurrent resends cannot exceed the cap
+	incremented, err := q.IncrementOtpResendQuery(ctx, conn, db.IncrementOtpResendQueryParams{
+		Ghusername: username,
+		Otp:        result.Otp,
+		MaxResends: a.Env.OtpMaxResends,
+		Cooldown:   a.otpResendCooldown(),
+	})
 	if err != nil {
-		cmd.Log.Error(
-			fmt.Sprintf("Failed to send email at %s %s", c.Request.Method, c.FullPath()),
-			err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": "Oops! Something happened. Please try again later.",
+		pkg.DbError(c, err)
+		return
+	}
+	if incremented == 0 {
+		// A concurrent resend got in first
+		a.otpResendTooSoon(c)
+		return
+	}
+
+	if err := a.sendOtp(ctx, c, conn, channel, result.Email, result.Phone, result.Otp); err != nil {
+		pkg.TxError(c, err)
+		return
+	}
+
+	message := "User OTP resent at specified email address"
+	if channel == pkg.OtpChannelSms {
+		message = "User OTP resent at specified phone number"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+	})
+	a.Log.Info(fmt.Sprintf(
+		"[SUCCESS]: Processed request at %s %s",
+		c.Request.Method, c.FullPath(),
+	))
+	return
+}
+
+// Sends the OTP over the given channel, logging mails for delivery tracking.
+// Responds and returns pkg.ErrTxAborted when delivery fails.
+func (a *App) sendOtp(ctx context.Context, c *gin.Context, conn db.DBTX,
+	channel, email, phone, otp string) error {
+	if channel == pkg.OtpChannelSms {
+		err := pkg.ErrSmsUnavailable
+		if a.SMS != nil {
+			err = a.SMS.SendSMS(ctx, phone, pkg.OtpSmsBody(otp))
+		}
+		if err != nil {
+			a.Log.Error(
+				fmt.Sprintf("[SMS-ERROR]: Failed to send OTP by SMS at %s %s",
+					c.Request.Method, c.FullPath()), err)
+			pkg.RespondUnavailableMessage(c, a.Env.UnavailableRetryAfter,
+				"Could not send SMS right now. Please try again later.")
+			return pkg.ErrTxAborted
+		}
+		return nil
+	}
+
+	sent := pkg.SendMail(pkg.MailOtp, []string{email}, pkg.OtpMailBody(otp))[0]
+	if sent.Err != nil {
+		pkg.MailError(c, sent.Err)
+		return pkg.ErrTxAborted
+	}
+	a.logMail(ctx, c, conn, db.AddMailLogQueryParams{
+		MessageID: sent.MessageId,
+		Recipient: email,
+		Kind:      string(pkg.MailOtp),
+	})
+	return nil
+}
+
+// The mail has already gone out, so failing to log it must not fail the
+// request and discard what was mailed. Inside a transaction the insert runs in
+// a savepoint, which keeps the transaction usable when it fails.
+func (a *App) logMail(ctx context.Context, c *gin.Context, conn db.DBTX,
+	params db.AddMailLogQueryParams) {
+	var err error
+	if tx, ok := conn.(pgx.Tx); ok {
+		err = pgx.BeginFunc(ctx, tx, func(savepoint pgx.Tx) error {
+			return a.Queries.AddMailLogQuery(ctx, savepoint, params)
 		})
+	} else {
+		err = a.Queries.AddMailLogQuery(ctx, conn, params)
+	}
+	if err != nil {
+		a.Log.Error(
+			fmt.Sprintf("[MAIL-LOG-ERROR]: Failed to log sent mail %s at %s %s",
+				params.MessageID, c.Request.Method, c.FullPath()), err)
+	}
+}
+
+// Addresses which previously hard bounced or complained are not mailed again
+func (a *App) checkMailable(ctx context.Context, c *gin.Context, conn db.DBTX, email string) bool {
+	suppressed, err := a.Queries.CheckEmailSuppressedQuery(ctx, conn, email)
+	if err != nil {
+		pkg.DbError(c, err)
+		return false
+	}
+	if suppressed {
+		a.Log.Warn(
+			fmt.Sprintf("Attempted to mail a suppressed address at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Email address cannot receive mail. Please use a different address.",
+		})
+		return false
+	}
+	return true
+}
+
+func (a *App) CheckUsernameAvailability(c *gin.Context) {
+	var query types.UsernameAvailabilityRequest
+	if err := c.ShouldBindQuery(&query); err != nil {
+		pkg.RequestValidatorError(c, err)
+		return
+	}
+	if err := query.Validate(); err != nil {
+		pkg.RequestValidatorError(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), a.Env.TimeoutFor("available"))
+	defer cancel()
+
+	conn, err := a.DB.Acquire(ctx)
+	if err != nil {
+		pkg.DbAcquireError(c, err)
+		return
+	}
+	defer conn.Release()
+
+	q := a.Queries
+	userExist, err := q.CheckUserExistQuery(ctx, conn, query.GhUsername)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		pkg.DbError(c, err)
 		return
 	}
 
+	// Response is kept minimal to avoid disclosing anything beyond availability
 	c.JSON(http.StatusOK, gin.H{
-		"message": "User OTP resent at specified email address",
+		"available": userExist.Ghusername == "",
 	})
-	cmd.Log.Info(fmt.Sprintf(
+	a.Log.Info(fmt.Sprintf(
 		"[SUCCESS]: Processed request at %s %s",
 		c.Request.Method, c.FullPath(),
 	))
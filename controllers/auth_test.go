@@ -0,0 +1,900 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/IAmRiteshKoushik/pulse/cmd"
+	db "github.com/IAmRiteshKoushik/pulse/db/gen"
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRegistrationPhoneKeys(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantPhone  string
+		wantPrefix string
+	}{
+		{name: "normalized phone", body: `{"phone": "+91 98765-43210"}`,
+			wantPhone: "phone:+919876543210", wantPrefix: "prefix:+91987654"},
+		{name: "no phone", body: `{"email": "a@b.c"}`},
+		{name: "invalid phone", body: `{"phone": "12345"}`},
+		{name: "not JSON", body: `phone=+919876543210`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/auth/register", strings.NewReader(tt.body))
+
+			if got := registrationPhone(c); got != tt.wantPhone {
+				t.Errorf("registrationPhone() = %q, want %q", got, tt.wantPhone)
+			}
+			if got := registrationPhonePrefix(c); got != tt.wantPrefix {
+				t.Errorf("registrationPhonePrefix() = %q, want %q", got, tt.wantPrefix)
+			}
+			// The handler still has to bind the body
+			var rest struct {
+				Phone string `json:"phone"`
+			}
+			if tt.wantPhone != "" {
+				if err := c.BindJSON(&rest); err != nil || rest.Phone == "" {
+					t.Errorf("body not readable after peeking: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestRegistrationPhoneRateLimit(t *testing.T) {
+	a := newTestApp(&fakeQuerier{})
+	router := gin.New()
+	router.POST("/api/v1/auth/register", a.rateLimitBy(3, time.Hour, registrationPhone),
+		func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	tests := []struct {
+		name       string
+		ip         string
+		phone      string
+		wantStatus int
+	}{
+		{name: "first", ip: "192.0.2.1", phone: "+919876543210", wantStatus: http.StatusNoContent},
+		{name: "second from another IP", ip: "192.0.2.2", phone: "+919876543210", wantStatus: http.StatusNoContent},
+		{name: "third from another IP", ip: "192.0.2.3", phone: "+91 98765 43210", wantStatus: http.StatusNoContent},
+		{name: "fourth from another IP", ip: "192.0.2.4", phone: "+919876543210", wantStatus: http.StatusTooManyRequests},
+		{name: "another phone", ip: "192.0.2.4", phone: "+919876543211", wantStatus: http.StatusNoContent},
+	}
+
+	// Steps share the limiter, so they run in order
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/register",
+			strings.NewReader(`{"phone": "`+tt.phone+`"}`))
+		req.RemoteAddr = tt.ip + ":1234"
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.wantStatus)
+		}
+	}
+}
+
+func TestCheckOtpResend(t *testing.T) {
+	tests := []struct {
+		name           string
+		resendCount    int32
+		sentSecondsAgo int32
+		wantOk         bool
+		wantStatus     int
+		wantRetryAfter string
+	}{
+		{name: "allowed after the cooldown", resendCount: 1, sentSecondsAgo: 60, wantOk: true},
+		{name: "within the cooldown", resendCount: 1, sentSecondsAgo: 20,
+			wantStatus: http.StatusTooManyRequests, wantRetryAfter: "40"},
+		{name: "cap reached", resendCount: 3, sentSecondsAgo: 600, wantStatus: http.StatusConflict},
+		{name: "cap reached within the cooldown", resendCount: 3, sentSecondsAgo: 0,
+			wantStatus: http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestApp(&fakeQuerier{})
+			a.Env = testEnv()
+			a.Env.OtpMaxResends = 3
+			a.Env.OtpResendCooldown = time.Minute
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/auth/register/otp/resend", nil)
+
+			ok := a.checkOtpResend(c, tt.resendCount, tt.sentSecondsAgo, "limit reached")
+			if ok != tt.wantOk {
+				t.Fatalf("checkOtpResend() = %v, want %v", ok, tt.wantOk)
+			}
+			if tt.wantOk {
+				return
+			}
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("Retry-After"); got != tt.wantRetryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.wantRetryAfter)
+			}
+		})
+	}
+}
+
+func TestConcurrentOtpVerifies(t *testing.T) {
+	const attempts = 5
+
+	// A pending registration, guarded like the rows of user_onboarding
+	var (
+		mu         sync.Mutex
+		state      = "pending_otp"
+		otp        = "123456"
+		verified   string
+		onboarded  []string
+		userLock   sync.Mutex // pg_advisory_xact_lock of the user
+		lockWaits  atomic.Int32
+		released   = make(chan struct{})
+		firstEnded sync.Once
+	)
+	q := &fakeQuerier{
+		lockOnboarding: func(conn db.DBTX, ghUsername string) error {
+			lockWaits.Add(1)
+			userLock.Lock()
+			onTxEnd(conn, func() {
+				firstEnded.Do(func() { close(released) })
+				userLock.Unlock()
+			})
+			return nil
+		},
+		checkOtpReplay: func(_ db.DBTX, arg db.CheckOtpReplayQueryParams) (string, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if state == "onboarded" && verified == arg.Otp {
+				return arg.Ghusername, nil
+			}
+			return "", pgx.ErrNoRows
+		},
+		fetchOnboardingState: func(db.DBTX, string) (string, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			return state, nil
+		},
+		verifyOtp: func(_ db.DBTX, arg db.VerifyOtpQueryParams) (db.VerifyOtpQueryRow, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if state != "pending_otp" || arg.Otp != otp {
+				return db.VerifyOtpQueryRow{}, pgx.ErrNoRows
+			}
+			state, verified, otp = "onboarded", otp, ""
+			return db.VerifyOtpQueryRow{Email: "octo@example.com", Ghusername: arg.Ghusername}, nil
+		},
+		createUserAccount: func(_ db.DBTX, arg db.CreateUserAccountQueryParams) (string, error) {
+			// Keeps the first attempt inside its transaction while the others
+			// arrive, which they never do without the lock
+			deadline := time.Now().Add(200 * time.Millisecond)
+			for lockWaits.Load() < attempts && time.Now().Before(deadline) {
+				time.Sleep(time.Millisecond)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			onboarded = append(onboarded, arg.Ghusername)
+			return arg.Ghusername, nil
+		},
+		countOnboardedToday: func(db.DBTX) (int64, error) { return 2, nil },
+	}
+	a := newTestApp(q)
+	a.DB = lockingPool{fakePool{name: "primary"}}
+
+	router := gin.New()
+	router.POST("/api/v1/auth/register/otp/verify", func(c *gin.Context) {
+		c.Set("username", "octocat")
+	}, a.RegisterUserOtpVerify)
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	statuses := make([]int, attempts)
+	names := make([]string, attempts)
+	for i := range attempts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/register/otp/verify",
+				strings.NewReader(`{"otp": "123456"}`))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			statuses[i] = w.Code
+			var body struct {
+				GhUsername string `json:"github_username"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &body)
+			names[i] = body.GhUsername
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	select {
+	case <-released:
+	default:
+		t.Fatal("the onboarding lock was never released")
+	}
+	if len(onboarded) != 1 {
+		t.Errorf("onboarded %d times, want exactly once", len(onboarded))
+	}
+	for i := range attempts {
+		if statuses[i] != http.StatusOK || names[i] != "octocat" {
+			t.Errorf("attempt %d: status = %d, github_username = %q", i, statuses[i], names[i])
+		}
+	}
+}
+
+func TestOtpResendBySms(t *testing.T) {
+	tests := []struct {
+		name        string
+		otpChannel  string
+		phone       string
+		query       string
+		smsProvider string
+		sendErr     error
+		wantStatus  int
+		wantSent    bool
+	}{
+		{name: "registered by SMS", otpChannel: "sms", phone: "+919876543210",
+			smsProvider: "twilio", wantStatus: http.StatusOK, wantSent: true},
+		{name: "SMS asked for", otpChannel: "sms", phone: "+919876543210", query: "?channel=sms",
+			smsProvider: "twilio", wantStatus: http.StatusOK, wantSent: true},
+		{name: "registered by mail", otpChannel: "email", phone: "+919876543210", query: "?channel=sms",
+			smsProvider: "twilio", wantStatus: http.StatusUnprocessableEntity},
+		{name: "country not allowed", otpChannel: "sms", phone: "+447700900123", query: "?channel=sms",
+			smsProvider: "twilio", wantStatus: http.StatusUnprocessableEntity},
+		{name: "SMS disabled", otpChannel: "sms", phone: "+919876543210", query: "?channel=sms",
+			wantStatus: http.StatusUnprocessableEntity},
+		{name: "sender failing", otpChannel: "sms", phone: "+919876543210",
+			smsProvider: "twilio", sendErr: errors.New("twilio down"),
+			wantStatus: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := testEnv()
+			env.SmsProvider = tt.smsProvider
+			env.SmsCountryCodes = []string{"+91"}
+			env.OtpMaxResends = 3
+			env.OtpResendCooldown = time.Minute
+
+			var incremented bool
+			q := &fakeQuerier{
+				fetchOnboardingState: func(db.DBTX, string) (string, error) {
+					return "pending_otp", nil
+				},
+				checkForExistingOtp: func(db.DBTX, string) (db.CheckForExistingOtpQueryRow, error) {
+					return db.CheckForExistingOtpQueryRow{
+						Email:          "octo@example.com",
+						Otp:            "123456",
+						ResendCount:    1,
+						Phone:          tt.phone,
+						OtpChannel:     tt.otpChannel,
+						SentSecondsAgo: 120,
+					}, nil
+				},
+				incrementOtpResend: func(db.DBTX, db.IncrementOtpResendQueryParams) (int64, error) {
+					incremented = true
+					return 1, nil
+				},
+			}
+			sms := &fakeSMS{err: tt.sendErr}
+			a := newTestApp(q)
+			a.Env = env
+			a.SMS = sms
+
+			router := gin.New()
+			router.GET("/api/v1/auth/register/otp/resend", func(c *gin.Context) {
+				c.Set("username", "octocat")
+			}, a.RegisterUserOtpResend)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet,
+				"/api/v1/auth/register/otp/resend"+tt.query, nil))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantStatus == http.StatusUnprocessableEntity && incremented {
+				t.Error("a refused resend was counted")
+			}
+			if !tt.wantSent {
+				if len(sms.sent) != 0 {
+					t.Errorf("sent %v, want nothing", sms.sent)
+				}
+				return
+			}
+			want := fakeMessage{to: tt.phone, body: pkg.OtpSmsBody("123456")}
+			if len(sms.sent) != 1 || sms.sent[0] != want {
+				t.Errorf("sent %v, want [%v]", sms.sent, want)
+			}
+		})
+	}
+}
+
+func TestOtpVerifyMetrics(t *testing.T) {
+	tests := []struct {
+		name       string
+		otp        string
+		wantStatus int
+		wantResult string
+	}{
+		{name: "success", otp: "123456", wantStatus: http.StatusOK, wantResult: "success"},
+		{name: "failure", otp: "654321", wantStatus: http.StatusForbidden, wantResult: "failure"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &fakeQuerier{
+				lockOnboarding:       func(db.DBTX, string) error { return nil },
+				checkOtpReplay:       func(db.DBTX, db.CheckOtpReplayQueryParams) (string, error) { return "", pgx.ErrNoRows },
+				fetchOnboardingState: func(db.DBTX, string) (string, error) { return "pending_otp", nil },
+				verifyOtp: func(_ db.DBTX, arg db.VerifyOtpQueryParams) (db.VerifyOtpQueryRow, error) {
+					if arg.Otp != "123456" {
+						return db.VerifyOtpQueryRow{}, pgx.ErrNoRows
+					}
+					return db.VerifyOtpQueryRow{
+						Email:      "octo@example.com",
+						Ghusername: arg.Ghusername,
+						CreatedAt:  pkg.NewTimestamp(time.Now().Add(-time.Minute)),
+					}, nil
+				},
+				createUserAccount: func(_ db.DBTX, arg db.CreateUserAccountQueryParams) (string, error) {
+					return arg.Ghusername, nil
+				},
+				countOnboardedToday: func(db.DBTX) (int64, error) { return 2, nil },
+			}
+			a := newTestApp(q)
+			router := gin.New()
+			router.POST("/api/v1/auth/register/otp/verify", func(c *gin.Context) {
+				c.Set("username", "octocat")
+			}, a.RegisterUserOtpVerify)
+
+			before := map[string]float64{}
+			for _, result := range []string{"success", "failure"} {
+				before[result] = testutil.ToFloat64(cmd.OtpVerifyTotal.WithLabelValues(result))
+			}
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/register/otp/verify",
+				strings.NewReader(`{"otp": "`+tt.otp+`"}`))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			for result, count := range before {
+				want := count
+				if result == tt.wantResult {
+					want++
+				}
+				if got := testutil.ToFloat64(cmd.OtpVerifyTotal.WithLabelValues(result)); got != want {
+					t.Errorf("%s count = %v, want %v", result, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckUsernameAvailability(t *testing.T) {
+	tests := []struct {
+		name          string
+		query         string
+		wantStatus    int
+		wantAvailable bool
+	}{
+		{name: "taken", query: "?ghusername=octocat", wantStatus: http.StatusOK, wantAvailable: false},
+		{name: "taken in another case", query: "?ghusername=OctoCat", wantStatus: http.StatusOK, wantAvailable: false},
+		{name: "available", query: "?ghusername=hubot", wantStatus: http.StatusOK, wantAvailable: true},
+		{name: "missing", query: "", wantStatus: http.StatusBadRequest},
+		{name: "too short", query: "?ghusername=ab", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &fakeQuerier{
+				checkUserExist: func(_ db.DBTX, ghUsername string) (db.CheckUserExistQueryRow, error) {
+					if ghUsername != "octocat" {
+						return db.CheckUserExistQueryRow{}, pgx.ErrNoRows
+					}
+					return db.CheckUserExistQueryRow{Ghusername: ghUsername, Email: "octo@example.com"}, nil
+				},
+			}
+			a := newTestApp(q)
+			router := gin.New()
+			router.GET("/api/v1/auth/available", a.CheckUsernameAvailability)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/auth/available"+tt.query, nil))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var body map[string]any
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			// Nothing about the account is disclosed beyond availability
+			if len(body) != 1 || body["available"] != tt.wantAvailable {
+				t.Errorf("body = %v, want only available = %v", body, tt.wantAvailable)
+			}
+		})
+	}
+}
+
+func TestOtpVerifyTwice(t *testing.T) {
+	tests := []struct {
+		name            string
+		uniqueViolation bool // the state still reads pending, so the account insert conflicts
+	}{
+		{name: "onboarded state"},
+		{name: "unique violation", uniqueViolation: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := "pending_otp"
+			accounts := 0
+			q := &fakeQuerier{
+				lockOnboarding: func(db.DBTX, string) error { return nil },
+				// Outside the replay window the first success is not repeated
+				checkOtpReplay: func(db.DBTX, db.CheckOtpReplayQueryParams) (string, error) { return "", pgx.ErrNoRows },
+				fetchOnboardingState: func(db.DBTX, string) (string, error) {
+					if tt.uniqueViolation {
+						return "pending_otp", nil
+					}
+					return state, nil
+				},
+				verifyOtp: func(_ db.DBTX, arg db.VerifyOtpQueryParams) (db.VerifyOtpQueryRow, error) {
+					return db.VerifyOtpQueryRow{Email: "octo@example.com", Ghusername: arg.Ghusername}, nil
+				},
+				createUserAccount: func(_ db.DBTX, arg db.CreateUserAccountQueryParams) (string, error) {
+					if accounts > 0 {
+						return "", &pgconn.PgError{Code: "23505"}
+					}
+					accounts++
+					state = "onboarded"
+					return arg.Ghusername, nil
+				},
+				countOnboardedToday: func(db.DBTX) (int64, error) { return 2, nil },
+			}
+			a := newTestApp(q)
+			router := gin.New()
+			router.POST("/api/v1/auth/register/otp/verify", func(c *gin.Context) {
+				c.Set("username", "octocat")
+			}, a.RegisterUserOtpVerify)
+
+			var statuses []int
+			var body struct {
+				Message    string `json:"message"`
+				GhUsername string `json:"github_username"`
+			}
+			for range 2 {
+				req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/register/otp/verify",
+					strings.NewReader(`{"otp": "123456"}`))
+				req.Header.Set("Content-Type", "application/json")
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+				statuses = append(statuses, w.Code)
+				body.GhUsername = ""
+				json.Unmarshal(w.Body.Bytes(), &body)
+			}
+
+			if statuses[0] != http.StatusOK || statuses[1] != http.StatusConflict {
+				t.Fatalf("statuses = %v, want [200 409]", statuses)
+			}
+			if body.GhUsername != "octocat" {
+				t.Errorf("second response github_username = %q, want the existing username", body.GhUsername)
+			}
+			if accounts != 1 {
+				t.Errorf("created %d accounts, want 1", accounts)
+			}
+		})
+	}
+}
+
+// A registration in user_onboarding with its account, replayed the way
+// CheckOtpReplayQuery replays it
+type otpRegistration struct {
+	state      string
+	otp        string // kept after verification, as its hash is
+	verifiedAt time.Time
+	active     bool // the created account
+	accounts   int
+}
+
+func (r *otpRegistration) querier() *fakeQuerier {
+	return &fakeQuerier{
+		lockOnboarding: func(db.DBTX, string) error { return nil },
+		checkOtpReplay: func(_ db.DBTX, arg db.CheckOtpReplayQueryParams) (string, error) {
+			window := time.Duration(arg.Window.Microseconds) * time.Microsecond
+			if r.state != "onboarded" || !strings.EqualFold(arg.Otp, r.otp) ||
+				!r.verifiedAt.After(time.Now().Add(-window)) || !r.active {
+				return "", pgx.ErrNoRows
+			}
+			return arg.Ghusername, nil
+		},
+		fetchOnboardingState: func(db.DBTX, string) (string, error) { return r.state, nil },
+		verifyOtp: func(_ db.DBTX, arg db.VerifyOtpQueryParams) (db.VerifyOtpQueryRow, error) {
+			if r.state != "pending_otp" || arg.Otp != r.otp {
+				return db.VerifyOtpQueryRow{}, pgx.ErrNoRows
+			}
+			r.state, r.verifiedAt = "onboarded", time.Now()
+			return db.VerifyOtpQueryRow{Email: "octo@example.com", Ghusername: arg.Ghusername}, nil
+		},
+		createUserAccount: func(_ db.DBTX, arg db.CreateUserAccountQueryParams) (string, error) {
+			if r.accounts > 0 {
+				return "", &pgconn.PgError{Code: "23505"}
+			}
+			r.accounts++
+			r.active = true
+			return arg.Ghusername, nil
+		},
+		countOnboardedToday: func(db.DBTX) (int64, error) { return 2, nil },
+	}
+}
+
+func TestOtpVerifyRetry(t *testing.T) {
+	tests := []struct {
+		name       string
+		retryOtp   string
+		after      func(r *otpRegistration) // between the success and the retry
+		wantStatus int
+	}{
+		{name: "same OTP", retryOtp: "123456", wantStatus: http.StatusOK},
+		{name: "same OTP near the end of the window", retryOtp: "123456", wantStatus: http.StatusOK,
+			after: func(r *otpRegistration) { r.verifiedAt = r.verifiedAt.Add(-9 * time.Minute) }},
+		{name: "same OTP after the window", retryOtp: "123456", wantStatus: http.StatusConflict,
+			after: func(r *otpRegistration) { r.verifiedAt = r.verifiedAt.Add(-11 * time.Minute) }},
+		{name: "another OTP", retryOtp: "654321", wantStatus: http.StatusConflict},
+		{name: "account suspended since", retryOtp: "123456", wantStatus: http.StatusConflict,
+			after: func(r *otpRegistration) { r.active = false }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registration := &otpRegistration{state: "pending_otp", otp: "123456"}
+			a := newTestApp(registration.querier())
+			router := gin.New()
+			router.POST("/api/v1/auth/register/otp/verify", func(c *gin.Context) {
+				c.Set("username", "octocat")
+			}, a.RegisterUserOtpVerify)
+			verify := func(otp string) *httptest.ResponseRecorder {
+				req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/register/otp/verify",
+					strings.NewReader(`{"otp": "`+otp+`"}`))
+				req.Header.Set("Content-Type", "application/json")
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+				return w
+			}
+
+			first := verify("123456")
+			if first.Code != http.StatusOK {
+				t.Fatalf("first status = %d: %s", first.Code, first.Body.String())
+			}
+			if tt.after != nil {
+				tt.after(registration)
+			}
+			retry := verify(tt.retryOtp)
+
+			if retry.Code != tt.wantStatus {
+				t.Fatalf("retry status = %d, want %d: %s", retry.Code, tt.wantStatus, retry.Body.String())
+			}
+			if tt.wantStatus == http.StatusOK && retry.Body.String() != first.Body.String() {
+				t.Errorf("retry body = %s, want the original %s", retry.Body.String(), first.Body.String())
+			}
+			if registration.accounts != 1 {
+				t.Errorf("created %d accounts, want 1", registration.accounts)
+			}
+		})
+	}
+}
+
+func TestOtpVerifyAlphanumeric(t *testing.T) {
+	tests := []struct {
+		name         string
+		stored       string
+		otp          string
+		alphanumeric bool
+		wantStatus   int
+	}{
+		{name: "mixed case", stored: "abc123", otp: "AbC123", alphanumeric: true, wantStatus: http.StatusOK},
+		{name: "upper case", stored: "abc123", otp: "ABC123", alphanumeric: true, wantStatus: http.StatusOK},
+		{name: "wrong letter", stored: "abc123", otp: "AbD123", alphanumeric: true, wantStatus: http.StatusForbidden},
+		// Letters are not valid in numeric mode, whatever their case
+		{name: "numeric mode", stored: "abc123", otp: "abc123", wantStatus: http.StatusBadRequest},
+		{name: "numeric", stored: "123456", otp: "123456", wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var presented string
+			q := &fakeQuerier{
+				lockOnboarding:       func(db.DBTX, string) error { return nil },
+				checkOtpReplay:       func(db.DBTX, db.CheckOtpReplayQueryParams) (string, error) { return "", pgx.ErrNoRows },
+				fetchOnboardingState: func(db.DBTX, string) (string, error) { return "pending_otp", nil },
+				verifyOtp: func(_ db.DBTX, arg db.VerifyOtpQueryParams) (db.VerifyOtpQueryRow, error) {
+					presented = arg.Otp
+					// LOWER(otp) = LOWER($2)
+					if !strings.EqualFold(arg.Otp, tt.stored) {
+						return db.VerifyOtpQueryRow{}, pgx.ErrNoRows
+					}
+					return db.VerifyOtpQueryRow{
+						Email:      "octo@example.com",
+						Ghusername: arg.Ghusername,
+						CreatedAt:  pkg.NewTimestamp(time.Now().Add(-time.Minute)),
+					}, nil
+				},
+				createUserAccount: func(_ db.DBTX, arg db.CreateUserAccountQueryParams) (string, error) {
+					return arg.Ghusername, nil
+				},
+				countOnboardedToday: func(db.DBTX) (int64, error) { return 1, nil },
+			}
+			a := newTestApp(q)
+			a.Env = testEnv()
+			a.Env.OtpAlphanumeric = tt.alphanumeric
+
+			w := serveAs("octocat", http.MethodPost, a.RegisterUserOtpVerify, `{"otp": "`+tt.otp+`"}`)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			// Presented in the stored form, so that the hash of a used OTP
+			// matches whatever case it is retried in
+			if tt.alphanumeric && presented != strings.ToLower(tt.otp) {
+				t.Errorf("OTP queried as %q, want %q", presented, strings.ToLower(tt.otp))
+			}
+		})
+	}
+}
+
+func TestReissueTempToken(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		pending    bool // whether octocat's registration awaits its OTP
+		wantStatus int
+	}{
+		{
+			name:       "pending registration",
+			body:       `{"email": "octo@example.com", "github_username": "octocat", "otp": "123456"}`,
+			pending:    true,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "normalized before matching",
+			body:       `{"email": " Octo@Example.com ", "github_username": "OctoCat", "otp": " 123456 "}`,
+			pending:    true,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "wrong OTP",
+			body:       `{"email": "octo@example.com", "github_username": "octocat", "otp": "654321"}`,
+			pending:    true,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "other user's email",
+			body:       `{"email": "hubot@example.com", "github_username": "octocat", "otp": "123456"}`,
+			pending:    true,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "no pending registration",
+			body:       `{"email": "octo@example.com", "github_username": "octocat", "otp": "123456"}`,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "missing OTP",
+			body:       `{"email": "octo@example.com", "github_username": "octocat"}`,
+			pending:    true,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &fakeQuerier{
+				checkPendingRegistration: func(_ db.DBTX, arg db.CheckPendingRegistrationQueryParams) (bool, error) {
+					return tt.pending && arg.Ghusername == "octocat" && arg.Email == "octo@example.com" &&
+						arg.Otp == "123456", nil
+				},
+			}
+			a := newTestApp(q)
+			a.Env = testEnv()
+
+			w := serveAs("", http.MethodPost, a.ReissueTempToken, tt.body)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			var resp struct {
+				AccessKey string `json:"access_key"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantStatus != http.StatusOK {
+				if resp.AccessKey != "" {
+					t.Errorf("token issued with status %d", w.Code)
+				}
+				return
+			}
+			claims, err := pkg.VerifyToken(a.Env, resp.AccessKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims.Subject != "temp_token" || claims.Email != "octo@example.com" ||
+				!slices.Equal([]string(claims.Audience), []string{"octocat"}) {
+				t.Errorf("claims = %s %s %v", claims.Subject, claims.Email, claims.Audience)
+			}
+		})
+	}
+}
+
+func TestOtpResendCap(t *testing.T) {
+	const maxResends = 3
+	env := testEnv()
+	env.SmsProvider = "twilio"
+	env.SmsCountryCodes = []string{"+91"}
+	env.OtpMaxResends = maxResends
+	env.OtpResendCooldown = 0
+
+	// resend_count of octocat's registration
+	var resendCount int32
+	q := &fakeQuerier{
+		fetchOnboardingState: func(db.DBTX, string) (string, error) {
+			return "pending_otp", nil
+		},
+		checkForExistingOtp: func(db.DBTX, string) (db.CheckForExistingOtpQueryRow, error) {
+			return db.CheckForExistingOtpQueryRow{
+				Email:          "octo@example.com",
+				Otp:            "123456",
+				ResendCount:    resendCount,
+				Phone:          "+919876543210",
+				OtpChannel:     pkg.OtpChannelSms,
+				SentSecondsAgo: 3600,
+			}, nil
+		},
+		incrementOtpResend: func(_ db.DBTX, arg db.IncrementOtpResendQueryParams) (int64, error) {
+			if resendCount >= arg.MaxResends {
+				return 0, nil
+			}
+			resendCount++
+			return 1, nil
+		},
+	}
+	sms := &fakeSMS{}
+	a := newTestApp(q)
+	a.Env = env
+	a.SMS = sms
+
+	for attempt := 1; attempt <= maxResends+2; attempt++ {
+		w := serveAs("octocat", http.MethodGet, a.RegisterUserOtpResend, "")
+
+		wantStatus := http.StatusOK
+		if attempt > maxResends {
+			wantStatus = http.StatusConflict
+		}
+		if w.Code != wantStatus {
+			t.Fatalf("resend %d status = %d, want %d: %s", attempt, w.Code, wantStatus, w.Body.String())
+		}
+		if wantStatus == http.StatusConflict &&
+			!strings.Contains(w.Body.String(), "Please restart registration.") {
+			t.Errorf("resend %d body = %s", attempt, w.Body.String())
+		}
+	}
+	if resendCount != maxResends {
+		t.Errorf("resend_count = %d, want %d", resendCount, maxResends)
+	}
+	if len(sms.sent) != maxResends {
+		t.Errorf("sent %d OTPs, want %d", len(sms.sent), maxResends)
+	}
+}
+
+func TestOtpVerifyKeepsLocale(t *testing.T) {
+	registration := &otpRegistration{state: "pending_otp", otp: "123456"}
+	q := registration.querier()
+	verifyOtp, createUserAccount := q.verifyOtp, q.createUserAccount
+	q.verifyOtp = func(conn db.DBTX, arg db.VerifyOtpQueryParams) (db.VerifyOtpQueryRow, error) {
+		row, err := verifyOtp(conn, arg)
+		row.Timezone, row.Locale = "Asia/Kolkata", "en-IN"
+		return row, err
+	}
+	var created db.CreateUserAccountQueryParams
+	q.createUserAccount = func(conn db.DBTX, arg db.CreateUserAccountQueryParams) (string, error) {
+		created = arg
+		return createUserAccount(conn, arg)
+	}
+	a := newTestApp(q)
+	router := gin.New()
+	router.POST("/api/v1/auth/register/otp/verify", func(c *gin.Context) {
+		c.Set("username", "octocat")
+	}, a.RegisterUserOtpVerify)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/register/otp/verify",
+		strings.NewReader(`{"otp": "123456"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	// The time zone and locale given at registration move to the account
+	if created.Timezone != "Asia/Kolkata" || created.Locale != "en-IN" {
+		t.Errorf("account created with timezone %q, locale %q", created.Timezone, created.Locale)
+	}
+}
+
+// Records the level of every log line
+type levelLogger struct {
+	mu     sync.Mutex
+	levels []string
+}
+
+func (l *levelLogger) log(level string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.levels = append(l.levels, level)
+}
+
+func (l *levelLogger) Debug(string)        { l.log("debug") }
+func (l *levelLogger) Info(string)         { l.log("info") }
+func (l *levelLogger) Warn(string)         { l.log("warn") }
+func (l *levelLogger) Error(string, error) { l.log("error") }
+func (l *levelLogger) Fatal(string, error) { l.log("fatal") }
+
+func TestRegisterTokenFailure(t *testing.T) {
+	github := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer github.Close()
+	log := &levelLogger{}
+	// Any query panics, so a failed token must stop the registration first
+	a := newTestApp(&fakeQuerier{})
+	a.Env = testEnv()
+	a.Env.GhApiUrl = github.URL
+	a.Env.TokenSecret = ""
+	a.Log = log
+	router := gin.New()
+	router.POST("/api/v1/auth/register", a.RegisterUserAccount)
+
+	// The server keeps answering after a failure
+	for range 2 {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/register", strings.NewReader(
+			`{"email": "octo@example.com", "github_username": "octocat", "first_name": "Octo", `+
+				`"middle_name": "The", "last_name": "Cat"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusInternalServerError {
+			t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusInternalServerError, w.Body.String())
+		}
+		if strings.Contains(w.Body.String(), "access_key") {
+			t.Errorf("body = %s, want no token", w.Body.String())
+		}
+	}
+	if want := []string{"error", "error"}; !slices.Equal(log.levels, want) {
+		t.Errorf("logged %v, want %v", log.levels, want)
+	}
+}
@@ -0,0 +1,443 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	db "github.com/IAmRiteshKoushik/pulse/db/gen"
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	"github.com/IAmRiteshKoushik/pulse/types"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+func (a *App) TransferBounty(c *gin.Context) {
+	username, ok := pkg.GrabUsername(c)
+	if !ok {
+		a.Log.Warn(
+			fmt.Sprintf("Failed to extract username from token at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Oops! Something happened. Please try again later.",
+		})
+		return
+	}
+
+	var body types.BountyTransferRequest
+	if err := c.BindJSON(&body); err != nil {
+		pkg.JSONUnmarshallError(c, err)
+		return
+	}
+	if err := body.Validate(); err != nil {
+		pkg.RequestValidatorError(c, err)
+		return
+	}
+	if body.ToGhUsername == username {
+		a.Log.Warn(
+			fmt.Sprintf("User attempted self-transfer of bounty at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Cannot transfer bounty to yourself",
+		})
+		return
+	}
+
+	if body.Amount > a.Env.BountyMaxPerTransaction {
+		a.Log.Warn(
+			fmt.Sprintf("Bounty transfer exceeding per-transaction limit at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": fmt.Sprintf("Amount must not exceed %d", a.Env.BountyMaxPerTransaction),
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), a.Env.TimeoutFor("bounty"))
+	defer cancel()
+
+	tx, err := a.DB.Begin(ctx)
+	if err != nil {
+		pkg.DbAcquireError(c, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	q := a.Queries
+	debit := func() error {
+		_, err := q.DebitBountyQuery(ctx, tx, db.DebitBountyQueryParams{
+			Amount:     body.Amount,
+			Ghusername: username,
+		})
+		return err
+	}
+	var creditedTotal int32
+	credit := func() error {
+		var err error
+		creditedTotal, err = q.CreditBountyQuery(ctx, tx, db.CreditBountyQueryParams{
+			Amount:     body.Amount,
+			Ghusername: body.ToGhUsername,
+		})
+		return err
+	}
+
+	// Rows are always locked in username order so that two opposing transfers
+	// cannot deadlock each other
+	var debitErr, creditErr error
+	if username < body.ToGhUsername {
+		if debitErr = debit(); debitErr == nil {
+			creditErr = credit()
+		}
+	} else {
+		if creditErr = credit(); creditErr == nil {
+			debitErr = debit()
+		}
+	}
+
+	if errors.Is(creditErr, pgx.ErrNoRows) {
+		a.Log.Warn(
+			fmt.Sprintf("Bounty transfer to unknown user at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Recipient not found",
+		})
+		return
+	}
+	if errors.Is(debitErr, pgx.ErrNoRows) {
+		a.Log.Warn(
+			fmt.Sprintf("Insufficient bounty for transfer at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Insufficient bounty balance",
+		})
+		return
+	}
+	if err := errors.Join(debitErr, creditErr); err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+	// Transaction is rolled back on return, undoing the credit
+	if creditedTotal > a.Env.BountyMaxTotal {
+		a.Log.Warn(
+			fmt.Sprintf("Bounty transfer exceeding recipient cap at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Recipient would exceed the maximum bounty allowed",
+		})
+		return
+	}
+
+	err = q.AddBountyLedgerQuery(ctx, tx, db.AddBountyLedgerQueryParams{
+		Ghusername:   username,
+		Amount:       -body.Amount,
+		Kind:         "transfer_out",
+		Counterparty: body.ToGhUsername,
+	})
+	if err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+	err = q.AddBountyLedgerQuery(ctx, tx, db.AddBountyLedgerQueryParams{
+		Ghusername:   body.ToGhUsername,
+		Amount:       body.Amount,
+		Kind:         "transfer_in",
+		Counterparty: username,
+	})
+	if err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Bounty transferred successfully",
+		"to":      body.ToGhUsername,
+		"amount":  body.Amount,
+	})
+	a.Log.Info(fmt.Sprintf(
+		"[SUCCESS]: Processed request at %s %s",
+		c.Request.Method, c.FullPath(),
+	))
+	return
+}
+
+// Awards bounty to a contributor for work on a project. The balance is
+// incremented in SQL so that concurrent awards never lose an update.
+func (a *App) AwardBounty(c *gin.Context) {
+	username, ok := pkg.GrabUsername(c)
+	if !ok {
+		a.Log.Warn(
+			fmt.Sprintf("Failed to extract username from token at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Oops! Something happened. Please try again later.",
+		})
+		return
+	}
+
+	var body types.BountyAwardRequest
+	if err := c.BindJSON(&body); err != nil {
+		pkg.JSONUnmarshallError(c, err)
+		return
+	}
+	if err := body.Validate(); err != nil {
+		pkg.RequestValidatorError(c, err)
+		return
+	}
+	repoId := uuid.MustParse(body.RepoId) // validated above
+
+	if body.Amount > a.Env.BountyMaxPerTransaction {
+		a.Log.Warn(
+			fmt.Sprintf("Bounty award exceeding per-transaction limit at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": fmt.Sprintf("Amount must not exceed %d", a.Env.BountyMaxPerTransaction),
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), a.Env.TimeoutFor("bounty"))
+	defer cancel()
+
+	tx, err := a.DB.Begin(ctx)
+	if err != nil {
+		pkg.DbAcquireError(c, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	q := a.Queries
+	exists, err := q.CheckIfProjectExistsQuery(ctx, tx, repoId)
+	if err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+	if !exists {
+		a.Log.Warn(
+			fmt.Sprintf("[INVALID-ID]: Bounty award for unknown project at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Project not found",
+		})
+		return
+	}
+
+	total, err := q.CreditBountyQuery(ctx, tx, db.CreditBountyQueryParams{
+		Amount:     body.Amount,
+		Ghusername: body.GhUsername,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		a.Log.Warn(
+			fmt.Sprintf("Bounty award to unknown user at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Recipient not found",
+		})
+		return
+	}
+	if err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+	// Transaction is rolled back on return, undoing the credit
+	if total > a.Env.BountyMaxTotal {
+		a.Log.Warn(
+			fmt.Sprintf("Bounty award exceeding recipient cap at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"message": "Recipient would exceed the maximum bounty allowed",
+		})
+		return
+	}
+
+	err = q.AddBountyLogQuery(ctx, tx, db.AddBountyLogQueryParams{
+		Ghusername:   body.GhUsername,
+		DispatchedBy: username,
+		ProofUrl:     body.ProofUrl,
+		RepoID:       repoId,
+		Amount:       body.Amount,
+	})
+	if err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+	err = q.AddBountyLedgerQuery(ctx, tx, db.AddBountyLedgerQueryParams{
+		Ghusername:   body.GhUsername,
+		Amount:       body.Amount,
+		Kind:         "award",
+		Counterparty: username,
+	})
+	if err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+	err = q.AddAuditLogQuery(ctx, tx, db.AddAuditLogQueryParams{
+		Actor:   username,
+		Action:  "bounty.award",
+		Target:  body.GhUsername,
+		Details: fmt.Sprintf("amount=%d repo_id=%s proof_url=%s", body.Amount, repoId, body.ProofUrl),
+	})
+	if err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Bounty awarded successfully",
+		"to":      body.GhUsername,
+		"amount":  body.Amount,
+		"bounty":  total,
+	})
+	a.Log.Info(fmt.Sprintf(
+		"[SUCCESS]: Processed request at %s %s",
+		c.Request.Method, c.FullPath(),
+	))
+	return
+}
+
+// Awards bounty to every row of an uploaded CSV. Each row runs in its own
+// savepoint so that a failing row is reported without undoing the others.
+func (a *App) AwardBountyBatch(c *gin.Context) {
+	username, ok := pkg.GrabUsername(c)
+	if !ok {
+		a.Log.Warn(
+			fmt.Sprintf("Failed to extract username from token at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Oops! Something happened. Please try again later.",
+		})
+		return
+	}
+
+	// Accepts either a multipart upload in "file" or a raw text/csv body
+	var csvFile io.Reader = c.Request.Body
+	if file, err := c.FormFile("file"); err == nil {
+		f, err := file.Open()
+		if err != nil {
+			pkg.RequestValidatorError(c, err)
+			return
+		}
+		defer f.Close()
+		csvFile = f
+	}
+	rows, err := types.ParseBountyBatchCSV(csvFile)
+	if err != nil {
+		pkg.RequestValidatorError(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), a.Env.TimeoutFor("bounty_batch"))
+	defer cancel()
+
+	tx, err := a.DB.Begin(ctx)
+	if err != nil {
+		pkg.DbAcquireError(c, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	results := make([]types.BountyBatchResult, 0, len(rows))
+	succeeded := 0
+	for _, row := range rows {
+		result := types.BountyBatchResult{
+			Line:       row.Line,
+			GhUsername: row.GhUsername,
+			Amount:     row.Amount,
+			Status:     "failed",
+		}
+		rowErr, err := a.awardBatchRow(ctx, tx, username, row)
+		if err != nil {
+			pkg.DbError(c, err)
+			return
+		}
+		if rowErr != "" {
+			result.Error = rowErr
+		} else {
+			result.Status = "awarded"
+			succeeded++
+		}
+		results = append(results, result)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Bounty batch processed",
+		"succeeded": succeeded,
+		"failed":    len(rows) - succeeded,
+		"results":   results,
+	})
+	a.Log.Info(fmt.Sprintf(
+		"[SUCCESS]: Processed request at %s %s",
+		c.Request.Method, c.FullPath(),
+	))
+	return
+}
+
+// Returns a message describing why the row was rejected, or an error when
+// the database itself failed and the batch must be aborted
+func (a *App) awardBatchRow(ctx context.Context, tx pgx.Tx, actor string,
+	row types.BountyBatchRow) (string, error) {
+	if row.Err != nil {
+		return row.Err.Error(), nil
+	}
+	if row.Amount > a.Env.BountyMaxPerTransaction {
+		return fmt.Sprintf("amount must not exceed %d", a.Env.BountyMaxPerTransaction), nil
+	}
+
+	savepoint, err := tx.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer savepoint.Rollback(ctx)
+
+	q := a.Queries
+	total, err := q.CreditBountyQuery(ctx, savepoint, db.CreditBountyQueryParams{
+		Amount:     row.Amount,
+		Ghusername: row.GhUsername,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "user not found", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if total > a.Env.BountyMaxTotal {
+		return "recipient would exceed the maximum bounty allowed", nil
+	}
+
+	err = q.AddBountyLedgerQuery(ctx, savepoint, db.AddBountyLedgerQueryParams{
+		Ghusername:   row.GhUsername,
+		Amount:       row.Amount,
+		Kind:         "award",
+		Counterparty: actor,
+	})
+	if err != nil {
+		return "", err
+	}
+	err = q.AddAuditLogQuery(ctx, savepoint, db.AddAuditLogQueryParams{
+		Actor:   actor,
+		Action:  "bounty.award",
+		Target:  row.GhUsername,
+		Details: fmt.Sprintf("amount=%d reason=%q batch_line=%d", row.Amount, row.Reason, row.Line),
+	})
+	if err != nil {
+		return "", err
+	}
+	return "", savepoint.Commit(ctx)
+}
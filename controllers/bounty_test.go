@@ -0,0 +1,510 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	db "github.com/IAmRiteshKoushik/pulse/db/gen"
+	"github.com/IAmRiteshKoushik/pulse/types"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// The only project bounty can be awarded for
+var bountyRepoId = uuid.MustParse("8f6b2c1e-4d3a-4b5c-9e7f-0a1b2c3d4e5f")
+
+// Bounty balances of user_account and the rows of bounty_ledger. Balances
+// change by deltas, like the UPDATEs of the bounty queries, which are applied
+// when the transaction commits.
+type bank struct {
+	mu       sync.Mutex
+	balances map[string]int32
+	ledger   []db.AddBountyLedgerQueryParams
+	locked   []string // users in the order their rows were locked
+}
+
+func newBank(balances map[string]int32) *bank {
+	return &bank{balances: balances}
+}
+
+func (b *bank) balance(username string) int32 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.balances[username]
+}
+
+func (b *bank) querier() *fakeQuerier {
+	return &fakeQuerier{
+		debitBounty: func(conn db.DBTX, arg db.DebitBountyQueryParams) (int32, error) {
+			tx := conn.(*bankTx)
+			balance, ok := tx.lock(arg.Ghusername)
+			if !ok || balance < arg.Amount {
+				return 0, pgx.ErrNoRows
+			}
+			tx.deltas[arg.Ghusername] -= arg.Amount
+			return balance - arg.Amount, nil
+		},
+		creditBounty: func(conn db.DBTX, arg db.CreditBountyQueryParams) (int32, error) {
+			tx := conn.(*bankTx)
+			balance, ok := tx.lock(arg.Ghusername)
+			if !ok {
+				return 0, pgx.ErrNoRows
+			}
+			tx.deltas[arg.Ghusername] += arg.Amount
+			return balance + arg.Amount, nil
+		},
+		addBountyLedger: func(conn db.DBTX, arg db.AddBountyLedgerQueryParams) error {
+			tx := conn.(*bankTx)
+			tx.ledger = append(tx.ledger, arg)
+			return nil
+		},
+		addBountyLog: func(db.DBTX, db.AddBountyLogQueryParams) error { return nil },
+		addAuditLog:  func(db.DBTX, db.AddAuditLogQueryParams) error { return nil },
+		checkIfProjectExists: func(_ db.DBTX, id uuid.UUID) (bool, error) {
+			return id == bountyRepoId, nil
+		},
+	}
+}
+
+type bankTx struct {
+	fakeTx
+	bank   *bank
+	parent *bankTx // of a savepoint
+	deltas map[string]int32
+	ledger []db.AddBountyLedgerQueryParams
+}
+
+func (b *bank) begin(parent *bankTx) *bankTx {
+	return &bankTx{bank: b, parent: parent, deltas: map[string]int32{}}
+}
+
+func (tx *bankTx) Begin(ctx context.Context) (pgx.Tx, error) { return tx.bank.begin(tx), nil }
+
+func (tx *bankTx) Commit(ctx context.Context) error {
+	if tx.parent != nil {
+		for username, delta := range tx.deltas {
+			tx.parent.deltas[username] += delta
+		}
+		tx.parent.ledger = append(tx.parent.ledger, tx.ledger...)
+	} else {
+		tx.bank.mu.Lock()
+		for username, delta := range tx.deltas {
+			tx.bank.balances[username] += delta
+		}
+		tx.bank.ledger = append(tx.bank.ledger, tx.ledger...)
+		tx.bank.mu.Unlock()
+	}
+	tx.deltas, tx.ledger = map[string]int32{}, nil
+	return nil
+}
+
+func (tx *bankTx) Rollback(ctx context.Context) error {
+	tx.deltas, tx.ledger = map[string]int32{}, nil
+	return nil
+}
+
+// Locks the row of the user, returning the balance as seen by tx
+func (tx *bankTx) lock(username string) (int32, bool) {
+	tx.bank.mu.Lock()
+	defer tx.bank.mu.Unlock()
+	tx.bank.locked = append(tx.bank.locked, username)
+	balance, ok := tx.bank.balances[username]
+	for t := tx; t != nil; t = t.parent {
+		balance += t.deltas[username]
+	}
+	return balance, ok
+}
+
+type bankPool struct {
+	fakePool
+	bank *bank
+}
+
+func (p bankPool) Begin(ctx context.Context) (pgx.Tx, error) { return p.bank.begin(nil), nil }
+
+func newBankApp(b *bank) *App {
+	a := newTestApp(b.querier())
+	a.DB = bankPool{fakePool: fakePool{name: "primary"}, bank: b}
+	a.Env = testEnv()
+	a.Env.BountyMaxPerTransaction = 1000
+	a.Env.BountyMaxTotal = 1_000_000
+	return a
+}
+
+// Serves handler as username
+func serveAs(username string, method string, handler gin.HandlerFunc, body string) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.Handle(method, "/", func(c *gin.Context) {
+		c.Set("username", username)
+	}, handler)
+	req := httptest.NewRequest(method, "/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestTransferBounty(t *testing.T) {
+	tests := []struct {
+		name         string
+		from         string
+		body         string
+		wantStatus   int
+		wantBalances map[string]int32
+		wantLedger   int
+	}{
+		{
+			name:         "transferred",
+			from:         "alice",
+			body:         `{"to_ghusername": "bob", "amount": 30}`,
+			wantStatus:   http.StatusOK,
+			wantBalances: map[string]int32{"alice": 70, "bob": 80},
+			wantLedger:   2,
+		},
+		{
+			name:         "whole balance",
+			from:         "bob",
+			body:         `{"to_ghusername": "alice", "amount": 50}`,
+			wantStatus:   http.StatusOK,
+			wantBalances: map[string]int32{"alice": 150, "bob": 0},
+			wantLedger:   2,
+		},
+		{
+			name:         "insufficient balance",
+			from:         "alice",
+			body:         `{"to_ghusername": "bob", "amount": 101}`,
+			wantStatus:   http.StatusUnprocessableEntity,
+			wantBalances: map[string]int32{"alice": 100, "bob": 50},
+		},
+		{
+			name:         "insufficient balance credited first",
+			from:         "bob",
+			body:         `{"to_ghusername": "alice", "amount": 51}`,
+			wantStatus:   http.StatusUnprocessableEntity,
+			wantBalances: map[string]int32{"alice": 100, "bob": 50},
+		},
+		{
+			name:         "self-transfer",
+			from:         "alice",
+			body:         `{"to_ghusername": "Alice", "amount": 10}`,
+			wantStatus:   http.StatusBadRequest,
+			wantBalances: map[string]int32{"alice": 100, "bob": 50},
+		},
+		{
+			name:         "unknown recipient",
+			from:         "alice",
+			body:         `{"to_ghusername": "carol", "amount": 10}`,
+			wantStatus:   http.StatusNotFound,
+			wantBalances: map[string]int32{"alice": 100, "bob": 50},
+		},
+		{
+			name:         "zero amount",
+			from:         "alice",
+			body:         `{"to_ghusername": "bob", "amount": 0}`,
+			wantStatus:   http.StatusBadRequest,
+			wantBalances: map[string]int32{"alice": 100, "bob": 50},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBank(map[string]int32{"alice": 100, "bob": 50})
+			a := newBankApp(b)
+
+			w := serveAs(tt.from, http.MethodPost, a.TransferBounty, tt.body)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			for username, want := range tt.wantBalances {
+				if got := b.balance(username); got != want {
+					t.Errorf("%s has %d, want %d", username, got, want)
+				}
+			}
+			if len(b.ledger) != tt.wantLedger {
+				t.Fatalf("ledger has %d rows, want %d", len(b.ledger), tt.wantLedger)
+			}
+			// Both sides of a transfer are recorded, summing to zero
+			var sum int32
+			for _, row := range b.ledger {
+				sum += row.Amount
+			}
+			if sum != 0 {
+				t.Errorf("ledger rows sum to %d: %+v", sum, b.ledger)
+			}
+		})
+	}
+}
+
+func TestTransferBountyLockOrder(t *testing.T) {
+	tests := []struct {
+		name string
+		from string
+		to   string
+	}{
+		{name: "to a later username", from: "alice", to: "bob"},
+		{name: "to an earlier username", from: "bob", to: "alice"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBank(map[string]int32{"alice": 100, "bob": 100})
+			a := newBankApp(b)
+
+			w := serveAs(tt.from, http.MethodPost, a.TransferBounty,
+				`{"to_ghusername": "`+tt.to+`", "amount": 10}`)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+			}
+			// Opposing transfers lock in the same order, so neither waits on
+			// the other
+			if want := []string{"alice", "bob"}; !slices.Equal(b.locked, want) {
+				t.Errorf("rows locked in order %v, want %v", b.locked, want)
+			}
+		})
+	}
+}
+
+func TestTransferBountyLimits(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		balances     map[string]int32
+		wantStatus   int
+		wantBalances map[string]int32
+	}{
+		{
+			name:         "at the per-transaction limit",
+			body:         `{"to_ghusername": "bob", "amount": 1000}`,
+			balances:     map[string]int32{"alice": 5000, "bob": 0},
+			wantStatus:   http.StatusOK,
+			wantBalances: map[string]int32{"alice": 4000, "bob": 1000},
+		},
+		{
+			name:         "over the per-transaction limit",
+			body:         `{"to_ghusername": "bob", "amount": 1001}`,
+			balances:     map[string]int32{"alice": 5000, "bob": 0},
+			wantStatus:   http.StatusUnprocessableEntity,
+			wantBalances: map[string]int32{"alice": 5000, "bob": 0},
+		},
+		{
+			name:         "recipient over the total cap",
+			body:         `{"to_ghusername": "bob", "amount": 10}`,
+			balances:     map[string]int32{"alice": 5000, "bob": 999_995},
+			wantStatus:   http.StatusUnprocessableEntity,
+			wantBalances: map[string]int32{"alice": 5000, "bob": 999_995},
+		},
+		{
+			name:         "sender going negative",
+			body:         `{"to_ghusername": "bob", "amount": 10}`,
+			balances:     map[string]int32{"alice": 9, "bob": 0},
+			wantStatus:   http.StatusUnprocessableEntity,
+			wantBalances: map[string]int32{"alice": 9, "bob": 0},
+		},
+		{
+			name:         "negative amount",
+			body:         `{"to_ghusername": "bob", "amount": -10}`,
+			balances:     map[string]int32{"alice": 9, "bob": 0},
+			wantStatus:   http.StatusBadRequest,
+			wantBalances: map[string]int32{"alice": 9, "bob": 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBank(tt.balances)
+			a := newBankApp(b)
+
+			w := serveAs("alice", http.MethodPost, a.TransferBounty, tt.body)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			for username, want := range tt.wantBalances {
+				if got := b.balance(username); got != want {
+					t.Errorf("%s has %d, want %d", username, got, want)
+				}
+			}
+		})
+	}
+}
+
+func awardBody(username string, amount int, repoId string) string {
+	return fmt.Sprintf(`{"ghusername": %q, "amount": %d, "proof_url": "https://github.com/acm/pulse/pull/1", "repo_id": %q}`,
+		username, amount, repoId)
+}
+
+func TestAwardBounty(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		wantStatus   int
+		wantBalances map[string]int32
+		wantLedger   int
+	}{
+		{
+			name:         "awarded",
+			body:         awardBody("Bob", 30, bountyRepoId.String()),
+			wantStatus:   http.StatusOK,
+			wantBalances: map[string]int32{"bob": 80},
+			wantLedger:   1,
+		},
+		{
+			name:         "at the per-transaction limit",
+			body:         awardBody("bob", 1000, bountyRepoId.String()),
+			wantStatus:   http.StatusOK,
+			wantBalances: map[string]int32{"bob": 1050},
+			wantLedger:   1,
+		},
+		{
+			name:         "over the per-transaction limit",
+			body:         awardBody("bob", 1001, bountyRepoId.String()),
+			wantStatus:   http.StatusUnprocessableEntity,
+			wantBalances: map[string]int32{"bob": 50},
+		},
+		{
+			name:         "recipient over the total cap",
+			body:         awardBody("carol", 10, bountyRepoId.String()),
+			wantStatus:   http.StatusUnprocessableEntity,
+			wantBalances: map[string]int32{"carol": 999_995},
+		},
+		{
+			name:         "unknown recipient",
+			body:         awardBody("dave", 10, bountyRepoId.String()),
+			wantStatus:   http.StatusNotFound,
+			wantBalances: map[string]int32{"bob": 50},
+		},
+		{
+			name:         "unknown project",
+			body:         awardBody("bob", 10, uuid.NewString()),
+			wantStatus:   http.StatusNotFound,
+			wantBalances: map[string]int32{"bob": 50},
+		},
+		{
+			name:         "invalid project ID",
+			body:         awardBody("bob", 10, "pulse"),
+			wantStatus:   http.StatusBadRequest,
+			wantBalances: map[string]int32{"bob": 50},
+		},
+		{
+			name:         "zero amount",
+			body:         awardBody("bob", 0, bountyRepoId.String()),
+			wantStatus:   http.StatusBadRequest,
+			wantBalances: map[string]int32{"bob": 50},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBank(map[string]int32{"bob": 50, "carol": 999_995})
+			a := newBankApp(b)
+
+			w := serveAs("maintainer", http.MethodPost, a.AwardBounty, tt.body)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			for username, want := range tt.wantBalances {
+				if got := b.balance(username); got != want {
+					t.Errorf("%s has %d, want %d", username, got, want)
+				}
+			}
+			if len(b.ledger) != tt.wantLedger {
+				t.Fatalf("ledger has %d rows, want %d", len(b.ledger), tt.wantLedger)
+			}
+			for _, row := range b.ledger {
+				if row.Kind != "award" || row.Counterparty != "maintainer" {
+					t.Errorf("ledger row = %+v", row)
+				}
+			}
+		})
+	}
+}
+
+func TestAwardBountyBatch(t *testing.T) {
+	b := newBank(map[string]int32{"bob": 50, "carol": 999_995})
+	a := newBankApp(b)
+	csv := strings.Join([]string{
+		"ghusername,amount,reason",
+		"bob,20,Fixed the leaderboard",
+		"dave,10,Unknown user",
+		"bob,1001,Over the limit",
+		"carol,10,Over the cap",
+		"bob,ten,Not a number",
+		"Bob,5,Reviewed a pull request",
+	}, "\n")
+
+	router := gin.New()
+	router.POST("/", func(c *gin.Context) { c.Set("username", "maintainer") }, a.AwardBountyBatch)
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(csv))
+	req.Header.Set("Content-Type", "text/csv")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Succeeded int                       `json:"succeeded"`
+		Failed    int                       `json:"failed"`
+		Results   []types.BountyBatchResult `json:"results"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Succeeded != 2 || resp.Failed != 4 {
+		t.Errorf("succeeded %d, failed %d, want 2 and 4", resp.Succeeded, resp.Failed)
+	}
+	wantStatuses := map[int]string{2: "awarded", 3: "failed", 4: "failed", 5: "failed", 6: "failed", 7: "awarded"}
+	for _, result := range resp.Results {
+		if result.Status != wantStatuses[result.Line] {
+			t.Errorf("line %d is %s (%s), want %s", result.Line, result.Status, result.Error, wantStatuses[result.Line])
+		}
+	}
+	// A failing row is undone on its own, the others are kept
+	if got := b.balance("bob"); got != 75 {
+		t.Errorf("bob has %d, want 75", got)
+	}
+	if got := b.balance("carol"); got != 999_995 {
+		t.Errorf("carol has %d, want 999995", got)
+	}
+	if len(b.ledger) != 2 {
+		t.Errorf("ledger has %d rows, want 2: %+v", len(b.ledger), b.ledger)
+	}
+}
+
+func TestAwardBountyConcurrent(t *testing.T) {
+	const awards = 50
+	b := newBank(map[string]int32{"bob": 100})
+	a := newBankApp(b)
+
+	var wg sync.WaitGroup
+	statuses := make([]int, awards)
+	for i := range awards {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := serveAs("maintainer", http.MethodPost, a.AwardBounty, awardBody("bob", 1, bountyRepoId.String()))
+			statuses[i] = w.Code
+		}()
+	}
+	wg.Wait()
+
+	for i, status := range statuses {
+		if status != http.StatusOK {
+			t.Fatalf("award %d status = %d", i, status)
+		}
+	}
+	// No increment is lost to another award running at the same time
+	if got := b.balance("bob"); got != 100+awards {
+		t.Errorf("bob has %d, want %d", got, 100+awards)
+	}
+	if len(b.ledger) != awards {
+		t.Errorf("ledger has %d rows, want %d", len(b.ledger), awards)
+	}
+}
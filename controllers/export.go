@@ -0,0 +1,256 @@
+package controllers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	db "github.com/IAmRiteshKoushik/pulse/db/gen"
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	"github.com/IAmRiteshKoushik/pulse/types"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Returns every record held about the authenticated user as a downloadable
+// JSON document. Each query is scoped to the user so nothing foreign leaks.
+func (a *App) ExportUserData(c *gin.Context) {
+	username, ok := pkg.GrabUsername(c)
+	if !ok {
+		a.Log.Warn(
+			fmt.Sprintf("Failed to extract username from token at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Oops! Something happened. Please try again later.",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), a.Env.TimeoutFor("export"))
+	defer cancel()
+
+	// A single snapshot keeps the sections consistent with each other
+	tx, err := a.DB.BeginTx(ctx, pgx.TxOptions{
+		IsoLevel:   pgx.RepeatableRead,
+		AccessMode: pgx.ReadOnly,
+	})
+	if err != nil {
+		pkg.DbAcquireError(c, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	q := a.Queries
+	profile, err := q.FetchProfileQuery(ctx, tx, username)
+	if err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+	badges, err := q.FetchBadgesQuery(ctx, tx, username)
+	if err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+	ledger, err := q.ExportBountyLedgerQuery(ctx, tx, username)
+	if err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+	sessions, err := q.ExportSessionsQuery(ctx, tx, username)
+	if err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+	auditLogs, err := q.ExportAuditLogsQuery(ctx, tx, username)
+	if err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+	redactForeignAuditLogs(username, auditLogs)
+
+	c.Header("Content-Disposition",
+		fmt.Sprintf(`attachment; filename="pulse-export-%s.json"`, username))
+	c.JSON(http.StatusOK, gin.H{
+		"exported_at":   pkg.FormatTime(time.Now()),
+		"profile":       profile,
+		"badges":        badges,
+		"bounty_ledger": ledger,
+		"sessions":      sessions,
+		"audit_logs":    auditLogs,
+	})
+	a.Log.Info(fmt.Sprintf(
+		"[SUCCESS]: Processed request at %s %s",
+		c.Request.Method, c.FullPath(),
+	))
+	return
+}
+
+// Entries recorded by another user only targeting this one, e.g. an award,
+// keep what was done to the user but not who did it or their notes
+func redactForeignAuditLogs(username string, logs []db.ExportAuditLogsQueryRow) {
+	for i := range logs {
+		if logs[i].Actor != username {
+			logs[i].Actor = ""
+			logs[i].Details = ""
+		}
+	}
+}
+
+// Users are read in pages of this size, so memory use does not grow with the
+// number of users
+const userExportPageSize = 500
+
+type userExportRow struct {
+	Ghusername string `json:"github_username"`
+	Email      string `json:"email"`
+	FullName   string `json:"full_name"`
+	Bounty     int32  `json:"bounty"`
+	Active     bool   `json:"active"`
+	CreatedAt  string `json:"created_at"`
+}
+
+var userExportColumns = []string{
+	"github_username", "email", "full_name", "bounty", "active", "created_at",
+}
+
+// Streams every user as newline delimited JSON or CSV. Pages are fetched by
+// keyset within a single read-only snapshot and flushed as they are written.
+func (a *App) ExportUsers(c *gin.Context) {
+	var query types.ExportUsersRequest
+	if err := c.ShouldBindQuery(&query); err != nil {
+		pkg.RequestValidatorError(c, err)
+		return
+	}
+	if err := query.Validate(); err != nil {
+		pkg.RequestValidatorError(c, err)
+		return
+	}
+	format := query.Format
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), a.Env.TimeoutFor("users_export"))
+	defer cancel()
+
+	tx, err := a.Replica.BeginTx(ctx, pgx.TxOptions{
+		IsoLevel:   pgx.RepeatableRead,
+		AccessMode: pgx.ReadOnly,
+	})
+	if err != nil {
+		pkg.DbAcquireError(c, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	// The first page is fetched up front so that failures still get a
+	// proper error response
+	q := a.Queries
+	page, err := q.ExportUsersPageQuery(ctx, tx, db.ExportUsersPageQueryParams{
+		AfterID:  0,
+		PageSize: userExportPageSize,
+	})
+	if err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+
+	contentType := "application/x-ndjson"
+	if format == "csv" {
+		contentType = "text/csv; charset=utf-8"
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition",
+		fmt.Sprintf(`attachment; filename="pulse-users.%s"`, format))
+	c.Status(http.StatusOK)
+
+	exported := 0
+	c.Stream(func(w io.Writer) bool {
+		if err := writeUserExportPage(w, format, page, exported == 0); err != nil {
+			a.Log.Error("Failed to write user export page", err)
+			return false
+		}
+		exported += len(page)
+		if len(page) < userExportPageSize {
+			return false
+		}
+
+		page, err = q.ExportUsersPageQuery(ctx, tx, db.ExportUsersPageQueryParams{
+			AfterID:  page[len(page)-1].ID,
+			PageSize: userExportPageSize,
+		})
+		if err != nil {
+			// Headers are gone by now, so the truncated body is all the
+			// client gets
+			a.Log.Error("Failed to fetch user export page", err)
+			return false
+		}
+		return true
+	})
+	a.Log.Info(fmt.Sprintf(
+		"[SUCCESS]: Processed request at %s %s (%d users)",
+		c.Request.Method, c.FullPath(), exported,
+	))
+	return
+}
+
+func writeUserExportPage(w io.Writer, format string, page []db.ExportUsersPageQueryRow,
+	withHeader bool) error {
+	rows := make([]userExportRow, len(page))
+	for i, user := range page {
+		rows[i] = userExportRow{
+			Ghusername: user.Ghusername,
+			Email:      user.Email,
+			FullName:   user.FullName,
+			Bounty:     user.Bounty,
+			Active:     user.Status.Valid && user.Status.Bool,
+		}
+		if user.CreatedAt.Valid {
+			rows[i].CreatedAt = pkg.FormatTime(user.CreatedAt.Time)
+		}
+	}
+
+	if format == "ndjson" {
+		enc := json.NewEncoder(w)
+		for _, row := range rows {
+			if err := enc.Encode(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	cw := csv.NewWriter(w)
+	if withHeader {
+		if err := cw.Write(userExportColumns); err != nil {
+			return err
+		}
+	}
+	for _, row := range rows {
+		err := cw.Write([]string{
+			csvCell(row.Ghusername),
+			csvCell(row.Email),
+			csvCell(row.FullName),
+			strconv.Itoa(int(row.Bounty)),
+			strconv.FormatBool(row.Active),
+			row.CreatedAt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Spreadsheets evaluate cells starting with any of these as formulas, so
+// user supplied values are prefixed with a quote to be shown as text
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
@@ -0,0 +1,248 @@
+package controllers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	db "github.com/IAmRiteshKoushik/pulse/db/gen"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestWriteUserExportPageCsv(t *testing.T) {
+	tests := []struct {
+		name       string
+		user       db.ExportUsersPageQueryRow
+		withHeader bool
+		want       string
+	}{
+		{
+			name:       "plain values with header",
+			user:       db.ExportUsersPageQueryRow{Ghusername: "octocat", Email: "octo@example.com", FullName: "Octo Cat", Bounty: 40},
+			withHeader: true,
+			want: "github_username,email,full_name,bounty,active,created_at\n" +
+				"octocat,octo@example.com,Octo Cat,40,false,\n",
+		},
+		{
+			name: "formula in name",
+			user: db.ExportUsersPageQueryRow{Ghusername: "octocat", FullName: "=HYPERLINK(\"http://x\")", Status: pgtype.Bool{Bool: true, Valid: true}},
+			want: "octocat,,\"'=HYPERLINK(\"\"http://x\"\")\",0,true,\n",
+		},
+		{
+			name: "plus, minus and at signs",
+			user: db.ExportUsersPageQueryRow{Ghusername: "-octocat", Email: "@example.com", FullName: "+1"},
+			want: "'-octocat,'@example.com,'+1,0,false,\n",
+		},
+		{
+			name: "leading tab and carriage return",
+			user: db.ExportUsersPageQueryRow{Ghusername: "octocat", Email: "\tx", FullName: "\rx"},
+			want: "octocat,'\tx,\"'\rx\",0,false,\n",
+		},
+		{
+			name: "sign within the value is kept",
+			user: db.ExportUsersPageQueryRow{Ghusername: "octo-cat", FullName: "A=B"},
+			want: "octo-cat,,A=B,0,false,\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := writeUserExportPage(&buf, "csv", []db.ExportUsersPageQueryRow{tt.user}, tt.withHeader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func TestWriteUserExportPageWriteError(t *testing.T) {
+	page := []db.ExportUsersPageQueryRow{{Ghusername: "octocat"}}
+	for _, format := range []string{"csv", "ndjson"} {
+		if err := writeUserExportPage(failingWriter{}, format, page, true); err == nil {
+			t.Errorf("%s: expected the write error to be returned", format)
+		}
+	}
+}
+
+func TestExportUserDataOmitsOtherUsers(t *testing.T) {
+	q := &fakeQuerier{
+		fetchProfile: func(db.DBTX, string) (db.FetchProfileQueryRow, error) {
+			return db.FetchProfileQueryRow{}, nil
+		},
+		fetchBadges: func(db.DBTX, string) ([]db.FetchBadgesQueryRow, error) { return nil, nil },
+		exportBountyLedger: func(db.DBTX, string) ([]db.ExportBountyLedgerQueryRow, error) {
+			return []db.ExportBountyLedgerQueryRow{{Amount: 50, Kind: "award"}, {Amount: -20, Kind: "transfer_out"}}, nil
+		},
+		exportSessions: func(db.DBTX, string) ([]db.ExportSessionsQueryRow, error) { return nil, nil },
+		exportAuditLogs: func(_ db.DBTX, username string) ([]db.ExportAuditLogsQueryRow, error) {
+			return []db.ExportAuditLogsQueryRow{
+				{Actor: username, Action: "session.revoke", Target: username, Details: "own device"},
+				{Actor: "maintainer", Action: "bounty.award", Target: username, Details: "notes of maintainer"},
+			}, nil
+		},
+	}
+	a := newTestApp(q)
+
+	router := gin.New()
+	router.GET("/api/v1/me/export", func(c *gin.Context) {
+		c.Set("username", "octocat")
+	}, a.ExportUserData)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/me/export", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+
+	var export struct {
+		BountyLedger []map[string]any             `json:"bounty_ledger"`
+		AuditLogs    []db.ExportAuditLogsQueryRow `json:"audit_logs"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &export); err != nil {
+		t.Fatal(err)
+	}
+	want := []db.ExportAuditLogsQueryRow{
+		{Actor: "octocat", Action: "session.revoke", Target: "octocat", Details: "own device"},
+		{Action: "bounty.award", Target: "octocat"},
+	}
+	if !slices.Equal(export.AuditLogs, want) {
+		t.Errorf("audit_logs = %+v, want %+v", export.AuditLogs, want)
+	}
+	for _, entry := range export.BountyLedger {
+		if _, ok := entry["counterparty"]; ok {
+			t.Errorf("bounty_ledger entry carries a counterparty: %v", entry)
+		}
+	}
+	if strings.Contains(w.Body.String(), "maintainer") {
+		t.Errorf("export mentions another user: %s", w.Body)
+	}
+}
+
+func TestExportUsersStreams(t *testing.T) {
+	// Two and a half pages, so that the export takes three fetches
+	const seeded = 2*userExportPageSize + userExportPageSize/2
+	users := make([]db.ExportUsersPageQueryRow, seeded)
+	for i := range users {
+		users[i] = db.ExportUsersPageQueryRow{
+			ID:         int32(i + 1),
+			Ghusername: fmt.Sprintf("user%04d", i+1),
+			Email:      fmt.Sprintf("user%04d@example.com", i+1),
+		}
+	}
+
+	tests := []struct {
+		name       string
+		format     string
+		wantHeader string // first line ahead of the users
+		username   func(line string) string
+	}{
+		{
+			name:   "ndjson",
+			format: "ndjson",
+			username: func(line string) string {
+				var row userExportRow
+				json.Unmarshal([]byte(line), &row)
+				return row.Ghusername
+			},
+		},
+		{
+			name:       "csv",
+			format:     "csv",
+			wantHeader: strings.Join(userExportColumns, ","),
+			username: func(line string) string {
+				username, _, _ := strings.Cut(line, ",")
+				return username
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The last page is held back until the client has read the first,
+			// which it could not if the export were buffered. Each page is
+			// flushed once the next one is fetched.
+			released := make(chan struct{})
+			var afterIds []int32
+			q := &fakeQuerier{
+				exportUsersPage: func(conn db.DBTX, arg db.ExportUsersPageQueryParams) ([]db.ExportUsersPageQueryRow, error) {
+					if pool := poolOf(conn); pool != "replica" {
+						t.Errorf("export read from the %s", pool)
+					}
+					afterIds = append(afterIds, arg.AfterID)
+					if arg.AfterID == 2*userExportPageSize {
+						select {
+						case <-released:
+						case <-time.After(5 * time.Second):
+							t.Error("earlier pages never reached the client")
+						}
+					}
+					start := int(arg.AfterID)
+					end := min(start+int(arg.PageSize), len(users))
+					return users[start:end], nil
+				},
+			}
+			a := newTestApp(q)
+			a.Env = testEnv()
+			router := gin.New()
+			router.GET("/api/v1/admin/users/export", a.ExportUsers)
+			server := httptest.NewServer(router)
+			defer server.Close()
+
+			resp, err := server.Client().Get(server.URL + "/api/v1/admin/users/export?format=" + tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d", resp.StatusCode)
+			}
+			if resp.ContentLength != -1 {
+				t.Errorf("Content-Length = %d, want the body streamed", resp.ContentLength)
+			}
+
+			scanner := bufio.NewScanner(resp.Body)
+			if tt.wantHeader != "" {
+				if !scanner.Scan() || scanner.Text() != tt.wantHeader {
+					t.Fatalf("header = %q, want %q", scanner.Text(), tt.wantHeader)
+				}
+			}
+			read := 0
+			for scanner.Scan() {
+				if got, want := tt.username(scanner.Text()), users[read].Ghusername; got != want {
+					t.Fatalf("line %d has %q, want %q", read+1, got, want)
+				}
+				read++
+				if read == userExportPageSize {
+					close(released)
+				}
+			}
+			if err := scanner.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if read != seeded {
+				t.Errorf("read %d users, want %d", read, seeded)
+			}
+			if want := []int32{0, userExportPageSize, 2 * userExportPageSize}; !slices.Equal(afterIds, want) {
+				t.Errorf("pages fetched after %v, want %v", afterIds, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,663 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IAmRiteshKoushik/pulse/cmd"
+	db "github.com/IAmRiteshKoushik/pulse/db/gen"
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		panic(err)
+	}
+	cmd.Log = cmd.NewLoggerService("production", devNull)
+	cmd.EnvVars = testEnv()
+	os.Exit(m.Run())
+}
+
+func testEnv() *cmd.EnvConfig {
+	return &cmd.EnvConfig{
+		TokenSecret:         "test-secret",
+		RefreshTokenExpiry:  "fixed",
+		RefreshTokenMaxAge:  30 * 24 * time.Hour,
+		RefreshTokenIdleTTL: 7 * 24 * time.Hour,
+		RequestTimeout:      5 * time.Second,
+	}
+}
+
+func newTestApp(q db.Querier) *App {
+	return &App{
+		Env:     cmd.EnvVars,
+		Log:     cmd.Log,
+		DB:      fakePool{name: "primary"},
+		Replica: fakePool{name: "replica"},
+		Queries: q,
+	}
+}
+
+// Answers the queries a test sets up. Any other query panics, as the
+// embedded Querier is nil.
+type fakeQuerier struct {
+	db.Querier
+
+	revokeTokenByJti   func(db.DBTX, db.RevokeTokenByJtiQueryParams) (int64, error)
+	addSession         func(db.DBTX, db.AddSessionQueryParams) error
+	checkSession       func(db.DBTX, uuid.UUID) (db.CheckSessionQueryRow, error)
+	fetchSessionStatus func(db.DBTX, db.FetchSessionStatusQueryParams) (db.FetchSessionStatusQueryRow, error)
+	fetchProfile       func(db.DBTX, string) (db.FetchProfileQueryRow, error)
+	fetchBadges        func(db.DBTX, string) ([]db.FetchBadgesQueryRow, error)
+	fetchPublicProfile func(db.DBTX, string) (db.FetchPublicProfileQueryRow, error)
+
+	checkUserExist           func(db.DBTX, string) (db.CheckUserExistQueryRow, error)
+	checkUserExistByGithubId func(db.DBTX, pgtype.Int8) (db.CheckUserExistByGithubIdQueryRow, error)
+	checkGithubFallbackUser  func(db.DBTX, db.CheckGithubFallbackUserQueryParams) (db.CheckGithubFallbackUserQueryRow, error)
+	checkIsMaintainer        func(db.DBTX, string) (bool, error)
+	fetchLoginUser           func(db.DBTX, string) (db.FetchLoginUserQueryRow, error)
+	saveGithubToken          func(db.DBTX, db.SaveGithubTokenQueryParams) error
+	updateGithubProfile      func(db.DBTX, db.UpdateGithubProfileQueryParams) error
+
+	linkGithubId       func(db.DBTX, db.LinkGithubIdQueryParams) (int64, error)
+	fetchIdentityOwner func(db.DBTX, db.FetchIdentityOwnerQueryParams) (string, error)
+	addIdentity        func(db.DBTX, db.AddIdentityQueryParams) error
+
+	lockUserAccount      func(db.DBTX, string) (string, error)
+	fetchLinkedProviders func(db.DBTX, string) ([]db.FetchLinkedProvidersQueryRow, error)
+	countPasskeys        func(db.DBTX, string) (int64, error)
+	unlinkGithubId       func(db.DBTX, string) (int64, error)
+	deleteIdentity       func(db.DBTX, db.DeleteIdentityQueryParams) (int64, error)
+
+	fetchPasskeyUser func(db.DBTX, string) (db.FetchPasskeyUserQueryRow, error)
+	fetchPasskeys    func(db.DBTX, string) ([][]byte, error)
+	addPasskey       func(db.DBTX, db.AddPasskeyQueryParams) error
+	addAuditLog      func(db.DBTX, db.AddAuditLogQueryParams) error
+
+	lockOnboarding       func(db.DBTX, string) error
+	checkOtpReplay       func(db.DBTX, db.CheckOtpReplayQueryParams) (string, error)
+	fetchOnboardingState func(db.DBTX, string) (string, error)
+	verifyOtp            func(db.DBTX, db.VerifyOtpQueryParams) (db.VerifyOtpQueryRow, error)
+	createUserAccount    func(db.DBTX, db.CreateUserAccountQueryParams) (string, error)
+	countOnboardedToday  func(db.DBTX) (int64, error)
+
+	checkUserExistByEmail      func(db.DBTX, string) (db.CheckUserExistByEmailQueryRow, error)
+	fetchPendingRegByEmail     func(db.DBTX, string) (db.FetchPendingRegistrationByEmailQueryRow, error)
+	markRegistrationsOnboarded func(db.DBTX, string) error
+
+	fetchNotificationPreferences func(db.DBTX, string) ([]db.FetchNotificationPreferencesQueryRow, error)
+	setNotificationPreference    func(db.DBTX, db.SetNotificationPreferenceQueryParams) error
+	notificationEnabled          func(db.DBTX, db.NotificationEnabledQueryParams) (bool, error)
+	regenerateOtp                func(db.DBTX, db.RegenerateOtpQueryParams) (db.RegenerateOtpQueryRow, error)
+
+	checkForExistingOtp      func(db.DBTX, string) (db.CheckForExistingOtpQueryRow, error)
+	checkPendingRegistration func(db.DBTX, db.CheckPendingRegistrationQueryParams) (bool, error)
+	incrementOtpResend       func(db.DBTX, db.IncrementOtpResendQueryParams) (int64, error)
+
+	debitBounty     func(db.DBTX, db.DebitBountyQueryParams) (int32, error)
+	creditBounty    func(db.DBTX, db.CreditBountyQueryParams) (int32, error)
+	addBountyLedger func(db.DBTX, db.AddBountyLedgerQueryParams) error
+	addBountyLog    func(db.DBTX, db.AddBountyLogQueryParams) error
+
+	checkIfProjectExists func(db.DBTX, uuid.UUID) (bool, error)
+
+	loginHistory    func(db.DBTX, string) ([]db.LoginHistoryQueryRow, error)
+	addLoginHistory func(db.DBTX, db.AddLoginHistoryQueryParams) error
+
+	updateMailStatus func(db.DBTX, db.UpdateMailStatusQueryParams) (string, error)
+	suppressEmail    func(db.DBTX, db.SuppressEmailQueryParams) error
+
+	exportUsersPage    func(db.DBTX, db.ExportUsersPageQueryParams) ([]db.ExportUsersPageQueryRow, error)
+	exportBountyLedger func(db.DBTX, string) ([]db.ExportBountyLedgerQueryRow, error)
+	exportSessions     func(db.DBTX, string) ([]db.ExportSessionsQueryRow, error)
+	exportAuditLogs    func(db.DBTX, string) ([]db.ExportAuditLogsQueryRow, error)
+
+	fetchSnapshotDate        func(db.DBTX, pgtype.Date) (pgtype.Date, error)
+	fetchLeaderboardSnapshot func(db.DBTX, db.FetchLeaderboardSnapshotQueryParams) ([]db.FetchLeaderboardSnapshotQueryRow, error)
+}
+
+func (q *fakeQuerier) RevokeTokenByJtiQuery(ctx context.Context, conn db.DBTX,
+	arg db.RevokeTokenByJtiQueryParams) (int64, error) {
+	return q.revokeTokenByJti(conn, arg)
+}
+
+func (q *fakeQuerier) AddSessionQuery(ctx context.Context, conn db.DBTX,
+	arg db.AddSessionQueryParams) error {
+	return q.addSession(conn, arg)
+}
+
+func (q *fakeQuerier) CheckSessionQuery(ctx context.Context, conn db.DBTX,
+	jti uuid.UUID) (db.CheckSessionQueryRow, error) {
+	return q.checkSession(conn, jti)
+}
+
+func (q *fakeQuerier) FetchSessionStatusQuery(ctx context.Context, conn db.DBTX,
+	arg db.FetchSessionStatusQueryParams) (db.FetchSessionStatusQueryRow, error) {
+	return q.fetchSessionStatus(conn, arg)
+}
+
+func (q *fakeQuerier) FetchProfileQuery(ctx context.Context, conn db.DBTX,
+	ghUsername string) (db.FetchProfileQueryRow, error) {
+	return q.fetchProfile(conn, ghUsername)
+}
+
+func (q *fakeQuerier) FetchBadgesQuery(ctx context.Context, conn db.DBTX,
+	ghUsername string) ([]db.FetchBadgesQueryRow, error) {
+	return q.fetchBadges(conn, ghUsername)
+}
+
+func (q *fakeQuerier) FetchPublicProfileQuery(ctx context.Context, conn db.DBTX,
+	ghUsername string) (db.FetchPublicProfileQueryRow, error) {
+	return q.fetchPublicProfile(conn, ghUsername)
+}
+
+func (q *fakeQuerier) CheckUserExistQuery(ctx context.Context, conn db.DBTX,
+	ghUsername string) (db.CheckUserExistQueryRow, error) {
+	return q.checkUserExist(conn, ghUsername)
+}
+
+func (q *fakeQuerier) LinkGithubIdQuery(ctx context.Context, conn db.DBTX,
+	arg db.LinkGithubIdQueryParams) (int64, error) {
+	return q.linkGithubId(conn, arg)
+}
+
+func (q *fakeQuerier) FetchIdentityOwnerQuery(ctx context.Context, conn db.DBTX,
+	arg db.FetchIdentityOwnerQueryParams) (string, error) {
+	return q.fetchIdentityOwner(conn, arg)
+}
+
+func (q *fakeQuerier) AddIdentityQuery(ctx context.Context, conn db.DBTX,
+	arg db.AddIdentityQueryParams) error {
+	return q.addIdentity(conn, arg)
+}
+
+func (q *fakeQuerier) CheckUserExistByGithubIdQuery(ctx context.Context, conn db.DBTX,
+	ghID pgtype.Int8) (db.CheckUserExistByGithubIdQueryRow, error) {
+	return q.checkUserExistByGithubId(conn, ghID)
+}
+
+func (q *fakeQuerier) CheckGithubFallbackUserQuery(ctx context.Context, conn db.DBTX,
+	arg db.CheckGithubFallbackUserQueryParams) (db.CheckGithubFallbackUserQueryRow, error) {
+	return q.checkGithubFallbackUser(conn, arg)
+}
+
+func (q *fakeQuerier) CheckIsMaintainerQuery(ctx context.Context, conn db.DBTX,
+	ghUsername string) (bool, error) {
+	return q.checkIsMaintainer(conn, ghUsername)
+}
+
+func (q *fakeQuerier) FetchLoginUserQuery(ctx context.Context, conn db.DBTX,
+	ghUsername string) (db.FetchLoginUserQueryRow, error) {
+	return q.fetchLoginUser(conn, ghUsername)
+}
+
+func (q *fakeQuerier) SaveGithubTokenQuery(ctx context.Context, conn db.DBTX,
+	arg db.SaveGithubTokenQueryParams) error {
+	return q.saveGithubToken(conn, arg)
+}
+
+func (q *fakeQuerier) UpdateGithubProfileQuery(ctx context.Context, conn db.DBTX,
+	arg db.UpdateGithubProfileQueryParams) error {
+	return q.updateGithubProfile(conn, arg)
+}
+
+func (q *fakeQuerier) FetchPasskeyUserQuery(ctx context.Context, conn db.DBTX,
+	ghUsername string) (db.FetchPasskeyUserQueryRow, error) {
+	return q.fetchPasskeyUser(conn, ghUsername)
+}
+
+func (q *fakeQuerier) FetchPasskeysQuery(ctx context.Context, conn db.DBTX,
+	ghUsername string) ([][]byte, error) {
+	return q.fetchPasskeys(conn, ghUsername)
+}
+
+func (q *fakeQuerier) AddPasskeyQuery(ctx context.Context, conn db.DBTX,
+	arg db.AddPasskeyQueryParams) error {
+	return q.addPasskey(conn, arg)
+}
+
+func (q *fakeQuerier) AddAuditLogQuery(ctx context.Context, conn db.DBTX,
+	arg db.AddAuditLogQueryParams) error {
+	return q.addAuditLog(conn, arg)
+}
+
+func (q *fakeQuerier) LockOnboardingQuery(ctx context.Context, conn db.DBTX,
+	ghUsername string) error {
+	return q.lockOnboarding(conn, ghUsername)
+}
+
+func (q *fakeQuerier) CheckOtpReplayQuery(ctx context.Context, conn db.DBTX,
+	arg db.CheckOtpReplayQueryParams) (string, error) {
+	return q.checkOtpReplay(conn, arg)
+}
+
+func (q *fakeQuerier) FetchOnboardingStateQuery(ctx context.Context, conn db.DBTX,
+	ghUsername string) (string, error) {
+	return q.fetchOnboardingState(conn, ghUsername)
+}
+
+func (q *fakeQuerier) VerifyOtpQuery(ctx context.Context, conn db.DBTX,
+	arg db.VerifyOtpQueryParams) (db.VerifyOtpQueryRow, error) {
+	return q.verifyOtp(conn, arg)
+}
+
+func (q *fakeQuerier) CreateUserAccountQuery(ctx context.Context, conn db.DBTX,
+	arg db.CreateUserAccountQueryParams) (string, error) {
+	return q.createUserAccount(conn, arg)
+}
+
+func (q *fakeQuerier) CountOnboardedTodayQuery(ctx context.Context, conn db.DBTX) (int64, error) {
+	return q.countOnboardedToday(conn)
+}
+
+func (q *fakeQuerier) CheckForExistingOtpQuery(ctx context.Context, conn db.DBTX,
+	ghUsername string) (db.CheckForExistingOtpQueryRow, error) {
+	return q.checkForExistingOtp(conn, ghUsername)
+}
+
+func (q *fakeQuerier) IncrementOtpResendQuery(ctx context.Context, conn db.DBTX,
+	arg db.IncrementOtpResendQueryParams) (int64, error) {
+	return q.incrementOtpResend(conn, arg)
+}
+
+func (q *fakeQuerier) CheckUserExistByEmailQuery(ctx context.Context, conn db.DBTX,
+	email string) (db.CheckUserExistByEmailQueryRow, error) {
+	return q.checkUserExistByEmail(conn, email)
+}
+
+func (q *fakeQuerier) FetchPendingRegistrationByEmailQuery(ctx context.Context, conn db.DBTX,
+	email string) (db.FetchPendingRegistrationByEmailQueryRow, error) {
+	return q.fetchPendingRegByEmail(conn, email)
+}
+
+func (q *fakeQuerier) MarkRegistrationsOnboardedQuery(ctx context.Context, conn db.DBTX,
+	ghusername string) error {
+	return q.markRegistrationsOnboarded(conn, ghusername)
+}
+
+func (q *fakeQuerier) CheckPendingRegistrationQuery(ctx context.Context, conn db.DBTX,
+	arg db.CheckPendingRegistrationQueryParams) (bool, error) {
+	return q.checkPendingRegistration(conn, arg)
+}
+
+func (q *fakeQuerier) DebitBountyQuery(ctx context.Context, conn db.DBTX,
+	arg db.DebitBountyQueryParams) (int32, error) {
+	return q.debitBounty(conn, arg)
+}
+
+func (q *fakeQuerier) CreditBountyQuery(ctx context.Context, conn db.DBTX,
+	arg db.CreditBountyQueryParams) (int32, error) {
+	return q.creditBounty(conn, arg)
+}
+
+func (q *fakeQuerier) AddBountyLedgerQuery(ctx context.Context, conn db.DBTX,
+	arg db.AddBountyLedgerQueryParams) error {
+	return q.addBountyLedger(conn, arg)
+}
+
+func (q *fakeQuerier) AddBountyLogQuery(ctx context.Context, conn db.DBTX,
+	arg db.AddBountyLogQueryParams) error {
+	return q.addBountyLog(conn, arg)
+}
+
+func (q *fakeQuerier) CheckIfProjectExistsQuery(ctx context.Context, conn db.DBTX,
+	id uuid.UUID) (bool, error) {
+	return q.checkIfProjectExists(conn, id)
+}
+
+func (q *fakeQuerier) LoginHistoryQuery(ctx context.Context, conn db.DBTX,
+	ghusername string) ([]db.LoginHistoryQueryRow, error) {
+	return q.loginHistory(conn, ghusername)
+}
+
+func (q *fakeQuerier) AddLoginHistoryQuery(ctx context.Context, conn db.DBTX,
+	arg db.AddLoginHistoryQueryParams) error {
+	return q.addLoginHistory(conn, arg)
+}
+
+func (q *fakeQuerier) UpdateMailStatusQuery(ctx context.Context, conn db.DBTX,
+	arg db.UpdateMailStatusQueryParams) (string, error) {
+	return q.updateMailStatus(conn, arg)
+}
+
+func (q *fakeQuerier) SuppressEmailQuery(ctx context.Context, conn db.DBTX,
+	arg db.SuppressEmailQueryParams) error {
+	return q.suppressEmail(conn, arg)
+}
+
+func (q *fakeQuerier) ExportUsersPageQuery(ctx context.Context, conn db.DBTX,
+	arg db.ExportUsersPageQueryParams) ([]db.ExportUsersPageQueryRow, error) {
+	return q.exportUsersPage(conn, arg)
+}
+
+func (q *fakeQuerier) LockUserAccountQuery(ctx context.Context, conn db.DBTX,
+	ghusername string) (string, error) {
+	return q.lockUserAccount(conn, ghusername)
+}
+
+func (q *fakeQuerier) FetchLinkedProvidersQuery(ctx context.Context, conn db.DBTX,
+	ghusername string) ([]db.FetchLinkedProvidersQueryRow, error) {
+	return q.fetchLinkedProviders(conn, ghusername)
+}
+
+func (q *fakeQuerier) CountPasskeysQuery(ctx context.Context, conn db.DBTX,
+	ghusername string) (int64, error) {
+	return q.countPasskeys(conn, ghusername)
+}
+
+func (q *fakeQuerier) UnlinkGithubIdQuery(ctx context.Context, conn db.DBTX,
+	ghusername string) (int64, error) {
+	return q.unlinkGithubId(conn, ghusername)
+}
+
+func (q *fakeQuerier) DeleteIdentityQuery(ctx context.Context, conn db.DBTX,
+	arg db.DeleteIdentityQueryParams) (int64, error) {
+	return q.deleteIdentity(conn, arg)
+}
+
+func (q *fakeQuerier) RegenerateOtpQuery(ctx context.Context, conn db.DBTX,
+	arg db.RegenerateOtpQueryParams) (db.RegenerateOtpQueryRow, error) {
+	return q.regenerateOtp(conn, arg)
+}
+
+func (q *fakeQuerier) FetchNotificationPreferencesQuery(ctx context.Context, conn db.DBTX,
+	ghusername string) ([]db.FetchNotificationPreferencesQueryRow, error) {
+	return q.fetchNotificationPreferences(conn, ghusername)
+}
+
+func (q *fakeQuerier) SetNotificationPreferenceQuery(ctx context.Context, conn db.DBTX,
+	arg db.SetNotificationPreferenceQueryParams) error {
+	return q.setNotificationPreference(conn, arg)
+}
+
+func (q *fakeQuerier) NotificationEnabledQuery(ctx context.Context, conn db.DBTX,
+	arg db.NotificationEnabledQueryParams) (bool, error) {
+	return q.notificationEnabled(conn, arg)
+}
+
+func (q *fakeQuerier) FetchSnapshotDateQuery(ctx context.Context, conn db.DBTX,
+	asOf pgtype.Date) (pgtype.Date, error) {
+	return q.fetchSnapshotDate(conn, asOf)
+}
+
+func (q *fakeQuerier) FetchLeaderboardSnapshotQuery(ctx context.Context, conn db.DBTX,
+	arg db.FetchLeaderboardSnapshotQueryParams) ([]db.FetchLeaderboardSnapshotQueryRow, error) {
+	return q.fetchLeaderboardSnapshot(conn, arg)
+}
+
+func (q *fakeQuerier) ExportBountyLedgerQuery(ctx context.Context, conn db.DBTX,
+	ghUsername string) ([]db.ExportBountyLedgerQueryRow, error) {
+	return q.exportBountyLedger(conn, ghUsername)
+}
+
+func (q *fakeQuerier) ExportSessionsQuery(ctx context.Context, conn db.DBTX,
+	ghUsername string) ([]db.ExportSessionsQueryRow, error) {
+	return q.exportSessions(conn, ghUsername)
+}
+
+func (q *fakeQuerier) ExportAuditLogsQuery(ctx context.Context, conn db.DBTX,
+	ghUsername string) ([]db.ExportAuditLogsQueryRow, error) {
+	return q.exportAuditLogs(conn, ghUsername)
+}
+
+// Records the messages it is asked to send instead of sending them
+type fakeSMS struct {
+	mu   sync.Mutex
+	sent []fakeMessage
+	err  error
+}
+
+type fakeMessage struct {
+	to   string
+	body string
+}
+
+func (s *fakeSMS) SendSMS(ctx context.Context, to string, body string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.sent = append(s.sent, fakeMessage{to: to, body: body})
+	return nil
+}
+
+// Name of the fake pool a query was run against
+func poolOf(conn db.DBTX) string {
+	switch conn := conn.(type) {
+	case fakeConn:
+		return conn.pool
+	case fakeTx:
+		return conn.pool
+	case *lockingTx:
+		return conn.pool
+	}
+	return ""
+}
+
+// Queries never reach the database, so transactions only need to end
+type fakeTx struct {
+	pgx.Tx
+	pool string
+}
+
+func (tx fakeTx) Begin(ctx context.Context) (pgx.Tx, error) { return tx, nil }
+func (fakeTx) Commit(ctx context.Context) error             { return nil }
+func (fakeTx) Rollback(ctx context.Context) error           { return nil }
+
+type fakeConn struct {
+	pkg.Conn
+	pool string
+}
+
+func (c fakeConn) Begin(ctx context.Context) (pgx.Tx, error) { return fakeTx{pool: c.pool}, nil }
+func (fakeConn) Release()                                    {}
+
+type fakePool struct {
+	pkg.Pool
+	name string
+}
+
+func (p fakePool) Acquire(ctx context.Context) (pkg.Conn, error) {
+	return fakeConn{pool: p.name}, nil
+}
+func (p fakePool) Begin(ctx context.Context) (pgx.Tx, error) { return fakeTx{pool: p.name}, nil }
+func (p fakePool) BeginTx(ctx context.Context, opts pgx.TxOptions) (pgx.Tx, error) {
+	return fakeTx{pool: p.name}, nil
+}
+func (fakePool) Ping(ctx context.Context) error { return nil }
+
+// Runs the functions registered with onEnd once the transaction ends, like
+// Postgres releases transaction-level advisory locks
+type lockingTx struct {
+	fakeTx
+	mu    sync.Mutex
+	ended bool
+	onEnd []func()
+}
+
+func (tx *lockingTx) Begin(ctx context.Context) (pgx.Tx, error) { return tx, nil }
+func (tx *lockingTx) Commit(ctx context.Context) error          { tx.end(); return nil }
+func (tx *lockingTx) Rollback(ctx context.Context) error        { tx.end(); return nil }
+
+func (tx *lockingTx) end() {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.ended {
+		return
+	}
+	tx.ended = true
+	for _, fn := range tx.onEnd {
+		fn()
+	}
+}
+
+// Registers fn to run when the transaction conn ends
+func onTxEnd(conn db.DBTX, fn func()) {
+	tx := conn.(*lockingTx)
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	tx.onEnd = append(tx.onEnd, fn)
+}
+
+// Begins lockingTx transactions
+type lockingPool struct {
+	fakePool
+}
+
+func (p lockingPool) Begin(ctx context.Context) (pgx.Tx, error) {
+	return &lockingTx{fakeTx: fakeTx{pool: p.name}}, nil
+}
+
+// Starts a server speaking just enough of the Postgres protocol to open
+// connections and answer pings, so that a real pgx pool can be exhausted
+// without a database
+func fakePostgres(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveFakePostgres(conn)
+		}
+	}()
+	return fmt.Sprintf("postgres://pulse@%s/pulse?sslmode=disable", ln.Addr())
+}
+
+func serveFakePostgres(conn net.Conn) {
+	defer conn.Close()
+	backend := pgproto3.NewBackend(conn, conn)
+	if _, err := backend.ReceiveStartupMessage(); err != nil {
+		return
+	}
+	backend.Send(&pgproto3.AuthenticationOk{})
+	backend.Send(&pgproto3.BackendKeyData{ProcessID: 1, SecretKey: 1})
+	backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+	if err := backend.Flush(); err != nil {
+		return
+	}
+
+	for {
+		msg, err := backend.Receive()
+		if err != nil {
+			return
+		}
+		switch msg.(type) {
+		case *pgproto3.Query:
+			backend.Send(&pgproto3.EmptyQueryResponse{})
+			backend.Send(&pgproto3.ReadyForQuery{TxStatus: 'I'})
+			if err := backend.Flush(); err != nil {
+				return
+			}
+		case *pgproto3.Terminate:
+			return
+		}
+	}
+}
+
+// Accepts every mail sent to it, recording the recipients
+type fakeMailServer struct {
+	mu         sync.Mutex
+	recipients []string
+}
+
+func (s *fakeMailServer) Recipients() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.recipients...)
+}
+
+// Points the mailer at a fake mail server for the duration of the test
+func useMailServer(t *testing.T) *fakeMailServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	server := &fakeMailServer{}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	portNum, _ := strconv.Atoi(port)
+	saved := cmd.EnvVars
+	env := *saved
+	env.SmtpHost, env.SmtpPort = host, portNum
+	env.GmailUser, env.AppPassword = "pulse@example.com", "password"
+	env.MailFromAddr = "pulse@example.com"
+	cmd.EnvVars = &env
+	t.Cleanup(func() { cmd.EnvVars = saved })
+	if err := pkg.InitMailSubjects("Season of Code", nil); err != nil {
+		t.Fatal(err)
+	}
+	return server
+}
+
+func (s *fakeMailServer) serve(conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 smtp.example.com ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			tp.PrintfLine("250-smtp.example.com")
+			tp.PrintfLine("250 AUTH PLAIN")
+		case "AUTH":
+			tp.PrintfLine("235 2.7.0 Accepted")
+		case "RCPT":
+			s.mu.Lock()
+			s.recipients = append(s.recipients, strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>"))
+			s.mu.Unlock()
+			tp.PrintfLine("250 2.1.5 OK")
+		case "DATA":
+			tp.PrintfLine("354 Go ahead")
+			if _, err := tp.ReadDotBytes(); err != nil {
+				return
+			}
+			tp.PrintfLine("250 2.0.0 OK")
+		case "QUIT":
+			tp.PrintfLine("221 2.0.0 Bye")
+			return
+		default:
+			tp.PrintfLine("250 2.0.0 OK")
+		}
+	}
+}
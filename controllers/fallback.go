@@ -0,0 +1,26 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (a *App) RouteNotFound(c *gin.Context) {
+	a.Log.Warn(
+		fmt.Sprintf("Unknown route requested at %s %s", c.Request.Method, c.Request.URL.Path))
+	c.JSON(http.StatusNotFound, gin.H{
+		"message":    "Requested resource does not exist",
+		"request_id": c.GetString("request_id"),
+	})
+}
+
+func (a *App) MethodNotAllowed(c *gin.Context) {
+	a.Log.Warn(
+		fmt.Sprintf("Unsupported method requested at %s %s", c.Request.Method, c.Request.URL.Path))
+	c.JSON(http.StatusMethodNotAllowed, gin.H{
+		"message":    "Method not allowed on the requested resource",
+		"request_id": c.GetString("request_id"),
+	})
+}
@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	mw "github.com/IAmRiteshKoushik/pulse/middleware"
+)
+
+func TestFallbackHandlers(t *testing.T) {
+	tests := []struct {
+		name          string
+		method        string
+		path          string
+		requestId     string // sent by an upstream proxy
+		wantStatus    int
+		wantMessage   string
+		wantRequestId string // empty when a fresh one is expected
+	}{
+		{
+			name:        "unknown path",
+			method:      http.MethodGet,
+			path:        "/api/v1/nothing-here",
+			wantStatus:  http.StatusNotFound,
+			wantMessage: "Requested resource does not exist",
+		},
+		{
+			name:        "unknown version",
+			method:      http.MethodGet,
+			path:        "/api/v2/leaderboard",
+			wantStatus:  http.StatusNotFound,
+			wantMessage: "Requested resource does not exist",
+		},
+		{
+			name:        "wrong method",
+			method:      http.MethodDelete,
+			path:        "/api/v1/leaderboard",
+			wantStatus:  http.StatusMethodNotAllowed,
+			wantMessage: "Method not allowed on the requested resource",
+		},
+		{
+			name:          "request ID of the proxy",
+			method:        http.MethodPut,
+			path:          "/api/v1/leaderboard",
+			requestId:     "edge-42",
+			wantStatus:    http.StatusMethodNotAllowed,
+			wantMessage:   "Method not allowed on the requested resource",
+			wantRequestId: "edge-42",
+		},
+	}
+
+	a := newTestApp(&fakeQuerier{})
+	a.Env = testEnv()
+	router, adminRouter, err := NewRouters(a.Env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.RegisterRoutes(router, adminRouter)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.requestId != "" {
+				req.Header.Set(mw.RequestIdHeader, tt.requestId)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			var body struct {
+				Message   string `json:"message"`
+				RequestId string `json:"request_id"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("body is not JSON: %v: %s", err, w.Body.String())
+			}
+			if body.Message != tt.wantMessage {
+				t.Errorf("message = %q, want %q", body.Message, tt.wantMessage)
+			}
+			if body.RequestId == "" || body.RequestId != w.Header().Get(mw.RequestIdHeader) {
+				t.Errorf("request_id = %q, header = %q", body.RequestId, w.Header().Get(mw.RequestIdHeader))
+			}
+			if tt.wantRequestId != "" && body.RequestId != tt.wantRequestId {
+				t.Errorf("request_id = %q, want %q", body.RequestId, tt.wantRequestId)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	db "github.com/IAmRiteshKoushik/pulse/db/gen"
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	"github.com/IAmRiteshKoushik/pulse/types"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var errGithubTokenExpired = errors.New("GitHub token expired or revoked")
+
+func (a *App) SyncGithubProfile(c *gin.Context) {
+	username, ok := pkg.GrabUsername(c)
+	if !ok {
+		a.Log.Warn(
+			fmt.Sprintf("Failed to extract username from token at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Oops! Something happened. Please try again later.",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), a.Env.TimeoutFor("sync_github"))
+	defer cancel()
+
+	conn, err := a.DB.Acquire(ctx)
+	if err != nil {
+		pkg.DbAcquireError(c, err)
+		return
+	}
+	defer conn.Release()
+
+	q := a.Queries
+	ghToken, err := q.FetchGithubTokenQuery(ctx, conn, username)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		pkg.DbError(c, err)
+		return
+	}
+	// Accounts which never logged in through GitHub have no token stored
+	accessToken, ok := a.openGithubToken(ghToken)
+	if !ok {
+		a.githubReauthRequired(c)
+		return
+	}
+
+	user, err := a.fetchGithubUser(ctx, accessToken)
+	if errors.Is(err, errGithubTokenExpired) {
+		a.githubReauthRequired(c)
+		return
+	}
+	if err != nil {
+		a.Log.Error(
+			fmt.Sprintf("Failed to fetch user info from GitHub at %s %s",
+				c.Request.Method, c.FullPath()), err)
+		c.JSON(http.StatusBadGateway, gin.H{
+			"message": "Could not reach GitHub. Please try again later.",
+		})
+		return
+	}
+
+	err = q.UpdateGithubProfileQuery(ctx, conn, db.UpdateGithubProfileQueryParams{
+		FullName:          user.Name,
+		AvatarUrl:         user.AvatarUrl,
+		GhID:              pgtype.Int8{Int64: user.ID, Valid: user.ID != 0},
+		Ghusername:        username,
+		DisplayGhusername: displayGhUsername(username, user.Username),
+	})
+	if err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "GitHub profile synced successfully",
+		"full_name":  user.Name,
+		"avatar_url": user.AvatarUrl,
+	})
+	a.Log.Info(fmt.Sprintf(
+		"[SUCCESS]: Processed request at %s %s",
+		c.Request.Method, c.FullPath(),
+	))
+	return
+}
+
+// The GitHub login supplies the display casing unless the account has been
+// renamed on GitHub since, as the stored username is then left unchanged
+func displayGhUsername(ghUsername string, login string) string {
+	if strings.EqualFold(ghUsername, login) {
+		return login
+	}
+	return ghUsername
+}
+
+// Clients are expected to send the user through the OAuth flow again
+func (a *App) githubReauthRequired(c *gin.Context) {
+	a.Log.Warn(
+		fmt.Sprintf("GitHub token missing or expired at %s %s",
+			c.Request.Method, c.FullPath()))
+	c.JSON(http.StatusUnauthorized, gin.H{
+		"message": "GitHub authorization has expired. Please login with GitHub again.",
+		"reauth":  "/api/v1/auth/github/url",
+	})
+}
+
+func (a *App) fetchGithubUser(ctx context.Context, accessToken string) (types.GithubUser, error) {
+	var user types.GithubUser
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.Env.GhApiUrl+"/user", nil)
+	if err != nil {
+		return user, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := a.Client.Do(req)
+	if err != nil {
+		return user, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return user, errGithubTokenExpired
+	}
+	if resp.StatusCode != http.StatusOK {
+		return user, fmt.Errorf("GitHub responded with status %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return user, err
+	}
+	if err := user.Validate(); err != nil {
+		return user, fmt.Errorf("Incomplete GitHub user: %w", err)
+	}
+	return user, nil
+}
+
+// GitHub tokens are only stored sealed with GITHUB_TOKEN_KEY. Tokens sealed
+// with a rotated key are treated as missing, the next GitHub login replaces
+// them.
+func (a *App) sealGithubToken(accessToken string) (pgtype.Text, error) {
+	if len(a.Env.GhTokenKey) == 0 {
+		return pgtype.Text{}, nil
+	}
+	sealed, err := pkg.SealSecret(a.Env.GhTokenKey, accessToken)
+	if err != nil {
+		return pgtype.Text{}, err
+	}
+	return pgtype.Text{String: sealed, Valid: true}, nil
+}
+
+func (a *App) openGithubToken(stored pgtype.Text) (string, bool) {
+	if len(a.Env.GhTokenKey) == 0 || !stored.Valid || stored.String == "" {
+		return "", false
+	}
+	accessToken, err := pkg.OpenSecret(a.Env.GhTokenKey, stored.String)
+	if err != nil {
+		a.Log.Warn(fmt.Sprintf("Stored GitHub token could not be decrypted: %v", err))
+		return "", false
+	}
+	return accessToken, true
+}
@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	"github.com/gin-gonic/gin"
+)
+
+// Checks are kept short as load balancers poll readiness frequently
+const readinessTimeout = 2 * time.Second
+
+// Reports whether this instance can serve requests. The mail provider is
+// probed in the background, so its last known state is used here.
+func (a *App) Readyz(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+	defer cancel()
+
+	ready := true
+	checks := gin.H{"database": "ok", "mail": "ok"}
+	if err := a.DB.Ping(ctx); err != nil {
+		a.Log.Error(
+			fmt.Sprintf("Database unreachable at %s %s", c.Request.Method, c.FullPath()), err)
+		checks["database"] = "unreachable"
+		ready = false
+	}
+	if a.Replica != a.DB {
+		checks["replica"] = "ok"
+		if err := a.Replica.Ping(ctx); err != nil {
+			a.Log.Error(
+				fmt.Sprintf("Database replica unreachable at %s %s", c.Request.Method, c.FullPath()), err)
+			checks["replica"] = "unreachable"
+			ready = false
+		}
+	}
+	if !pkg.MailReachable() {
+		checks["mail"] = "unreachable"
+		ready = false
+	}
+
+	if !ready {
+		seconds := pkg.SetRetryAfter(c, a.Env.UnavailableRetryAfter)
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"message":             "Server is not ready",
+			"checks":              checks,
+			"retry_after_seconds": seconds,
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Server is ready",
+		"checks":  checks,
+	})
+	return
+}
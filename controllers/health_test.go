@@ -0,0 +1,53 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	"github.com/gin-gonic/gin"
+)
+
+func TestReadyzMail(t *testing.T) {
+	t.Cleanup(func() { pkg.SetMailReachable(true) })
+
+	tests := []struct {
+		name       string
+		reachable  bool
+		wantStatus int
+		wantMail   string
+	}{
+		{name: "reachable", reachable: true, wantStatus: http.StatusOK, wantMail: "ok"},
+		{name: "unreachable", reachable: false, wantStatus: http.StatusServiceUnavailable, wantMail: "unreachable"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pkg.SetMailReachable(tt.reachable)
+			a := newTestApp(&fakeQuerier{})
+			a.Env = testEnv()
+			router := gin.New()
+			router.GET("/readyz", a.Readyz)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			var body struct {
+				Checks map[string]string `json:"checks"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Checks["mail"] != tt.wantMail || body.Checks["database"] != "ok" {
+				t.Errorf("checks = %v, want mail %s", body.Checks, tt.wantMail)
+			}
+			if !tt.reachable && w.Header().Get("Retry-After") == "" {
+				t.Error("Retry-After is not set")
+			}
+		})
+	}
+}
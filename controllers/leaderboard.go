@@ -1,19 +1,106 @@
 package controllers
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
-	"github.com/IAmRiteshKoushik/pulse/cmd"
+	db "github.com/IAmRiteshKoushik/pulse/db/gen"
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	"github.com/IAmRiteshKoushik/pulse/types"
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
-func FetchLeaderboard(c *gin.Context) {
+func (a *App) FetchLeaderboard(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Leaderboard WIP",
 	})
-	cmd.Log.Info(fmt.Sprintf(
+	a.Log.Info(fmt.Sprintf(
+		"[SUCCESS]: Processed request at %s %s",
+		c.Request.Method, c.FullPath(),
+	))
+	return
+}
+
+// The ranking is served from the latest snapshot taken on or before the
+// requested date, which is echoed back as snapshot_date
+func (a *App) FetchLeaderboardSnapshot(c *gin.Context) {
+	asOf, err := types.ParseSnapshotDate(c.Param("date"))
+	if err != nil {
+		a.Log.Warn(fmt.Sprintf("[INVALID-DATE]: Snapshot date is not YYYY-MM-DD at %s %s",
+			c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid date. Expected format is YYYY-MM-DD.",
+		})
+		return
+	}
+
+	var query types.FetchLeaderboardSnapshotRequest
+	if err := c.ShouldBindQuery(&query); err != nil {
+		pkg.RequestValidatorError(c, err)
+		return
+	}
+	if err := query.Validate(); err != nil {
+		pkg.RequestValidatorError(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), a.Env.TimeoutFor("leaderboard"))
+	defer cancel()
+
+	conn, err := a.Replica.Acquire(ctx)
+	if err != nil {
+		pkg.DbAcquireError(c, err)
+		return
+	}
+	defer conn.Release()
+
+	q := a.Queries
+	snapshotDate, err := q.FetchSnapshotDateQuery(ctx, conn,
+		pgtype.Date{Time: asOf, Valid: true})
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "No leaderboard snapshot exists on or before the given date",
+		})
+		return
+	}
+	if err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+
+	// Matches are escaped so that search stays a plain substring match
+	search := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(query.Search)
+	results, err := q.FetchLeaderboardSnapshotQuery(ctx, conn, db.FetchLeaderboardSnapshotQueryParams{
+		SnapshotDate:  snapshotDate,
+		AfterPosition: int32(query.After),
+		Search:        pgtype.Text{String: search, Valid: search != ""},
+		PageSize:      int32(query.PageSize),
+	})
+	if err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+
+	// A full page implies there may be more entries after the last one
+	var nextAfter *int32
+	if len(results) == query.PageSize {
+		nextAfter = &results[len(results)-1].Position
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Leaderboard snapshot retrieved successfully",
+		"snapshot_date": snapshotDate.Time.Format(time.DateOnly),
+		"ranking":       results,
+		"next_after":    nextAfter,
+	})
+	a.Log.Info(fmt.Sprintf(
 		"[SUCCESS]: Processed request at %s %s",
 		c.Request.Method, c.FullPath(),
 	))
@@ -0,0 +1,208 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	db "github.com/IAmRiteshKoushik/pulse/db/gen"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Rankings of leaderboard_snapshots by snapshot date, read the way the
+// snapshot queries read them
+type snapshotTable map[string][]db.FetchLeaderboardSnapshotQueryRow
+
+func (s snapshotTable) querier(t *testing.T) *fakeQuerier {
+	return &fakeQuerier{
+		fetchSnapshotDate: func(conn db.DBTX, asOf pgtype.Date) (pgtype.Date, error) {
+			if pool := poolOf(conn); pool != "replica" {
+				t.Errorf("snapshot read from the %s", pool)
+			}
+			var latest string
+			for date := range s {
+				if date <= asOf.Time.Format(time.DateOnly) && date > latest {
+					latest = date
+				}
+			}
+			if latest == "" {
+				return pgtype.Date{}, pgx.ErrNoRows
+			}
+			day, _ := time.Parse(time.DateOnly, latest)
+			return pgtype.Date{Time: day, Valid: true}, nil
+		},
+		fetchLeaderboardSnapshot: func(_ db.DBTX, arg db.FetchLeaderboardSnapshotQueryParams) ([]db.FetchLeaderboardSnapshotQueryRow, error) {
+			search := ilikePattern("%" + arg.Search.String + "%")
+			var page []db.FetchLeaderboardSnapshotQueryRow
+			for _, row := range s[arg.SnapshotDate.Time.Format(time.DateOnly)] {
+				if row.Position <= arg.AfterPosition || (arg.Search.Valid && !search.MatchString(row.Ghusername)) {
+					continue
+				}
+				if len(page) == int(arg.PageSize) {
+					break
+				}
+				page = append(page, row)
+			}
+			return page, nil
+		},
+	}
+}
+
+// Translates an ILIKE pattern, with backslash as its escape character
+func ilikePattern(pattern string) *regexp.Regexp {
+	var expr strings.Builder
+	expr.WriteString("(?is)^")
+	escaped := false
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '%':
+			expr.WriteString(".*")
+		case r == '_':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.MustCompile(expr.String())
+}
+
+func TestFetchLeaderboardSnapshot(t *testing.T) {
+	snapshots := snapshotTable{
+		"2026-10-05": {
+			{Position: 1, Rank: 1, Ghusername: "octocat", Bounty: 60},
+			{Position: 2, Rank: 2, Ghusername: "hubot", Bounty: 20},
+		},
+		"2026-10-12": {
+			{Position: 1, Rank: 1, Ghusername: "octocat", Bounty: 90},
+			{Position: 2, Rank: 2, Ghusername: "hubot", Bounty: 40},
+			{Position: 3, Rank: 2, Ghusername: "monalisa", Bounty: 40},
+			{Position: 4, Rank: 4, Ghusername: "octo_bot", Bounty: 10},
+		},
+	}
+
+	tests := []struct {
+		name          string
+		path          string
+		wantStatus    int
+		wantDate      string
+		wantUsernames []string
+		wantNextAfter int32 // 0 when no page follows
+	}{
+		{
+			name:          "snapshot of the date",
+			path:          "/2026-10-12",
+			wantStatus:    http.StatusOK,
+			wantDate:      "2026-10-12",
+			wantUsernames: []string{"octocat", "hubot", "monalisa", "octo_bot"},
+		},
+		{
+			name:          "latest snapshot before the date",
+			path:          "/2026-10-11",
+			wantStatus:    http.StatusOK,
+			wantDate:      "2026-10-05",
+			wantUsernames: []string{"octocat", "hubot"},
+		},
+		{
+			name:          "first page",
+			path:          "/2026-10-12?page_size=2",
+			wantStatus:    http.StatusOK,
+			wantDate:      "2026-10-12",
+			wantUsernames: []string{"octocat", "hubot"},
+			wantNextAfter: 2,
+		},
+		{
+			name:          "page after a position",
+			path:          "/2026-10-12?page_size=2&after=2",
+			wantStatus:    http.StatusOK,
+			wantDate:      "2026-10-12",
+			wantUsernames: []string{"monalisa", "octo_bot"},
+			wantNextAfter: 4,
+		},
+		{
+			name:          "search",
+			path:          "/2026-10-12?search=OCTO",
+			wantStatus:    http.StatusOK,
+			wantDate:      "2026-10-12",
+			wantUsernames: []string{"octocat", "octo_bot"},
+		},
+		{
+			// An underscore is matched as such, not as any character
+			name:          "search with wildcard characters",
+			path:          "/2026-10-12?search=o_",
+			wantStatus:    http.StatusOK,
+			wantDate:      "2026-10-12",
+			wantUsernames: []string{"octo_bot"},
+		},
+		{
+			name:       "before the first snapshot",
+			path:       "/2026-10-01",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "invalid date",
+			path:       "/12-10-2026",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "page size over the cap",
+			path:       "/2026-10-12?page_size=500",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestApp(snapshots.querier(t))
+			a.Env = testEnv()
+			router := gin.New()
+			router.GET("/api/v1/leaderboard/snapshots/:date", a.FetchLeaderboardSnapshot)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/leaderboard/snapshots"+tt.path, nil))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var body struct {
+				SnapshotDate string                                `json:"snapshot_date"`
+				Ranking      []db.FetchLeaderboardSnapshotQueryRow `json:"ranking"`
+				NextAfter    *int32                                `json:"next_after"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.SnapshotDate != tt.wantDate {
+				t.Errorf("snapshot_date = %q, want %q", body.SnapshotDate, tt.wantDate)
+			}
+			var usernames []string
+			for _, row := range body.Ranking {
+				usernames = append(usernames, row.Ghusername)
+			}
+			if !slices.Equal(usernames, tt.wantUsernames) {
+				t.Errorf("ranking = %v, want %v", usernames, tt.wantUsernames)
+			}
+			var nextAfter int32
+			if body.NextAfter != nil {
+				nextAfter = *body.NextAfter
+			}
+			if nextAfter != tt.wantNextAfter {
+				t.Errorf("next_after = %d, want %d", nextAfter, tt.wantNextAfter)
+			}
+		})
+	}
+}
@@ -0,0 +1,363 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	db "github.com/IAmRiteshKoushik/pulse/db/gen"
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	"github.com/IAmRiteshKoushik/pulse/types"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"golang.org/x/oauth2"
+)
+
+const (
+	providerGithub = "github"
+	providerGoogle = "google"
+)
+
+const googleUserInfoUrl = "https://openidconnect.googleapis.com/v1/userinfo"
+
+// Starts linking a GitHub account to the signed in user. GitHub calls back
+// the regular OAuth callback, which tells the flows apart by their state.
+func (a *App) StartGithubLink(c *gin.Context) {
+	a.startLink(c, providerGithub, a.OAuth)
+}
+
+func (a *App) StartGoogleLink(c *gin.Context) {
+	if a.Google == nil {
+		a.googleNotConfigured(c)
+		return
+	}
+	a.startLink(c, providerGoogle, a.Google)
+}
+
+func (a *App) startLink(c *gin.Context, provider string, cfg *oauth2.Config) {
+	username, ok := pkg.GrabUsername(c)
+	if !ok {
+		a.Log.Warn(
+			fmt.Sprintf("Failed to extract username from token at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Oops! Something happened. Please try again later.",
+		})
+		return
+	}
+
+	state, nonce, err := pkg.CreateLinkState(provider, username)
+	if err != nil {
+		a.Log.Error(
+			fmt.Sprintf("Failed to generate link state at %s %s",
+				c.Request.Method, c.FullPath()), err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Oops! Something happened. Please try again later.",
+		})
+		return
+	}
+
+	pkg.SetOAuthStateCookie(c, nonce)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Authorize URL generated successfully",
+		"url":     cfg.AuthCodeURL(state),
+	})
+	a.Log.Info(fmt.Sprintf(
+		"[SUCCESS]: Processed request at %s %s",
+		c.Request.Method, c.FullPath(),
+	))
+	return
+}
+
+// Attaches the GitHub ID to the user who started the link, called from
+// CompleteGitHubOAuth
+func (a *App) completeGithubLink(c *gin.Context, code string, state string) {
+	ghUsername, err := a.verifyLinkState(c, state, providerGithub)
+	if err != nil {
+		a.invalidLinkState(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), a.Env.TimeoutFor("oauth"))
+	defer cancel()
+
+	token, err := a.OAuth.Exchange(context.WithValue(ctx, oauth2.HTTPClient, a.Client), code)
+	if err != nil {
+		a.oauthExchangeFailed(c, err)
+		return
+	}
+	user, err := a.fetchGithubUser(ctx, token.AccessToken)
+	if err != nil {
+		a.Log.Error(
+			fmt.Sprintf("Failed to fetch user info from GitHub at %s %s",
+				c.Request.Method, c.FullPath()), err)
+		c.JSON(http.StatusBadGateway, gin.H{
+			"message": "Could not fetch the GitHub account. Please try again later.",
+		})
+		return
+	}
+
+	tx, err := a.DB.Begin(ctx)
+	if err != nil {
+		pkg.DbAcquireError(c, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	q := a.Queries
+	ghId := pgtype.Int8{Int64: user.ID, Valid: true}
+	owner, err := q.CheckUserExistByGithubIdQuery(ctx, tx, ghId)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		pkg.DbError(c, err)
+		return
+	}
+	if owner.Ghusername == ghUsername {
+		a.accountLinked(c, providerGithub)
+		return
+	}
+	if owner.Ghusername != "" {
+		a.identityTaken(c, providerGithub)
+		return
+	}
+
+	linked, err := q.LinkGithubIdQuery(ctx, tx, db.LinkGithubIdQueryParams{
+		GhID:       ghId,
+		Ghusername: ghUsername,
+	})
+	if pkg.IsUniqueViolation(err) {
+		a.identityTaken(c, providerGithub)
+		return
+	}
+	if err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+	if linked == 0 {
+		a.providerAlreadyLinked(c, providerGithub)
+		return
+	}
+
+	err = q.AddAuditLogQuery(ctx, tx, db.AddAuditLogQueryParams{
+		Actor:   ghUsername,
+		Action:  "account.link",
+		Target:  ghUsername,
+		Details: fmt.Sprintf("provider=%s subject=%d login=%s", providerGithub, user.ID, user.Username),
+	})
+	if err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+	a.accountLinked(c, providerGithub)
+	return
+}
+
+func (a *App) CompleteGoogleLink(c *gin.Context) {
+	if a.Google == nil {
+		a.googleNotConfigured(c)
+		return
+	}
+	code := c.Query("code")
+	if code == "" {
+		a.Log.Warn(
+			fmt.Sprintf("Missing authorization code in google oauth callback at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Missing authorization code",
+		})
+		return
+	}
+	ghUsername, err := a.verifyLinkState(c, c.Query("state"), providerGoogle)
+	if err != nil {
+		a.invalidLinkState(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), a.Env.TimeoutFor("oauth"))
+	defer cancel()
+
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, a.Client)
+	token, err := a.Google.Exchange(ctx, code)
+	if err != nil {
+		a.oauthExchangeFailed(c, err)
+		return
+	}
+	user, err := a.fetchGoogleUser(ctx, token)
+	if err != nil || user.Sub == "" {
+		a.Log.Error(
+			fmt.Sprintf("Failed to fetch user info from Google at %s %s",
+				c.Request.Method, c.FullPath()), err)
+		c.JSON(http.StatusBadGateway, gin.H{
+			"message": "Could not fetch the Google account. Please try again later.",
+		})
+		return
+	}
+
+	tx, err := a.DB.Begin(ctx)
+	if err != nil {
+		pkg.DbAcquireError(c, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	q := a.Queries
+	owner, err := q.FetchIdentityOwnerQuery(ctx, tx, db.FetchIdentityOwnerQueryParams{
+		Provider: providerGoogle,
+		Subject:  user.Sub,
+	})
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		pkg.DbError(c, err)
+		return
+	}
+	if owner == ghUsername {
+		a.accountLinked(c, providerGoogle)
+		return
+	}
+	if owner != "" {
+		a.identityTaken(c, providerGoogle)
+		return
+	}
+
+	account, err := q.CheckUserExistQuery(ctx, tx, ghUsername)
+	if errors.Is(err, pgx.ErrNoRows) {
+		a.Log.Warn(
+			fmt.Sprintf("Link completed for missing account at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "User not registered",
+		})
+		return
+	}
+	if err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+	email := ""
+	if user.EmailVerified {
+		email = pkg.NormalizeEmail(user.Email, a.Env.CollapseGmailDots)
+	}
+	err = q.AddIdentityQuery(ctx, tx, db.AddIdentityQueryParams{
+		Provider:   providerGoogle,
+		Subject:    user.Sub,
+		Ghusername: account.Ghusername,
+		Email:      email,
+	})
+	// Either the identity was linked concurrently or the user already has
+	// another Google account linked
+	if pkg.IsUniqueViolation(err) {
+		a.providerAlreadyLinked(c, providerGoogle)
+		return
+	}
+	if err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+
+	err = q.AddAuditLogQuery(ctx, tx, db.AddAuditLogQueryParams{
+		Actor:   account.Ghusername,
+		Action:  "account.link",
+		Target:  account.Ghusername,
+		Details: fmt.Sprintf("provider=%s subject=%s", providerGoogle, user.Sub),
+	})
+	if err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+	a.accountLinked(c, providerGoogle)
+	return
+}
+
+func (a *App) fetchGoogleUser(ctx context.Context, token *oauth2.Token) (types.GoogleUser, error) {
+	var user types.GoogleUser
+	resp, err := a.Google.Client(ctx, token).Get(googleUserInfoUrl)
+	if err != nil {
+		return user, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return user, fmt.Errorf("Google responded with status %d", resp.StatusCode)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&user)
+	return user, err
+}
+
+func (a *App) accountLinked(c *gin.Context, provider string) {
+	if a.Env.OAuthResponseMode == "redirect" {
+		c.Redirect(http.StatusFound, a.Env.FrontendUrl)
+	} else {
+		c.JSON(http.StatusOK, gin.H{
+			"message":  "Account linked successfully",
+			"provider": provider,
+		})
+	}
+	a.Log.Info(fmt.Sprintf(
+		"[SUCCESS]: Processed request at %s %s",
+		c.Request.Method, c.FullPath(),
+	))
+}
+
+func (a *App) identityTaken(c *gin.Context, provider string) {
+	a.Log.Warn(
+		fmt.Sprintf("Link of %s identity bound to another user at %s %s",
+			provider, c.Request.Method, c.FullPath()))
+	c.JSON(http.StatusConflict, gin.H{
+		"message": "This account is already linked to another user",
+	})
+}
+
+func (a *App) providerAlreadyLinked(c *gin.Context, provider string) {
+	a.Log.Warn(
+		fmt.Sprintf("Link of a second %s identity at %s %s",
+			provider, c.Request.Method, c.FullPath()))
+	c.JSON(http.StatusConflict, gin.H{
+		"message":  "Another account of this provider is already linked",
+		"provider": provider,
+	})
+}
+
+// The state cookie is single use, whether or not it matches
+func (a *App) verifyLinkState(c *gin.Context, state string, provider string) (string, error) {
+	nonce, _ := c.Cookie(pkg.OAuthStateCookie)
+	pkg.ClearOAuthStateCookie(c)
+	return pkg.VerifyLinkState(state, provider, nonce)
+}
+
+func (a *App) invalidLinkState(c *gin.Context, err error) {
+	a.Log.Error(
+		fmt.Sprintf("Invalid state in link callback at %s %s",
+			c.Request.Method, c.FullPath()), err)
+	c.JSON(http.StatusBadRequest, gin.H{
+		"message": "Invalid or expired state",
+	})
+}
+
+func (a *App) oauthExchangeFailed(c *gin.Context, err error) {
+	a.Log.Error(
+		fmt.Sprintf("Failed to exchange code for token at %s %s",
+			c.Request.Method, c.FullPath()), err)
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"message": "Oops! Something happened. Please try again later",
+	})
+}
+
+func (a *App) googleNotConfigured(c *gin.Context) {
+	a.Log.Warn(
+		fmt.Sprintf("Google link attempted without configuration at %s %s",
+			c.Request.Method, c.FullPath()))
+	c.JSON(http.StatusNotFound, gin.H{
+		"message": "Google sign-in is not available",
+	})
+}
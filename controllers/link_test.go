@@ -0,0 +1,350 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	db "github.com/IAmRiteshKoushik/pulse/db/gen"
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"golang.org/x/oauth2"
+)
+
+// GitHub IDs kept on user_account and the rows of user_identity, with the
+// constraints the queries rely on
+type identityTable struct {
+	ghIds      map[string]int64  // of each account, 0 when none is linked
+	identities map[string]string // owner of each "provider|subject"
+	emails     map[string]string // stored with each "provider|subject"
+	audits     []db.AddAuditLogQueryParams
+}
+
+func (tbl *identityTable) querier() *fakeQuerier {
+	return &fakeQuerier{
+		checkUserExist: func(_ db.DBTX, ghUsername string) (db.CheckUserExistQueryRow, error) {
+			if _, ok := tbl.ghIds[ghUsername]; !ok {
+				return db.CheckUserExistQueryRow{}, pgx.ErrNoRows
+			}
+			return db.CheckUserExistQueryRow{Ghusername: ghUsername}, nil
+		},
+		checkUserExistByGithubId: func(_ db.DBTX, ghId pgtype.Int8) (db.CheckUserExistByGithubIdQueryRow, error) {
+			for username, id := range tbl.ghIds {
+				if id == ghId.Int64 {
+					return db.CheckUserExistByGithubIdQueryRow{Ghusername: username}, nil
+				}
+			}
+			return db.CheckUserExistByGithubIdQueryRow{}, pgx.ErrNoRows
+		},
+		linkGithubId: func(_ db.DBTX, arg db.LinkGithubIdQueryParams) (int64, error) {
+			id, ok := tbl.ghIds[arg.Ghusername]
+			if !ok || (id != 0 && id != arg.GhID.Int64) {
+				return 0, nil
+			}
+			tbl.ghIds[arg.Ghusername] = arg.GhID.Int64
+			return 1, nil
+		},
+		fetchIdentityOwner: func(_ db.DBTX, arg db.FetchIdentityOwnerQueryParams) (string, error) {
+			owner, ok := tbl.identities[arg.Provider+"|"+arg.Subject]
+			if !ok {
+				return "", pgx.ErrNoRows
+			}
+			return owner, nil
+		},
+		addIdentity: func(_ db.DBTX, arg db.AddIdentityQueryParams) error {
+			key := arg.Provider + "|" + arg.Subject
+			for existing, owner := range tbl.identities {
+				provider, _, _ := strings.Cut(existing, "|")
+				if existing == key || (owner == arg.Ghusername && provider == arg.Provider) {
+					return &pgconn.PgError{Code: "23505"}
+				}
+			}
+			tbl.identities[key] = arg.Ghusername
+			tbl.emails[key] = arg.Email
+			return nil
+		},
+		addAuditLog: func(_ db.DBTX, arg db.AddAuditLogQueryParams) error {
+			tbl.audits = append(tbl.audits, arg)
+			return nil
+		},
+	}
+}
+
+// Starts a link as the signed in user with start, then follows the provider
+// back to callback the way a browser would, returning the callback response
+func linkAccount(t *testing.T, username string, start gin.HandlerFunc, callbackPath string,
+	callback gin.HandlerFunc, sendCookie bool) *httptest.ResponseRecorder {
+	t.Helper()
+	router := gin.New()
+	router.POST("/api/v1/me/link", func(c *gin.Context) { c.Set("username", username) }, start)
+	router.GET(callbackPath, callback)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/me/link", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("start status = %d: %s", w.Code, w.Body.String())
+	}
+	var body struct {
+		Url string `json:"url"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	authorize, err := url.Parse(body.Url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := authorize.Query().Get("state")
+
+	req := httptest.NewRequest(http.MethodGet, callbackPath+"?code=abc&state="+url.QueryEscape(state), nil)
+	if sendCookie {
+		for _, cookie := range w.Result().Cookies() {
+			req.AddCookie(cookie)
+		}
+	}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestGithubLink(t *testing.T) {
+	const octocatId = 583231 // served by fakeGithub
+	tests := []struct {
+		name        string
+		ghIds       map[string]int64
+		noCookie    bool
+		wantStatus  int
+		wantMessage string
+		wantGhIds   map[string]int64
+		wantAudit   bool
+	}{
+		{
+			name:        "unlinked account",
+			ghIds:       map[string]int64{"octocat": 0},
+			wantStatus:  http.StatusOK,
+			wantMessage: "Account linked successfully",
+			wantGhIds:   map[string]int64{"octocat": octocatId},
+			wantAudit:   true,
+		},
+		{
+			name:        "same identity linked already",
+			ghIds:       map[string]int64{"octocat": octocatId},
+			wantStatus:  http.StatusOK,
+			wantMessage: "Account linked successfully",
+			wantGhIds:   map[string]int64{"octocat": octocatId},
+		},
+		{
+			name:        "identity bound to another user",
+			ghIds:       map[string]int64{"octocat": 0, "hubot": octocatId},
+			wantStatus:  http.StatusConflict,
+			wantMessage: "This account is already linked to another user",
+			wantGhIds:   map[string]int64{"octocat": 0, "hubot": octocatId},
+		},
+		{
+			name:        "another GitHub account linked",
+			ghIds:       map[string]int64{"octocat": 1},
+			wantStatus:  http.StatusConflict,
+			wantMessage: "Another account of this provider is already linked",
+			wantGhIds:   map[string]int64{"octocat": 1},
+		},
+		{
+			// The callback could have been opened in a victim's browser
+			name:        "state cookie missing",
+			ghIds:       map[string]int64{"octocat": 0},
+			noCookie:    true,
+			wantStatus:  http.StatusBadRequest,
+			wantMessage: "Invalid or expired state",
+			wantGhIds:   map[string]int64{"octocat": 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tbl := &identityTable{ghIds: tt.ghIds}
+			a := newTestApp(tbl.querier())
+			a.Env = testEnv()
+			github := fakeGithub(t)
+			a.Env.GhApiUrl = github.URL
+			a.OAuth = githubOAuthConfig(github)
+			a.Client = github.Client()
+
+			w := linkAccount(t, "octocat", a.StartGithubLink,
+				"/api/v1/auth/github/callback", a.CompleteGitHubOAuth, !tt.noCookie)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), tt.wantMessage) {
+				t.Errorf("body = %s, want %q", w.Body.String(), tt.wantMessage)
+			}
+			for username, want := range tt.wantGhIds {
+				if got := tbl.ghIds[username]; got != want {
+					t.Errorf("gh_id of %s = %d, want %d", username, got, want)
+				}
+			}
+			if (len(tbl.audits) == 1) != tt.wantAudit || len(tbl.audits) > 1 {
+				t.Fatalf("audit records = %+v, want one %v", tbl.audits, tt.wantAudit)
+			}
+			if tt.wantAudit && (tbl.audits[0].Action != "account.link" || tbl.audits[0].Target != "octocat") {
+				t.Errorf("audit record = %+v", tbl.audits[0])
+			}
+		})
+	}
+}
+
+// Sends every request to target whatever its host, standing in for Google's
+// fixed userinfo endpoint
+type redirectTransport struct {
+	target *url.URL
+}
+
+func (t redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme, req.URL.Host = t.target.Scheme, t.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func fakeGoogle(t *testing.T, userinfo string) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token": "ya29.token", "token_type": "Bearer"}`))
+	})
+	mux.HandleFunc("GET /v1/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer ya29.token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(userinfo))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestGoogleLink(t *testing.T) {
+	const verified = `{"sub": "1098", "email": "Octo.Cat@Example.com", "email_verified": true}`
+	tests := []struct {
+		name           string
+		userinfo       string
+		identities     map[string]string
+		wantStatus     int
+		wantMessage    string
+		wantIdentities map[string]string
+		wantEmail      string // stored with the linked identity
+	}{
+		{
+			name:           "new identity",
+			userinfo:       verified,
+			identities:     map[string]string{},
+			wantStatus:     http.StatusOK,
+			wantMessage:    "Account linked successfully",
+			wantIdentities: map[string]string{"google|1098": "octocat"},
+			wantEmail:      "octo.cat@example.com",
+		},
+		{
+			name:           "unverified email",
+			userinfo:       `{"sub": "1098", "email": "octo@example.com", "email_verified": false}`,
+			identities:     map[string]string{},
+			wantStatus:     http.StatusOK,
+			wantMessage:    "Account linked successfully",
+			wantIdentities: map[string]string{"google|1098": "octocat"},
+		},
+		{
+			name:           "same identity linked already",
+			userinfo:       verified,
+			identities:     map[string]string{"google|1098": "octocat"},
+			wantStatus:     http.StatusOK,
+			wantMessage:    "Account linked successfully",
+			wantIdentities: map[string]string{"google|1098": "octocat"},
+		},
+		{
+			name:           "identity bound to another user",
+			userinfo:       verified,
+			identities:     map[string]string{"google|1098": "hubot"},
+			wantStatus:     http.StatusConflict,
+			wantMessage:    "This account is already linked to another user",
+			wantIdentities: map[string]string{"google|1098": "hubot"},
+		},
+		{
+			name:           "another Google account linked",
+			userinfo:       verified,
+			identities:     map[string]string{"google|2077": "octocat"},
+			wantStatus:     http.StatusConflict,
+			wantMessage:    "Another account of this provider is already linked",
+			wantIdentities: map[string]string{"google|2077": "octocat"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tbl := &identityTable{
+				ghIds:      map[string]int64{"octocat": 583231, "hubot": 0},
+				identities: tt.identities,
+				emails:     map[string]string{},
+			}
+			a := newTestApp(tbl.querier())
+			a.Env = testEnv()
+			google := fakeGoogle(t, tt.userinfo)
+			target, _ := url.Parse(google.URL)
+			a.Client = &http.Client{Transport: redirectTransport{target: target}}
+			a.Google = &oauth2.Config{
+				ClientID:     "client",
+				ClientSecret: "secret",
+				Endpoint: oauth2.Endpoint{
+					AuthURL:   "https://accounts.google.com/o/oauth2/v2/auth",
+					TokenURL:  google.URL + "/token",
+					AuthStyle: oauth2.AuthStyleInParams,
+				},
+			}
+
+			w := linkAccount(t, "octocat", a.StartGoogleLink,
+				"/api/v1/auth/google/callback", a.CompleteGoogleLink, true)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), tt.wantMessage) {
+				t.Errorf("body = %s, want %q", w.Body.String(), tt.wantMessage)
+			}
+			if len(tbl.identities) != len(tt.wantIdentities) {
+				t.Errorf("identities = %v, want %v", tbl.identities, tt.wantIdentities)
+			}
+			for key, want := range tt.wantIdentities {
+				if got := tbl.identities[key]; got != want {
+					t.Errorf("owner of %s = %q, want %q", key, got, want)
+				}
+			}
+			if got := tbl.emails["google|1098"]; got != tt.wantEmail {
+				t.Errorf("stored email = %q, want %q", got, tt.wantEmail)
+			}
+		})
+	}
+
+	// A state issued for GitHub is not accepted by the Google callback
+	tbl := &identityTable{ghIds: map[string]int64{"octocat": 583231}, identities: map[string]string{}}
+	a := newTestApp(tbl.querier())
+	a.Google = &oauth2.Config{}
+	state, nonce, err := pkg.CreateLinkState("github", "octocat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := gin.New()
+	router.GET("/api/v1/auth/google/callback", a.CompleteGoogleLink)
+	req := httptest.NewRequest(http.MethodGet,
+		"/api/v1/auth/google/callback?code=abc&state="+url.QueryEscape(state), nil)
+	req.AddCookie(&http.Cookie{Name: pkg.OAuthStateCookie, Value: nonce})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "Invalid or expired state") {
+		t.Errorf("GitHub state at the Google callback: status = %d, body = %s", w.Code, w.Body.String())
+	}
+}
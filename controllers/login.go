@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	db "github.com/IAmRiteshKoushik/pulse/db/gen"
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Records the login and reports whether it came from an IP address not seen
+// in the user's recent logins. A user's first login is never flagged.
+func (a *App) recordLogin(ctx context.Context, c *gin.Context, conn db.DBTX,
+	ghUsername string) (bool, error) {
+	ip := pkg.ClientIP(c)
+	userAgent := c.Request.UserAgent()
+
+	q := a.Queries
+	history, err := q.LoginHistoryQuery(ctx, conn, ghUsername)
+	if err != nil {
+		return false, err
+	}
+	err = q.AddLoginHistoryQuery(ctx, conn, db.AddLoginHistoryQueryParams{
+		Ghusername: ghUsername,
+		Ip:         ip,
+		UserAgent:  userAgent,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if len(history) == 0 {
+		return false, nil
+	}
+	for _, login := range history {
+		if login.Ip == ip {
+			return false, nil
+		}
+	}
+
+	err = q.AddAuditLogQuery(ctx, conn, db.AddAuditLogQueryParams{
+		Actor:   ghUsername,
+		Action:  "login.new_device",
+		Target:  ghUsername,
+		Details: fmt.Sprintf("ip=%s user_agent=%q", ip, userAgent),
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (a *App) sendLoginAlert(ghUsername string, email string, ip string, userAgent string) {
+	if !a.notificationEnabled(ghUsername, pkg.MailLoginAlert) {
+		return
+	}
+	if err := pkg.SendLoginAlertMail(email, ip, userAgent); err != nil {
+		a.Log.Error("Failed to send login alert mail", err)
+	}
+}
+
+// Issues an access and refresh token pair for a verified user and records
+// the refresh token as a new session bound to the requesting client. Both
+// tokens are bound to clientId when set.
+func (a *App) startSession(ctx context.Context, c *gin.Context, tx pgx.Tx,
+	ghUsername, email, clientId string) (string, string, db.FetchLoginUserQueryRow, bool) {
+	var loginUser db.FetchLoginUserQueryRow
+
+	q := a.Queries
+	scopes, err := userScopes(ctx, q, tx, ghUsername)
+	if err != nil {
+		pkg.DbError(c, err)
+		return "", "", loginUser, false
+	}
+	authTime := time.Now()
+	accessToken, err := pkg.CreateToken(a.Env, ghUsername, email, "access_token",
+		pkg.WithScopes(scopes...), pkg.WithClient(clientId), pkg.WithAuthTime(authTime))
+	if err != nil {
+		a.Log.Error(
+			fmt.Sprintf("Failed to create access token at %s %s", c.Request.Method, c.FullPath()),
+			err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Oops! Something happened. Please try again later",
+		})
+		return "", "", loginUser, false
+	}
+	refreshJti := uuid.New()
+	refreshToken, err := pkg.CreateToken(a.Env, ghUsername, email, "refresh_token",
+		pkg.WithTokenId(refreshJti.String()), pkg.WithClient(clientId), pkg.WithAuthTime(authTime))
+	if err != nil {
+		a.Log.Error(
+			fmt.Sprintf("Failed to create token at %s %s", c.Request.Method, c.FullPath()),
+			err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Oops! Something happened. Please try again later",
+		})
+		return "", "", loginUser, false
+	}
+
+	// Only the jti of the refresh token is stored, as its session
+	loginUser, err = q.FetchLoginUserQuery(ctx, tx, ghUsername)
+	if err != nil {
+		pkg.DbError(c, err)
+		return "", "", loginUser, false
+	}
+	fingerprint := pkg.ClientFingerprint(c)
+	err = q.AddSessionQuery(ctx, tx, db.AddSessionQueryParams{
+		Jti:         refreshJti,
+		Ghusername:  loginUser.Ghusername,
+		UaHash:      fingerprint.UaHash,
+		IpClass:     fingerprint.IpClass,
+		DeviceLabel: pkg.ParseUserAgent(c.Request.UserAgent()),
+		Ttl:         pgtype.Interval{Microseconds: a.Env.RefreshSessionTTL().Microseconds(), Valid: true},
+		MaxAge:      pgtype.Interval{Microseconds: a.Env.RefreshTokenMaxAge.Microseconds(), Valid: true},
+	})
+	if err != nil {
+		pkg.DbError(c, err)
+		return "", "", loginUser, false
+	}
+	return accessToken, refreshToken, loginUser, true
+}
@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	db "github.com/IAmRiteshKoushik/pulse/db/gen"
+	"github.com/gin-gonic/gin"
+)
+
+// Rows of login_history and the audit entries written alongside them
+type loginBook struct {
+	history map[string][]db.LoginHistoryQueryRow
+	audits  []db.AddAuditLogQueryParams
+}
+
+func (b *loginBook) querier() *fakeQuerier {
+	return &fakeQuerier{
+		loginHistory: func(_ db.DBTX, ghUsername string) ([]db.LoginHistoryQueryRow, error) {
+			return b.history[ghUsername], nil
+		},
+		addLoginHistory: func(_ db.DBTX, arg db.AddLoginHistoryQueryParams) error {
+			b.history[arg.Ghusername] = append(b.history[arg.Ghusername],
+				db.LoginHistoryQueryRow{Ip: arg.Ip, UserAgent: arg.UserAgent})
+			return nil
+		},
+		addAuditLog: func(_ db.DBTX, arg db.AddAuditLogQueryParams) error {
+			b.audits = append(b.audits, arg)
+			return nil
+		},
+	}
+}
+
+func TestRecordLogin(t *testing.T) {
+	logins := []struct {
+		username    string
+		remoteAddr  string
+		wantFlagged bool
+	}{
+		// A first login has nothing to compare against
+		{username: "octocat", remoteAddr: "203.0.113.7:52100"},
+		{username: "octocat", remoteAddr: "203.0.113.7:52311"},
+		{username: "octocat", remoteAddr: "198.51.100.23:40000", wantFlagged: true},
+		{username: "octocat", remoteAddr: "198.51.100.23:40001"},
+		{username: "octocat", remoteAddr: "203.0.113.7:52100"},
+		// Seen for octocat, but new to hubot
+		{username: "hubot", remoteAddr: "192.0.2.1:1234"},
+		{username: "hubot", remoteAddr: "198.51.100.23:40000", wantFlagged: true},
+	}
+
+	book := &loginBook{history: map[string][]db.LoginHistoryQueryRow{}}
+	a := newTestApp(book.querier())
+	a.Env = testEnv()
+
+	wantAudits := 0
+	for i, login := range logins {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/auth/github/callback", nil)
+		c.Request.RemoteAddr = login.remoteAddr
+		c.Request.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64) Firefox/130.0")
+
+		flagged, err := a.recordLogin(context.Background(), c, fakeConn{}, login.username)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if flagged != login.wantFlagged {
+			t.Errorf("login %d of %s from %s flagged = %v, want %v",
+				i+1, login.username, login.remoteAddr, flagged, login.wantFlagged)
+		}
+		if login.wantFlagged {
+			wantAudits++
+		}
+	}
+
+	if got := len(book.history["octocat"]) + len(book.history["hubot"]); got != len(logins) {
+		t.Errorf("recorded %d logins, want %d", got, len(logins))
+	}
+	if len(book.audits) != wantAudits {
+		t.Fatalf("audit entries = %+v, want %d", book.audits, wantAudits)
+	}
+	want := db.AddAuditLogQueryParams{
+		Actor:   "octocat",
+		Action:  "login.new_device",
+		Target:  "octocat",
+		Details: `ip=198.51.100.23 user_agent="Mozilla/5.0 (X11; Linux x86_64) Firefox/130.0"`,
+	}
+	if book.audits[0] != want {
+		t.Errorf("audit entry = %+v, want %+v", book.audits[0], want)
+	}
+}
+
+func TestLoginAnomalyDetectionToggle(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		book := &loginBook{history: map[string][]db.LoginHistoryQueryRow{}}
+		q := loginQuerier()
+		history := book.querier()
+		q.loginHistory, q.addLoginHistory = history.loginHistory, history.addLoginHistory
+		q.addAuditLog = history.addAuditLog
+		a := newTestApp(q)
+		a.Env = testEnv()
+		a.Env.OAuthResponseMode = "json"
+		a.Env.LoginAnomalyDetection = enabled
+
+		w := githubLogin(t, a, fakeGithub(t))
+		if w.Code != http.StatusOK {
+			t.Fatalf("enabled %v: status = %d: %s", enabled, w.Code, w.Body.String())
+		}
+		if recorded := len(book.history["octocat"]) == 1; recorded != enabled {
+			t.Errorf("enabled %v: login recorded = %v", enabled, recorded)
+		}
+	}
+}
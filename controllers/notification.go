@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	db "github.com/IAmRiteshKoushik/pulse/db/gen"
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	"github.com/IAmRiteshKoushik/pulse/types"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+func (a *App) FetchNotifications(c *gin.Context) {
+	username, ok := pkg.GrabUsername(c)
+	if !ok {
+		a.Log.Warn(
+			fmt.Sprintf("Failed to extract username from token at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Oops! Something happened. Please try again later.",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), a.Env.TimeoutFor("profile"))
+	defer cancel()
+
+	conn, err := a.DB.Acquire(ctx)
+	if err != nil {
+		pkg.DbAcquireError(c, err)
+		return
+	}
+	defer conn.Release()
+
+	preferences, err := a.notificationPreferences(ctx, conn, username)
+	if err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Notification preferences retrived successfully",
+		"notifications": preferences,
+	})
+	a.Log.Info(fmt.Sprintf(
+		"[SUCCESS]: Processed request at %s %s",
+		c.Request.Method, c.FullPath(),
+	))
+	return
+}
+
+func (a *App) UpdateNotifications(c *gin.Context) {
+	username, ok := pkg.GrabUsername(c)
+	if !ok {
+		a.Log.Warn(
+			fmt.Sprintf("Failed to extract username from token at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Oops! Something happened. Please try again later.",
+		})
+		return
+	}
+
+	var body types.NotificationPreferencesRequest
+	if err := c.BindJSON(&body); err != nil {
+		pkg.JSONUnmarshallError(c, err)
+		return
+	}
+	if err := body.Validate(); err != nil {
+		pkg.RequestValidatorError(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), a.Env.TimeoutFor("profile"))
+	defer cancel()
+
+	q := a.Queries
+	var preferences map[string]bool
+	err := pkg.RunInTx(ctx, a.DB, func(tx pgx.Tx) error {
+		for kind, enabled := range body.Notifications {
+			err := q.SetNotificationPreferenceQuery(ctx, tx, db.SetNotificationPreferenceQueryParams{
+				Ghusername: username,
+				Kind:       kind,
+				Enabled:    enabled,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		var err error
+		preferences, err = a.notificationPreferences(ctx, tx, username)
+		return err
+	})
+	if err != nil {
+		pkg.TxError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Notification preferences updated successfully",
+		"notifications": preferences,
+	})
+	a.Log.Info(fmt.Sprintf(
+		"[SUCCESS]: Processed request at %s %s",
+		c.Request.Method, c.FullPath(),
+	))
+	return
+}
+
+func (a *App) notificationPreferences(ctx context.Context, conn db.DBTX,
+	ghUsername string) (map[string]bool, error) {
+	rows, err := a.Queries.FetchNotificationPreferencesQuery(ctx, conn, ghUsername)
+	if err != nil {
+		return nil, err
+	}
+	stored := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		stored[row.Kind] = row.Enabled
+	}
+	return pkg.NotificationPreferences(stored), nil
+}
+
+// Reports whether the user still wants the notification. Lookup failures
+// err on the side of sending, as notifications are on by default.
+func (a *App) notificationEnabled(ghUsername string, kind pkg.MailType) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), a.Env.TimeoutFor("profile"))
+	defer cancel()
+
+	enabled, err := a.Queries.NotificationEnabledQuery(ctx, a.DB, db.NotificationEnabledQueryParams{
+		Ghusername: ghUsername,
+		Kind:       string(kind),
+	})
+	if err != nil {
+		a.Log.Error("Failed to look up notification preference", err)
+		return true
+	}
+	return enabled
+}
@@ -0,0 +1,165 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	db "github.com/IAmRiteshKoushik/pulse/db/gen"
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	"github.com/gin-gonic/gin"
+)
+
+// Rows of notification_preference by username and kind, read the way the
+// notification queries read them
+type preferenceTable map[string]map[string]bool
+
+func (p preferenceTable) querier() *fakeQuerier {
+	return &fakeQuerier{
+		fetchNotificationPreferences: func(_ db.DBTX, ghUsername string) ([]db.FetchNotificationPreferencesQueryRow, error) {
+			var rows []db.FetchNotificationPreferencesQueryRow
+			for kind, enabled := range p[ghUsername] {
+				rows = append(rows, db.FetchNotificationPreferencesQueryRow{Kind: kind, Enabled: enabled})
+			}
+			return rows, nil
+		},
+		setNotificationPreference: func(_ db.DBTX, arg db.SetNotificationPreferenceQueryParams) error {
+			if p[arg.Ghusername] == nil {
+				p[arg.Ghusername] = map[string]bool{}
+			}
+			p[arg.Ghusername][arg.Kind] = arg.Enabled
+			return nil
+		},
+		notificationEnabled: func(_ db.DBTX, arg db.NotificationEnabledQueryParams) (bool, error) {
+			enabled, ok := p[arg.Ghusername][arg.Kind]
+			return enabled || !ok, nil
+		},
+	}
+}
+
+func TestNotificationPreferences(t *testing.T) {
+	tests := []struct {
+		name       string
+		stored     map[string]bool
+		method     string
+		body       string
+		wantStatus int
+		want       map[string]bool
+	}{
+		{name: "defaults all on", method: http.MethodGet, wantStatus: http.StatusOK,
+			want: map[string]bool{"login_alert": true}},
+		{name: "stored", stored: map[string]bool{"login_alert": false}, method: http.MethodGet,
+			wantStatus: http.StatusOK, want: map[string]bool{"login_alert": false}},
+		// Kinds no longer sent are left out
+		{name: "retired kind", stored: map[string]bool{"weekly_digest": false}, method: http.MethodGet,
+			wantStatus: http.StatusOK, want: map[string]bool{"login_alert": true}},
+		{name: "turned off", method: http.MethodPatch, body: `{"notifications": {"login_alert": false}}`,
+			wantStatus: http.StatusOK, want: map[string]bool{"login_alert": false}},
+		{name: "turned back on", stored: map[string]bool{"login_alert": false}, method: http.MethodPatch,
+			body: `{"notifications": {"login_alert": true}}`, wantStatus: http.StatusOK,
+			want: map[string]bool{"login_alert": true}},
+		{name: "unknown kind", method: http.MethodPatch, body: `{"notifications": {"newsletter": false}}`,
+			wantStatus: http.StatusBadRequest},
+		{name: "nothing to change", method: http.MethodPatch, body: `{"notifications": {}}`,
+			wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := preferenceTable{}
+			if tt.stored != nil {
+				table["octocat"] = maps.Clone(tt.stored)
+			}
+			a := newTestApp(table.querier())
+			a.Env = testEnv()
+			router := gin.New()
+			me := router.Group("/api/v1/me", func(c *gin.Context) {
+				c.Set("username", "octocat")
+			})
+			me.GET("/notifications", a.FetchNotifications)
+			me.PATCH("/notifications", a.UpdateNotifications)
+
+			req := httptest.NewRequest(tt.method, "/api/v1/me/notifications", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				if len(table["octocat"]) != len(tt.stored) {
+					t.Errorf("stored %v after a rejected update", table["octocat"])
+				}
+				return
+			}
+			var body struct {
+				Notifications map[string]bool `json:"notifications"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if !maps.Equal(body.Notifications, tt.want) {
+				t.Errorf("notifications = %v, want %v", body.Notifications, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoginAlertPreference(t *testing.T) {
+	tests := []struct {
+		name      string
+		stored    map[string]bool
+		lookupErr error
+		wantSent  bool
+	}{
+		{name: "on by default", wantSent: true},
+		{name: "turned on", stored: map[string]bool{"login_alert": true}, wantSent: true},
+		{name: "turned off", stored: map[string]bool{"login_alert": false}},
+		{name: "other kind turned off", stored: map[string]bool{"bounty_awarded": false}, wantSent: true},
+		// Failing to look the preference up errs on the side of sending
+		{name: "lookup failing", lookupErr: errors.New("connection reset"), wantSent: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := useMailServer(t)
+			table := preferenceTable{"octocat": tt.stored}
+			q := table.querier()
+			if tt.lookupErr != nil {
+				q.notificationEnabled = func(db.DBTX, db.NotificationEnabledQueryParams) (bool, error) {
+					return false, tt.lookupErr
+				}
+			}
+			a := newTestApp(q)
+			a.Env = testEnv()
+
+			a.sendLoginAlert("octocat", "octo@example.com", "203.0.113.7", "Mozilla/5.0")
+
+			var want []string
+			if tt.wantSent {
+				want = []string{"octo@example.com"}
+			}
+			if got := server.Recipients(); !slices.Equal(got, want) {
+				t.Errorf("mailed %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+// Only mails users can opt out of have a preference
+func TestNotificationKinds(t *testing.T) {
+	for _, kind := range []pkg.MailType{pkg.MailOtp, pkg.MailStaff} {
+		if pkg.IsNotification(string(kind)) {
+			t.Errorf("%s can be turned off", kind)
+		}
+	}
+	if !pkg.IsNotification(string(pkg.MailLoginAlert)) {
+		t.Errorf("%s cannot be turned off", pkg.MailLoginAlert)
+	}
+}
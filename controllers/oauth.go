@@ -3,29 +3,120 @@ package controllers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
 
-	"github.com/IAmRiteshKoushik/pulse/cmd"
 	db "github.com/IAmRiteshKoushik/pulse/db/gen"
 	"github.com/IAmRiteshKoushik/pulse/pkg"
 	"github.com/IAmRiteshKoushik/pulse/types"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
+	"golang.org/x/oauth2"
 )
 
-func InitiateGitHubOAuth(c *gin.Context) {
-	url := cmd.GithubOAuthConfig.AuthCodeURL("")
+func (a *App) InitiateGitHubOAuth(c *gin.Context) {
+	url, ok := a.githubAuthorizeUrl(c)
+	if !ok {
+		return
+	}
 	c.Redirect(http.StatusTemporaryRedirect, url)
 }
 
-func CompleteGitHubOAuth(c *gin.Context) {
+// For SPA clients that navigate to the authorize URL themselves
+func (a *App) FetchGitHubOAuthUrl(c *gin.Context) {
+	url, ok := a.githubAuthorizeUrl(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Authorize URL generated successfully",
+		"url":     url,
+	})
+	a.Log.Info(fmt.Sprintf(
+		"[SUCCESS]: Processed request at %s %s",
+		c.Request.Method, c.FullPath(),
+	))
+	return
+}
+
+// Clients may pick another allowed redirect URI with redirect_uri, otherwise
+// the one configured with GITHUB_REDIRECT_URL is used. Tokens are bound to the
+// client application named by client_id, if any.
+func (a *App) githubAuthorizeUrl(c *gin.Context) (string, bool) {
+	redirectUri := c.Query("redirect_uri")
+	if redirectUri != "" && !slices.Contains(a.Env.GhRedirectUrls, redirectUri) {
+		a.redirectUriNotAllowed(c, redirectUri)
+		return "", false
+	}
+	clientId := c.Query("client_id")
+	if err := pkg.CheckClientId(a.Env, clientId); err != nil {
+		a.clientIdNotAllowed(c, clientId)
+		return "", false
+	}
+	state, nonce, err := pkg.CreateOAuthState(redirectUri, clientId)
+	if err != nil {
+		a.Log.Error(
+			fmt.Sprintf("Failed to generate oauth state at %s %s",
+				c.Request.Method, c.FullPath()), err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Oops! Something happened. Please try again later",
+		})
+		return "", false
+	}
+	pkg.SetOAuthStateCookie(c, nonce)
+	return a.OAuth.AuthCodeURL(state, redirectOption(redirectUri)...), true
+}
+
+// The redirect URI sent on exchange must match the one authorized
+func redirectOption(redirectUri string) []oauth2.AuthCodeOption {
+	if redirectUri == "" {
+		return nil
+	}
+	return []oauth2.AuthCodeOption{oauth2.SetAuthURLParam("redirect_uri", redirectUri)}
+}
+
+func (a *App) redirectUriNotAllowed(c *gin.Context, redirectUri string) {
+	a.Log.Warn(
+		fmt.Sprintf("Redirect URI %q not allowed at %s %s",
+			redirectUri, c.Request.Method, c.FullPath()))
+	c.JSON(http.StatusBadRequest, gin.H{
+		"message": "redirect_uri is not allowed",
+	})
+}
+
+// GitHub omits the email when it is private and the email scope was not
+// granted, which the user can fix on their side
+func (a *App) githubEmailMissing(c *gin.Context) {
+	a.Log.Warn(
+		fmt.Sprintf("GitHub login without an email address at %s %s",
+			c.Request.Method, c.FullPath()))
+	c.JSON(http.StatusUnprocessableEntity, gin.H{
+		"message": "GitHub did not share an email address. Make your email public on GitHub " +
+			"or grant access to your email addresses, then try again.",
+	})
+}
+
+func (a *App) clientIdNotAllowed(c *gin.Context, clientId string) {
+	a.Log.Warn(
+		fmt.Sprintf("Client id %q not registered at %s %s",
+			clientId, c.Request.Method, c.FullPath()))
+	c.JSON(http.StatusBadRequest, gin.H{
+		"message": "client_id is not registered",
+	})
+}
+
+func (a *App) CompleteGitHubOAuth(c *gin.Context) {
 	// Extract code from github oauth callback URL
 	code := c.Query("code")
 	if code == "" {
-		cmd.Log.Warn(
+		a.Log.Warn(
 			fmt.Sprintf("Missing authorization code in github oauth callback at %s %s",
 				c.Request.Method, c.FullPath()))
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -33,13 +124,42 @@ func CompleteGitHubOAuth(c *gin.Context) {
 		})
 		return
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	// Account linking shares the callback registered with GitHub
+	if pkg.IsLinkState(c.Query("state")) {
+		a.completeGithubLink(c, code, c.Query("state"))
+		return
+	}
+	// Login CSRF is prevented by only accepting the state in the browser which
+	// started the flow, the cookie is single use either way
+	nonce, _ := c.Cookie(pkg.OAuthStateCookie)
+	pkg.ClearOAuthStateCookie(c)
+	redirectUri, clientId, err := pkg.VerifyOAuthState(c.Query("state"), nonce)
+	if err != nil {
+		a.Log.Error(
+			fmt.Sprintf("Invalid state in github oauth callback at %s %s",
+				c.Request.Method, c.FullPath()), err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid or expired state",
+		})
+		return
+	}
+	// Allowed redirect URIs may have changed since the flow was started
+	if redirectUri != "" && !slices.Contains(a.Env.GhRedirectUrls, redirectUri) {
+		a.redirectUriNotAllowed(c, redirectUri)
+		return
+	}
+	if err := pkg.CheckClientId(a.Env, clientId); err != nil {
+		a.clientIdNotAllowed(c, clientId)
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), a.Env.TimeoutFor("oauth"))
 	defer cancel()
 
 	// Fetching the github user
-	token, err := cmd.GithubOAuthConfig.Exchange(ctx, code)
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, a.Client)
+	token, err := a.OAuth.Exchange(ctx, code, redirectOption(redirectUri)...)
 	if err != nil {
-		cmd.Log.Error(
+		a.Log.Error(
 			fmt.Sprintf("Failed to exchange code for token at %s %s",
 				c.Request.Method, c.FullPath()), err)
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -48,10 +168,10 @@ func CompleteGitHubOAuth(c *gin.Context) {
 		return
 	}
 
-	client := cmd.GithubOAuthConfig.Client(ctx, token)
-	resp, err := client.Get("https://api.github.com/user")
+	client := a.OAuth.Client(ctx, token)
+	resp, err := client.Get(a.Env.GhApiUrl + "/user")
 	if err != nil {
-		cmd.Log.Warn(
+		a.Log.Warn(
 			fmt.Sprintf("Failed to fetch user info from GitHub at %s %s",
 				c.Request.Method, c.FullPath()))
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -63,7 +183,7 @@ func CompleteGitHubOAuth(c *gin.Context) {
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		cmd.Log.Warn(fmt.Sprintf("Failed to unmarshal github user info at %s %s",
+		a.Log.Warn(fmt.Sprintf("Failed to unmarshal github user info at %s %s",
 			c.Request.Method, c.FullPath()))
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"message": "Oops! Something happened. Please try again later",
@@ -73,7 +193,7 @@ func CompleteGitHubOAuth(c *gin.Context) {
 	// Extracting the github user
 	var user types.GithubUser
 	if err := json.Unmarshal(body, &user); err != nil {
-		cmd.Log.Warn(
+		a.Log.Warn(
 			fmt.Sprintf("Failed to parse github user info at %s %s",
 				c.Request.Method, c.FullPath()))
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -81,88 +201,192 @@ func CompleteGitHubOAuth(c *gin.Context) {
 		})
 		return
 	}
-
-	// Verifying the github account's presence against database to validate
-	// post registration
-	tx, err := cmd.DBPool.Begin(ctx)
-	if err != nil {
-		pkg.DbError(c, err)
+	if err := user.Validate(); err != nil {
+		a.Log.Error(
+			fmt.Sprintf("Incomplete github user info at %s %s",
+				c.Request.Method, c.FullPath()), err)
+		c.JSON(http.StatusBadGateway, gin.H{
+			"message": "GitHub returned an incomplete profile. Please try again later.",
+		})
 		return
 	}
-	tx.Rollback(ctx)
 
-	q := db.New()
-	userExist, err := q.CheckUserExistQuery(ctx, tx, user.Username)
-	if err != nil {
-		pkg.DbError(c, err)
-		return
+	// Profile email is null unless made public, so fall back to the verified
+	// primary email of the account
+	if user.Email == "" {
+		primaryEmail, err := a.fetchPrimaryEmail(client)
+		if err != nil {
+			a.Log.Warn(
+				fmt.Sprintf("Failed to fetch github primary email at %s %s",
+					c.Request.Method, c.FullPath()))
+		}
+		user.Email = primaryEmail
 	}
-	if userExist.Email == "" {
-		cmd.Log.Warn(
-			fmt.Sprintf("Unregistered user attempted to login at %s %s",
-				c.Request.Method, c.FullPath()))
-		c.JSON(http.StatusNotFound, gin.H{
-			"message": "User not registered",
-		})
-		return
+	user.Email = pkg.NormalizeEmail(user.Email, a.Env.CollapseGmailDots)
+
+	// Restricting login to organization members when configured
+	if a.Env.GhOrg != "" {
+		isMember, err := a.checkOrgMembership(client, user.Username)
+		if err != nil {
+			a.Log.Error(
+				fmt.Sprintf("Failed to check github org membership at %s %s",
+					c.Request.Method, c.FullPath()), err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"message": "Oops! Something happened. Please try again later",
+			})
+			return
+		}
+		if !isMember {
+			a.Log.Warn(
+				fmt.Sprintf("Non-member of github org attempted to login at %s %s",
+					c.Request.Method, c.FullPath()))
+			c.JSON(http.StatusForbidden, gin.H{
+				"message": "User is not a member of the organization",
+			})
+			return
+		}
 	}
 
-	// If the presence is verified, then generate access and refresh token
-	// , add them in DB and respond back in request
-	accessToken, err := pkg.CreateToken(userExist.Ghusername, userExist.Email, "access_token")
-	if err != nil {
-		cmd.Log.Error(
-			fmt.Sprintf("Failed to create access token at %s %s", c.Request.Method, c.FullPath()),
-			err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": "Oops! Something happened. Please try again later",
+	// Verifying the github account's presence against database to validate
+	// post registration
+	q := a.Queries
+	var accessToken, refreshToken string
+	var loginUser db.FetchLoginUserQueryRow
+	newDevice := false
+	err = pkg.RunInTx(ctx, a.DB, func(tx pgx.Tx) error {
+		// The GitHub ID survives renames, so it is the most reliable match
+		byId, err := q.CheckUserExistByGithubIdQuery(ctx, tx,
+			pgtype.Int8{Int64: user.ID, Valid: user.ID != 0})
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+		userExist := db.CheckUserExistQueryRow(byId)
+		// Otherwise by username, or by email for users who renamed their github
+		// account. Accounts which unlinked GitHub are left out, as are accounts
+		// tied to another GitHub ID. An empty email is never matched.
+		if userExist.Email == "" {
+			fallback, err := q.CheckGithubFallbackUserQuery(ctx, tx, db.CheckGithubFallbackUserQueryParams{
+				Ghusername: pkg.NormalizeGhUsername(user.Username),
+				Email:      user.Email,
+			})
+			if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+				return err
+			}
+			userExist = db.CheckUserExistQueryRow(fallback)
+		}
+		// Without an email registration cannot proceed either
+		if userExist.Email == "" && user.Email == "" {
+			a.githubEmailMissing(c)
+			return pkg.ErrTxAborted
+		}
+		if userExist.Email == "" && a.Env.OAuthAutoRegister {
+			var ok bool
+			userExist, ok = a.autoRegisterGithubUser(ctx, c, tx, client, user)
+			if !ok {
+				return pkg.ErrTxAborted
+			}
+		}
+		if userExist.Email == "" {
+			a.Log.Warn(
+				fmt.Sprintf("Unregistered user attempted to login at %s %s",
+					c.Request.Method, c.FullPath()))
+			c.JSON(http.StatusNotFound, gin.H{
+				"message": "User not registered",
+			})
+			return pkg.ErrTxAborted
+		}
+
+		// If the presence is verified, then generate access and refresh token
+		// , add them in DB and respond back in request
+		var ok bool
+		accessToken, refreshToken, loginUser, ok = a.startSession(ctx, c, tx,
+			userExist.Ghusername, userExist.Email, clientId)
+		if !ok {
+			return pkg.ErrTxAborted
+		}
+		// GitHub token is kept, encrypted, to allow re-syncing the profile later
+		// on. Without a key any previously stored token is cleared instead.
+		ghToken, err := a.sealGithubToken(token.AccessToken)
+		if err != nil {
+			return err
+		}
+		err = q.SaveGithubTokenQuery(ctx, tx, db.SaveGithubTokenQueryParams{
+			GhToken:    ghToken,
+			Ghusername: loginUser.Ghusername,
 		})
-		return
-	}
-	refreshToken, err := pkg.CreateToken(userExist.Ghusername, userExist.Email, "refresh_token")
-	if err != nil {
-		cmd.Log.Error(
-			fmt.Sprintf("Failed to create token at %s %s", c.Request.Method, c.FullPath()),
-			err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"message": "Oops! Something happened. Please try again later",
+		if err != nil {
+			return err
+		}
+		err = q.UpdateGithubProfileQuery(ctx, tx, db.UpdateGithubProfileQueryParams{
+			FullName:          user.Name,
+			AvatarUrl:         user.AvatarUrl,
+			GhID:              pgtype.Int8{Int64: user.ID, Valid: user.ID != 0},
+			Ghusername:        loginUser.Ghusername,
+			DisplayGhusername: displayGhUsername(loginUser.Ghusername, user.Username),
 		})
-		return
-	}
-
-	loginUser, err := q.AddRefreshTokenQuery(ctx, tx, db.AddRefreshTokenQueryParams{
-		Ghusername:   userExist.Ghusername,
-		RefreshToken: pgtype.Text{String: refreshToken, Valid: true},
+		if err != nil {
+			return err
+		}
+		if a.Env.LoginAnomalyDetection {
+			newDevice, err = a.recordLogin(ctx, c, tx, loginUser.Ghusername)
+		}
+		return err
 	})
 	if err != nil {
-		pkg.DbError(c, err)
+		pkg.TxError(c, err)
 		return
 	}
+	// Mailed in the background so that login is not held up by SMTP
+	if newDevice && a.Env.LoginAlertMail {
+		go a.sendLoginAlert(loginUser.Ghusername, loginUser.Email, pkg.ClientIP(c), c.Request.UserAgent())
+	}
 
-	if err := tx.Commit(ctx); err != nil {
-		pkg.DbError(c, err)
+	// Browser clients are sent back to the frontend with tokens in cookies
+	if a.Env.OAuthResponseMode == "redirect" {
+		pkg.SetTokenCookies(c, accessToken, refreshToken)
+		c.Redirect(http.StatusFound, a.Env.FrontendUrl)
+		a.Log.Info(fmt.Sprintf(
+			"[SUCCESS]: Processed request at %s %s",
+			c.Request.Method, c.FullPath(),
+		))
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message":         "User login successful",
-		"access_token":    accessToken,
-		"refresh_token":   refreshToken,
-		"github_username": loginUser.Ghusername,
-		"email":           loginUser.Email,
-		"bounty":          loginUser.Bounty,
-	})
-	cmd.Log.Info(fmt.Sprintf(
+	response := gin.H{
+		"message":          "User login successful",
+		"access_token":     accessToken,
+		"refresh_token":    refreshToken,
+		"github_username":  loginUser.Ghusername,
+		"email":            loginUser.Email,
+		"bounty":           loginUser.Bounty,
+		"profile_complete": profileComplete(user.Name, user.AvatarUrl),
+	}
+	// Keep the refresh token out of reach of scripts when configured
+	if a.Env.RefreshTokenCookie {
+		pkg.SetRefreshTokenCookie(c, refreshToken)
+		delete(response, "refresh_token")
+	}
+	c.JSON(http.StatusOK, response)
+	a.Log.Info(fmt.Sprintf(
 		"[SUCCESS]: Processed request at %s %s",
 		c.Request.Method, c.FullPath(),
 	))
 	return
 }
 
-func RegenerateToken(c *gin.Context) {
+// Refresh tokens are read from the Authorization header or the HttpOnly
+// refresh token cookie. Tokens sent as a cookie are rotated on every refresh,
+// as browser clients never handle them directly.
+func (a *App) RegenerateToken(c *gin.Context) {
 	authHeader := c.GetHeader("Authorization")
+	// Refresh token cookie, when present, takes precedence over the header
+	fromCookie := false
+	if cookie, err := c.Cookie(pkg.RefreshTokenCookie); err == nil && cookie != "" {
+		authHeader = "Bearer " + cookie
+		fromCookie = true
+	}
 	if authHeader == "" {
-		cmd.Log.Warn(
+		a.Log.Warn(
 			fmt.Sprintf("RefreshToken not sent as Authorization header at %s %s",
 				c.Request.Method, c.FullPath()),
 		)
@@ -176,30 +400,25 @@ func RegenerateToken(c *gin.Context) {
 	if len(authHeader) > 7 && authHeader[0:7] == "Bearer " {
 		tokenString = authHeader[7:]
 	} else {
-		cmd.Log.Warn(fmt.Sprintf("Authorization failed at %s %s", c.Request.Method, c.FullPath()))
+		a.Log.Warn(fmt.Sprintf("Authorization failed at %s %s", c.Request.Method, c.FullPath()))
 		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
 			"message": "Invalid Authorization header format",
 		})
 		return
 	}
 
-	claims, err := pkg.VerifyToken(tokenString)
+	claims, err := pkg.VerifyToken(a.Env, tokenString)
 	if err != nil {
-		cmd.Log.Warn(
-			fmt.Sprintf("Invalid refresh token at %s %s",
-				c.Request.Method, c.FullPath()),
-		)
-		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-			"message": "The request is malformed",
-		})
+		pkg.TokenError(c, err)
 		return
 	}
 
 	validIssuer := claims.Issuer == "api.season-of-code"
 	validSub := claims.Subject == "refresh_token"
-	validAudience := len(claims.Audience) == 1
-	if !validIssuer || !validSub || !validAudience {
-		cmd.Log.Error(
+	validAudience := claims.ValidAudience(a.Env)
+	jti, jtiErr := uuid.Parse(claims.ID)
+	if !validIssuer || !validSub || !validAudience || jtiErr != nil {
+		a.Log.Error(
 			fmt.Sprintf("Tampered token sent at %s %s", c.Request.Method, c.FullPath()),
 			err)
 		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
@@ -209,29 +428,58 @@ func RegenerateToken(c *gin.Context) {
 	}
 
 	// Actual controller
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), a.Env.TimeoutFor("refresh"))
 	defer cancel()
 
-	conn, err := cmd.DBPool.Acquire(ctx)
+	conn, err := a.DB.Acquire(ctx)
 	if err != nil {
-		pkg.DbError(c, err)
+		pkg.DbAcquireError(c, err)
 		return
 	}
 	defer conn.Release()
 
-	q := db.New()
-	result, err := q.CheckRefreshTokenQuery(ctx, conn, db.CheckRefreshTokenQueryParams{
-		Email:        claims.ID,
-		RefreshToken: pgtype.Text{String: tokenString, Valid: true},
-	})
-	if err != nil || result.Ghusername == "" {
+	q := a.Queries
+	result, err := q.CheckSessionQuery(ctx, conn, jti)
+	if errors.Is(err, pgx.ErrNoRows) {
+		a.Log.Warn(
+			fmt.Sprintf("Revoked or unknown refresh token at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"message": "Session has expired or been revoked",
+		})
+		return
+	}
+	if err != nil {
 		pkg.DbError(c, err)
 		return
 	}
+	if a.Env.TokenFingerprintBinding {
+		issuedTo := pkg.Fingerprint{UaHash: result.UaHash, IpClass: result.IpClass}
+		if !issuedTo.Matches(pkg.ClientFingerprint(c)) {
+			a.Log.Warn(
+				fmt.Sprintf("Refresh token used from a different client at %s %s",
+					c.Request.Method, c.FullPath()))
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"message": "Session is not valid for this device. Please login again.",
+			})
+			return
+		}
+	}
 
-	accessToken, err := pkg.CreateToken(result.Ghusername, result.Email, "access_token")
+	scopes, err := userScopes(ctx, q, conn, result.Ghusername)
 	if err != nil {
-		cmd.Log.Error(
+		pkg.DbError(c, err)
+		return
+	}
+	// Refreshed tokens stay bound to the client and sign-in time of the
+	// refresh token
+	opts := []pkg.TokenOption{pkg.WithScopes(scopes...), pkg.WithClient(claims.ClientId())}
+	if claims.AuthTime != nil {
+		opts = append(opts, pkg.WithAuthTime(claims.AuthTime.Time))
+	}
+	accessToken, err := pkg.CreateToken(a.Env, result.Ghusername, result.Email, "access_token", opts...)
+	if err != nil {
+		a.Log.Error(
 			fmt.Sprintf("Could not generate access token at %s %s", c.Request.Method, c.FullPath()),
 			err)
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -239,14 +487,229 @@ func RegenerateToken(c *gin.Context) {
 		})
 		return
 	}
+	if fromCookie && !a.rotateRefreshCookie(ctx, c, conn, claims, jti, result) {
+		return
+	}
+	// Rotated sessions are created with a renewed expiry already
+	if !fromCookie && a.Env.RefreshTokenExpiry == "sliding" {
+		extended, err := q.ExtendSessionQuery(ctx, conn, db.ExtendSessionQueryParams{
+			Ttl: pgtype.Interval{Microseconds: a.Env.RefreshTokenIdleTTL.Microseconds(), Valid: true},
+			Jti: jti,
+		})
+		if err != nil {
+			pkg.DbError(c, err)
+			return
+		}
+		if extended == 0 {
+			a.Log.Warn(
+				fmt.Sprintf("Session expired or revoked during refresh at %s %s",
+					c.Request.Method, c.FullPath()))
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"message": "Session has expired or been revoked",
+			})
+			return
+		}
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"message":   "Token refreshed successfully",
 		"accessKey": accessToken,
 	})
-	cmd.Log.Info(fmt.Sprintf(
+	a.Log.Info(fmt.Sprintf(
 		"[SUCCESS]: Processed request at %s %s",
 		c.Request.Method, c.FullPath(),
 	))
 	return
 }
+
+// Replaces the session of the given refresh token with a new one bound to the
+// same client, and sets its token as the refresh cookie. A token that was
+// already rotated by a concurrent refresh is rejected. Sessions keep their
+// absolute expiry across rotations.
+func (a *App) rotateRefreshCookie(ctx context.Context, c *gin.Context, conn pkg.Conn,
+	claims *pkg.Claims, jti uuid.UUID, session db.CheckSessionQueryRow) bool {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		pkg.DbAcquireError(c, err)
+		return false
+	}
+	defer tx.Rollback(ctx)
+
+	q := a.Queries
+	revoked, err := q.RevokeTokenByJtiQuery(ctx, tx, db.RevokeTokenByJtiQueryParams{
+		Jti:        jti,
+		Ghusername: session.Ghusername,
+	})
+	if err != nil {
+		pkg.DbError(c, err)
+		return false
+	}
+	if revoked == 0 {
+		a.Log.Warn(
+			fmt.Sprintf("Refresh token reused during rotation at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"message": "Session has expired or been revoked",
+		})
+		return false
+	}
+
+	refreshJti := uuid.New()
+	opts := []pkg.TokenOption{pkg.WithTokenId(refreshJti.String()), pkg.WithClient(claims.ClientId())}
+	if claims.AuthTime != nil {
+		opts = append(opts, pkg.WithAuthTime(claims.AuthTime.Time))
+	}
+	// Rotation never extends the absolute lifetime, in either mode
+	if claims.ExpiresAt != nil {
+		opts = append(opts, pkg.WithExpiry(claims.ExpiresAt.Time))
+	}
+	refreshToken, err := pkg.CreateToken(a.Env, session.Ghusername, session.Email, "refresh_token", opts...)
+	if err != nil {
+		a.Log.Error(
+			fmt.Sprintf("Could not generate refresh token at %s %s", c.Request.Method, c.FullPath()),
+			err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Oops! Something happened. Please try again later.",
+		})
+		return false
+	}
+	err = q.AddSessionQuery(ctx, tx, db.AddSessionQueryParams{
+		Jti:          refreshJti,
+		Ghusername:   session.Ghusername,
+		UaHash:       session.UaHash,
+		IpClass:      session.IpClass,
+		DeviceLabel:  session.DeviceLabel,
+		Ttl:          pgtype.Interval{Microseconds: a.Env.RefreshSessionTTL().Microseconds(), Valid: true},
+		MaxExpiresAt: session.MaxExpiresAt,
+		MaxAge:       pgtype.Interval{Microseconds: a.Env.RefreshTokenMaxAge.Microseconds(), Valid: true},
+	})
+	if err != nil {
+		pkg.DbError(c, err)
+		return false
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		pkg.DbError(c, err)
+		return false
+	}
+	pkg.SetRefreshTokenCookie(c, refreshToken)
+	return true
+}
+
+// Membership rarely changes, so lookups are cached briefly to spare the
+// GitHub API on repeated logins
+var orgMembershipCache = pkg.NewTTLCache[string, bool](5 * time.Minute)
+
+// Creates an account for a GitHub user logging in for the first time. The OTP
+// step is skipped as GitHub has already verified the primary email, which is
+// why the public profile email is not trusted here.
+func (a *App) autoRegisterGithubUser(ctx context.Context, c *gin.Context, tx pgx.Tx,
+	client *http.Client, user types.GithubUser) (db.CheckUserExistQueryRow, bool) {
+	var account db.CheckUserExistQueryRow
+	email, err := a.fetchPrimaryEmail(client)
+	if err != nil {
+		a.Log.Warn(
+			fmt.Sprintf("Auto-registration without verified email at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": "A verified primary email on GitHub is required to register",
+		})
+		return account, false
+	}
+	email = pkg.NormalizeEmail(email, a.Env.CollapseGmailDots)
+	if err := pkg.CheckEmailDomain(a.Env, email); err != nil {
+		a.Log.Warn(
+			fmt.Sprintf("Auto-registration with disallowed email at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": "The primary email on GitHub is not allowed to register",
+		})
+		return account, false
+	}
+
+	q := a.Queries
+	ghUsername, err := q.CreateUserAccountQuery(ctx, tx, db.CreateUserAccountQueryParams{
+		Email:             email,
+		Ghusername:        pkg.NormalizeGhUsername(user.Username),
+		DisplayGhusername: user.Username,
+		Timezone:          pkg.DefaultTimezone,
+		Locale:            pkg.DefaultLocale,
+	})
+	if pkg.IsUniqueViolation(err) {
+		a.Log.Warn(
+			fmt.Sprintf("Auto-registration conflicts with an existing account at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusConflict, gin.H{
+			"message": "An account with this username or email already exists",
+		})
+		return account, false
+	}
+	if err != nil {
+		pkg.DbError(c, err)
+		return account, false
+	}
+	err = q.AddAuditLogQuery(ctx, tx, db.AddAuditLogQueryParams{
+		Actor:   ghUsername,
+		Action:  "user.auto_register",
+		Target:  ghUsername,
+		Details: fmt.Sprintf("email=%s gh_id=%d", email, user.ID),
+	})
+	if err != nil {
+		pkg.DbError(c, err)
+		return account, false
+	}
+
+	account.Ghusername = ghUsername
+	account.Email = email
+	return account, true
+}
+
+func (a *App) checkOrgMembership(client *http.Client, username string) (bool, error) {
+	if isMember, ok := orgMembershipCache.Get(username); ok {
+		return isMember, nil
+	}
+
+	url := fmt.Sprintf("%s/orgs/%s/members/%s",
+		a.Env.GhApiUrl, a.Env.GhOrg, username)
+	resp, err := client.Get(url)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	var isMember bool
+	switch resp.StatusCode {
+	case http.StatusNoContent:
+		isMember = true
+	case http.StatusNotFound, http.StatusFound:
+		isMember = false
+	case http.StatusUnauthorized:
+		return false, errGithubTokenExpired
+	default:
+		return false, fmt.Errorf("Unexpected status from GitHub: %d", resp.StatusCode)
+	}
+	orgMembershipCache.Set(username, isMember)
+	return isMember, nil
+}
+
+func (a *App) fetchPrimaryEmail(client *http.Client) (string, error) {
+	resp, err := client.Get(a.Env.GhApiUrl + "/user/emails")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Unexpected status from GitHub: %d", resp.StatusCode)
+	}
+	var emails []types.GithubEmail
+	if err := json.NewDecoder(resp.Body).Decode(&emails); err != nil {
+		return "", err
+	}
+	for _, e := range emails {
+		if e.Primary && e.Verified && strings.TrimSpace(e.Email) != "" {
+			return e.Email, nil
+		}
+	}
+	return "", fmt.Errorf("No verified primary email found")
+}
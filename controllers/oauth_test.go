@@ -0,0 +1,1323 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IAmRiteshKoushik/pulse/cmd"
+	db "github.com/IAmRiteshKoushik/pulse/db/gen"
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"golang.org/x/oauth2"
+)
+
+func TestRotateRefreshCookieKeepsAbsoluteExpiry(t *testing.T) {
+	tests := []struct {
+		name   string
+		mode   string
+		issued time.Duration // how long ago the session started
+	}{
+		{name: "fixed", mode: "fixed", issued: 24 * time.Hour},
+		{name: "fixed near the cap", mode: "fixed", issued: 29 * 24 * time.Hour},
+		{name: "sliding", mode: "sliding", issued: 24 * time.Hour},
+		{name: "sliding near the cap", mode: "sliding", issued: 29 * 24 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var added db.AddSessionQueryParams
+			q := &fakeQuerier{
+				revokeTokenByJti: func(db.DBTX, db.RevokeTokenByJtiQueryParams) (int64, error) { return 1, nil },
+				addSession: func(_ db.DBTX, arg db.AddSessionQueryParams) error {
+					added = arg
+					return nil
+				},
+			}
+			a := newTestApp(q)
+			env := *a.Env
+			env.RefreshTokenExpiry = tt.mode
+			a.Env = &env
+
+			signedIn := time.Now().Add(-tt.issued).Truncate(time.Second)
+			maxExpiresAt := signedIn.Add(env.RefreshTokenMaxAge)
+			claims := &pkg.Claims{
+				AuthTime: jwt.NewNumericDate(signedIn),
+				RegisteredClaims: jwt.RegisteredClaims{
+					ExpiresAt: jwt.NewNumericDate(maxExpiresAt),
+				},
+			}
+			session := db.CheckSessionQueryRow{
+				Ghusername:   "octocat",
+				Email:        "octo@example.com",
+				MaxExpiresAt: pkg.NewTimestamp(maxExpiresAt),
+			}
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/auth/refresh", nil)
+			if !a.rotateRefreshCookie(context.Background(), c, fakeConn{}, claims, uuid.New(), session) {
+				t.Fatalf("rotation failed with status %d: %s", w.Code, w.Body.String())
+			}
+
+			if !added.MaxExpiresAt.Valid || !added.MaxExpiresAt.Time.Equal(maxExpiresAt) {
+				t.Errorf("new session max_expires_at = %v, want %v", added.MaxExpiresAt.Time, maxExpiresAt)
+			}
+			var cookie *http.Cookie
+			for _, ck := range w.Result().Cookies() {
+				if ck.Name == pkg.RefreshTokenCookie {
+					cookie = ck
+				}
+			}
+			if cookie == nil {
+				t.Fatal("refresh cookie was not set")
+			}
+			rotated, err := pkg.VerifyToken(a.Env, cookie.Value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !rotated.ExpiresAt.Time.Equal(maxExpiresAt) {
+				t.Errorf("rotated token expires at %v, want %v", rotated.ExpiresAt.Time, maxExpiresAt)
+			}
+			if rotated.AuthTime == nil || !rotated.AuthTime.Time.Equal(signedIn) {
+				t.Errorf("rotated token auth_time = %v, want %v", rotated.AuthTime, signedIn)
+			}
+		})
+	}
+}
+
+// Mirrors the expires_at AddSessionQuery stores: the TTL from now, never
+// past the session's absolute maximum
+func storedExpiry(now time.Time, arg db.AddSessionQueryParams) time.Time {
+	expiresAt := now.Add(time.Duration(arg.Ttl.Microseconds) * time.Microsecond)
+	if arg.MaxExpiresAt.Valid && expiresAt.After(arg.MaxExpiresAt.Time) {
+		return arg.MaxExpiresAt.Time
+	}
+	return expiresAt
+}
+
+func TestRotateRefreshCookieSlidingExpiry(t *testing.T) {
+	const day = 24 * time.Hour
+	tests := []struct {
+		name   string
+		mode   string
+		issued time.Duration // how long ago the session started
+		want   time.Duration // expiry after the refresh, relative to the sign in
+	}{
+		{name: "sliding extends by the idle ttl", mode: "sliding", issued: 2 * day, want: 2*day + 7*day},
+		{name: "sliding capped at the max age", mode: "sliding", issued: 27 * day, want: 30 * day},
+		{name: "fixed does not extend", mode: "fixed", issued: 2 * day, want: 30 * day},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var added db.AddSessionQueryParams
+			q := &fakeQuerier{
+				revokeTokenByJti: func(db.DBTX, db.RevokeTokenByJtiQueryParams) (int64, error) { return 1, nil },
+				addSession: func(_ db.DBTX, arg db.AddSessionQueryParams) error {
+					added = arg
+					return nil
+				},
+			}
+			a := newTestApp(q)
+			env := *a.Env
+			env.RefreshTokenExpiry = tt.mode
+			a.Env = &env
+
+			now := time.Now().Truncate(time.Second)
+			signedIn := now.Add(-tt.issued)
+			maxExpiresAt := signedIn.Add(env.RefreshTokenMaxAge)
+			// Before this refresh the session would have lapsed after one idle period
+			previous := storedExpiry(signedIn, db.AddSessionQueryParams{
+				Ttl:          pgtype.Interval{Microseconds: env.RefreshSessionTTL().Microseconds(), Valid: true},
+				MaxExpiresAt: pkg.NewTimestamp(maxExpiresAt),
+			})
+			claims := &pkg.Claims{
+				AuthTime: jwt.NewNumericDate(signedIn),
+				RegisteredClaims: jwt.RegisteredClaims{
+					ExpiresAt: jwt.NewNumericDate(maxExpiresAt),
+				},
+			}
+			session := db.CheckSessionQueryRow{
+				Ghusername:   "octocat",
+				Email:        "octo@example.com",
+				MaxExpiresAt: pkg.NewTimestamp(maxExpiresAt),
+			}
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/auth/refresh", nil)
+			if !a.rotateRefreshCookie(context.Background(), c, fakeConn{}, claims, uuid.New(), session) {
+				t.Fatalf("rotation failed with status %d: %s", w.Code, w.Body.String())
+			}
+
+			got := storedExpiry(now, added)
+			if want := signedIn.Add(tt.want); !got.Equal(want) {
+				t.Errorf("session expires at %v, want %v", got, want)
+			}
+			if got.After(maxExpiresAt) {
+				t.Errorf("session expires at %v, past the cap %v", got, maxExpiresAt)
+			}
+			if tt.mode == "sliding" && !got.After(previous) && got.Before(maxExpiresAt) {
+				t.Errorf("refresh did not extend the session past %v", previous)
+			}
+		})
+	}
+}
+
+// Stands in for both github.com and api.github.com
+func fakeGithub(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(fakeGithubMux())
+	t.Cleanup(server.Close)
+	return server
+}
+
+// Stands in for a GitHub Enterprise server, which serves its API under
+// /api/v3 of the web URL
+func fakeGithubEnterprise(t *testing.T) *httptest.Server {
+	t.Helper()
+	github := fakeGithubMux()
+	mux := http.NewServeMux()
+	mux.Handle("/login/", github)
+	mux.Handle("/api/v3/", http.StripPrefix("/api/v3", github))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func fakeGithubMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /login/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token": "gho_token", "token_type": "bearer"}`))
+	})
+	mux.HandleFunc("GET /user", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer gho_token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id": 583231, "login": "octocat", "name": "The Octocat",
+			"email": "octo@example.com", "avatar_url": "https://example.com/octocat.png"}`))
+	})
+	mux.HandleFunc("GET /orgs/{org}/members/{username}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("org") == "octo-org" && r.PathValue("username") == "octocat" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	})
+	return mux
+}
+
+// OAuth application registered with github
+func githubOAuthConfig(github *httptest.Server) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     "client",
+		ClientSecret: "secret",
+		Endpoint: oauth2.Endpoint{
+			AuthURL:   github.URL + "/login/oauth/authorize",
+			TokenURL:  github.URL + "/login/oauth/access_token",
+			AuthStyle: oauth2.AuthStyleInParams,
+		},
+	}
+}
+
+// Completes a GitHub login against github, as a browser following the
+// redirect back from GitHub would. The API is expected at the root of github
+// unless GhApiUrl is already set.
+func githubLogin(t *testing.T, a *App, github *httptest.Server) *httptest.ResponseRecorder {
+	t.Helper()
+	if a.Env.GhApiUrl == "" {
+		a.Env.GhApiUrl = github.URL
+	}
+	a.OAuth = githubOAuthConfig(github)
+	a.Client = github.Client()
+	return githubCallback(t, a)
+}
+
+// Returns GitHub to the callback with a code and a valid state
+func githubCallback(t *testing.T, a *App) *httptest.ResponseRecorder {
+	t.Helper()
+	state, nonce, err := pkg.CreateOAuthState("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := gin.New()
+	router.GET("/api/v1/auth/github/callback", a.CompleteGitHubOAuth)
+	req := httptest.NewRequest(http.MethodGet,
+		"/api/v1/auth/github/callback?code=abc&state="+url.QueryEscape(state), nil)
+	req.AddCookie(&http.Cookie{Name: pkg.OAuthStateCookie, Value: nonce})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// Answers the queries of a login by an existing user
+func loginQuerier() *fakeQuerier {
+	return &fakeQuerier{
+		checkUserExistByGithubId: func(_ db.DBTX, ghID pgtype.Int8) (db.CheckUserExistByGithubIdQueryRow, error) {
+			return db.CheckUserExistByGithubIdQueryRow{Ghusername: "octocat", Email: "octo@example.com"}, nil
+		},
+		checkIsMaintainer: func(db.DBTX, string) (bool, error) { return false, nil },
+		fetchLoginUser: func(_ db.DBTX, ghUsername string) (db.FetchLoginUserQueryRow, error) {
+			return db.FetchLoginUserQueryRow{Ghusername: ghUsername, Email: "octo@example.com", Bounty: 42}, nil
+		},
+		addSession:          func(db.DBTX, db.AddSessionQueryParams) error { return nil },
+		saveGithubToken:     func(db.DBTX, db.SaveGithubTokenQueryParams) error { return nil },
+		updateGithubProfile: func(db.DBTX, db.UpdateGithubProfileQueryParams) error { return nil },
+	}
+}
+
+func TestCompleteGitHubOAuthModes(t *testing.T) {
+	tests := []struct {
+		name          string
+		mode          string
+		refreshCookie bool
+		wantStatus    int
+		wantBody      []string // token fields in the JSON body
+		wantCookies   []string
+	}{
+		{
+			name:       "json",
+			mode:       "json",
+			wantStatus: http.StatusOK,
+			wantBody:   []string{"access_token", "refresh_token"},
+		},
+		{
+			name:          "json with refresh cookie",
+			mode:          "json",
+			refreshCookie: true,
+			wantStatus:    http.StatusOK,
+			wantBody:      []string{"access_token"},
+			wantCookies:   []string{pkg.RefreshTokenCookie},
+		},
+		{
+			name:        "redirect",
+			mode:        "redirect",
+			wantStatus:  http.StatusFound,
+			wantCookies: []string{pkg.AccessTokenCookie, pkg.RefreshTokenCookie},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestApp(loginQuerier())
+			a.Env = testEnv()
+			a.Env.OAuthResponseMode = tt.mode
+			a.Env.RefreshTokenCookie = tt.refreshCookie
+			a.Env.FrontendUrl = "https://pulse.example.com/dashboard"
+
+			w := githubLogin(t, a, fakeGithub(t))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.mode == "redirect" {
+				if got := w.Header().Get("Location"); got != a.Env.FrontendUrl {
+					t.Errorf("Location = %q, want %q", got, a.Env.FrontendUrl)
+				}
+			} else {
+				var body map[string]any
+				if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+					t.Fatal(err)
+				}
+				for _, field := range []string{"access_token", "refresh_token"} {
+					_, got := body[field]
+					if want := slices.Contains(tt.wantBody, field); got != want {
+						t.Errorf("body has %s = %v, want %v", field, got, want)
+					}
+				}
+				if body["github_username"] != "octocat" {
+					t.Errorf("github_username = %v", body["github_username"])
+				}
+			}
+
+			cookies := map[string]string{}
+			for _, ck := range w.Result().Cookies() {
+				cookies[ck.Name] = ck.Value
+			}
+			for _, name := range []string{pkg.AccessTokenCookie, pkg.RefreshTokenCookie} {
+				got := cookies[name] != ""
+				if want := slices.Contains(tt.wantCookies, name); got != want {
+					t.Errorf("cookie %s set = %v, want %v", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRegenerateTokenRefreshCookie(t *testing.T) {
+	tests := []struct {
+		name       string
+		cookie     bool
+		wantRotate bool
+	}{
+		{name: "read from the cookie and rotated", cookie: true, wantRotate: true},
+		{name: "read from the header", cookie: false, wantRotate: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jti := uuid.New()
+			var revoked []uuid.UUID
+			var added []db.AddSessionQueryParams
+			q := &fakeQuerier{
+				checkSession: func(_ db.DBTX, got uuid.UUID) (db.CheckSessionQueryRow, error) {
+					if got != jti {
+						t.Errorf("session looked up by %s, want %s", got, jti)
+					}
+					return db.CheckSessionQueryRow{Ghusername: "octocat", Email: "octo@example.com"}, nil
+				},
+				checkIsMaintainer: func(db.DBTX, string) (bool, error) { return false, nil },
+				revokeTokenByJti: func(_ db.DBTX, arg db.RevokeTokenByJtiQueryParams) (int64, error) {
+					revoked = append(revoked, arg.Jti)
+					return 1, nil
+				},
+				addSession: func(_ db.DBTX, arg db.AddSessionQueryParams) error {
+					added = append(added, arg)
+					return nil
+				},
+			}
+			a := newTestApp(q)
+			refreshToken, err := pkg.CreateToken(a.Env, "octocat", "octo@example.com", "refresh_token",
+				pkg.WithTokenId(jti.String()))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			router := gin.New()
+			router.POST("/api/v1/auth/refresh", a.RegenerateToken)
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/refresh", nil)
+			if tt.cookie {
+				req.AddCookie(&http.Cookie{Name: pkg.RefreshTokenCookie, Value: refreshToken})
+			} else {
+				req.Header.Set("Authorization", "Bearer "+refreshToken)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+			}
+			var body struct {
+				AccessKey string `json:"accessKey"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			access, err := pkg.VerifyToken(a.Env, body.AccessKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if access.Subject != "access_token" || access.Email != "octo@example.com" {
+				t.Errorf("issued %s for %s, want an access_token for octo@example.com", access.Subject, access.Email)
+			}
+
+			var cookie *http.Cookie
+			for _, ck := range w.Result().Cookies() {
+				if ck.Name == pkg.RefreshTokenCookie {
+					cookie = ck
+				}
+			}
+			if rotated := cookie != nil; rotated != tt.wantRotate {
+				t.Fatalf("refresh cookie set = %v, want %v", rotated, tt.wantRotate)
+			}
+			if !tt.wantRotate {
+				if len(revoked) != 0 || len(added) != 0 {
+					t.Errorf("session rotated without a cookie: revoked %v, added %d", revoked, len(added))
+				}
+				return
+			}
+
+			if !cookie.HttpOnly || !cookie.Secure || cookie.SameSite != http.SameSiteStrictMode {
+				t.Errorf("cookie HttpOnly = %v, Secure = %v, SameSite = %v, want HttpOnly, Secure and Strict",
+					cookie.HttpOnly, cookie.Secure, cookie.SameSite)
+			}
+			if len(revoked) != 1 || revoked[0] != jti {
+				t.Errorf("revoked %v, want [%s]", revoked, jti)
+			}
+			rotated, err := pkg.VerifyToken(a.Env, cookie.Value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(added) != 1 || rotated.ID != added[0].Jti.String() || rotated.ID == jti.String() {
+				t.Errorf("rotated token jti %s, want the new session's", rotated.ID)
+			}
+		})
+	}
+}
+
+func TestGithubOrgMembership(t *testing.T) {
+	tests := []struct {
+		name       string
+		org        string
+		wantStatus int
+	}{
+		{name: "no org configured", org: "", wantStatus: http.StatusOK},
+		{name: "member", org: "octo-org", wantStatus: http.StatusOK},
+		{name: "non-member", org: "other-org", wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orgMembershipCache = pkg.NewTTLCache[string, bool](5 * time.Minute)
+			a := newTestApp(loginQuerier())
+			a.Env = testEnv()
+			a.Env.GhOrg = tt.org
+
+			w := githubLogin(t, a, fakeGithub(t))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestFetchPrimaryEmail(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		emails  string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "verified primary",
+			status: http.StatusOK,
+			emails: `[{"email": "work@example.com", "primary": false, "verified": true},
+				{"email": "octo@example.com", "primary": true, "verified": true}]`,
+			want: "octo@example.com",
+		},
+		{
+			name:    "unverified primary",
+			status:  http.StatusOK,
+			emails:  `[{"email": "octo@example.com", "primary": true, "verified": false}]`,
+			wantErr: true,
+		},
+		{
+			name:    "no emails",
+			status:  http.StatusOK,
+			emails:  `[]`,
+			wantErr: true,
+		},
+		{
+			name:    "blank primary",
+			status:  http.StatusOK,
+			emails:  `[{"email": " ", "primary": true, "verified": true}]`,
+			wantErr: true,
+		},
+		{
+			name:    "scope not granted",
+			status:  http.StatusNotFound,
+			emails:  `{"message": "Not Found"}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			github := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/user/emails" {
+					t.Errorf("requested %s", r.URL.Path)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.emails))
+			}))
+			defer github.Close()
+			a := newTestApp(&fakeQuerier{})
+			a.Env = testEnv()
+			a.Env.GhApiUrl = github.URL
+
+			got, err := a.fetchPrimaryEmail(github.Client())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("fetchPrimaryEmail() error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("fetchPrimaryEmail() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGithubEnterpriseLogin(t *testing.T) {
+	tests := []struct {
+		name       string
+		apiPath    string
+		wantStatus int
+	}{
+		{name: "api url configured", apiPath: "/api/v3", wantStatus: http.StatusOK},
+		{name: "api url missing the enterprise path", apiPath: "", wantStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			github := fakeGithubEnterprise(t)
+			a := newTestApp(loginQuerier())
+			a.Env = testEnv()
+			a.Env.GhApiUrl = github.URL + tt.apiPath
+
+			w := githubLogin(t, a, github)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+		})
+	}
+}
+
+// Counts the requests sent through the transport it wraps
+type countingTransport struct {
+	http.RoundTripper
+	paths []string
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.paths = append(t.paths, req.Method+" "+req.URL.Path)
+	return t.RoundTripper.RoundTrip(req)
+}
+
+func TestCompleteGitHubOAuthInjected(t *testing.T) {
+	github := fakeGithub(t)
+	transport := &countingTransport{RoundTripper: github.Client().Transport}
+	env := testEnv()
+	env.GhApiUrl = github.URL
+	a := NewApp(env, cmd.Log, fakePool{name: "primary"}, nil, githubOAuthConfig(github), nil, nil,
+		&http.Client{Transport: transport}, nil)
+	a.Queries = loginQuerier()
+
+	w := githubCallback(t, a)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	var body struct {
+		AccessToken string `json:"access_token"`
+		GhUsername  string `json:"github_username"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	claims, err := pkg.VerifyToken(env, body.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body.GhUsername != "octocat" || claims.Email != "octo@example.com" {
+		t.Errorf("logged in %s with %s, want octocat with octo@example.com", body.GhUsername, claims.Email)
+	}
+	// Both the code exchange and the user fetch go through the injected client
+	want := []string{"POST /login/oauth/access_token", "GET /user"}
+	if !slices.Equal(transport.paths, want) {
+		t.Errorf("requests = %v, want %v", transport.paths, want)
+	}
+}
+
+func TestFetchGitHubOAuthUrl(t *testing.T) {
+	github := fakeGithub(t)
+	a := newTestApp(loginQuerier())
+	a.Env = testEnv()
+	a.Env.GhApiUrl = github.URL
+	a.OAuth = githubOAuthConfig(github)
+	a.Client = github.Client()
+	router := gin.New()
+	router.GET("/api/v1/auth/github/url", a.FetchGitHubOAuthUrl)
+	router.GET("/api/v1/auth/github/callback", a.CompleteGitHubOAuth)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/auth/github/url", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	var body struct {
+		Url string `json:"url"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	authorize, err := url.Parse(body.Url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := authorize.Query().Get("state")
+	if state == "" {
+		t.Fatalf("authorize URL %s has no state", body.Url)
+	}
+	var nonce *http.Cookie
+	for _, ck := range w.Result().Cookies() {
+		if ck.Name == pkg.OAuthStateCookie {
+			nonce = ck
+		}
+	}
+	if nonce == nil {
+		t.Fatal("state cookie was not set")
+	}
+
+	// The state is the one accepted on callback, in the browser it was issued to
+	tests := []struct {
+		name       string
+		cookie     *http.Cookie
+		wantStatus int
+	}{
+		{name: "same browser", cookie: nonce, wantStatus: http.StatusOK},
+		{name: "other browser", cookie: nil, wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet,
+				"/api/v1/auth/github/callback?code=abc&state="+url.QueryEscape(state), nil)
+			if tt.cookie != nil {
+				req.AddCookie(tt.cookie)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Errorf("callback status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestGithubIdMatching(t *testing.T) {
+	// Beyond float64 precision, renamed since registering as octocat
+	const ghId = int64(9007199254740993)
+	mux := http.NewServeMux()
+	mux.Handle("/", fakeGithubMux())
+	mux.HandleFunc("GET /user", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id": %d, "login": "octocat-renamed", "name": "The Octocat",
+			"email": "octo@example.com", "avatar_url": "https://example.com/octocat.png"}`, ghId)
+	})
+	github := httptest.NewServer(mux)
+	defer github.Close()
+
+	var lookedUp []pgtype.Int8
+	var updated []db.UpdateGithubProfileQueryParams
+	q := loginQuerier()
+	q.checkUserExistByGithubId = func(_ db.DBTX, id pgtype.Int8) (db.CheckUserExistByGithubIdQueryRow, error) {
+		lookedUp = append(lookedUp, id)
+		if id != (pgtype.Int8{Int64: ghId, Valid: true}) {
+			return db.CheckUserExistByGithubIdQueryRow{}, pgx.ErrNoRows
+		}
+		return db.CheckUserExistByGithubIdQueryRow{Ghusername: "octocat", Email: "octo@example.com"}, nil
+	}
+	q.updateGithubProfile = func(_ db.DBTX, arg db.UpdateGithubProfileQueryParams) error {
+		updated = append(updated, arg)
+		return nil
+	}
+	a := newTestApp(q)
+	a.Env = testEnv()
+	a.Env.OAuthResponseMode = "json"
+
+	w := githubLogin(t, a, github)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	if len(lookedUp) != 1 || lookedUp[0].Int64 != ghId {
+		t.Errorf("looked up GitHub IDs %v, want %d", lookedUp, ghId)
+	}
+	if len(updated) != 1 || updated[0].GhID != (pgtype.Int8{Int64: ghId, Valid: true}) ||
+		updated[0].Ghusername != "octocat" {
+		t.Errorf("profile updates = %+v", updated)
+	}
+}
+
+func TestRefreshFingerprintBinding(t *testing.T) {
+	const (
+		firefox = "Mozilla/5.0 (X11; Linux x86_64; rv:131.0) Gecko/20100101 Firefox/131.0"
+		curl    = "curl/8.10.1"
+	)
+	// Fingerprint of the client the session was issued to
+	var issuedTo pkg.Fingerprint
+	capture := gin.New()
+	capture.GET("/", func(c *gin.Context) { issuedTo = pkg.ClientFingerprint(c) })
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("User-Agent", firefox)
+	req.RemoteAddr = "203.0.113.7:52100"
+	capture.ServeHTTP(httptest.NewRecorder(), req)
+
+	tests := []struct {
+		name       string
+		binding    bool
+		recorded   bool // false for sessions issued before fingerprints
+		userAgent  string
+		remoteAddr string
+		wantStatus int
+	}{
+		{name: "same client", binding: true, recorded: true, userAgent: firefox,
+			remoteAddr: "203.0.113.7:52100", wantStatus: http.StatusOK},
+		{name: "same browser on another network", binding: true, recorded: true, userAgent: firefox,
+			remoteAddr: "198.51.100.1:40000", wantStatus: http.StatusOK},
+		{name: "other client on another network", binding: true, recorded: true, userAgent: curl,
+			remoteAddr: "198.51.100.1:40000", wantStatus: http.StatusUnauthorized},
+		{name: "binding disabled", binding: false, recorded: true, userAgent: curl,
+			remoteAddr: "198.51.100.1:40000", wantStatus: http.StatusOK},
+		{name: "unrecorded fingerprint", binding: true, recorded: false, userAgent: curl,
+			remoteAddr: "198.51.100.1:40000", wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jti := uuid.New()
+			session := db.CheckSessionQueryRow{Ghusername: "octocat", Email: "octo@example.com"}
+			if tt.recorded {
+				session.UaHash, session.IpClass = issuedTo.UaHash, issuedTo.IpClass
+			}
+			q := &fakeQuerier{
+				checkSession: func(db.DBTX, uuid.UUID) (db.CheckSessionQueryRow, error) {
+					return session, nil
+				},
+				checkIsMaintainer: func(db.DBTX, string) (bool, error) { return false, nil },
+			}
+			a := newTestApp(q)
+			a.Env = testEnv()
+			a.Env.TokenFingerprintBinding = tt.binding
+			refreshToken, err := pkg.CreateToken(a.Env, "octocat", "octo@example.com", "refresh_token",
+				pkg.WithTokenId(jti.String()))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			router := gin.New()
+			router.GET("/api/v1/auth/refresh", a.RegenerateToken)
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/refresh", nil)
+			req.Header.Set("Authorization", "Bearer "+refreshToken)
+			req.Header.Set("User-Agent", tt.userAgent)
+			req.RemoteAddr = tt.remoteAddr
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus == http.StatusUnauthorized &&
+				!strings.Contains(w.Body.String(), "Session is not valid for this device") {
+				t.Errorf("body = %s", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestUnregisteredGithubLogin(t *testing.T) {
+	const verifiedPrimary = `[{"email": "Octo@Example.com", "primary": true, "verified": true}]`
+	tests := []struct {
+		name         string
+		autoRegister bool
+		emails       string // served at /user/emails
+		wantStatus   int
+		wantMessage  string
+		wantAccount  *db.CreateUserAccountQueryParams
+	}{
+		{
+			name:        "rejected by default",
+			emails:      verifiedPrimary,
+			wantStatus:  http.StatusNotFound,
+			wantMessage: "User not registered",
+		},
+		{
+			name:         "registered",
+			autoRegister: true,
+			emails:       verifiedPrimary,
+			wantStatus:   http.StatusOK,
+			wantMessage:  "User login successful",
+			wantAccount: &db.CreateUserAccountQueryParams{
+				Email:             "octo@example.com",
+				Ghusername:        "octocat",
+				DisplayGhusername: "octocat",
+				Timezone:          pkg.DefaultTimezone,
+				Locale:            pkg.DefaultLocale,
+			},
+		},
+		{
+			// The public profile email is unverified, so it is not enough
+			name:         "no verified primary email",
+			autoRegister: true,
+			emails:       `[{"email": "octo@example.com", "primary": true, "verified": false}]`,
+			wantStatus:   http.StatusForbidden,
+			wantMessage:  "A verified primary email on GitHub is required to register",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var created []db.CreateUserAccountQueryParams
+			var audits []db.AddAuditLogQueryParams
+			q := loginQuerier()
+			q.checkUserExistByGithubId = func(db.DBTX, pgtype.Int8) (db.CheckUserExistByGithubIdQueryRow, error) {
+				return db.CheckUserExistByGithubIdQueryRow{}, pgx.ErrNoRows
+			}
+			q.checkGithubFallbackUser = func(db.DBTX, db.CheckGithubFallbackUserQueryParams) (db.CheckGithubFallbackUserQueryRow, error) {
+				return db.CheckGithubFallbackUserQueryRow{}, pgx.ErrNoRows
+			}
+			q.createUserAccount = func(_ db.DBTX, arg db.CreateUserAccountQueryParams) (string, error) {
+				created = append(created, arg)
+				return arg.Ghusername, nil
+			}
+			q.addAuditLog = func(_ db.DBTX, arg db.AddAuditLogQueryParams) error {
+				audits = append(audits, arg)
+				return nil
+			}
+			a := newTestApp(q)
+			a.Env = testEnv()
+			a.Env.OAuthResponseMode = "json"
+			a.Env.OAuthAutoRegister = tt.autoRegister
+
+			mux := fakeGithubMux()
+			mux.HandleFunc("GET /user/emails", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(tt.emails))
+			})
+			github := httptest.NewServer(mux)
+			defer github.Close()
+
+			w := githubLogin(t, a, github)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), tt.wantMessage) {
+				t.Errorf("body = %s, want %q", w.Body.String(), tt.wantMessage)
+			}
+			if tt.wantAccount == nil {
+				if len(created) != 0 || len(audits) != 0 {
+					t.Errorf("created = %+v, audits = %+v, want none", created, audits)
+				}
+				return
+			}
+			if len(created) != 1 || created[0] != *tt.wantAccount {
+				t.Errorf("created = %+v, want %+v", created, *tt.wantAccount)
+			}
+			if len(audits) != 1 || audits[0].Action != "user.auto_register" || audits[0].Target != "octocat" {
+				t.Errorf("audits = %+v", audits)
+			}
+		})
+	}
+}
+
+func TestIncompleteGithubUser(t *testing.T) {
+	tests := []struct {
+		name string
+		user string // served at /user
+	}{
+		{name: "login missing", user: `{"id": 583231, "name": "The Octocat"}`},
+		{name: "login blank", user: `{"id": 583231, "login": "  "}`},
+		{name: "id missing", user: `{"login": "octocat"}`},
+		{name: "empty object", user: `{}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.Handle("/login/", fakeGithubMux())
+			mux.HandleFunc("GET /user", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(tt.user))
+			})
+			github := httptest.NewServer(mux)
+			defer github.Close()
+
+			// Neither flow may go on to match an account, so no query is
+			// set up
+			a := newTestApp(&fakeQuerier{})
+			a.Env = testEnv()
+			w := githubLogin(t, a, github)
+			if w.Code != http.StatusBadGateway {
+				t.Errorf("login: status = %d, want %d: %s", w.Code, http.StatusBadGateway, w.Body.String())
+			}
+
+			w = linkAccount(t, "octocat", a.StartGithubLink,
+				"/api/v1/auth/github/callback", a.CompleteGitHubOAuth, true)
+			if w.Code != http.StatusBadGateway {
+				t.Errorf("link: status = %d, want %d: %s", w.Code, http.StatusBadGateway, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestGithubLoginUsernameCase(t *testing.T) {
+	tests := []struct {
+		name        string
+		login       string // served by GitHub
+		linkedId    bool   // the account already holds the GitHub ID
+		wantDisplay string
+	}{
+		{name: "same case", login: "octocat", wantDisplay: "octocat"},
+		{name: "mixed case", login: "OctoCat", wantDisplay: "OctoCat"},
+		{name: "upper case", login: "OCTOCAT", wantDisplay: "OCTOCAT"},
+		{name: "case changed after linking", login: "OctoCat", linkedId: true, wantDisplay: "OctoCat"},
+		// The stored username is kept, so its casing is the only one known
+		{name: "renamed after linking", login: "OctoCat-Renamed", linkedId: true, wantDisplay: "octocat"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.Handle("/", fakeGithubMux())
+			mux.HandleFunc("GET /user", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprintf(w, `{"id": 583231, "login": %q, "email": "octo@example.com"}`, tt.login)
+			})
+			github := httptest.NewServer(mux)
+			defer github.Close()
+
+			// Registered as OctoCat, which was stored lowercased
+			var updated []db.UpdateGithubProfileQueryParams
+			q := loginQuerier()
+			q.checkUserExistByGithubId = func(db.DBTX, pgtype.Int8) (db.CheckUserExistByGithubIdQueryRow, error) {
+				if !tt.linkedId {
+					return db.CheckUserExistByGithubIdQueryRow{}, pgx.ErrNoRows
+				}
+				return db.CheckUserExistByGithubIdQueryRow{Ghusername: "octocat", Email: "octo@example.com"}, nil
+			}
+			q.checkGithubFallbackUser = func(_ db.DBTX, arg db.CheckGithubFallbackUserQueryParams) (db.CheckGithubFallbackUserQueryRow, error) {
+				if arg.Ghusername != "octocat" {
+					return db.CheckGithubFallbackUserQueryRow{}, pgx.ErrNoRows
+				}
+				return db.CheckGithubFallbackUserQueryRow{Ghusername: "octocat", Email: "octo@example.com"}, nil
+			}
+			q.updateGithubProfile = func(_ db.DBTX, arg db.UpdateGithubProfileQueryParams) error {
+				updated = append(updated, arg)
+				return nil
+			}
+			a := newTestApp(q)
+			a.Env = testEnv()
+			a.Env.OAuthResponseMode = "json"
+
+			w := githubLogin(t, a, github)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+			}
+			var body map[string]any
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body["github_username"] != "octocat" {
+				t.Errorf("github_username = %v, want octocat", body["github_username"])
+			}
+			if len(updated) != 1 || updated[0].Ghusername != "octocat" || updated[0].DisplayGhusername != tt.wantDisplay {
+				t.Errorf("profile updates = %+v, want display %q", updated, tt.wantDisplay)
+			}
+		})
+	}
+}
+
+func TestRefreshRoutes(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		cookie     bool
+		reused     bool // rotated by a concurrent refresh already
+		wantStatus int
+		wantRotate bool
+	}{
+		{name: "GET with header", method: http.MethodGet, wantStatus: http.StatusOK},
+		{name: "POST with header", method: http.MethodPost, wantStatus: http.StatusOK},
+		{name: "GET with cookie", method: http.MethodGet, cookie: true, wantStatus: http.StatusOK, wantRotate: true},
+		{name: "POST with cookie", method: http.MethodPost, cookie: true, wantStatus: http.StatusOK, wantRotate: true},
+		{name: "cookie rotated already", method: http.MethodPost, cookie: true, reused: true,
+			wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var added int
+			q := &fakeQuerier{
+				checkSession: func(db.DBTX, uuid.UUID) (db.CheckSessionQueryRow, error) {
+					return db.CheckSessionQueryRow{Ghusername: "octocat", Email: "octo@example.com"}, nil
+				},
+				checkIsMaintainer: func(db.DBTX, string) (bool, error) { return false, nil },
+				revokeTokenByJti: func(db.DBTX, db.RevokeTokenByJtiQueryParams) (int64, error) {
+					if tt.reused {
+						return 0, nil
+					}
+					return 1, nil
+				},
+				addSession: func(db.DBTX, db.AddSessionQueryParams) error {
+					added++
+					return nil
+				},
+			}
+			a := newTestApp(q)
+			a.Env = testEnv()
+			router, adminRouter, err := NewRouters(a.Env)
+			if err != nil {
+				t.Fatal(err)
+			}
+			a.RegisterRoutes(router, adminRouter)
+			refreshToken, err := pkg.CreateToken(a.Env, "octocat", "octo@example.com", "refresh_token",
+				pkg.WithTokenId(uuid.NewString()))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(tt.method, "/api/v1/auth/refresh", nil)
+			if tt.cookie {
+				req.AddCookie(&http.Cookie{Name: pkg.RefreshTokenCookie, Value: refreshToken})
+			} else {
+				req.Header.Set("Authorization", "Bearer "+refreshToken)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			rotated := false
+			for _, ck := range w.Result().Cookies() {
+				rotated = rotated || (ck.Name == pkg.RefreshTokenCookie && ck.Value != "")
+			}
+			if rotated != tt.wantRotate || (added == 1) != tt.wantRotate {
+				t.Errorf("refresh cookie set = %v, sessions added = %d, want rotation %v", rotated, added, tt.wantRotate)
+			}
+			if tt.wantStatus == http.StatusOK && !strings.Contains(w.Body.String(), `"accessKey"`) {
+				t.Errorf("body = %s, want an access token", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestGithubRedirectUri(t *testing.T) {
+	const (
+		production = "https://pulse.example.com/api/v1/auth/github/callback"
+		staging    = "https://staging.pulse.example.com/api/v1/auth/github/callback"
+	)
+	tests := []struct {
+		name         string
+		redirectUri  string // requested for the authorize URL
+		wantStatus   int
+		wantRedirect string // in the authorize URL and sent on exchange
+	}{
+		{name: "default", wantStatus: http.StatusOK, wantRedirect: production},
+		{name: "allowed", redirectUri: staging, wantStatus: http.StatusOK, wantRedirect: staging},
+		{name: "spoofed", redirectUri: "https://pulse.example.com.evil.example/callback",
+			wantStatus: http.StatusBadRequest},
+		// Compared whole, so a path added to an allowed URI is not allowed
+		{name: "spoofed path", redirectUri: production + "/../../evil", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var exchanged []string
+			mux := http.NewServeMux()
+			mux.Handle("/", fakeGithubMux())
+			mux.HandleFunc("POST /login/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+				exchanged = append(exchanged, r.FormValue("redirect_uri"))
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"access_token": "gho_token", "token_type": "bearer"}`))
+			})
+			github := httptest.NewServer(mux)
+			defer github.Close()
+
+			a := newTestApp(loginQuerier())
+			a.Env = testEnv()
+			a.Env.GhApiUrl = github.URL
+			a.Env.GhRedirectUrls = []string{production, staging}
+			a.OAuth = githubOAuthConfig(github)
+			a.OAuth.RedirectURL = production
+			a.Client = github.Client()
+			router := gin.New()
+			router.GET("/api/v1/auth/github/url", a.FetchGitHubOAuthUrl)
+			router.GET("/api/v1/auth/github/callback", a.CompleteGitHubOAuth)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet,
+				"/api/v1/auth/github/url?redirect_uri="+url.QueryEscape(tt.redirectUri), nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				if !strings.Contains(w.Body.String(), "redirect_uri is not allowed") {
+					t.Errorf("body = %s", w.Body.String())
+				}
+				if len(w.Result().Cookies()) != 0 {
+					t.Errorf("cookies set for a rejected redirect: %v", w.Result().Cookies())
+				}
+				return
+			}
+			var body struct {
+				Url string `json:"url"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			authorize, err := url.Parse(body.Url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := authorize.Query().Get("redirect_uri"); got != tt.wantRedirect {
+				t.Errorf("authorize redirect_uri = %q, want %q", got, tt.wantRedirect)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/github/callback?code=abc&state="+
+				url.QueryEscape(authorize.Query().Get("state")), nil)
+			for _, ck := range w.Result().Cookies() {
+				req.AddCookie(ck)
+			}
+			w = httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != http.StatusOK {
+				t.Fatalf("callback status = %d: %s", w.Code, w.Body.String())
+			}
+			if len(exchanged) != 1 || exchanged[0] != tt.wantRedirect {
+				t.Errorf("exchanged with redirect_uri %q, want %q", exchanged, tt.wantRedirect)
+			}
+		})
+	}
+
+	// A redirect URI removed from the allowlist after the flow started
+	a := newTestApp(loginQuerier())
+	a.Env = testEnv()
+	a.Env.GhRedirectUrls = []string{production}
+	state, nonce, err := pkg.CreateOAuthState(staging, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := gin.New()
+	router.GET("/api/v1/auth/github/callback", a.CompleteGitHubOAuth)
+	req := httptest.NewRequest(http.MethodGet,
+		"/api/v1/auth/github/callback?code=abc&state="+url.QueryEscape(state), nil)
+	req.AddCookie(&http.Cookie{Name: pkg.OAuthStateCookie, Value: nonce})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "redirect_uri is not allowed") {
+		t.Errorf("callback with a withdrawn redirect: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestGithubLoginWithoutEmail(t *testing.T) {
+	type account struct {
+		ghUsername string
+		email      string
+		ghId       int64
+	}
+	tests := []struct {
+		name        string
+		accounts    []account
+		publicEmail string // of the GitHub profile, null when empty
+		emails      string // served at /user/emails, 404 when empty
+		wantStatus  int
+		wantUser    string
+	}{
+		{
+			// An account without an email must not be taken for the user's
+			name:       "no email anywhere",
+			accounts:   []account{{ghUsername: "hubot"}},
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "blank primary email",
+			accounts:   []account{{ghUsername: "hubot"}},
+			emails:     `[{"email": "", "primary": true, "verified": true}]`,
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "unverified primary email",
+			accounts:   []account{{ghUsername: "hubot", email: "octo@example.com"}},
+			emails:     `[{"email": "octo@example.com", "primary": true, "verified": false}]`,
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "private email, verified primary",
+			accounts:   []account{{ghUsername: "octo-old", email: "octo@example.com"}},
+			emails:     `[{"email": "octo@example.com", "primary": true, "verified": true}]`,
+			wantStatus: http.StatusOK,
+			wantUser:   "octo-old",
+		},
+		{
+			name:        "public email",
+			accounts:    []account{{ghUsername: "octo-old", email: "octo@example.com"}},
+			publicEmail: "octo@example.com",
+			wantStatus:  http.StatusOK,
+			wantUser:    "octo-old",
+		},
+		{
+			name:       "no email, matched by username",
+			accounts:   []account{{ghUsername: "octocat", email: "octo@example.com"}},
+			wantStatus: http.StatusOK,
+			wantUser:   "octocat",
+		},
+		{
+			name:       "no email, matched by GitHub ID",
+			accounts:   []account{{ghUsername: "octo-old", email: "octo@example.com", ghId: 583231}},
+			wantStatus: http.StatusOK,
+			wantUser:   "octo-old",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := loginQuerier()
+			q.checkUserExistByGithubId = func(_ db.DBTX, ghId pgtype.Int8) (db.CheckUserExistByGithubIdQueryRow, error) {
+				for _, acc := range tt.accounts {
+					if ghId.Valid && acc.ghId == ghId.Int64 {
+						return db.CheckUserExistByGithubIdQueryRow{Ghusername: acc.ghUsername, Email: acc.email}, nil
+					}
+				}
+				return db.CheckUserExistByGithubIdQueryRow{}, pgx.ErrNoRows
+			}
+			q.checkGithubFallbackUser = func(_ db.DBTX, arg db.CheckGithubFallbackUserQueryParams) (db.CheckGithubFallbackUserQueryRow, error) {
+				for _, acc := range tt.accounts {
+					if acc.ghId == 0 && (acc.ghUsername == arg.Ghusername || (acc.email == arg.Email && acc.email != "")) {
+						return db.CheckGithubFallbackUserQueryRow{Ghusername: acc.ghUsername, Email: acc.email}, nil
+					}
+				}
+				return db.CheckGithubFallbackUserQueryRow{}, pgx.ErrNoRows
+			}
+			a := newTestApp(q)
+			a.Env = testEnv()
+			a.Env.OAuthResponseMode = "json"
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("POST /login/oauth/access_token", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"access_token": "gho_token", "token_type": "bearer"}`))
+			})
+			mux.HandleFunc("GET /user", func(w http.ResponseWriter, r *http.Request) {
+				email := "null"
+				if tt.publicEmail != "" {
+					email = `"` + tt.publicEmail + `"`
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"id": 583231, "login": "octocat", "email": ` + email + `}`))
+			})
+			mux.HandleFunc("GET /user/emails", func(w http.ResponseWriter, r *http.Request) {
+				if tt.emails == "" {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(tt.emails))
+			})
+			github := httptest.NewServer(mux)
+			defer github.Close()
+
+			w := githubLogin(t, a, github)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus == http.StatusUnprocessableEntity {
+				if !strings.Contains(w.Body.String(), "Make your email public on GitHub") {
+					t.Errorf("body = %s, want a prompt to share the email", w.Body.String())
+				}
+				return
+			}
+			var body struct {
+				GithubUsername string `json:"github_username"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.GithubUsername != tt.wantUser {
+				t.Errorf("logged in as %q, want %q", body.GithubUsername, tt.wantUser)
+			}
+		})
+	}
+}
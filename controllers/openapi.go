@@ -0,0 +1,217 @@
+package controllers
+
+import (
+	"maps"
+
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	"github.com/IAmRiteshKoushik/pulse/types"
+)
+
+// Documentation for routes, keyed by "<METHOD> <path>" as registered with gin.
+// Routes without an entry are left out of the OpenAPI document.
+var routeDocs = map[string]pkg.RouteDoc{
+	"POST /api/v1/auth/github": {
+		Summary: "Redirect to GitHub to begin OAuth login",
+		Query:   types.GithubAuthorizeQuery{},
+	},
+	"GET /api/v1/auth/github/url": {
+		Summary: "Fetch the GitHub authorize URL for OAuth login",
+		Query:   types.GithubAuthorizeQuery{},
+	},
+	"GET /api/v1/auth/github/callback": {
+		Summary: "Complete GitHub OAuth login",
+		Query:   types.GithubCallbackQuery{},
+	},
+	"POST /api/v1/auth/github/callback": {
+		Summary: "Complete GitHub OAuth login",
+		Query:   types.GithubCallbackQuery{},
+	},
+	"GET /api/v1/auth/google/callback": {
+		Summary: "Complete linking a Google account",
+		Query:   types.GithubCallbackQuery{},
+	},
+	"POST /api/v1/auth/register": {
+		Summary: "Begin user registration and send an OTP",
+		Body:    types.RegisterUserRequest{},
+	},
+	"POST /api/v1/auth/register/token": {
+		Summary: "Reissue the temp token of a pending registration, given its OTP",
+		Body:    types.ReissueTempTokenRequest{},
+	},
+	"POST /api/v1/auth/register/otp/verify": {
+		Summary: "Verify the registration OTP",
+		Body:    types.RegisterUserOtpVerifyRequest{},
+		Secured: true,
+	},
+	"GET /api/v1/auth/register/otp/resend": {
+		Summary: "Resend the registration OTP, over mail instead of SMS when ?channel=email",
+		Secured: true,
+	},
+	"GET /api/v1/auth/refresh": {
+		Summary: "Issue a new access token from a refresh token",
+		Secured: true,
+	},
+	"POST /api/v1/auth/refresh": {
+		Summary: "Issue a new access token, rotating the refresh token cookie when sent as one",
+		Secured: true,
+	},
+	"GET /api/v1/auth/token/info": {
+		Summary: "Fetch expiry information of an access token",
+		Secured: true,
+	},
+	"POST /api/v1/auth/passkey/options": {
+		Summary: "Fetch a passkey challenge to sign in without GitHub",
+		Body:    types.PasskeyLoginRequest{},
+	},
+	"POST /api/v1/auth/passkey/verify": {
+		Summary: "Sign in with the authenticator response to a passkey challenge",
+		Query:   types.PasskeyLoginVerifyRequest{},
+	},
+	"GET /api/v1/auth/available": {
+		Summary: "Check whether a GitHub username is available",
+		Query:   types.UsernameAvailabilityRequest{},
+	},
+	"GET /api/v1/profile": {
+		Summary: "Fetch the profile of the authenticated user",
+		Secured: true,
+	},
+	"GET /api/v1/users/:ghusername": {
+		Summary: "Fetch the public profile of a user",
+	},
+	"GET /api/v1/leaderboard": {
+		Summary: "Fetch the leaderboard",
+		Secured: true,
+	},
+	"GET /api/v1/leaderboard/snapshots/:date": {
+		Summary: "Fetch the leaderboard as of the latest snapshot on or before a date",
+		Query:   types.FetchLeaderboardSnapshotRequest{},
+		Secured: true,
+	},
+	"GET /api/v1/projects": {
+		Summary: "Fetch all projects",
+		Secured: true,
+	},
+	"GET /api/v1/issues/:projectId": {
+		Summary: "Fetch open issues of a project",
+		Secured: true,
+	},
+	"GET /api/v1/updates/live": {
+		Summary: "Fetch live updates",
+		Secured: true,
+	},
+	"GET /api/v1/me/sessions": {
+		Summary: "List signed in devices with their device labels",
+		Secured: true,
+	},
+	"DELETE /api/v1/me/sessions/:jti": {
+		Summary: "Sign out a device by revoking its refresh token",
+		Secured: true,
+	},
+	"GET /api/v1/me/token-status": {
+		Summary: "Fetch issue and expiry times of the refresh token cookie and whether to rotate it",
+		Secured: true,
+	},
+	"GET /api/v1/me/export": {
+		Summary: "Download all data held about the authenticated user",
+		Secured: true,
+	},
+	"POST /api/v1/me/sync-github": {
+		Summary: "Re-fetch the GitHub profile using the stored GitHub token",
+		Secured: true,
+	},
+	"POST /api/v1/me/link/github": {
+		Summary: "Fetch the GitHub authorize URL to link a GitHub account",
+		Secured: true,
+	},
+	"POST /api/v1/me/link/google": {
+		Summary: "Fetch the Google authorize URL to link a Google account",
+		Secured: true,
+	},
+	"POST /api/v1/me/passkeys/options": {
+		Summary: "Fetch the options to register a passkey",
+		Secured: true,
+	},
+	"POST /api/v1/me/passkeys": {
+		Summary: "Register a passkey from the authenticator response",
+		Query:   types.PasskeyRegisterRequest{},
+		Secured: true,
+	},
+	"GET /api/v1/me/providers": {
+		Summary: "List the sign-in providers linked to the account",
+		Secured: true,
+	},
+	"DELETE /api/v1/me/providers/:provider": {
+		Summary: "Unlink a sign-in provider, unless it is the last one",
+		Secured: true,
+	},
+	"GET /api/v1/me/notifications": {
+		Summary: "Fetch which notification mails the user receives",
+		Secured: true,
+	},
+	"PATCH /api/v1/me/notifications": {
+		Summary: "Turn notification mails on or off",
+		Body:    types.NotificationPreferencesRequest{},
+		Secured: true,
+	},
+	"POST /api/v1/me/bounty/transfer": {
+		Summary: "Transfer bounty points to another user",
+		Body:    types.BountyTransferRequest{},
+		Secured: true,
+	},
+	"POST /webhooks/mail": {
+		Summary: "Receive delivery events from the mail provider",
+		Body:    types.MailEvent{},
+	},
+	"GET /api/v1/admin/users/export": {
+		Summary: "Stream all users as newline delimited JSON or CSV",
+		Query:   types.ExportUsersRequest{},
+		Secured: true,
+	},
+	"POST /api/v1/admin/users/:email/force-onboard": {
+		Summary: "Onboard a pending registration without OTP verification",
+		Secured: true,
+	},
+	"POST /api/v1/admin/users/:email/resend-otp": {
+		Summary: "Regenerate and resend the OTP of a pending registration",
+		Secured: true,
+	},
+	"POST /api/v1/admin/bounty/award": {
+		Summary: "Award bounty points to a contributor",
+		Body:    types.BountyAwardRequest{},
+		Secured: true,
+	},
+	"POST /api/v1/admin/bounty/batch": {
+		Summary: "Award bounty from a CSV of ghusername,amount,reason rows",
+		Secured: true,
+	},
+	"GET /api/v1/admin/maintenance": {
+		Summary: "Fetch whether maintenance mode is enabled",
+		Secured: true,
+	},
+	"PUT /api/v1/admin/maintenance": {
+		Summary: "Enable or disable maintenance mode",
+		Body:    types.MaintenanceRequest{},
+		Secured: true,
+	},
+	"GET /api/v1/admin/audit": {
+		Summary: "Fetch audit logs",
+		Query:   types.FetchAuditLogsRequest{},
+		Secured: true,
+	},
+}
+
+// Only documented where mail previews are served, see MAIL_PREVIEW
+var mailPreviewDoc = pkg.RouteDoc{
+	Summary: "Render a mail template with sample data as HTML",
+	Query:   types.MailPreviewQuery{},
+	Secured: true,
+}
+
+// Documentation for the routes this configuration serves
+func (a *App) openAPIDocs() map[string]pkg.RouteDoc {
+	docs := maps.Clone(routeDocs)
+	if a.Env.MailPreview {
+		docs["GET /api/v1/admin/mail/preview"] = mailPreviewDoc
+	}
+	return docs
+}
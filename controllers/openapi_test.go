@@ -0,0 +1,176 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Paths of the OpenAPI document served by router
+func openAPIPaths(t *testing.T, router *gin.Engine) map[string]any {
+	t.Helper()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /openapi.json status = %d", w.Code)
+	}
+	var spec struct {
+		Paths map[string]any `json:"paths"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &spec); err != nil {
+		t.Fatal(err)
+	}
+	return spec.Paths
+}
+
+func TestOpenAPIMailPreview(t *testing.T) {
+	tests := []struct {
+		name        string
+		mailPreview bool
+		want        bool
+	}{
+		{name: "documented when enabled", mailPreview: true, want: true},
+		{name: "left out when disabled", mailPreview: false, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestApp(&fakeQuerier{})
+			a.Env = testEnv()
+			a.Env.MailPreview = tt.mailPreview
+			router := gin.New()
+			a.RegisterRoutes(router, router)
+
+			_, ok := openAPIPaths(t, router)["/api/v1/admin/mail/preview"]
+			if ok != tt.want {
+				t.Errorf("mail preview documented = %v, want %v", ok, tt.want)
+			}
+		})
+	}
+}
+
+// The parts of an OpenAPI operation the tests look at
+type openAPIOperation struct {
+	RequestBody *struct {
+		Content map[string]struct {
+			Schema struct {
+				Properties map[string]any `json:"properties"`
+			} `json:"schema"`
+		} `json:"content"`
+	} `json:"requestBody"`
+	Parameters []struct {
+		Name string `json:"name"`
+		In   string `json:"in"`
+	} `json:"parameters"`
+	Responses map[string]any `json:"responses"`
+	Security  []any          `json:"security"`
+}
+
+func TestOpenAPIRegistrationAndLogin(t *testing.T) {
+	tests := []struct {
+		path    string
+		method  string
+		body    []string // request body properties
+		query   []string // query parameters
+		secured bool
+	}{
+		{
+			path:   "/api/v1/auth/register",
+			method: "post",
+			body: []string{
+				"email", "github_username", "first_name", "middle_name", "last_name",
+				"timezone", "locale", "phone", "otp_channel",
+			},
+		},
+		{
+			path:    "/api/v1/auth/register/otp/verify",
+			method:  "post",
+			body:    []string{"otp"},
+			secured: true,
+		},
+		{
+			path:   "/api/v1/auth/register/token",
+			method: "post",
+			body:   []string{"email", "github_username", "otp"},
+		},
+		{
+			path:   "/api/v1/auth/github",
+			method: "post",
+			query:  []string{"redirect_uri", "client_id"},
+		},
+		{
+			path:   "/api/v1/auth/github/callback",
+			method: "get",
+			query:  []string{"code", "state"},
+		},
+		{
+			path:   "/api/v1/auth/github/callback",
+			method: "post",
+			query:  []string{"code", "state"},
+		},
+		{
+			path:   "/api/v1/auth/passkey/options",
+			method: "post",
+			body:   []string{"github_username"},
+		},
+	}
+
+	a := newTestApp(&fakeQuerier{})
+	router := gin.New()
+	a.RegisterRoutes(router, router)
+	paths := openAPIPaths(t, router)
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			item, ok := paths[tt.path]
+			if !ok {
+				t.Fatalf("%s is not documented", tt.path)
+			}
+			raw, err := json.Marshal(item)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ops map[string]openAPIOperation
+			if err := json.Unmarshal(raw, &ops); err != nil {
+				t.Fatal(err)
+			}
+			op, ok := ops[tt.method]
+			if !ok {
+				t.Fatalf("%s %s is not documented", tt.method, tt.path)
+			}
+
+			if _, ok := op.Responses["200"]; !ok {
+				t.Errorf("responses = %v, want a 200 response", op.Responses)
+			}
+			if secured := len(op.Security) > 0; secured != tt.secured {
+				t.Errorf("secured = %v, want %v", secured, tt.secured)
+			}
+
+			var body []string
+			if op.RequestBody != nil {
+				for name := range op.RequestBody.Content["application/json"].Schema.Properties {
+					body = append(body, name)
+				}
+			}
+			slices.Sort(body)
+			want := slices.Sorted(slices.Values(tt.body))
+			if !slices.Equal(body, want) {
+				t.Errorf("request body properties = %v, want %v", body, want)
+			}
+
+			var query []string
+			for _, p := range op.Parameters {
+				if p.In == "query" {
+					query = append(query, p.Name)
+				}
+			}
+			if !slices.Equal(query, tt.query) {
+				t.Errorf("query parameters = %v, want %v", query, tt.query)
+			}
+		})
+	}
+}
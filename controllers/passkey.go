@@ -0,0 +1,524 @@
+package controllers
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	db "github.com/IAmRiteshKoushik/pulse/db/gen"
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	"github.com/IAmRiteshKoushik/pulse/types"
+	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/oauth2"
+)
+
+// Ceremony state between issuing a challenge and verifying its response,
+// keyed by the kind of ceremony and a random id handed to the client. Kept in
+// memory, so both requests of a ceremony must reach the same instance.
+var passkeySessions = pkg.NewTTLCache[string, passkeyCeremony](5 * time.Minute)
+
+type passkeyCeremony struct {
+	ghUsername string
+	session    webauthn.SessionData
+}
+
+// Registering a passkey grants a new way to sign in, so it needs a recent
+// sign-in rather than any refreshed token
+const passkeyRegistrationAuthAge = 10 * time.Minute
+
+type passkeyUser struct {
+	account     db.FetchPasskeyUserQueryRow
+	credentials []webauthn.Credential
+}
+
+func (u passkeyUser) WebAuthnID() []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(u.account.ID))
+}
+
+func (u passkeyUser) WebAuthnName() string {
+	return u.account.Ghusername
+}
+
+func (u passkeyUser) WebAuthnDisplayName() string {
+	if u.account.DisplayGhusername != "" {
+		return u.account.DisplayGhusername
+	}
+	return u.account.Ghusername
+}
+
+func (u passkeyUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
+
+func (a *App) loadPasskeyUser(ctx context.Context, conn db.DBTX,
+	ghUsername string) (passkeyUser, error) {
+	var user passkeyUser
+
+	q := a.Queries
+	account, err := q.FetchPasskeyUserQuery(ctx, conn, ghUsername)
+	if err != nil {
+		return user, err
+	}
+	user.account = account
+
+	rows, err := q.FetchPasskeysQuery(ctx, conn, ghUsername)
+	if err != nil {
+		return user, err
+	}
+	for _, row := range rows {
+		var credential webauthn.Credential
+		if err := json.Unmarshal(row, &credential); err != nil {
+			return user, err
+		}
+		user.credentials = append(user.credentials, credential)
+	}
+	return user, nil
+}
+
+// Returns the options for navigator.credentials.create() to register a new
+// passkey on the signed in account
+func (a *App) StartPasskeyRegistration(c *gin.Context) {
+	if a.WebAuthn == nil {
+		a.passkeysNotConfigured(c)
+		return
+	}
+	username, ok := pkg.GrabUsername(c)
+	if !ok {
+		a.Log.Warn(
+			fmt.Sprintf("Failed to extract username from token at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Oops! Something happened. Please try again later.",
+		})
+		return
+	}
+	if !a.requireRecentSignIn(c) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), a.Env.TimeoutFor("passkey"))
+	defer cancel()
+
+	conn, err := a.DB.Acquire(ctx)
+	if err != nil {
+		pkg.DbAcquireError(c, err)
+		return
+	}
+	defer conn.Release()
+
+	user, err := a.loadPasskeyUser(ctx, conn, username)
+	if err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+	// Authenticators already registered are asked not to register again
+	creation, session, err := a.WebAuthn.BeginRegistration(user,
+		webauthn.WithExclusions(webauthn.Credentials(user.credentials).CredentialDescriptors()))
+	if err != nil {
+		a.passkeyCeremonyFailed(c, err)
+		return
+	}
+	ceremonyId := uuid.NewString()
+	passkeySessions.Set("register|"+ceremonyId, passkeyCeremony{ghUsername: username, session: *session})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Passkey registration options created successfully",
+		"options":     creation,
+		"ceremony_id": ceremonyId,
+	})
+	a.Log.Info(fmt.Sprintf(
+		"[SUCCESS]: Processed request at %s %s",
+		c.Request.Method, c.FullPath(),
+	))
+	return
+}
+
+// Verifies the attestation returned by the authenticator and stores the new
+// passkey. The body is the PublicKeyCredential as produced by the browser.
+func (a *App) RegisterPasskey(c *gin.Context) {
+	if a.WebAuthn == nil {
+		a.passkeysNotConfigured(c)
+		return
+	}
+	username, ok := pkg.GrabUsername(c)
+	if !ok {
+		a.Log.Warn(
+			fmt.Sprintf("Failed to extract username from token at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Oops! Something happened. Please try again later.",
+		})
+		return
+	}
+	var query types.PasskeyRegisterRequest
+	if err := c.ShouldBindQuery(&query); err != nil {
+		pkg.RequestValidatorError(c, err)
+		return
+	}
+	if err := query.Validate(); err != nil {
+		pkg.RequestValidatorError(c, err)
+		return
+	}
+	if !a.requireRecentSignIn(c) {
+		return
+	}
+
+	ceremony, ok := passkeySessions.Get("register|" + query.CeremonyId)
+	if !ok || ceremony.ghUsername != username {
+		a.passkeyChallengeExpired(c)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), a.Env.TimeoutFor("passkey"))
+	defer cancel()
+
+	tx, err := a.DB.Begin(ctx)
+	if err != nil {
+		pkg.DbAcquireError(c, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	user, err := a.loadPasskeyUser(ctx, tx, username)
+	if err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+	credential, err := a.WebAuthn.FinishRegistration(user, ceremony.session, c.Request)
+	if err != nil {
+		a.passkeyCeremonyFailed(c, err)
+		return
+	}
+	// A challenge is only ever answered once
+	passkeySessions.Delete("register|" + query.CeremonyId)
+	serialized, err := json.Marshal(credential)
+	if err != nil {
+		a.passkeyCeremonyFailed(c, err)
+		return
+	}
+
+	q := a.Queries
+	err = q.AddPasskeyQuery(ctx, tx, db.AddPasskeyQueryParams{
+		Ghusername:   username,
+		CredentialID: credential.ID,
+		Credential:   serialized,
+		Name:         query.Name,
+	})
+	if pkg.IsUniqueViolation(err) {
+		a.Log.Warn(
+			fmt.Sprintf("Passkey already registered at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusConflict, gin.H{
+			"message": "This passkey is already registered",
+		})
+		return
+	}
+	if err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+	err = q.AddAuditLogQuery(ctx, tx, db.AddAuditLogQueryParams{
+		Actor:   username,
+		Action:  "passkey.register",
+		Target:  username,
+		Details: fmt.Sprintf("name=%q", query.Name),
+	})
+	if err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Passkey registered successfully",
+	})
+	a.Log.Info(fmt.Sprintf(
+		"[SUCCESS]: Processed request at %s %s",
+		c.Request.Method, c.FullPath(),
+	))
+	return
+}
+
+// Returns the options for navigator.credentials.get() to sign in with one of
+// the passkeys of the given user
+func (a *App) StartPasskeyLogin(c *gin.Context) {
+	if a.WebAuthn == nil {
+		a.passkeysNotConfigured(c)
+		return
+	}
+	var body types.PasskeyLoginRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		pkg.JSONUnmarshallError(c, err)
+		return
+	}
+	if err := body.Validate(); err != nil {
+		pkg.RequestValidatorError(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), a.Env.TimeoutFor("passkey"))
+	defer cancel()
+
+	conn, err := a.DB.Acquire(ctx)
+	if err != nil {
+		pkg.DbAcquireError(c, err)
+		return
+	}
+	defer conn.Release()
+
+	user, err := a.loadPasskeyUser(ctx, conn, body.GhUsername)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		pkg.DbError(c, err)
+		return
+	}
+	// Unknown users and users without passkeys are indistinguishable
+	if len(user.credentials) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "No passkey is registered for this user",
+		})
+		return
+	}
+	assertion, session, err := a.WebAuthn.BeginLogin(user)
+	if err != nil {
+		a.passkeyCeremonyFailed(c, err)
+		return
+	}
+	ceremonyId := uuid.NewString()
+	passkeySessions.Set("login|"+ceremonyId, passkeyCeremony{ghUsername: body.GhUsername, session: *session})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Passkey login options created successfully",
+		"options":     assertion,
+		"ceremony_id": ceremonyId,
+	})
+	a.Log.Info(fmt.Sprintf(
+		"[SUCCESS]: Processed request at %s %s",
+		c.Request.Method, c.FullPath(),
+	))
+	return
+}
+
+// Verifies the assertion returned by the authenticator and signs the user in,
+// as an alternative to GitHub login. The body is the PublicKeyCredential as
+// produced by the browser, the ceremony it answers is named in the query
+// string.
+func (a *App) CompletePasskeyLogin(c *gin.Context) {
+	if a.WebAuthn == nil {
+		a.passkeysNotConfigured(c)
+		return
+	}
+	var query types.PasskeyLoginVerifyRequest
+	if err := c.ShouldBindQuery(&query); err != nil {
+		pkg.RequestValidatorError(c, err)
+		return
+	}
+	if err := query.Validate(a.Env); err != nil {
+		pkg.RequestValidatorError(c, err)
+		return
+	}
+
+	ceremony, ok := passkeySessions.Get("login|" + query.CeremonyId)
+	if !ok {
+		a.passkeyChallengeExpired(c)
+		return
+	}
+	ghUsername := ceremony.ghUsername
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), a.Env.TimeoutFor("passkey"))
+	defer cancel()
+
+	tx, err := a.DB.Begin(ctx)
+	if err != nil {
+		pkg.DbAcquireError(c, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	user, err := a.loadPasskeyUser(ctx, tx, ghUsername)
+	if errors.Is(err, pgx.ErrNoRows) {
+		a.passkeyLoginFailed(c, err)
+		return
+	}
+	if err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+	credential, err := a.WebAuthn.FinishLogin(user, ceremony.session, c.Request)
+	if err != nil {
+		a.passkeyLoginFailed(c, err)
+		return
+	}
+	// A sign count going backwards suggests a cloned authenticator
+	if credential.Authenticator.CloneWarning {
+		a.passkeyLoginFailed(c, fmt.Errorf("Sign count of passkey did not increase"))
+		return
+	}
+	// A challenge is only ever answered once
+	passkeySessions.Delete("login|" + query.CeremonyId)
+
+	if a.Env.GhOrg != "" && !a.checkPasskeyOrgMembership(ctx, c, tx, ghUsername) {
+		return
+	}
+	serialized, err := json.Marshal(credential)
+	if err != nil {
+		a.passkeyCeremonyFailed(c, err)
+		return
+	}
+
+	q := a.Queries
+	err = q.UpdatePasskeyQuery(ctx, tx, db.UpdatePasskeyQueryParams{
+		Credential:   serialized,
+		CredentialID: credential.ID,
+		Ghusername:   ghUsername,
+	})
+	if err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+	accessToken, refreshToken, loginUser, ok := a.startSession(ctx, c, tx,
+		user.account.Ghusername, user.account.Email, query.ClientId)
+	if !ok {
+		return
+	}
+	newDevice := false
+	if a.Env.LoginAnomalyDetection {
+		newDevice, err = a.recordLogin(ctx, c, tx, loginUser.Ghusername)
+		if err != nil {
+			pkg.DbError(c, err)
+			return
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+	if newDevice && a.Env.LoginAlertMail {
+		go a.sendLoginAlert(loginUser.Ghusername, loginUser.Email, pkg.ClientIP(c), c.Request.UserAgent())
+	}
+
+	response := gin.H{
+		"message":          "User login successful",
+		"access_token":     accessToken,
+		"refresh_token":    refreshToken,
+		"github_username":  loginUser.Ghusername,
+		"email":            loginUser.Email,
+		"bounty":           loginUser.Bounty,
+		"profile_complete": profileComplete(user.account.FullName, user.account.AvatarUrl),
+	}
+	if a.Env.RefreshTokenCookie {
+		pkg.SetRefreshTokenCookie(c, refreshToken)
+		delete(response, "refresh_token")
+	}
+	c.JSON(http.StatusOK, response)
+	a.Log.Info(fmt.Sprintf(
+		"[SUCCESS]: Processed request at %s %s",
+		c.Request.Method, c.FullPath(),
+	))
+	return
+}
+
+// Passkey logins bypass GitHub, so membership of GITHUB_ORG is checked with
+// the GitHub token stored at the user's last GitHub login
+func (a *App) checkPasskeyOrgMembership(ctx context.Context, c *gin.Context, conn db.DBTX,
+	ghUsername string) bool {
+	q := a.Queries
+	ghToken, err := q.FetchGithubTokenQuery(ctx, conn, ghUsername)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		pkg.DbError(c, err)
+		return false
+	}
+	accessToken, ok := a.openGithubToken(ghToken)
+	if !ok {
+		a.githubReauthRequired(c)
+		return false
+	}
+
+	client := a.OAuth.Client(context.WithValue(ctx, oauth2.HTTPClient, a.Client),
+		&oauth2.Token{AccessToken: accessToken, TokenType: "Bearer"})
+	isMember, err := a.checkOrgMembership(client, ghUsername)
+	if errors.Is(err, errGithubTokenExpired) {
+		a.githubReauthRequired(c)
+		return false
+	}
+	if err != nil {
+		a.Log.Error(
+			fmt.Sprintf("Failed to check github org membership at %s %s",
+				c.Request.Method, c.FullPath()), err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Oops! Something happened. Please try again later",
+		})
+		return false
+	}
+	if !isMember {
+		a.Log.Warn(
+			fmt.Sprintf("Non-member of github org attempted to login at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusForbidden, gin.H{
+			"message": "User is not a member of the organization",
+		})
+		return false
+	}
+	return true
+}
+
+func (a *App) requireRecentSignIn(c *gin.Context) bool {
+	claims, ok := pkg.GrabClaims(c)
+	if ok && claims.SignedInWithin(passkeyRegistrationAuthAge) {
+		return true
+	}
+	a.Log.Warn(
+		fmt.Sprintf("Passkey registration without a recent sign-in at %s %s",
+			c.Request.Method, c.FullPath()))
+	c.JSON(http.StatusForbidden, gin.H{
+		"message": "Please sign in again to register a passkey",
+	})
+	return false
+}
+
+func (a *App) passkeysNotConfigured(c *gin.Context) {
+	a.Log.Warn(
+		fmt.Sprintf("Passkey used without configuration at %s %s",
+			c.Request.Method, c.FullPath()))
+	c.JSON(http.StatusNotFound, gin.H{
+		"message": "Passkeys are not available",
+	})
+}
+
+func (a *App) passkeyChallengeExpired(c *gin.Context) {
+	a.Log.Warn(
+		fmt.Sprintf("Passkey response without a pending challenge at %s %s",
+			c.Request.Method, c.FullPath()))
+	c.JSON(http.StatusBadRequest, gin.H{
+		"message": "Passkey challenge has expired. Please try again.",
+	})
+}
+
+func (a *App) passkeyCeremonyFailed(c *gin.Context, err error) {
+	a.Log.Warn(
+		fmt.Sprintf("Passkey ceremony failed at %s %s: %v",
+			c.Request.Method, c.FullPath(), err))
+	c.JSON(http.StatusBadRequest, gin.H{
+		"message": "Passkey could not be verified",
+	})
+}
+
+func (a *App) passkeyLoginFailed(c *gin.Context, err error) {
+	a.Log.Warn(
+		fmt.Sprintf("Passkey login rejected at %s %s: %v",
+			c.Request.Method, c.FullPath(), err))
+	c.JSON(http.StatusUnauthorized, gin.H{
+		"message": "Passkey login failed",
+	})
+}
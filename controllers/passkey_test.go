@@ -0,0 +1,235 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	db "github.com/IAmRiteshKoushik/pulse/db/gen"
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	"github.com/gin-gonic/gin"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testRPID   = "pulse.example.com"
+	testOrigin = "https://pulse.example.com"
+)
+
+// Software authenticator answering registration ceremonies with a P-256 key
+// and "none" attestation, as most platform authenticators do
+type softAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialId []byte
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentialId := make([]byte, 16)
+	rand.Read(credentialId)
+	return &softAuthenticator{key: key, credentialId: credentialId}
+}
+
+// Returns the PublicKeyCredential a browser would send for challenge
+func (s *softAuthenticator) create(t *testing.T, challenge, origin string) []byte {
+	t.Helper()
+	clientData, err := json.Marshal(map[string]string{
+		"type":      "webauthn.create",
+		"challenge": challenge,
+		"origin":    origin,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	publicKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  1, // P-256
+		XCoord: s.key.X.FillBytes(make([]byte, 32)),
+		YCoord: s.key.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rpIdHash := sha256.Sum256([]byte(testRPID))
+	var authData bytes.Buffer
+	authData.Write(rpIdHash[:])
+	authData.WriteByte(byte(protocol.FlagUserPresent | protocol.FlagUserVerified | protocol.FlagAttestedCredentialData))
+	authData.Write([]byte{0, 0, 0, 0}) // sign count
+	authData.Write(make([]byte, 16))   // AAGUID
+	authData.Write(binary.BigEndian.AppendUint16(nil, uint16(len(s.credentialId))))
+	authData.Write(s.credentialId)
+	authData.Write(publicKey)
+
+	attestation, err := webauthncbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": authData.Bytes(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encode := base64.RawURLEncoding.EncodeToString
+	body, err := json.Marshal(map[string]any{
+		"id":    encode(s.credentialId),
+		"rawId": encode(s.credentialId),
+		"type":  "public-key",
+		"response": map[string]string{
+			"clientDataJSON":    encode(clientData),
+			"attestationObject": encode(attestation),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+// Serves the passkey registration routes to a user signed in authAge ago
+func passkeyRouter(t *testing.T, a *App, username string, authAge time.Duration) *gin.Engine {
+	t.Helper()
+	router := gin.New()
+	signIn := func(c *gin.Context) {
+		c.Set("claims", &pkg.Claims{
+			AuthTime:         jwt.NewNumericDate(time.Now().Add(-authAge)),
+			RegisteredClaims: jwt.RegisteredClaims{Subject: username},
+		})
+		c.Set("username", username)
+	}
+	router.POST("/api/v1/me/passkeys/options", signIn, a.StartPasskeyRegistration)
+	router.POST("/api/v1/me/passkeys", signIn, a.RegisterPasskey)
+	return router
+}
+
+func startPasskeyRegistration(t *testing.T, router *gin.Engine) (ceremonyId, challenge string, status int) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/me/passkeys/options", nil))
+	if w.Code != http.StatusOK {
+		return "", "", w.Code
+	}
+	var body struct {
+		CeremonyId string                      `json:"ceremony_id"`
+		Options    protocol.CredentialCreation `json:"options"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	return body.CeremonyId, body.Options.Response.Challenge.String(), w.Code
+}
+
+func TestPasskeyRegistration(t *testing.T) {
+	tests := []struct {
+		name          string
+		authAge       time.Duration
+		finishAs      string // user answering the challenge, octocat if empty
+		origin        string
+		challenge     string // overrides the issued challenge
+		wantStart     int
+		wantStatus    int
+		wantPasskeyId bool
+	}{
+		{name: "registers", wantStart: http.StatusOK, wantStatus: http.StatusCreated, wantPasskeyId: true},
+		{name: "stale sign-in", authAge: time.Hour, wantStart: http.StatusForbidden},
+		{name: "foreign origin", origin: "https://evil.example.com", wantStart: http.StatusOK, wantStatus: http.StatusBadRequest},
+		{name: "other challenge", challenge: "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA", wantStart: http.StatusOK, wantStatus: http.StatusBadRequest},
+		{name: "ceremony of another user", finishAs: "hubot", wantStart: http.StatusOK, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var added []db.AddPasskeyQueryParams
+			q := &fakeQuerier{
+				fetchPasskeyUser: func(_ db.DBTX, ghUsername string) (db.FetchPasskeyUserQueryRow, error) {
+					return db.FetchPasskeyUserQueryRow{ID: 7, Ghusername: ghUsername}, nil
+				},
+				fetchPasskeys: func(db.DBTX, string) ([][]byte, error) { return nil, nil },
+				addPasskey: func(_ db.DBTX, arg db.AddPasskeyQueryParams) error {
+					added = append(added, arg)
+					return nil
+				},
+				addAuditLog: func(db.DBTX, db.AddAuditLogQueryParams) error { return nil },
+			}
+			a := newTestApp(q)
+			var err error
+			a.WebAuthn, err = webauthn.New(&webauthn.Config{
+				RPID:          testRPID,
+				RPDisplayName: "Pulse",
+				RPOrigins:     []string{testOrigin},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ceremonyId, challenge, status := startPasskeyRegistration(t, passkeyRouter(t, a, "octocat", tt.authAge))
+			if status != tt.wantStart {
+				t.Fatalf("options status = %d, want %d", status, tt.wantStart)
+			}
+			if status != http.StatusOK {
+				return
+			}
+
+			if tt.challenge != "" {
+				challenge = tt.challenge
+			}
+			origin := testOrigin
+			if tt.origin != "" {
+				origin = tt.origin
+			}
+			finishAs := "octocat"
+			if tt.finishAs != "" {
+				finishAs = tt.finishAs
+			}
+			authenticator := newSoftAuthenticator(t)
+			register := func() *httptest.ResponseRecorder {
+				req := httptest.NewRequest(http.MethodPost,
+					"/api/v1/me/passkeys?name=Laptop&ceremony_id="+ceremonyId,
+					bytes.NewReader(authenticator.create(t, challenge, origin)))
+				req.Header.Set("Content-Type", "application/json")
+				w := httptest.NewRecorder()
+				passkeyRouter(t, a, finishAs, 0).ServeHTTP(w, req)
+				return w
+			}
+
+			w := register()
+			if w.Code != tt.wantStatus {
+				t.Fatalf("register status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if !tt.wantPasskeyId {
+				if len(added) != 0 {
+					t.Errorf("passkey stored although the ceremony failed")
+				}
+				return
+			}
+			if len(added) != 1 || !bytes.Equal(added[0].CredentialID, authenticator.credentialId) ||
+				added[0].Ghusername != "octocat" || added[0].Name != "Laptop" {
+				t.Fatalf("stored passkeys = %+v", added)
+			}
+			// A challenge is only ever answered once
+			if w := register(); w.Code != http.StatusBadRequest {
+				t.Errorf("replayed register status = %d, want %d", w.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
@@ -4,27 +4,24 @@ import (
 	"context"
 	"fmt"
 	"net/http"
-	"time"
 
-	"github.com/IAmRiteshKoushik/pulse/cmd"
-	db "github.com/IAmRiteshKoushik/pulse/db/gen"
 	"github.com/IAmRiteshKoushik/pulse/pkg"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
-func FetchProjects(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (a *App) FetchProjects(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), a.Env.TimeoutFor("projects"))
 	defer cancel()
 
-	conn, err := cmd.DBPool.Acquire(ctx)
+	conn, err := a.Replica.Acquire(ctx)
 	if err != nil {
-		pkg.DbError(c, err)
+		pkg.DbAcquireError(c, err)
 		return
 	}
-	conn.Release()
+	defer conn.Release()
 
-	q := db.New()
+	q := a.Queries
 	results, err := q.FetchAllProjectsQuery(ctx, conn)
 	if err != nil {
 		pkg.DbError(c, err)
@@ -35,18 +32,18 @@ func FetchProjects(c *gin.Context) {
 		"message":  "Projects retrived successfully",
 		"projects": results,
 	})
-	cmd.Log.Info(fmt.Sprintf(
+	a.Log.Info(fmt.Sprintf(
 		"[SUCCESS]: Processed request at %s %s",
 		c.Request.Method, c.FullPath(),
 	))
 	return
 }
 
-func FetchIssues(c *gin.Context) {
+func (a *App) FetchIssues(c *gin.Context) {
 	projectIdParam := c.Param("projectId")
 	projectId, err := uuid.Parse(projectIdParam)
 	if err != nil {
-		cmd.Log.Error(
+		a.Log.Error(
 			fmt.Sprintf("[INVALID-ID]: Given project-id is invalid UUID at %s %s",
 				c.Request.Method, c.FullPath()), err)
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -55,24 +52,24 @@ func FetchIssues(c *gin.Context) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), a.Env.TimeoutFor("issues"))
 	defer cancel()
 
-	conn, err := cmd.DBPool.Acquire(ctx)
+	conn, err := a.Replica.Acquire(ctx)
 	if err != nil {
-		pkg.DbError(c, err)
+		pkg.DbAcquireError(c, err)
 		return
 	}
-	conn.Release()
+	defer conn.Release()
 
-	q := db.New()
+	q := a.Queries
 	ok, err := q.CheckIfProjectExistsQuery(ctx, conn, projectId)
 	if err != nil {
 		pkg.DbError(c, err)
 		return
 	}
 	if !ok {
-		cmd.Log.Error(
+		a.Log.Error(
 			fmt.Sprintf("[INVALID-ID]: No project with given project-id exists at %s %s",
 				c.Request.Method, c.FullPath()), err)
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -91,7 +88,7 @@ func FetchIssues(c *gin.Context) {
 		"message":  "Issues retrived successfully",
 		"projects": results,
 	})
-	cmd.Log.Info(fmt.Sprintf(
+	a.Log.Info(fmt.Sprintf(
 		"[SUCCESS]: Processed request at %s %s",
 		c.Request.Method, c.FullPath(),
 	))
@@ -0,0 +1,195 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	db "github.com/IAmRiteshKoushik/pulse/db/gen"
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Lists the sign-in providers linked to the account. GitHub is linked through
+// the GitHub ID kept on the account, other providers through user_identity.
+func (a *App) FetchProviders(c *gin.Context) {
+	username, ok := pkg.GrabUsername(c)
+	if !ok {
+		a.Log.Warn(
+			fmt.Sprintf("Failed to extract username from token at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Oops! Something happened. Please try again later.",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), a.Env.TimeoutFor("profile"))
+	defer cancel()
+
+	conn, err := a.DB.Acquire(ctx)
+	if err != nil {
+		pkg.DbAcquireError(c, err)
+		return
+	}
+	defer conn.Release()
+
+	q := a.Queries
+	linked, err := q.FetchLinkedProvidersQuery(ctx, conn, username)
+	if err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+
+	providers := make([]gin.H, 0, len(linked))
+	for _, provider := range linked {
+		linkedAt := ""
+		if provider.LinkedAt.Valid {
+			linkedAt = pkg.FormatTime(provider.LinkedAt.Time)
+		}
+		providers = append(providers, gin.H{
+			"provider":  provider.Provider,
+			"linked_at": linkedAt,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Linked providers retrived successfully",
+		"providers": providers,
+	})
+	a.Log.Info(fmt.Sprintf(
+		"[SUCCESS]: Processed request at %s %s",
+		c.Request.Method, c.FullPath(),
+	))
+	return
+}
+
+// Unlinks a provider from the account. GitHub cannot be unlinked while it is
+// the only way to sign in, and stays unlinked until linked again.
+func (a *App) UnlinkProvider(c *gin.Context) {
+	username, ok := pkg.GrabUsername(c)
+	if !ok {
+		a.Log.Warn(
+			fmt.Sprintf("Failed to extract username from token at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Oops! Something happened. Please try again later.",
+		})
+		return
+	}
+
+	provider := c.Param("provider")
+	if provider != providerGithub && provider != providerGoogle {
+		a.Log.Warn(
+			fmt.Sprintf("Unlink of unknown provider %q at %s %s",
+				provider, c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Unknown provider",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), a.Env.TimeoutFor("profile"))
+	defer cancel()
+
+	tx, err := a.DB.Begin(ctx)
+	if err != nil {
+		pkg.DbAcquireError(c, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	q := a.Queries
+	// Serializes concurrent unlinks, which could otherwise remove both of the
+	// last two providers
+	_, err = q.LockUserAccountQuery(ctx, tx, username)
+	if errors.Is(err, pgx.ErrNoRows) {
+		a.Log.Warn(
+			fmt.Sprintf("Unlink requested for missing account at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "User not registered",
+		})
+		return
+	}
+	if err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+	linked, err := q.FetchLinkedProvidersQuery(ctx, tx, username)
+	if err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+	found := false
+	for _, l := range linked {
+		if l.Provider == provider {
+			found = true
+		}
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message":  "Provider is not linked",
+			"provider": provider,
+		})
+		return
+	}
+	// Google is only linked, never signed in with, so GitHub can only go
+	// when a passkey is left to sign in with
+	if provider == providerGithub {
+		passkeys, err := q.CountPasskeysQuery(ctx, tx, username)
+		if err != nil {
+			pkg.DbError(c, err)
+			return
+		}
+		if passkeys == 0 {
+			a.Log.Warn(
+				fmt.Sprintf("Unlink of the last sign-in method at %s %s",
+					c.Request.Method, c.FullPath()))
+			c.JSON(http.StatusConflict, gin.H{
+				"message":  "The last way to sign in cannot be unlinked. Please register a passkey first.",
+				"provider": provider,
+			})
+			return
+		}
+	}
+
+	if provider == providerGithub {
+		_, err = q.UnlinkGithubIdQuery(ctx, tx, username)
+	} else {
+		_, err = q.DeleteIdentityQuery(ctx, tx, db.DeleteIdentityQueryParams{
+			Ghusername: username,
+			Provider:   provider,
+		})
+	}
+	if err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+
+	err = q.AddAuditLogQuery(ctx, tx, db.AddAuditLogQueryParams{
+		Actor:   username,
+		Action:  "account.unlink",
+		Target:  username,
+		Details: fmt.Sprintf("provider=%s", provider),
+	})
+	if err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Provider unlinked successfully",
+		"provider": provider,
+	})
+	a.Log.Info(fmt.Sprintf(
+		"[SUCCESS]: Processed request at %s %s",
+		c.Request.Method, c.FullPath(),
+	))
+	return
+}
@@ -0,0 +1,197 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sort"
+	"testing"
+	"time"
+
+	db "github.com/IAmRiteshKoushik/pulse/db/gen"
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Sign-in providers of user_account and user_identity, and passkeys, read the
+// way the provider queries read them
+type providerTable struct {
+	accounts map[string]bool // active accounts
+	linked   map[string]map[string]time.Time
+	passkeys map[string]int64
+	audits   []db.AddAuditLogQueryParams
+}
+
+func (tbl *providerTable) querier() *fakeQuerier {
+	return &fakeQuerier{
+		lockUserAccount: func(_ db.DBTX, ghUsername string) (string, error) {
+			if !tbl.accounts[ghUsername] {
+				return "", pgx.ErrNoRows
+			}
+			return ghUsername, nil
+		},
+		fetchLinkedProviders: func(_ db.DBTX, ghUsername string) ([]db.FetchLinkedProvidersQueryRow, error) {
+			var rows []db.FetchLinkedProvidersQueryRow
+			for provider, at := range tbl.linked[ghUsername] {
+				rows = append(rows, db.FetchLinkedProvidersQueryRow{Provider: provider, LinkedAt: pkg.NewTimestamp(at)})
+			}
+			sort.Slice(rows, func(i, j int) bool { return rows[i].LinkedAt.Time.Before(rows[j].LinkedAt.Time) })
+			return rows, nil
+		},
+		countPasskeys: func(_ db.DBTX, ghUsername string) (int64, error) {
+			return tbl.passkeys[ghUsername], nil
+		},
+		unlinkGithubId: func(_ db.DBTX, ghUsername string) (int64, error) {
+			if _, ok := tbl.linked[ghUsername][providerGithub]; !ok {
+				return 0, nil
+			}
+			delete(tbl.linked[ghUsername], providerGithub)
+			return 1, nil
+		},
+		deleteIdentity: func(_ db.DBTX, arg db.DeleteIdentityQueryParams) (int64, error) {
+			if _, ok := tbl.linked[arg.Ghusername][arg.Provider]; !ok || arg.Provider == providerGithub {
+				return 0, nil
+			}
+			delete(tbl.linked[arg.Ghusername], arg.Provider)
+			return 1, nil
+		},
+		addAuditLog: func(_ db.DBTX, arg db.AddAuditLogQueryParams) error {
+			tbl.audits = append(tbl.audits, arg)
+			return nil
+		},
+	}
+}
+
+func newProviderTable(providers ...string) *providerTable {
+	linked := map[string]time.Time{}
+	start := time.Date(2026, time.October, 1, 9, 0, 0, 0, time.UTC)
+	for i, provider := range providers {
+		linked[provider] = start.Add(time.Duration(i) * time.Hour)
+	}
+	return &providerTable{
+		accounts: map[string]bool{"octocat": true},
+		linked:   map[string]map[string]time.Time{"octocat": linked},
+		passkeys: map[string]int64{},
+	}
+}
+
+func providerRouter(a *App) *gin.Engine {
+	router := gin.New()
+	me := router.Group("/api/v1/me", func(c *gin.Context) {
+		c.Set("username", "octocat")
+	})
+	me.GET("/providers", a.FetchProviders)
+	me.DELETE("/providers/:provider", a.UnlinkProvider)
+	return router
+}
+
+func TestFetchProviders(t *testing.T) {
+	tests := []struct {
+		name      string
+		providers []string
+		want      []string
+	}{
+		{name: "github and google", providers: []string{providerGithub, providerGoogle},
+			want: []string{providerGithub, providerGoogle}},
+		{name: "github only", providers: []string{providerGithub}, want: []string{providerGithub}},
+		{name: "none", want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tbl := newProviderTable(tt.providers...)
+			a := newTestApp(tbl.querier())
+			a.Env = testEnv()
+			w := httptest.NewRecorder()
+			providerRouter(a).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/me/providers", nil))
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+			}
+			var body struct {
+				Providers []struct {
+					Provider string `json:"provider"`
+					LinkedAt string `json:"linked_at"`
+				} `json:"providers"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Providers == nil {
+				t.Fatalf("providers = null, want a list: %s", w.Body.String())
+			}
+			got := []string{}
+			for _, p := range body.Providers {
+				got = append(got, p.Provider)
+				if want := pkg.FormatTime(tbl.linked["octocat"][p.Provider]); p.LinkedAt != want {
+					t.Errorf("linked_at of %s = %q, want %q", p.Provider, p.LinkedAt, want)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("providers = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnlinkProvider(t *testing.T) {
+	tests := []struct {
+		name       string
+		providers  []string
+		passkeys   int64
+		unlink     string
+		wantStatus int
+		wantLeft   []string
+	}{
+		{name: "google of two", providers: []string{providerGithub, providerGoogle}, unlink: providerGoogle,
+			wantStatus: http.StatusOK, wantLeft: []string{providerGithub}},
+		{name: "github with a passkey left", providers: []string{providerGithub, providerGoogle}, passkeys: 1,
+			unlink: providerGithub, wantStatus: http.StatusOK, wantLeft: []string{providerGoogle}},
+		// Google is never signed in with, so it does not count as a way left
+		{name: "github beside google only", providers: []string{providerGithub, providerGoogle}, unlink: providerGithub,
+			wantStatus: http.StatusConflict, wantLeft: []string{providerGithub, providerGoogle}},
+		{name: "last provider", providers: []string{providerGithub}, unlink: providerGithub,
+			wantStatus: http.StatusConflict, wantLeft: []string{providerGithub}},
+		{name: "not linked", providers: []string{providerGithub}, unlink: providerGoogle,
+			wantStatus: http.StatusNotFound, wantLeft: []string{providerGithub}},
+		{name: "unknown provider", providers: []string{providerGithub}, unlink: "gitlab",
+			wantStatus: http.StatusBadRequest, wantLeft: []string{providerGithub}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tbl := newProviderTable(tt.providers...)
+			tbl.passkeys["octocat"] = tt.passkeys
+			a := newTestApp(tbl.querier())
+			a.Env = testEnv()
+			w := httptest.NewRecorder()
+			providerRouter(a).ServeHTTP(w, httptest.NewRequest(http.MethodDelete,
+				"/api/v1/me/providers/"+tt.unlink, nil))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			var left []string
+			for provider := range tbl.linked["octocat"] {
+				left = append(left, provider)
+			}
+			slices.Sort(left)
+			if !slices.Equal(left, tt.wantLeft) {
+				t.Errorf("linked = %v, want %v", left, tt.wantLeft)
+			}
+			wantAudits := 0
+			if tt.wantStatus == http.StatusOK {
+				wantAudits = 1
+			}
+			if len(tbl.audits) != wantAudits {
+				t.Fatalf("audited %+v", tbl.audits)
+			}
+			if wantAudits == 1 && (tbl.audits[0].Action != "account.unlink" ||
+				tbl.audits[0].Details != "provider="+tt.unlink) {
+				t.Errorf("audit = %+v", tbl.audits[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	mw "github.com/IAmRiteshKoushik/pulse/middleware"
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	"github.com/gin-gonic/gin"
+)
+
+// Routes which keep working in maintenance mode, as they never write. Routes
+// starting a flow which ends in a write, e.g. account linking, are left out.
+var maintenanceReadOnly = []string{
+	"POST /api/v1/auth/github",
+	"GET /api/v1/auth/github/url",
+	"POST /api/v1/auth/register/token",
+	"GET /api/v1/auth/token/info",
+	"POST /api/v1/auth/passkey/options",
+	"GET /api/v1/auth/available",
+	"GET /api/v1/profile",
+	"GET /api/v1/users/:ghusername",
+	"GET /api/v1/leaderboard",
+	"GET /api/v1/leaderboard/snapshots/:date",
+	"GET /api/v1/projects",
+	"GET /api/v1/issues/:projectId",
+	"GET /api/v1/updates/live",
+	"GET /api/v1/me/sessions",
+	"GET /api/v1/me/token-status",
+	"GET /api/v1/me/export",
+	"GET /api/v1/me/providers",
+	"GET /api/v1/me/notifications",
+	"GET /api/v1/admin/audit",
+	"GET /api/v1/admin/users/export",
+	"GET /api/v1/admin/mail/preview",
+}
+
+// Admin routes are registered on adminRouter, which is router itself unless
+// they are served on a separate port
+func (a *App) RegisterRoutes(router *gin.Engine, adminRouter *gin.Engine) {
+	auth := mw.Auth(a.Env)
+	onboarded := mw.RequireOnboarded(a.Env, a.DB, a.Queries)
+	requireAdmin := mw.Admin(a.Env, a.DB, a.Queries)
+
+	// Writes are frozen in maintenance mode, reads keep working
+	maintenance := mw.Maintenance(maintenanceReadOnly...)
+	v1 := router.Group("/api/v1", maintenance)
+
+	v1.POST("/auth/github", a.InitiateGitHubOAuth)
+	v1.GET("/auth/github/url", a.FetchGitHubOAuthUrl)
+	v1.POST("/auth/github/callback", a.rateLimit(20, time.Minute), a.CompleteGitHubOAuth)
+	v1.GET("/auth/github/callback", a.rateLimit(20, time.Minute), a.CompleteGitHubOAuth)
+	v1.GET("/auth/google/callback", a.rateLimit(20, time.Minute), a.CompleteGoogleLink)
+	v1.POST("/auth/register", a.rateLimit(5, time.Minute), mw.RequireJSON,
+		a.rateLimitBy(3, time.Hour, registrationPhone), a.rateLimitBy(20, time.Hour, registrationPhonePrefix),
+		a.RegisterUserAccount)
+	v1.POST("/auth/register/token", a.rateLimit(5, time.Minute), mw.RequireJSON, a.ReissueTempToken)
+	v1.POST("/auth/register/otp/verify", a.rateLimit(10, time.Minute), auth, mw.RequireJSON, a.RegisterUserOtpVerify)
+	v1.GET("/auth/register/otp/resend", a.rateLimit(5, time.Minute), auth, a.RegisterUserOtpResend)
+	v1.GET("/auth/refresh", a.RegenerateToken)
+	v1.POST("/auth/refresh", a.RegenerateToken)
+	v1.GET("/auth/token/info", a.FetchTokenInfo)
+	v1.POST("/auth/passkey/options", a.rateLimit(10, time.Minute), mw.RequireJSON, a.StartPasskeyLogin)
+	v1.POST("/auth/passkey/verify", a.rateLimit(10, time.Minute), mw.RequireJSON, a.CompletePasskeyLogin)
+	v1.GET("/auth/available", a.rateLimit(10, time.Minute), a.CheckUsernameAvailability)
+
+	v1.GET("/profile", auth, onboarded, mw.RequireScope(pkg.ScopeProfileRead), a.FetchUserAccount)
+	v1.GET("/users/:ghusername", mw.Feature(pkg.FeaturePublicProfile), a.rateLimit(60, time.Minute), mw.Cache(a.Env.CacheMaxAge, true), a.FetchPublicProfile)
+	v1.GET("/leaderboard", auth, mw.RequireScope(pkg.ScopeLeaderboardRead), mw.Cache(a.Env.CacheMaxAge, false), a.FetchLeaderboard)
+	v1.GET("/leaderboard/snapshots/:date", auth, mw.RequireScope(pkg.ScopeLeaderboardRead), mw.Cache(a.Env.CacheMaxAge, false), a.FetchLeaderboardSnapshot)
+	v1.GET("/projects", auth, mw.RequireScope(pkg.ScopeProjectsRead), a.FetchProjects)
+	v1.GET("/issues/:projectId", auth, mw.RequireScope(pkg.ScopeProjectsRead), a.FetchIssues)
+	v1.GET("/updates/live", auth, mw.RequireScope(pkg.ScopeLeaderboardRead), a.FetchLiveUpdates)
+
+	// Feature flags are checked before authentication, so that a disabled
+	// feature is a 404 for everyone rather than a 401 for anonymous callers
+	v1.GET("/me/export", mw.Feature(pkg.FeatureExport), auth, onboarded, mw.RequireScope(pkg.ScopeProfileRead), a.ExportUserData)
+	v1.POST("/me/sync-github", mw.Feature(pkg.FeatureGithubSync), auth, onboarded, mw.RequireScope(pkg.ScopeProfileWrite), a.SyncGithubProfile)
+
+	// Only reachable by fully onboarded users
+	me := v1.Group("/me", auth, onboarded)
+	me.GET("/sessions", mw.RequireScope(pkg.ScopeProfileRead), a.FetchSessions)
+	me.DELETE("/sessions/:jti", mw.RequireScope(pkg.ScopeProfileWrite), a.RevokeSession)
+	me.GET("/token-status", mw.RequireScope(pkg.ScopeProfileRead), a.FetchTokenStatus)
+	me.POST("/link/github", mw.RequireScope(pkg.ScopeProfileWrite), a.StartGithubLink)
+	me.POST("/link/google", mw.RequireScope(pkg.ScopeProfileWrite), a.StartGoogleLink)
+	me.GET("/providers", mw.RequireScope(pkg.ScopeProfileRead), a.FetchProviders)
+	me.DELETE("/providers/:provider", mw.RequireScope(pkg.ScopeProfileWrite), a.UnlinkProvider)
+	me.GET("/notifications", mw.RequireScope(pkg.ScopeProfileRead), a.FetchNotifications)
+	me.PATCH("/notifications", mw.RequireScope(pkg.ScopeProfileWrite), mw.RequireJSON, a.UpdateNotifications)
+	me.POST("/passkeys/options", mw.RequireScope(pkg.ScopeProfileWrite), a.StartPasskeyRegistration)
+	me.POST("/passkeys", mw.RequireScope(pkg.ScopeProfileWrite), mw.RequireJSON, a.RegisterPasskey)
+	me.POST("/bounty/transfer", mw.RequireScope(pkg.ScopeBountyTransfer), mw.RequireJSON, a.TransferBounty)
+
+	router.GET("/readyz", a.Readyz)
+	router.POST("/webhooks/mail", mw.Feature(pkg.FeatureMailWebhook), maintenance, mw.RequireJSON, a.HandleMailWebhook)
+
+	admin := adminRouter.Group("/api/v1/admin", maintenance, auth, requireAdmin, mw.RequireClient(a.Env.AdminClientIds...))
+	admin.GET("/audit", a.FetchAuditLogs)
+	admin.GET("/users/export", a.ExportUsers)
+	admin.POST("/users/:email/force-onboard", a.ForceOnboardUser)
+	admin.POST("/users/:email/resend-otp", a.rateLimit(5, time.Minute), a.ResendUserOtp)
+	admin.POST("/bounty/award", mw.RequireScope(pkg.ScopeBountyWrite), mw.RequireJSON, a.AwardBounty)
+	if a.Env.MailPreview {
+		admin.GET("/mail/preview", a.PreviewMail)
+	}
+
+	adminClient := mw.RequireClient(a.Env.AdminClientIds...)
+	adminRouter.POST("/api/v1/admin/bounty/batch", mw.Feature(pkg.FeatureBountyBatch), maintenance, auth, requireAdmin, adminClient, mw.RequireScope(pkg.ScopeBountyWrite), a.AwardBountyBatch)
+
+	// Registered outside the admin group so that maintenance mode can be lifted
+	adminRouter.GET("/api/v1/admin/maintenance", auth, requireAdmin, adminClient, a.FetchMaintenanceMode)
+	adminRouter.PUT("/api/v1/admin/maintenance", auth, requireAdmin, adminClient, mw.RequireJSON, a.UpdateMaintenanceMode)
+
+	// Unknown routes and methods share the JSON shape of every other response
+	for _, r := range []*gin.Engine{router, adminRouter} {
+		r.HandleMethodNotAllowed = true
+		r.NoRoute(a.RouteNotFound)
+		r.NoMethod(a.MethodNotAllowed)
+	}
+
+	// Generated last so that every route above is included, from both
+	// engines when admin routes are served on their own port
+	routes := router.Routes()
+	if adminRouter != router {
+		routes = append(routes, adminRouter.Routes()...)
+	}
+	spec := pkg.BuildOpenAPISpec("Pulse API", "1.0.0", routes, a.openAPIDocs())
+	router.GET("/openapi.json", func(c *gin.Context) {
+		c.JSON(http.StatusOK, spec)
+	})
+}
+
+// Rate limits backed by Postgres count hits in the database of the app
+func (a *App) rateLimit(limit int, window time.Duration) gin.HandlerFunc {
+	return mw.RateLimit(a.Env, a.DB, a.Queries, limit, window)
+}
+
+func (a *App) rateLimitBy(limit int, window time.Duration, key func(c *gin.Context) string) gin.HandlerFunc {
+	return mw.RateLimitBy(a.Env, a.DB, a.Queries, limit, window, key)
+}
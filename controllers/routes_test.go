@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	db "github.com/IAmRiteshKoushik/pulse/db/gen"
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+)
+
+func TestAdminRequiresAccessToken(t *testing.T) {
+	tests := []struct {
+		name         string
+		username     string
+		tokenType    string
+		wantStatus   int
+		wantConsults bool // whether the maintainer list is looked up
+	}{
+		{name: "no token", wantStatus: http.StatusUnauthorized},
+		// Registering under a maintainer's GitHub name yields a temp token for it
+		{name: "temp token of a maintainer", username: "maintainer", tokenType: "temp_token",
+			wantStatus: http.StatusForbidden},
+		{name: "access token of a contributor", username: "octocat", tokenType: "access_token",
+			wantStatus: http.StatusForbidden, wantConsults: true},
+		{name: "access token of a maintainer", username: "maintainer", tokenType: "access_token",
+			wantStatus: http.StatusOK, wantConsults: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			consulted := false
+			q := &fakeQuerier{
+				checkIsMaintainer: func(_ db.DBTX, username string) (bool, error) {
+					consulted = true
+					return username == "maintainer", nil
+				},
+				exportUsersPage: func(db.DBTX, db.ExportUsersPageQueryParams) ([]db.ExportUsersPageQueryRow, error) {
+					return []db.ExportUsersPageQueryRow{{ID: 1, Ghusername: "octocat", Email: "octo@example.com"}}, nil
+				},
+			}
+			a := newTestApp(q)
+			a.Env = testEnv()
+			a.Env.CompressionMinSize = -1
+			router, adminRouter, err := NewRouters(a.Env)
+			if err != nil {
+				t.Fatal(err)
+			}
+			a.RegisterRoutes(router, adminRouter)
+
+			// Served for real, as the export streams its response
+			server := httptest.NewServer(adminRouter)
+			defer server.Close()
+			req, err := http.NewRequest(http.MethodGet, server.URL+"/api/v1/admin/users/export", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.tokenType != "" {
+				token, err := pkg.CreateToken(a.Env, tt.username, tt.username+"@example.com", tt.tokenType)
+				if err != nil {
+					t.Fatal(err)
+				}
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			resp, err := server.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", resp.StatusCode, tt.wantStatus, body)
+			}
+			if consulted != tt.wantConsults {
+				t.Errorf("maintainer looked up = %v, want %v", consulted, tt.wantConsults)
+			}
+			if exported := strings.Contains(string(body), "octo@example.com"); exported != (tt.wantStatus == http.StatusOK) {
+				t.Errorf("export body = %q", body)
+			}
+		})
+	}
+}
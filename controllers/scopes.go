@@ -0,0 +1,20 @@
+package controllers
+
+import (
+	"context"
+
+	db "github.com/IAmRiteshKoushik/pulse/db/gen"
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+)
+
+// Resolves the scopes to embed in a user's access token
+func userScopes(ctx context.Context, q db.Querier, conn db.DBTX, username string) ([]string, error) {
+	isMaintainer, err := q.CheckIsMaintainerQuery(ctx, conn, username)
+	if err != nil {
+		return nil, err
+	}
+	if isMaintainer {
+		return pkg.MaintainerScopes, nil
+	}
+	return pkg.DefaultUserScopes, nil
+}
@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"crypto/tls"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/IAmRiteshKoushik/pulse/cmd"
+	mw "github.com/IAmRiteshKoushik/pulse/middleware"
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+// NewRouters builds the engine for the public port and the one admin routes
+// and metrics are served on. Both are the same engine unless AdminPort is
+// set, so that the admin port can be firewalled off.
+func NewRouters(env *cmd.EnvConfig) (router *gin.Engine, adminRouter *gin.Engine, err error) {
+	router, err = NewRouter(env)
+	if err != nil {
+		return nil, nil, err
+	}
+	if env.AdminPort == 0 {
+		return router, router, nil
+	}
+	adminRouter, err = NewRouter(env)
+	if err != nil {
+		return nil, nil, err
+	}
+	return router, adminRouter, nil
+}
+
+// NewRouter builds an engine with the middleware shared by every listener
+func NewRouter(env *cmd.EnvConfig) (*gin.Engine, error) {
+	router := gin.New()
+	// X-Forwarded-For is only honoured when sent by a configured proxy
+	if err := router.SetTrustedProxies(env.TrustedProxies); err != nil {
+		return nil, err
+	}
+	router.Use(mw.RequestId)
+	if env.TLSEnabled() && env.HSTSMaxAge > 0 {
+		router.Use(mw.HSTS(env.HSTSMaxAge, env.HSTSIncludeSubdomains))
+	}
+	router.Use(mw.Timing)
+	router.Use(mw.RecoveryMiddleware)
+	router.Use(gin.Logger())
+	if env.RequestLogging {
+		router.Use(mw.RequestLogger)
+	}
+	if env.CompressionMinSize >= 0 {
+		router.Use(mw.Compress(env.CompressionMinSize))
+	}
+	if env.ErrorFormat == "problem" {
+		router.Use(mw.ProblemJSON)
+	}
+	router.Use(cors.New(cors.Config{
+		AllowOrigins:     []string{"*"},
+		AllowMethods:     []string{"*"},
+		AllowHeaders:     []string{"*"},
+		ExposeHeaders:    []string{mw.RequestIdHeader, mw.ResponseTimeHeader, "Server-Timing"},
+		AllowCredentials: true,
+		MaxAge:           12 * time.Hour,
+	}))
+	return router, nil
+}
+
+// Serve serves over HTTPS when tlsConfig is set. Certificates come from
+// tlsConfig, so that SIGHUP can swap them.
+func Serve(handler http.Handler, port int, tlsConfig *tls.Config) error {
+	server := &http.Server{
+		Addr:      ":" + strconv.Itoa(port),
+		Handler:   handler,
+		TLSConfig: tlsConfig,
+	}
+	if tlsConfig != nil {
+		return server.ListenAndServeTLS("", "")
+	}
+	return server.ListenAndServe()
+}
@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAdminPortRoutes(t *testing.T) {
+	a := newTestApp(&fakeQuerier{})
+	a.Env = testEnv()
+	a.Env.AdminPort = 9001
+	a.Env.CompressionMinSize = -1
+	router, adminRouter, err := NewRouters(a.Env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if router == adminRouter {
+		t.Fatal("admin routes share the public engine although ADMIN_PORT is set")
+	}
+	a.RegisterRoutes(router, adminRouter)
+
+	tests := []struct {
+		name     string
+		admin    bool // sent to the admin listener
+		method   string
+		path     string
+		notFound bool
+	}{
+		{name: "public route on the public port", method: http.MethodGet, path: "/api/v1/leaderboard"},
+		{name: "public route on the admin port", admin: true, method: http.MethodGet, path: "/api/v1/leaderboard", notFound: true},
+		{name: "auth route on the admin port", admin: true, method: http.MethodPost, path: "/api/v1/auth/github", notFound: true},
+		{name: "admin route on the admin port", admin: true, method: http.MethodGet, path: "/api/v1/admin/audit"},
+		{name: "admin route on the public port", method: http.MethodGet, path: "/api/v1/admin/audit", notFound: true},
+		{name: "maintenance on the public port", method: http.MethodGet, path: "/api/v1/admin/maintenance", notFound: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := router
+			if tt.admin {
+				engine = adminRouter
+			}
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+			if got := w.Code == http.StatusNotFound; got != tt.notFound {
+				t.Errorf("%s %s status = %d, want not found = %v", tt.method, tt.path, w.Code, tt.notFound)
+			}
+		})
+	}
+
+	paths := openAPIPaths(t, router)
+	for _, path := range []string{"/api/v1/leaderboard", "/api/v1/admin/audit", "/api/v1/admin/maintenance"} {
+		if _, ok := paths[path]; !ok {
+			t.Errorf("OpenAPI document is missing %s", path)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	db "github.com/IAmRiteshKoushik/pulse/db/gen"
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Lists the active sessions of the user, one per signed in device. The
+// session of the refresh token sent in the cookie is marked as current.
+func (a *App) FetchSessions(c *gin.Context) {
+	username, ok := pkg.GrabUsername(c)
+	if !ok {
+		a.Log.Warn(
+			fmt.Sprintf("Failed to extract username from token at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Oops! Something happened. Please try again later.",
+		})
+		return
+	}
+
+	current := ""
+	if cookie, err := c.Cookie(pkg.RefreshTokenCookie); err == nil && cookie != "" {
+		if claims, err := pkg.VerifyToken(a.Env, cookie); err == nil {
+			current = claims.ID
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), a.Env.TimeoutFor("session"))
+	defer cancel()
+
+	conn, err := a.DB.Acquire(ctx)
+	if err != nil {
+		pkg.DbAcquireError(c, err)
+		return
+	}
+	defer conn.Release()
+
+	q := a.Queries
+	rows, err := q.FetchSessionsQuery(ctx, conn, username)
+	if err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+
+	sessions := make([]gin.H, 0, len(rows))
+	for _, session := range rows {
+		sessions = append(sessions, gin.H{
+			"jti":          session.Jti,
+			"device_label": session.DeviceLabel,
+			"issued_at":    pkg.FormatTime(session.CreatedAt.Time),
+			"expires_at":   pkg.FormatTime(session.ExpiresAt.Time),
+			"current":      session.Jti.String() == current,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Sessions retrived successfully",
+		"sessions": sessions,
+	})
+	a.Log.Info(fmt.Sprintf(
+		"[SUCCESS]: Processed request at %s %s",
+		c.Request.Method, c.FullPath(),
+	))
+	return
+}
+
+// Signs out a single device by revoking the refresh token with the given jti
+func (a *App) RevokeSession(c *gin.Context) {
+	username, ok := pkg.GrabUsername(c)
+	if !ok {
+		a.Log.Warn(
+			fmt.Sprintf("Failed to extract username from token at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Oops! Something happened. Please try again later.",
+		})
+		return
+	}
+
+	jti, err := uuid.Parse(c.Param("jti"))
+	if err != nil {
+		a.Log.Error(
+			fmt.Sprintf("[INVALID-ID]: Given jti is invalid UUID at %s %s",
+				c.Request.Method, c.FullPath()), err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Invalid session id.",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), a.Env.TimeoutFor("session"))
+	defer cancel()
+
+	conn, err := a.DB.Acquire(ctx)
+	if err != nil {
+		pkg.DbAcquireError(c, err)
+		return
+	}
+	defer conn.Release()
+
+	q := a.Queries
+	revoked, err := q.RevokeTokenByJtiQuery(ctx, conn, db.RevokeTokenByJtiQueryParams{
+		Jti:        jti,
+		Ghusername: username,
+	})
+	if err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+	// Sessions of other users are indistinguishable from missing ones
+	if revoked == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "Session not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Session revoked successfully",
+	})
+	a.Log.Info(fmt.Sprintf(
+		"[SUCCESS]: Processed request at %s %s",
+		c.Request.Method, c.FullPath(),
+	))
+	return
+}
+
+// Reports the lifecycle of the refresh token sent in the cookie, so clients
+// can rotate proactively. Without the cookie there is no telling which of the
+// user's sessions is the caller's.
+func (a *App) FetchTokenStatus(c *gin.Context) {
+	username, ok := pkg.GrabUsername(c)
+	if !ok {
+		a.Log.Warn(
+			fmt.Sprintf("Failed to extract username from token at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Oops! Something happened. Please try again later.",
+		})
+		return
+	}
+
+	cookie, err := c.Cookie(pkg.RefreshTokenCookie)
+	if err != nil || cookie == "" {
+		a.Log.Warn(
+			fmt.Sprintf("Token status requested without refresh cookie at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Refresh token cookie is missing in request",
+		})
+		return
+	}
+	claims, err := pkg.VerifyToken(a.Env, cookie)
+	if err != nil {
+		pkg.TokenError(c, err)
+		return
+	}
+	jti, err := uuid.Parse(claims.ID)
+	if err != nil || claims.Subject != "refresh_token" {
+		a.Log.Warn(
+			fmt.Sprintf("Token status requested with an invalid refresh cookie at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Refresh token cookie is invalid",
+		})
+		return
+	}
+	params := db.FetchSessionStatusQueryParams{Ghusername: username, Jti: jti}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), a.Env.TimeoutFor("session"))
+	defer cancel()
+
+	conn, err := a.DB.Acquire(ctx)
+	if err != nil {
+		pkg.DbAcquireError(c, err)
+		return
+	}
+	defer conn.Release()
+
+	q := a.Queries
+	session, err := q.FetchSessionStatusQuery(ctx, conn, params)
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"message": "No active session found. Please login again.",
+		})
+		return
+	}
+	if err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+
+	remaining := max(time.Until(session.ExpiresAt.Time), 0)
+	c.JSON(http.StatusOK, gin.H{
+		"message":           "Token status retrived successfully",
+		"jti":               session.Jti,
+		"issued_at":         pkg.FormatTime(session.CreatedAt.Time),
+		"expires_at":        pkg.FormatTime(session.ExpiresAt.Time),
+		"remaining_seconds": int64(remaining.Seconds()),
+		"rotation_due":      remaining <= pkg.RefreshRotationWindow,
+	})
+	a.Log.Info(fmt.Sprintf(
+		"[SUCCESS]: Processed request at %s %s",
+		c.Request.Method, c.FullPath(),
+	))
+	return
+}
@@ -0,0 +1,204 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	db "github.com/IAmRiteshKoushik/pulse/db/gen"
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Live sessions of user_session, keyed by the jti of their refresh token
+type sessionStore struct {
+	mu       sync.Mutex
+	sessions map[uuid.UUID]string
+}
+
+func (s *sessionStore) querier() *fakeQuerier {
+	return &fakeQuerier{
+		checkSession: func(_ db.DBTX, jti uuid.UUID) (db.CheckSessionQueryRow, error) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			username, ok := s.sessions[jti]
+			if !ok {
+				return db.CheckSessionQueryRow{}, pgx.ErrNoRows
+			}
+			return db.CheckSessionQueryRow{Ghusername: username, Email: username + "@example.com"}, nil
+		},
+		revokeTokenByJti: func(_ db.DBTX, arg db.RevokeTokenByJtiQueryParams) (int64, error) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if s.sessions[arg.Jti] != arg.Ghusername {
+				return 0, nil
+			}
+			delete(s.sessions, arg.Jti)
+			return 1, nil
+		},
+		checkIsMaintainer: func(db.DBTX, string) (bool, error) { return false, nil },
+	}
+}
+
+func TestRevokeSession(t *testing.T) {
+	laptop, phone, other := uuid.New(), uuid.New(), uuid.New()
+	store := &sessionStore{sessions: map[uuid.UUID]string{
+		laptop: "octocat",
+		phone:  "octocat",
+		other:  "hubot",
+	}}
+	a := newTestApp(store.querier())
+	router := gin.New()
+	router.DELETE("/api/v1/me/sessions/:jti", func(c *gin.Context) {
+		c.Set("username", "octocat")
+	}, a.RevokeSession)
+	router.GET("/api/v1/auth/refresh", a.RegenerateToken)
+
+	refresh := func(jti uuid.UUID, username string) int {
+		t.Helper()
+		token, err := pkg.CreateToken(a.Env, username, username+"@example.com", "refresh_token",
+			pkg.WithTokenId(jti.String()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/refresh", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	revoke := func(jti string) int {
+		t.Helper()
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/v1/me/sessions/"+jti, nil))
+		return w.Code
+	}
+
+	if code := revoke(laptop.String()); code != http.StatusOK {
+		t.Fatalf("revoking own session: status = %d", code)
+	}
+	if code := refresh(laptop, "octocat"); code != http.StatusUnauthorized {
+		t.Errorf("refresh with the revoked jti: status = %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code := refresh(phone, "octocat"); code != http.StatusOK {
+		t.Errorf("refresh with another session of the user: status = %d, want %d", code, http.StatusOK)
+	}
+
+	// Sessions of other users cannot be revoked, nor told apart from missing ones
+	if code := revoke(other.String()); code != http.StatusNotFound {
+		t.Errorf("revoking another user's session: status = %d, want %d", code, http.StatusNotFound)
+	}
+	if code := refresh(other, "hubot"); code != http.StatusOK {
+		t.Errorf("refresh by the other user: status = %d, want %d", code, http.StatusOK)
+	}
+	if code := revoke(laptop.String()); code != http.StatusNotFound {
+		t.Errorf("revoking twice: status = %d, want %d", code, http.StatusNotFound)
+	}
+	if code := revoke("not-a-uuid"); code != http.StatusBadRequest {
+		t.Errorf("revoking a malformed jti: status = %d, want %d", code, http.StatusBadRequest)
+	}
+}
+
+func TestFetchTokenStatus(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	type session struct {
+		username  string
+		createdAt time.Time
+		expiresAt time.Time
+	}
+	fresh, nearExpiry, expired, other := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	sessions := map[uuid.UUID]session{
+		fresh:      {username: "octocat", createdAt: now, expiresAt: now.Add(30 * 24 * time.Hour)},
+		nearExpiry: {username: "octocat", createdAt: now.Add(-88 * 24 * time.Hour), expiresAt: now.Add(48 * time.Hour)},
+		expired:    {username: "octocat", createdAt: now.Add(-91 * 24 * time.Hour), expiresAt: now.Add(-time.Hour)},
+		other:      {username: "hubot", createdAt: now, expiresAt: now.Add(30 * 24 * time.Hour)},
+	}
+
+	tests := []struct {
+		name            string
+		jti             uuid.UUID
+		subject         string
+		noCookie        bool
+		wantStatus      int
+		wantRotationDue bool
+	}{
+		{name: "fresh token", jti: fresh, subject: "refresh_token", wantStatus: http.StatusOK},
+		{name: "token near expiry", jti: nearExpiry, subject: "refresh_token", wantStatus: http.StatusOK,
+			wantRotationDue: true},
+		{name: "expired session", jti: expired, subject: "refresh_token", wantStatus: http.StatusNotFound},
+		{name: "session of another user", jti: other, subject: "refresh_token", wantStatus: http.StatusNotFound},
+		{name: "access token in the cookie", jti: fresh, subject: "access_token", wantStatus: http.StatusBadRequest},
+		{name: "no cookie", noCookie: true, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &fakeQuerier{
+				// Mirrors the query, which only finds live sessions of the user
+				fetchSessionStatus: func(_ db.DBTX, arg db.FetchSessionStatusQueryParams) (db.FetchSessionStatusQueryRow, error) {
+					s, ok := sessions[arg.Jti]
+					if !ok || s.username != arg.Ghusername || !s.expiresAt.After(time.Now()) {
+						return db.FetchSessionStatusQueryRow{}, pgx.ErrNoRows
+					}
+					return db.FetchSessionStatusQueryRow{
+						Jti:       arg.Jti,
+						CreatedAt: pkg.NewTimestamp(s.createdAt),
+						ExpiresAt: pkg.NewTimestamp(s.expiresAt),
+					}, nil
+				},
+			}
+			a := newTestApp(q)
+			router := gin.New()
+			router.GET("/api/v1/me/token-status", func(c *gin.Context) {
+				c.Set("username", "octocat")
+			}, a.FetchTokenStatus)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/me/token-status", nil)
+			if !tt.noCookie {
+				token, err := pkg.CreateToken(a.Env, "octocat", "octo@example.com", tt.subject,
+					pkg.WithTokenId(tt.jti.String()))
+				if err != nil {
+					t.Fatal(err)
+				}
+				req.AddCookie(&http.Cookie{Name: pkg.RefreshTokenCookie, Value: token})
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var body struct {
+				Jti              uuid.UUID `json:"jti"`
+				IssuedAt         time.Time `json:"issued_at"`
+				ExpiresAt        time.Time `json:"expires_at"`
+				RemainingSeconds int64     `json:"remaining_seconds"`
+				RotationDue      bool      `json:"rotation_due"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			want := sessions[tt.jti]
+			if body.Jti != tt.jti || !body.IssuedAt.Equal(want.createdAt) || !body.ExpiresAt.Equal(want.expiresAt) {
+				t.Errorf("status of %s issued %s expiring %s, want %s issued %s expiring %s",
+					body.Jti, body.IssuedAt, body.ExpiresAt, tt.jti, want.createdAt, want.expiresAt)
+			}
+			wantRemaining := time.Until(want.expiresAt).Seconds()
+			if diff := float64(body.RemainingSeconds) - wantRemaining; diff > 1 || diff < -2 {
+				t.Errorf("remaining_seconds = %d, want about %.0f", body.RemainingSeconds, wantRemaining)
+			}
+			if body.RotationDue != tt.wantRotationDue {
+				t.Errorf("rotation_due = %v, want %v", body.RotationDue, tt.wantRotationDue)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	"github.com/gin-gonic/gin"
+)
+
+// Lets clients schedule a refresh ahead of expiry. Only timing information is
+// returned, never the identity claims.
+func (a *App) FetchTokenInfo(c *gin.Context) {
+	tokenString := ""
+	authHeader := c.GetHeader("Authorization")
+	if strings.HasPrefix(authHeader, "Bearer ") {
+		tokenString = authHeader[7:]
+	} else if cookie, err := c.Cookie(pkg.AccessTokenCookie); err == nil {
+		tokenString = cookie
+	}
+	if tokenString == "" {
+		a.Log.Warn(fmt.Sprintf("Authorization failed at %s %s", c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"message": "Authorization header required",
+		})
+		return
+	}
+
+	claims, err := pkg.VerifyToken(a.Env, tokenString)
+	if err != nil {
+		pkg.TokenError(c, err)
+		return
+	}
+	validToken := claims.Issuer == "api.season-of-code" &&
+		claims.Subject == "access_token" &&
+		claims.ExpiresAt != nil &&
+		claims.IssuedAt != nil
+	if !validToken {
+		a.Log.Warn(fmt.Sprintf("Invalid access token at %s %s", c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"message": "Invalid token",
+		})
+		return
+	}
+
+	remaining := max(time.Until(claims.ExpiresAt.Time), 0)
+	c.JSON(http.StatusOK, gin.H{
+		"message":           "Token info retrived successfully",
+		"issued_at":         pkg.FormatTime(claims.IssuedAt.Time),
+		"expires_at":        pkg.FormatTime(claims.ExpiresAt.Time),
+		"remaining_seconds": int64(remaining.Seconds()),
+	})
+	a.Log.Info(fmt.Sprintf(
+		"[SUCCESS]: Processed request at %s %s",
+		c.Request.Method, c.FullPath(),
+	))
+	return
+}
@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IAmRiteshKoushik/pulse/cmd"
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	"github.com/gin-gonic/gin"
+)
+
+func TestFetchTokenInfo(t *testing.T) {
+	env := testEnv()
+	otherEnv := testEnv()
+	otherEnv.TokenSecret = "other-secret"
+	token := func(env *cmd.EnvConfig, tokenType string, expiresIn time.Duration) string {
+		t.Helper()
+		token, err := pkg.CreateToken(env, "octocat", "octo@example.com", tokenType,
+			pkg.WithExpiry(time.Now().Add(expiresIn)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	tests := []struct {
+		name          string
+		header        string
+		cookie        string
+		wantStatus    int
+		wantRemaining time.Duration
+	}{
+		{
+			name:          "bearer token",
+			header:        "Bearer " + token(env, "access_token", 10*time.Minute),
+			wantStatus:    http.StatusOK,
+			wantRemaining: 10 * time.Minute,
+		},
+		{
+			name:          "access token cookie",
+			cookie:        token(env, "access_token", 45*time.Minute),
+			wantStatus:    http.StatusOK,
+			wantRemaining: 45 * time.Minute,
+		},
+		{name: "missing", wantStatus: http.StatusUnauthorized},
+		{
+			name:       "expired",
+			header:     "Bearer " + token(env, "access_token", -time.Minute),
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "refresh token",
+			header:     "Bearer " + token(env, "refresh_token", time.Hour),
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "signed with another secret",
+			header:     "Bearer " + token(otherEnv, "access_token", time.Hour),
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestApp(&fakeQuerier{})
+			a.Env = env
+			router := gin.New()
+			router.GET("/api/v1/auth/token/info", a.FetchTokenInfo)
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/auth/token/info", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: pkg.AccessTokenCookie, Value: tt.cookie})
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var body struct {
+				IssuedAt         time.Time `json:"issued_at"`
+				ExpiresAt        time.Time `json:"expires_at"`
+				RemainingSeconds int64     `json:"remaining_seconds"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			want := int64(tt.wantRemaining.Seconds())
+			if body.RemainingSeconds > want || body.RemainingSeconds < want-2 {
+				t.Errorf("remaining_seconds = %d, want %d", body.RemainingSeconds, want)
+			}
+			if got := body.ExpiresAt.Sub(body.IssuedAt); got < tt.wantRemaining-2*time.Second || got > tt.wantRemaining {
+				t.Errorf("expires %s after issue, want %s", got, tt.wantRemaining)
+			}
+			if strings.Contains(w.Body.String(), "octo") {
+				t.Errorf("identity claims returned: %s", w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	db "github.com/IAmRiteshKoushik/pulse/db/gen"
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	"github.com/IAmRiteshKoushik/pulse/types"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+const maxWebhookBody = 64 * 1024
+
+// Receives delivery events from the mail provider. Hard bounces and
+// complaints suppress the recipient from further mails.
+func (a *App) HandleMailWebhook(c *gin.Context) {
+	// Signature is computed over the raw body, so it is read before parsing
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBody))
+	if err != nil {
+		pkg.JSONUnmarshallError(c, err)
+		return
+	}
+	err = pkg.VerifyWebhookSignature(body, c.GetHeader("X-Mail-Signature"), a.Env.MailWebhookSecret)
+	if err != nil {
+		a.Log.Error(
+			fmt.Sprintf("Invalid mail webhook signature at %s %s",
+				c.Request.Method, c.FullPath()), err)
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"message": "Invalid signature",
+		})
+		return
+	}
+
+	var event types.MailEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		pkg.JSONUnmarshallError(c, err)
+		return
+	}
+	if err := event.Validate(); err != nil {
+		pkg.RequestValidatorError(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), a.Env.TimeoutFor("webhook"))
+	defer cancel()
+
+	tx, err := a.DB.Begin(ctx)
+	if err != nil {
+		pkg.DbAcquireError(c, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	status := event.Event
+	if event.Event == "bounced" && event.BounceType == "soft" {
+		status = "soft_bounced"
+	}
+
+	q := a.Queries
+	recipient, err := q.UpdateMailStatusQuery(ctx, tx, db.UpdateMailStatusQueryParams{
+		Status:    status,
+		MessageID: event.MessageId,
+	})
+	// Acknowledged regardless so that the provider stops retrying
+	if errors.Is(err, pgx.ErrNoRows) {
+		a.Log.Warn(
+			fmt.Sprintf("Mail event for unknown message at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Event ignored",
+		})
+		return
+	}
+	if err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+
+	if status == "bounced" || status == "complained" {
+		err = q.SuppressEmailQuery(ctx, tx, db.SuppressEmailQueryParams{
+			Email:  recipient,
+			Reason: status,
+		})
+		if err != nil {
+			pkg.DbError(c, err)
+			return
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		pkg.DbError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Event processed",
+	})
+	a.Log.Info(fmt.Sprintf(
+		"[SUCCESS]: Processed request at %s %s",
+		c.Request.Method, c.FullPath(),
+	))
+	return
+}
@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	db "github.com/IAmRiteshKoushik/pulse/db/gen"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+func signWebhook(secret string, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestHandleMailWebhook(t *testing.T) {
+	const secret = "webhook-secret"
+	tests := []struct {
+		name           string
+		body           string
+		signature      string // signed with secret when empty
+		wantStatus     int
+		wantMailStatus string // status written to mail_log, if any
+		wantSuppressed string // reason the recipient is suppressed for, if any
+	}{
+		{
+			name:           "delivered",
+			body:           `{"event": "delivered", "message_id": "<1.abc@example.com>", "recipient": "octo@example.com"}`,
+			wantStatus:     http.StatusOK,
+			wantMailStatus: "delivered",
+		},
+		{
+			name:           "hard bounce",
+			body:           `{"event": "bounced", "message_id": "1.abc@example.com", "bounce_type": "hard"}`,
+			wantStatus:     http.StatusOK,
+			wantMailStatus: "bounced",
+			wantSuppressed: "bounced",
+		},
+		{
+			name:           "soft bounce",
+			body:           `{"event": "Bounced", "message_id": "1.abc@example.com", "bounce_type": "soft"}`,
+			wantStatus:     http.StatusOK,
+			wantMailStatus: "soft_bounced",
+		},
+		{
+			name:           "complaint",
+			body:           `{"event": "complained", "message_id": "1.abc@example.com"}`,
+			wantStatus:     http.StatusOK,
+			wantMailStatus: "complained",
+			wantSuppressed: "complained",
+		},
+		{
+			name:       "unknown message",
+			body:       `{"event": "bounced", "message_id": "2.def@example.com", "bounce_type": "hard"}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "unknown event",
+			body:       `{"event": "opened", "message_id": "1.abc@example.com"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "wrong signature",
+			body:       `{"event": "bounced", "message_id": "1.abc@example.com", "bounce_type": "hard"}`,
+			signature:  signWebhook("other-secret", `{"event": "bounced", "message_id": "1.abc@example.com", "bounce_type": "hard"}`),
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "missing signature",
+			body:       `{"event": "delivered", "message_id": "1.abc@example.com"}`,
+			signature:  "-",
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mailStatus, suppressed string
+			q := &fakeQuerier{
+				updateMailStatus: func(_ db.DBTX, arg db.UpdateMailStatusQueryParams) (string, error) {
+					if arg.MessageID != "1.abc@example.com" {
+						return "", pgx.ErrNoRows
+					}
+					mailStatus = arg.Status
+					return "octo@example.com", nil
+				},
+				suppressEmail: func(_ db.DBTX, arg db.SuppressEmailQueryParams) error {
+					if arg.Email != "octo@example.com" {
+						t.Errorf("suppressed %s", arg.Email)
+					}
+					suppressed = arg.Reason
+					return nil
+				},
+			}
+			a := newTestApp(q)
+			a.Env = testEnv()
+			a.Env.MailWebhookSecret = secret
+
+			router := gin.New()
+			router.POST("/webhooks/mail", a.HandleMailWebhook)
+			req := httptest.NewRequest(http.MethodPost, "/webhooks/mail", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			switch tt.signature {
+			case "":
+				req.Header.Set("X-Mail-Signature", signWebhook(secret, tt.body))
+			case "-":
+			default:
+				req.Header.Set("X-Mail-Signature", tt.signature)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if mailStatus != tt.wantMailStatus {
+				t.Errorf("mail_log status = %q, want %q", mailStatus, tt.wantMailStatus)
+			}
+			if suppressed != tt.wantSuppressed {
+				t.Errorf("suppressed for %q, want %q", suppressed, tt.wantSuppressed)
+			}
+		})
+	}
+}
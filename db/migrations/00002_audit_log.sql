@@ -0,0 +1,30 @@
+-- +goose Up
+
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS audit_log(
+  id SERIAL NOT NULL,
+  actor TEXT NOT NULL,
+  action TEXT NOT NULL,
+  target TEXT NOT NULL,
+  details TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+  CONSTRAINT "audit_log_pkey" PRIMARY KEY (id)
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- Keyset pagination walks (created_at, id) in descending order, optionally
+-- narrowed down by actor or action
+CREATE INDEX IF NOT EXISTS audit_log_created_at_idx
+  ON audit_log (created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS audit_log_actor_idx
+  ON audit_log (actor, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS audit_log_action_idx
+  ON audit_log (action, created_at DESC, id DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS audit_log;
+-- +goose StatementEnd
@@ -0,0 +1,49 @@
+-- +goose Up
+
+-- +goose StatementBegin
+-- Every change to a user's bounty balance is recorded here. Debits carry a
+-- negative amount.
+CREATE TABLE IF NOT EXISTS bounty_ledger(
+  id SERIAL NOT NULL,
+  ghUsername TEXT NOT NULL,
+  amount INTEGER NOT NULL,
+  kind TEXT NOT NULL,
+  counterparty TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+  CONSTRAINT "bounty_ledger_pkey" PRIMARY KEY (id),
+  CONSTRAINT "bounty_ledger_ghUsername_fkey"
+    FOREIGN KEY (ghUsername)
+      REFERENCES user_account(ghUsername)
+        ON DELETE RESTRICT
+        ON UPDATE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS bounty_ledger_ghUsername_idx
+  ON bounty_ledger (ghUsername, created_at DESC);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- Balances earned before the ledger existed are carried over as an opening
+-- entry, so that every balance starts out matching its ledger total
+INSERT INTO
+  bounty_ledger
+  (
+    ghUsername,
+    amount,
+    kind
+  )
+SELECT
+  ghUsername,
+  bounty,
+  'opening_balance'
+FROM
+  user_account;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS bounty_ledger;
+-- +goose StatementEnd
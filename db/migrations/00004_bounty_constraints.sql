@@ -0,0 +1,12 @@
+-- +goose Up
+
+-- +goose StatementBegin
+ALTER TABLE user_account
+  ADD CONSTRAINT "user_account_bounty_nonnegative" CHECK (bounty >= 0);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE user_account
+  DROP CONSTRAINT IF EXISTS "user_account_bounty_nonnegative";
+-- +goose StatementEnd
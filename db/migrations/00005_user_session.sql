@@ -0,0 +1,31 @@
+-- +goose Up
+
+-- +goose StatementBegin
+-- One row per issued refresh token, identified by its jti claim, so that
+-- sessions can be revoked individually
+CREATE TABLE IF NOT EXISTS user_session(
+  id SERIAL NOT NULL,
+  jti UUID NOT NULL UNIQUE,
+  ghUsername TEXT NOT NULL,
+  revoked BOOLEAN NOT NULL DEFAULT false,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  expires_at TIMESTAMP NOT NULL,
+
+  CONSTRAINT "user_session_pkey" PRIMARY KEY (id),
+  CONSTRAINT "user_session_ghUsername_fkey"
+    FOREIGN KEY (ghUsername)
+      REFERENCES user_account(ghUsername)
+        ON DELETE CASCADE
+        ON UPDATE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS user_session_ghUsername_idx
+  ON user_session (ghUsername);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS user_session;
+-- +goose StatementEnd
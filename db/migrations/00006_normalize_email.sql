@@ -0,0 +1,13 @@
+-- +goose Up
+
+-- +goose StatementBegin
+-- Emails are normalized by the application from here on, bring existing rows
+-- in line so that lookups keep matching
+UPDATE user_account SET email = LOWER(TRIM(email));
+UPDATE user_onboarding SET email = LOWER(TRIM(email));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 1;
+-- +goose StatementEnd
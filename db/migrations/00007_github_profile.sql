@@ -0,0 +1,16 @@
+-- +goose Up
+
+-- +goose StatementBegin
+ALTER TABLE user_account
+  ADD COLUMN IF NOT EXISTS full_name TEXT NOT NULL DEFAULT '',
+  ADD COLUMN IF NOT EXISTS avatar_url TEXT NOT NULL DEFAULT '',
+  ADD COLUMN IF NOT EXISTS gh_token TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE user_account
+  DROP COLUMN IF EXISTS full_name,
+  DROP COLUMN IF EXISTS avatar_url,
+  DROP COLUMN IF EXISTS gh_token;
+-- +goose StatementEnd
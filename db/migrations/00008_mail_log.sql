@@ -0,0 +1,33 @@
+-- +goose Up
+
+-- +goose StatementBegin
+-- One row per sent mail, updated by delivery events from the mail provider
+CREATE TABLE IF NOT EXISTS mail_log(
+  id SERIAL NOT NULL,
+  message_id TEXT NOT NULL UNIQUE,
+  recipient TEXT NOT NULL,
+  kind TEXT NOT NULL,
+  status TEXT NOT NULL DEFAULT 'sent',
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+  CONSTRAINT "mail_log_pkey" PRIMARY KEY (id)
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- Addresses which hard bounced or complained are never mailed again
+CREATE TABLE IF NOT EXISTS mail_suppression(
+  email TEXT NOT NULL,
+  reason TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+  CONSTRAINT "mail_suppression_pkey" PRIMARY KEY (email)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS mail_suppression;
+DROP TABLE IF EXISTS mail_log;
+-- +goose StatementEnd
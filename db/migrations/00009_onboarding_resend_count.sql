@@ -0,0 +1,12 @@
+-- +goose Up
+
+-- +goose StatementBegin
+ALTER TABLE user_onboarding
+  ADD COLUMN IF NOT EXISTS resend_count INT NOT NULL DEFAULT 0;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE user_onboarding
+  DROP COLUMN IF EXISTS resend_count;
+-- +goose StatementEnd
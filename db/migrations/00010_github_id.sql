@@ -0,0 +1,13 @@
+-- +goose Up
+
+-- +goose StatementBegin
+-- GitHub IDs exceed the INT range, and unlike usernames never change
+ALTER TABLE user_account
+  ADD COLUMN IF NOT EXISTS gh_id BIGINT UNIQUE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE user_account
+  DROP COLUMN IF EXISTS gh_id;
+-- +goose StatementEnd
@@ -0,0 +1,28 @@
+-- +goose Up
+
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS login_history(
+  id SERIAL NOT NULL,
+  ghUsername TEXT NOT NULL,
+  ip TEXT NOT NULL,
+  user_agent TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+  CONSTRAINT "login_history_pkey" PRIMARY KEY (id),
+  CONSTRAINT "login_history_ghUsername_fkey"
+    FOREIGN KEY (ghUsername)
+      REFERENCES user_account(ghUsername)
+        ON DELETE CASCADE
+        ON UPDATE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS login_history_ghUsername_idx
+  ON login_history (ghUsername, created_at DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS login_history;
+-- +goose StatementEnd
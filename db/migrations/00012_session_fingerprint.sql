@@ -0,0 +1,15 @@
+-- +goose Up
+
+-- +goose StatementBegin
+-- Coarse client fingerprint captured when the refresh token was issued
+ALTER TABLE user_session
+  ADD COLUMN IF NOT EXISTS ua_hash TEXT NOT NULL DEFAULT '',
+  ADD COLUMN IF NOT EXISTS ip_class TEXT NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE user_session
+  DROP COLUMN IF EXISTS ua_hash,
+  DROP COLUMN IF EXISTS ip_class;
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS rate_limit(
+  key TEXT NOT NULL,
+  window_start TIMESTAMP NOT NULL,
+  count INT NOT NULL DEFAULT 0,
+
+  CONSTRAINT "rate_limit_pkey" PRIMARY KEY (key, window_start)
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS rate_limit_window_start_idx
+  ON rate_limit (window_start);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS rate_limit;
+-- +goose StatementEnd
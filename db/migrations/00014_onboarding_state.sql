@@ -0,0 +1,20 @@
+-- +goose Up
+
+-- +goose StatementBegin
+CREATE TYPE onboarding_state AS ENUM ('pending_otp', 'onboarded');
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE user_onboarding
+  ADD COLUMN IF NOT EXISTS state onboarding_state NOT NULL DEFAULT 'pending_otp';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE user_onboarding
+  DROP COLUMN IF EXISTS state;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DROP TYPE IF EXISTS onboarding_state;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS feature_flag(
+  name TEXT NOT NULL,
+  enabled BOOLEAN NOT NULL,
+  updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+  CONSTRAINT "feature_flag_pkey" PRIMARY KEY (name)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS feature_flag;
+-- +goose StatementEnd
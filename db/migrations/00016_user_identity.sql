@@ -0,0 +1,26 @@
+-- +goose Up
+
+-- +goose StatementBegin
+-- Identities from sign-in providers other than GitHub, whose ID is kept on
+-- user_account itself
+CREATE TABLE IF NOT EXISTS user_identity(
+  provider TEXT NOT NULL,
+  subject TEXT NOT NULL,
+  ghUsername TEXT NOT NULL,
+  email TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+  CONSTRAINT "user_identity_pkey" PRIMARY KEY (provider, subject),
+  CONSTRAINT "user_identity_ghUsername_provider_key" UNIQUE (ghUsername, provider),
+  CONSTRAINT "user_identity_ghUsername_fkey"
+    FOREIGN KEY (ghUsername)
+      REFERENCES user_account(ghUsername)
+        ON DELETE CASCADE
+        ON UPDATE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS user_identity;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+
+-- +goose StatementBegin
+-- Daily copies of the leaderboard. Position orders users with equal rank so
+-- that a snapshot can be paged through.
+CREATE TABLE IF NOT EXISTS leaderboard_snapshots(
+  snapshot_date DATE NOT NULL,
+  position INTEGER NOT NULL,
+  rank INTEGER NOT NULL,
+  ghUsername TEXT NOT NULL,
+  bounty INTEGER NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+  CONSTRAINT "leaderboard_snapshots_pkey" PRIMARY KEY (snapshot_date, position),
+  CONSTRAINT "leaderboard_snapshots_ghUsername_key" UNIQUE (snapshot_date, ghUsername)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS leaderboard_snapshots;
+-- +goose StatementEnd
@@ -0,0 +1,92 @@
+-- +goose Up
+
+-- +goose StatementBegin
+-- Accounts whose usernames only differ in casing cannot be merged safely, as
+-- each carries its own bounty, sessions and history. They are reported and the
+-- migration is aborted so that an operator can resolve them first.
+DO $$
+DECLARE
+  conflicts TEXT;
+BEGIN
+  SELECT
+    STRING_AGG(names, '; ')
+  INTO
+    conflicts
+  FROM (
+    SELECT
+      'user_account: ' || STRING_AGG(ghUsername, ', ' ORDER BY ghUsername) AS names
+    FROM
+      user_account
+    GROUP BY
+      LOWER(TRIM(ghUsername))
+    HAVING
+      COUNT(*) > 1
+    UNION ALL
+    SELECT
+      'maintainers: ' || STRING_AGG(ghUsername, ', ' ORDER BY ghUsername)
+    FROM
+      maintainers
+    GROUP BY
+      LOWER(TRIM(ghUsername))
+    HAVING
+      COUNT(*) > 1
+    UNION ALL
+    SELECT
+      'leaderboard_snapshots ' || snapshot_date || ': ' || STRING_AGG(ghUsername, ', ' ORDER BY ghUsername)
+    FROM
+      leaderboard_snapshots
+    GROUP BY
+      snapshot_date, LOWER(TRIM(ghUsername))
+    HAVING
+      COUNT(*) > 1
+  ) AS duplicate;
+
+  IF conflicts IS NOT NULL THEN
+    RAISE EXCEPTION 'GitHub usernames differing only in casing must be resolved first: %', conflicts;
+  END IF;
+END
+$$;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- GitHub usernames are case-insensitive, so they are stored lowercased from
+-- here on. The casing last seen on GitHub is kept for display. Tables with a
+-- foreign key to user_account follow through ON UPDATE CASCADE.
+ALTER TABLE user_account
+  ADD COLUMN IF NOT EXISTS display_ghusername TEXT NOT NULL DEFAULT '';
+UPDATE user_account SET display_ghusername = ghUsername;
+UPDATE user_account SET ghUsername = LOWER(TRIM(ghUsername));
+UPDATE user_onboarding SET ghUsername = LOWER(TRIM(ghUsername));
+UPDATE maintainers SET ghUsername = LOWER(TRIM(ghUsername));
+UPDATE badge_dispatch SET ghUsername = LOWER(TRIM(ghUsername));
+UPDATE leaderboard_snapshots SET ghUsername = LOWER(TRIM(ghUsername));
+ALTER TABLE user_account
+  ADD CONSTRAINT "user_account_ghUsername_lowercase" CHECK (ghUsername = LOWER(ghUsername));
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- Audit entries are filtered by actor, which must match the lowercased
+-- username. Only values naming a user are touched, the rest (emails, setting
+-- names) are left as they were logged.
+UPDATE audit_log
+SET
+  actor = LOWER(TRIM(actor))
+WHERE
+  actor <> LOWER(TRIM(actor))
+  AND LOWER(TRIM(actor)) IN (SELECT ghUsername FROM user_account UNION SELECT ghUsername FROM maintainers);
+UPDATE audit_log
+SET
+  target = LOWER(TRIM(target))
+WHERE
+  target <> LOWER(TRIM(target))
+  AND LOWER(TRIM(target)) IN (SELECT ghUsername FROM user_account UNION SELECT ghUsername FROM maintainers);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- Lossy: usernames stay lowercased everywhere. The original casing is only
+-- kept in display_ghusername, which is dropped here.
+ALTER TABLE user_account
+  DROP CONSTRAINT IF EXISTS "user_account_ghUsername_lowercase";
+ALTER TABLE user_account DROP COLUMN IF EXISTS display_ghusername;
+-- +goose StatementEnd
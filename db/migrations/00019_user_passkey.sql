@@ -0,0 +1,33 @@
+-- +goose Up
+
+-- +goose StatementBegin
+-- Passkeys registered by a user. The credential holds the public key and
+-- sign count as serialized by the WebAuthn library.
+CREATE TABLE IF NOT EXISTS user_passkey(
+  id SERIAL NOT NULL,
+  ghUsername TEXT NOT NULL,
+  credential_id BYTEA NOT NULL,
+  credential JSONB NOT NULL,
+  name TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  last_used_at TIMESTAMP,
+
+  CONSTRAINT "user_passkey_pkey" PRIMARY KEY (id),
+  CONSTRAINT "user_passkey_credential_id_key" UNIQUE (credential_id),
+  CONSTRAINT "user_passkey_ghUsername_fkey"
+    FOREIGN KEY (ghUsername)
+      REFERENCES user_account(ghUsername)
+        ON DELETE CASCADE
+        ON UPDATE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS user_passkey_ghUsername_idx
+  ON user_passkey (ghUsername);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS user_passkey;
+-- +goose StatementEnd
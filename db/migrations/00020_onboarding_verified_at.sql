@@ -0,0 +1,14 @@
+-- +goose Up
+
+-- +goose StatementBegin
+-- Set when the OTP is verified, so that retries of a successful verification
+-- can be recognised for a short while
+ALTER TABLE user_onboarding
+  ADD COLUMN IF NOT EXISTS verified_at TIMESTAMP;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE user_onboarding
+  DROP COLUMN IF EXISTS verified_at;
+-- +goose StatementEnd
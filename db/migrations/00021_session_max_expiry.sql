@@ -0,0 +1,28 @@
+-- +goose Up
+
+-- +goose StatementBegin
+-- Absolute expiry of a session. With sliding expiration, expires_at is pushed
+-- forward on every refresh but never past this, and sessions replaced by
+-- rotation carry it over.
+ALTER TABLE user_session
+  ADD COLUMN IF NOT EXISTS max_expires_at TIMESTAMP;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+UPDATE user_session
+SET
+  max_expires_at = expires_at
+WHERE
+  max_expires_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE user_session
+  ALTER COLUMN max_expires_at SET NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE user_session
+  DROP COLUMN IF EXISTS max_expires_at;
+-- +goose StatementEnd
@@ -0,0 +1,24 @@
+-- +goose Up
+
+-- +goose StatementBegin
+-- When the GitHub ID was attached to the account, listed alongside the
+-- creation time of other linked identities
+ALTER TABLE user_account
+  ADD COLUMN IF NOT EXISTS gh_linked_at TIMESTAMP;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- Link times of existing accounts are unknown, account creation is the
+-- closest approximation
+UPDATE user_account
+SET
+  gh_linked_at = COALESCE(created_at, NOW())
+WHERE
+  gh_id IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE user_account
+  DROP COLUMN IF EXISTS gh_linked_at;
+-- +goose StatementEnd
@@ -0,0 +1,28 @@
+-- +goose Up
+
+-- +goose StatementBegin
+-- IANA time zone and BCP 47 locale given at registration, used to time
+-- notifications and localize mails
+ALTER TABLE user_onboarding
+  ADD COLUMN IF NOT EXISTS timezone TEXT NOT NULL DEFAULT 'UTC',
+  ADD COLUMN IF NOT EXISTS locale TEXT NOT NULL DEFAULT 'en';
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE user_account
+  ADD COLUMN IF NOT EXISTS timezone TEXT NOT NULL DEFAULT 'UTC',
+  ADD COLUMN IF NOT EXISTS locale TEXT NOT NULL DEFAULT 'en';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE user_account
+  DROP COLUMN IF EXISTS timezone,
+  DROP COLUMN IF EXISTS locale;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE user_onboarding
+  DROP COLUMN IF EXISTS timezone,
+  DROP COLUMN IF EXISTS locale;
+-- +goose StatementEnd
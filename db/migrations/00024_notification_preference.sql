@@ -0,0 +1,23 @@
+-- +goose Up
+
+-- +goose StatementBegin
+-- Only notifications a user has changed are stored, every other one is on
+CREATE TABLE IF NOT EXISTS notification_preference(
+  ghUsername TEXT NOT NULL,
+  kind TEXT NOT NULL,
+  enabled BOOLEAN NOT NULL,
+  updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+  CONSTRAINT "notification_preference_pkey" PRIMARY KEY (ghUsername, kind),
+  CONSTRAINT "notification_preference_ghUsername_fkey"
+    FOREIGN KEY (ghUsername)
+      REFERENCES user_account(ghUsername)
+        ON DELETE CASCADE
+        ON UPDATE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS notification_preference;
+-- +goose StatementEnd
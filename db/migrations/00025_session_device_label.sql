@@ -0,0 +1,14 @@
+-- +goose Up
+
+-- +goose StatementBegin
+-- Human readable device derived from the user agent at login, e.g.
+-- "Chrome on macOS"
+ALTER TABLE user_session
+  ADD COLUMN IF NOT EXISTS device_label TEXT NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE user_session
+  DROP COLUMN IF EXISTS device_label;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+
+-- +goose StatementBegin
+-- Registrations may ask for their OTP by SMS to an E.164 phone number
+-- instead of by mail
+ALTER TABLE user_onboarding
+  ADD COLUMN IF NOT EXISTS phone TEXT NOT NULL DEFAULT '',
+  ADD COLUMN IF NOT EXISTS otp_channel TEXT NOT NULL DEFAULT 'email',
+  ADD CONSTRAINT "user_onboarding_otp_channel_check"
+    CHECK (otp_channel IN ('email', 'sms') AND (otp_channel = 'email' OR phone <> ''));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE user_onboarding
+  DROP CONSTRAINT IF EXISTS "user_onboarding_otp_channel_check",
+  DROP COLUMN IF EXISTS phone,
+  DROP COLUMN IF EXISTS otp_channel;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+
+-- +goose StatementBegin
+-- Refresh tokens are tracked by their jti in user_session since 00005, so the
+-- raw token of the latest login is no longer kept. Tokens issued before 00005
+-- carry no jti and were already rejected on refresh, the users holding them
+-- have to sign in once more.
+ALTER TABLE user_account
+  DROP COLUMN IF EXISTS refresh_token;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- Dropped tokens are not restored
+ALTER TABLE user_account
+  ADD COLUMN IF NOT EXISTS refresh_token TEXT;
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+
+-- +goose StatementBegin
+-- GitHub tokens are stored encrypted from now on. Tokens stored in plaintext
+-- are dropped, users sign in with GitHub again before re-syncing a profile.
+UPDATE user_account
+SET
+  gh_token = NULL
+WHERE
+  gh_token IS NOT NULL
+  AND gh_token NOT LIKE 'v1:%';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- Dropped tokens cannot be restored
+SELECT 1;
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+-- +goose Up
+
+-- +goose StatementBegin
+-- Verified OTPs are blanked, only their SHA-256 is kept to recognise retries
+-- of a successful verification
+ALTER TABLE user_onboarding
+  ADD COLUMN IF NOT EXISTS otp_hash TEXT;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+UPDATE user_onboarding
+SET
+  otp_hash = encode(sha256(convert_to(LOWER(otp), 'UTF8')), 'hex'),
+  otp = ''
+WHERE
+  state = 'onboarded'
+  AND otp <> '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- Blanked OTPs are not restored
+ALTER TABLE user_onboarding
+  DROP COLUMN IF EXISTS otp_hash;
+-- +goose StatementEnd
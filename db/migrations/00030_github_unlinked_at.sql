@@ -0,0 +1,14 @@
+-- +goose Up
+
+-- +goose StatementBegin
+-- Set when the user unlinks GitHub. Such accounts are no longer matched to a
+-- GitHub login by username or email, only linking GitHub again clears it.
+ALTER TABLE user_account
+  ADD COLUMN IF NOT EXISTS gh_unlinked_at TIMESTAMP;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE user_account
+  DROP COLUMN IF EXISTS gh_unlinked_at;
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+
+-- +goose StatementBegin
+-- When the OTP was last sent, resends are spaced out by OTP_RESEND_COOLDOWN
+ALTER TABLE user_onboarding
+  ADD COLUMN IF NOT EXISTS otp_sent_at TIMESTAMP NOT NULL DEFAULT NOW();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE user_onboarding
+  DROP COLUMN IF EXISTS otp_sent_at;
+-- +goose StatementEnd
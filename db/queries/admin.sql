@@ -0,0 +1,34 @@
+-- name: CheckIsMaintainerQuery :one
+SELECT EXISTS
+  (
+    SELECT 1 FROM maintainers
+    WHERE ghUsername = $1
+    LIMIT 1
+);
+
+-- name: FetchPendingRegistrationByEmailQuery :one
+SELECT
+  email,
+  ghUsername,
+  timezone,
+  locale,
+  resend_count,
+  EXTRACT(EPOCH FROM NOW() - otp_sent_at)::INT AS sent_seconds_ago
+FROM
+  user_onboarding
+WHERE
+  email = $1
+  AND state = 'pending_otp'
+ORDER BY
+  created_at DESC
+LIMIT 1;
+
+-- name: MarkRegistrationsOnboardedQuery :exec
+UPDATE user_onboarding
+SET
+  state = 'onboarded',
+  verified_at = NOW(),
+  otp = ''
+WHERE
+  ghUsername = $1
+  AND state = 'pending_otp';
@@ -0,0 +1,33 @@
+-- name: AddAuditLogQuery :exec
+INSERT INTO
+  audit_log
+  (
+    actor,
+    action,
+    target,
+    details
+  )
+VALUES ($1, $2, $3, $4);
+
+-- name: FetchAuditLogsQuery :many
+SELECT
+  id,
+  actor,
+  action,
+  target,
+  details,
+  created_at
+FROM
+  audit_log
+WHERE
+  (sqlc.narg('actor')::TEXT IS NULL OR actor = sqlc.narg('actor'))
+  AND (sqlc.narg('action')::TEXT IS NULL OR action = sqlc.narg('action'))
+  AND created_at >= sqlc.arg('from_time')
+  AND created_at < sqlc.arg('to_time')
+  AND (
+    sqlc.narg('cursor_id')::INT IS NULL
+    OR (created_at, id) < (sqlc.narg('cursor_time')::TIMESTAMP, sqlc.narg('cursor_id')::INT)
+  )
+ORDER BY
+  created_at DESC, id DESC
+LIMIT sqlc.arg('page_size');
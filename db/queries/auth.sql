@@ -8,40 +8,115 @@ WHERE
   status = true
   AND ghUsername = $1;
 
--- name: AddRefreshTokenQuery :one
-UPDATE user_account
-SET
-  refresh_token = $1
+-- name: CheckUserExistByGithubIdQuery :one
+SELECT
+  ghUsername,
+  email
+FROM 
+  user_account
 WHERE
-  ghUsername = $2
-  AND status = true
-  AND updated_at = NOW()
-RETURNING
-  email,
+  status = true
+  AND gh_id = $1;
+
+-- name: CheckUserExistByEmailQuery :one
+SELECT
   ghUsername,
-  refresh_token,
-  bounty;
+  email
+FROM 
+  user_account
+WHERE
+  status = true
+  AND email = $1
+  AND email <> '';
+
+-- name: CheckGithubFallbackUserQuery :one
+-- Accounts never tied to a GitHub ID are matched by username, or else by email
+-- for users who renamed their GitHub account. Accounts which unlinked GitHub
+-- are never matched this way.
+SELECT
+  ghUsername,
+  email
+FROM
+  user_account
+WHERE
+  status = true
+  AND gh_id IS NULL
+  AND gh_unlinked_at IS NULL
+  AND (
+    ghUsername = sqlc.arg('ghusername')
+    OR (email = sqlc.arg('email') AND email <> '')
+  )
+ORDER BY
+  ghUsername = sqlc.arg('ghusername') DESC
+LIMIT 1;
 
--- name: CheckRefreshTokenQuery :one
+-- name: FetchLoginUserQuery :one
 SELECT
-  ghUsername, email
+  email,
+  ghUsername,
+  bounty
 FROM
   user_account
 WHERE
-  refresh_token = $1
-  AND email = $2
+  ghUsername = $1
   AND status = true;
 
 -- name: CheckForExistingOtpQuery :one
 SELECT
   email,
-  otp
+  otp,
+  resend_count,
+  phone,
+  otp_channel,
+  EXTRACT(EPOCH FROM NOW() - otp_sent_at)::INT AS sent_seconds_ago
 FROM
   user_onboarding 
 WHERE
   ghUsername = $1
+  AND state = 'pending_otp'
   AND expiry_at >= NOW() + INTERVAL '1 minute';
 
+-- name: IncrementOtpResendQuery :execrows
+-- Matches nothing once the cap is reached or within the cooldown
+UPDATE user_onboarding
+SET
+  resend_count = resend_count + 1,
+  otp_sent_at = NOW()
+WHERE
+  ghUsername = $1
+  AND otp = $2
+  AND state = 'pending_otp'
+  AND resend_count < sqlc.arg('max_resends')::INT
+  AND otp_sent_at <= NOW() - sqlc.arg('cooldown')::INTERVAL;
+
+-- name: RegenerateOtpQuery :one
+-- Counts as a resend, so the cap and cooldown apply to resends by staff as well
+UPDATE user_onboarding
+SET
+  otp = sqlc.arg('otp'),
+  expiry_at = NOW() + INTERVAL '5 minutes',
+  resend_count = resend_count + 1,
+  otp_sent_at = NOW()
+WHERE
+  ghUsername = sqlc.arg('ghusername')
+  AND email = sqlc.arg('email')
+  AND state = 'pending_otp'
+  AND resend_count < sqlc.arg('max_resends')::INT
+  AND otp_sent_at <= NOW() - sqlc.arg('cooldown')::INTERVAL
+RETURNING
+  email, otp, phone, otp_channel;
+
+-- name: CheckPendingRegistrationQuery :one
+SELECT EXISTS
+  (
+    SELECT 1 FROM user_onboarding
+    WHERE ghUsername = $1
+    AND email = $2
+    AND LOWER(otp) = LOWER($3)
+    AND state = 'pending_otp'
+    AND expiry_at > NOW()
+);
+
 -- name: BeginUserRegistrationQuery :one
 INSERT INTO 
   user_onboarding
@@ -49,29 +124,92 @@ INSERT INTO
     email,
     ghUsername,
     otp,
+    timezone,
+    locale,
+    phone,
+    otp_channel,
     expiry_at
   )
-VALUES ($1, $2, $3, NOW() + INTERVAL '5 minutes')
+VALUES ($1, $2, $3, $4, $5, $6, $7, NOW() + INTERVAL '5 minutes')
 RETURNING
-  email, otp;
+  email, otp, phone, otp_channel;
 
 -- name: VerifyOtpQuery :one
-DELETE FROM 
-  user_onboarding
+-- The OTP is not kept once used, only its hash to recognise retries
+UPDATE user_onboarding
+SET
+  state = 'onboarded',
+  verified_at = NOW(),
+  otp = '',
+  otp_hash = encode(sha256(convert_to(LOWER(otp), 'UTF8')), 'hex')
 WHERE
   ghUsername = $1
-  AND otp = $2
+  AND LOWER(otp) = LOWER($2)
+  AND state = 'pending_otp'
   AND expiry_at > NOW()
 RETURNING
-  email, ghUsername;
+  email, ghUsername, timezone, locale, created_at;
+
+-- name: LockOnboardingQuery :exec
+-- Held until the transaction ends, serializing verifications of one user
+SELECT pg_advisory_xact_lock(hashtextextended('user_onboarding:' || sqlc.arg('ghusername')::TEXT, 0));
+
+-- name: CheckOtpReplayQuery :one
+SELECT
+  o.ghUsername
+FROM
+  user_onboarding o
+JOIN
+  user_account u ON u.ghUsername = o.ghUsername
+WHERE
+  o.ghUsername = sqlc.arg('ghusername')
+  AND o.otp_hash = encode(sha256(convert_to(LOWER(sqlc.arg('otp')::TEXT), 'UTF8')), 'hex')
+  AND o.state = 'onboarded'
+  AND o.verified_at > NOW() - sqlc.arg('window')::INTERVAL
+  AND u.status = true
+LIMIT 1;
 
 -- name: CreateUserAccountQuery :one
 INSERT INTO
   user_account
   (
     email,
-    ghUsername
+    ghUsername,
+    display_ghusername,
+    timezone,
+    locale
   )
-VALUES ($1, $2)
+VALUES ($1, $2, $3, $4, $5)
 RETURNING
   ghUsername;
+
+-- name: PurgeAbandonedRegistrationsQuery :execrows
+-- Completed registrations are purged as well, their email and phone live on
+-- in user_account where needed
+DELETE FROM
+  user_onboarding
+WHERE
+  (state = 'pending_otp' AND created_at < NOW() - sqlc.arg(ttl)::INTERVAL)
+  OR (state = 'onboarded' AND COALESCE(verified_at, created_at) < NOW() - sqlc.arg(ttl)::INTERVAL);
+
+-- name: FetchOnboardingStateQuery :one
+SELECT
+  (CASE
+    WHEN EXISTS (
+      SELECT 1 FROM user_account
+      WHERE ghUsername = $1 AND status = true
+    ) THEN 'onboarded'
+    WHEN EXISTS (
+      SELECT 1 FROM user_onboarding
+      WHERE ghUsername = $1 AND state = 'pending_otp' AND expiry_at > NOW()
+    ) THEN 'pending_otp'
+    ELSE ''
+  END)::TEXT AS state;
+
+-- name: CountOnboardedTodayQuery :one
+SELECT
+  COUNT(*)
+FROM
+  user_account
+WHERE
+  created_at >= DATE_TRUNC('day', NOW());
@@ -0,0 +1,72 @@
+-- name: DebitBountyQuery :one
+UPDATE user_account
+SET
+  bounty = bounty - sqlc.arg('amount')::INT,
+  updated_at = NOW()
+WHERE
+  ghUsername = sqlc.arg('ghusername')
+  AND status = true
+  AND bounty >= sqlc.arg('amount')::INT
+RETURNING
+  bounty;
+
+-- name: CreditBountyQuery :one
+UPDATE user_account
+SET
+  bounty = bounty + sqlc.arg('amount')::INT,
+  updated_at = NOW()
+WHERE
+  ghUsername = sqlc.arg('ghusername')
+  AND status = true
+RETURNING
+  bounty;
+
+-- name: AddBountyLedgerQuery :exec
+INSERT INTO
+  bounty_ledger
+  (
+    ghUsername,
+    amount,
+    kind,
+    counterparty
+  )
+VALUES ($1, $2, $3, $4);
+
+-- name: AddBountyLogQuery :exec
+INSERT INTO
+  bounty_log
+  (
+    ghUsername,
+    dispatched_by,
+    proof_url,
+    repo_id,
+    amount
+  )
+VALUES ($1, $2, $3, $4, $5);
+
+-- name: FetchBountyDriftQuery :many
+SELECT
+  u.ghUsername,
+  u.bounty,
+  COALESCE(SUM(l.amount), 0)::INT AS ledger_total
+FROM
+  user_account u
+  LEFT JOIN bounty_ledger l ON l.ghUsername = u.ghUsername
+GROUP BY
+  u.ghUsername,
+  u.bounty
+HAVING
+  u.bounty <> COALESCE(SUM(l.amount), 0)
+ORDER BY
+  u.ghUsername;
+
+-- name: CorrectBountyQuery :execrows
+-- Only applies while the balance is still the one found drifting, so that a
+-- concurrent award is never overwritten
+UPDATE user_account
+SET
+  bounty = sqlc.arg('ledger_total')::INT,
+  updated_at = NOW()
+WHERE
+  ghUsername = sqlc.arg('ghusername')
+  AND bounty = sqlc.arg('bounty')::INT;
@@ -0,0 +1,59 @@
+-- name: ExportBountyLedgerQuery :many
+-- The counterparty of a transfer is another user and left out
+SELECT
+  amount,
+  kind,
+  created_at
+FROM
+  bounty_ledger
+WHERE
+  ghUsername = $1
+ORDER BY
+  created_at ASC, id ASC;
+
+-- name: ExportSessionsQuery :many
+SELECT
+  jti,
+  revoked,
+  created_at,
+  expires_at
+FROM
+  user_session
+WHERE
+  ghUsername = $1
+ORDER BY
+  created_at ASC, id ASC;
+
+-- name: ExportAuditLogsQuery :many
+-- Entries recorded by others about the user, e.g. bounty awards, belong to
+-- the export as well. Their actor and details are redacted by the caller.
+SELECT
+  actor,
+  action,
+  target,
+  details,
+  created_at
+FROM
+  audit_log
+WHERE
+  actor = sqlc.arg('ghusername')
+  OR target = sqlc.arg('ghusername')
+ORDER BY
+  created_at ASC, id ASC;
+
+-- name: ExportUsersPageQuery :many
+SELECT
+  id,
+  ghUsername,
+  email,
+  full_name,
+  bounty,
+  status,
+  created_at
+FROM
+  user_account
+WHERE
+  id > sqlc.arg('after_id')::INT
+ORDER BY
+  id
+LIMIT sqlc.arg('page_size')::INT;
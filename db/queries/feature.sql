@@ -0,0 +1,6 @@
+-- name: FetchFeatureFlagsQuery :many
+SELECT
+  name,
+  enabled
+FROM
+  feature_flag;
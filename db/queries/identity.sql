@@ -0,0 +1,101 @@
+-- name: FetchIdentityOwnerQuery :one
+SELECT
+  ghUsername
+FROM
+  user_identity
+WHERE
+  provider = $1
+  AND subject = $2;
+
+-- name: FetchUserIdentityQuery :one
+SELECT
+  subject,
+  email
+FROM
+  user_identity
+WHERE
+  ghUsername = $1
+  AND provider = $2;
+
+-- name: AddIdentityQuery :exec
+INSERT INTO
+  user_identity
+  (
+    provider,
+    subject,
+    ghUsername,
+    email
+  )
+VALUES ($1, $2, $3, $4);
+
+-- name: FetchGithubIdQuery :one
+SELECT
+  gh_id
+FROM
+  user_account
+WHERE
+  ghUsername = $1
+  AND status = true;
+
+-- name: LinkGithubIdQuery :execrows
+-- GitHub logins record the ID of the account they matched, so linking that
+-- same account again only confirms it. Another linked account has to be
+-- unlinked first.
+UPDATE user_account
+SET
+  gh_id = $1,
+  gh_linked_at = COALESCE(gh_linked_at, NOW()),
+  gh_unlinked_at = NULL
+WHERE
+  ghUsername = $2
+  AND status = true
+  AND (gh_id IS NULL OR gh_id = $1);
+
+-- name: LockUserAccountQuery :one
+SELECT
+  ghUsername
+FROM
+  user_account
+WHERE
+  ghUsername = $1
+  AND status = true
+FOR UPDATE;
+
+-- name: FetchLinkedProvidersQuery :many
+SELECT
+  'github'::TEXT AS provider,
+  gh_linked_at AS linked_at
+FROM
+  user_account
+WHERE
+  ghUsername = $1
+  AND gh_id IS NOT NULL
+UNION ALL
+SELECT
+  provider,
+  created_at AS linked_at
+FROM
+  user_identity
+WHERE
+  ghUsername = $1
+ORDER BY
+  linked_at;
+
+-- name: UnlinkGithubIdQuery :execrows
+-- The stored GitHub token belongs to the unlinked account as well
+UPDATE user_account
+SET
+  gh_id = NULL,
+  gh_linked_at = NULL,
+  gh_token = NULL,
+  gh_unlinked_at = NOW()
+WHERE
+  ghUsername = $1
+  AND gh_id IS NOT NULL;
+
+-- name: DeleteIdentityQuery :execrows
+DELETE FROM
+  user_identity
+WHERE
+  ghUsername = $1
+  AND provider = $2;
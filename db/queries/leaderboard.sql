@@ -0,0 +1,55 @@
+-- name: CreateLeaderboardSnapshotQuery :execrows
+INSERT INTO
+  leaderboard_snapshots
+  (
+    snapshot_date,
+    position,
+    rank,
+    ghUsername,
+    bounty
+  )
+SELECT
+  sqlc.arg('snapshot_date')::DATE,
+  ROW_NUMBER() OVER (ORDER BY bounty DESC, ghUsername),
+  RANK() OVER (ORDER BY bounty DESC),
+  ghUsername,
+  bounty
+FROM
+  user_account
+WHERE
+  status = true
+  AND NOT EXISTS (
+    SELECT 1 FROM leaderboard_snapshots
+    WHERE snapshot_date = sqlc.arg('snapshot_date')::DATE
+  )
+ON CONFLICT DO NOTHING;
+
+-- name: FetchSnapshotDateQuery :one
+SELECT
+  snapshot_date
+FROM
+  leaderboard_snapshots
+WHERE
+  snapshot_date <= sqlc.arg('as_of')::DATE
+ORDER BY
+  snapshot_date DESC
+LIMIT 1;
+
+-- name: FetchLeaderboardSnapshotQuery :many
+SELECT
+  position,
+  rank,
+  ghUsername,
+  bounty
+FROM
+  leaderboard_snapshots
+WHERE
+  snapshot_date = sqlc.arg('snapshot_date')::DATE
+  AND position > sqlc.arg('after_position')::INT
+  AND (
+    sqlc.narg('search')::TEXT IS NULL
+    OR ghUsername ILIKE '%' || sqlc.narg('search')::TEXT || '%'
+  )
+ORDER BY
+  position
+LIMIT sqlc.arg('page_size');
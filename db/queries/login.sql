@@ -0,0 +1,22 @@
+-- name: LoginHistoryQuery :many
+SELECT
+  ip,
+  user_agent,
+  created_at
+FROM
+  login_history
+WHERE
+  ghUsername = $1
+ORDER BY
+  created_at DESC
+LIMIT 50;
+
+-- name: AddLoginHistoryQuery :exec
+INSERT INTO
+  login_history
+  (
+    ghUsername,
+    ip,
+    user_agent
+  )
+VALUES ($1, $2, $3);
@@ -0,0 +1,36 @@
+-- name: AddMailLogQuery :exec
+INSERT INTO
+  mail_log
+  (
+    message_id,
+    recipient,
+    kind
+  )
+VALUES ($1, $2, $3);
+
+-- name: UpdateMailStatusQuery :one
+UPDATE mail_log
+SET
+  status = $1,
+  updated_at = NOW()
+WHERE
+  message_id = $2
+RETURNING
+  recipient;
+
+-- name: SuppressEmailQuery :exec
+INSERT INTO
+  mail_suppression
+  (
+    email,
+    reason
+  )
+VALUES ($1, $2)
+ON CONFLICT (email) DO NOTHING;
+
+-- name: CheckEmailSuppressedQuery :one
+SELECT EXISTS
+  (
+    SELECT 1 FROM mail_suppression
+    WHERE email = $1
+);
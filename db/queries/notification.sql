@@ -0,0 +1,24 @@
+-- name: FetchNotificationPreferencesQuery :many
+SELECT
+  kind,
+  enabled
+FROM
+  notification_preference
+WHERE
+  ghUsername = $1;
+
+-- name: SetNotificationPreferenceQuery :exec
+INSERT INTO notification_preference (ghUsername, kind, enabled)
+VALUES ($1, $2, $3)
+ON CONFLICT (ghUsername, kind)
+DO UPDATE SET
+  enabled = EXCLUDED.enabled,
+  updated_at = NOW();
+
+-- name: NotificationEnabledQuery :one
+SELECT
+  COALESCE((
+    SELECT enabled
+    FROM notification_preference
+    WHERE ghUsername = $1 AND kind = $2
+  ), true)::BOOLEAN AS enabled;
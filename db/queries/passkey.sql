@@ -0,0 +1,51 @@
+-- name: FetchPasskeyUserQuery :one
+SELECT
+  id,
+  ghUsername,
+  display_ghusername,
+  email,
+  full_name,
+  avatar_url
+FROM
+  user_account
+WHERE
+  ghUsername = $1
+  AND status = true;
+
+-- name: FetchPasskeysQuery :many
+SELECT
+  credential
+FROM
+  user_passkey
+WHERE
+  ghUsername = $1
+ORDER BY
+  created_at;
+
+-- name: AddPasskeyQuery :exec
+INSERT INTO
+  user_passkey
+  (
+    ghUsername,
+    credential_id,
+    credential,
+    name
+  )
+VALUES ($1, $2, $3, $4);
+
+-- name: UpdatePasskeyQuery :exec
+UPDATE user_passkey
+SET
+  credential = $1,
+  last_used_at = NOW()
+WHERE
+  credential_id = $2
+  AND ghUsername = $3;
+
+-- name: CountPasskeysQuery :one
+SELECT
+  COUNT(*)
+FROM
+  user_passkey
+WHERE
+  ghUsername = $1;
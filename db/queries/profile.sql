@@ -2,7 +2,12 @@
 SELECT
   email,
   ghUsername,
-  bounty
+  display_ghusername,
+  bounty,
+  full_name,
+  avatar_url,
+  timezone,
+  locale
 FROM 
   user_account
 WHERE
@@ -18,3 +23,52 @@ FROM
 WHERE
   ghUsername = $1;
 
+-- name: FetchGithubTokenQuery :one
+SELECT
+  gh_token
+FROM
+  user_account
+WHERE
+  status = true
+  AND ghUsername = $1;
+
+-- name: SaveGithubTokenQuery :exec
+UPDATE user_account
+SET
+  gh_token = $1
+WHERE
+  ghUsername = $2;
+
+-- name: UpdateGithubProfileQuery :exec
+-- An account that unlinked GitHub keeps it unlinked until linked again
+UPDATE user_account
+SET
+  full_name = $1,
+  avatar_url = $2,
+  gh_id = CASE
+    WHEN gh_unlinked_at IS NULL THEN $3
+    ELSE gh_id
+  END,
+  gh_linked_at = CASE
+    WHEN gh_unlinked_at IS NOT NULL THEN gh_linked_at
+    WHEN gh_id IS NOT DISTINCT FROM $3 THEN COALESCE(gh_linked_at, NOW())
+    ELSE NOW()
+  END,
+  display_ghusername = $5,
+  updated_at = NOW()
+WHERE
+  ghUsername = $4
+  AND status = true;
+
+-- name: FetchPublicProfileQuery :one
+SELECT
+  ghUsername,
+  display_ghusername,
+  full_name,
+  avatar_url,
+  bounty
+FROM
+  user_account
+WHERE
+  status = true
+  AND ghUsername = $1;
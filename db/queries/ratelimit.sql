@@ -0,0 +1,28 @@
+-- name: HitRateLimitQuery :one
+WITH hit AS (
+  INSERT INTO
+    rate_limit
+    (
+      key,
+      window_start,
+      count
+    )
+  VALUES (sqlc.arg('key'), sqlc.arg('window_start'), 1)
+  ON CONFLICT (key, window_start)
+  DO UPDATE SET count = rate_limit.count + 1
+  RETURNING count
+)
+SELECT
+  hit.count AS current_count,
+  COALESCE((
+    SELECT count FROM rate_limit
+    WHERE key = sqlc.arg('key') AND window_start = sqlc.arg('previous_start')
+  ), 0)::INT AS previous_count
+FROM
+  hit;
+
+-- name: PurgeRateLimitsQuery :execrows
+DELETE FROM
+  rate_limit
+WHERE
+  window_start < $1;
@@ -0,0 +1,89 @@
+-- name: AddSessionQuery :exec
+-- Rotated sessions pass the absolute expiry of the session they replace
+INSERT INTO
+  user_session
+  (
+    jti,
+    ghUsername,
+    ua_hash,
+    ip_class,
+    device_label,
+    expires_at,
+    max_expires_at
+  )
+VALUES (
+  sqlc.arg('jti'),
+  sqlc.arg('ghusername'),
+  sqlc.arg('ua_hash'),
+  sqlc.arg('ip_class'),
+  sqlc.arg('device_label'),
+  LEAST(
+    NOW() + sqlc.arg('ttl')::INTERVAL,
+    COALESCE(sqlc.narg('max_expires_at')::TIMESTAMP, NOW() + sqlc.arg('max_age')::INTERVAL)
+  ),
+  COALESCE(sqlc.narg('max_expires_at')::TIMESTAMP, NOW() + sqlc.arg('max_age')::INTERVAL)
+);
+
+-- name: CheckSessionQuery :one
+SELECT
+  u.ghUsername,
+  u.email,
+  s.ua_hash,
+  s.ip_class,
+  s.device_label,
+  s.max_expires_at
+FROM
+  user_session s
+JOIN
+  user_account u ON u.ghUsername = s.ghUsername
+WHERE
+  s.jti = $1
+  AND s.revoked = false
+  AND s.expires_at > NOW()
+  AND u.status = true;
+
+-- name: ExtendSessionQuery :execrows
+UPDATE user_session
+SET
+  expires_at = LEAST(NOW() + sqlc.arg('ttl')::INTERVAL, max_expires_at)
+WHERE
+  jti = sqlc.arg('jti')
+  AND revoked = false
+  AND expires_at > NOW();
+
+-- name: RevokeTokenByJtiQuery :execrows
+UPDATE user_session
+SET
+  revoked = true
+WHERE
+  jti = $1
+  AND ghUsername = $2
+  AND revoked = false;
+
+-- name: FetchSessionStatusQuery :one
+SELECT
+  jti,
+  created_at,
+  expires_at
+FROM
+  user_session
+WHERE
+  ghUsername = sqlc.arg('ghusername')
+  AND jti = sqlc.arg('jti')
+  AND revoked = false
+  AND expires_at > NOW();
+
+-- name: FetchSessionsQuery :many
+SELECT
+  jti,
+  device_label,
+  created_at,
+  expires_at
+FROM
+  user_session
+WHERE
+  ghUsername = $1
+  AND revoked = false
+  AND expires_at > NOW()
+ORDER BY
+  created_at DESC;
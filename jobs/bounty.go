@@ -0,0 +1,77 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/IAmRiteshKoushik/pulse/cmd"
+	db "github.com/IAmRiteshKoushik/pulse/db/gen"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Balances start from the opening entry made when bounty_ledger was created,
+// and every change since is recorded there, so a balance which differs from
+// the sum of its ledger entries points at a bug in a bounty path. Drift is checked every interval until ctx is cancelled, and only
+// logged on a dry run.
+func RunBountyReconcile(ctx context.Context, pool *pgxpool.Pool, q db.Querier,
+	log cmd.Logger, interval time.Duration, dryRun bool) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		drifted, err := ReconcileBounties(ctx, pool, q, dryRun)
+		if err != nil {
+			log.Error("[JOB]: Failed to reconcile bounties", err)
+		}
+		for _, d := range drifted {
+			log.Warn(fmt.Sprintf("[JOB]: Bounty of %s is %d but its ledger sums to %d (corrected=%t)",
+				d.Ghusername, d.Bounty, d.LedgerTotal, d.Corrected))
+		}
+		if err == nil {
+			log.Info(fmt.Sprintf("[JOB]: Reconciled bounties, %d drifted", len(drifted)))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+type BountyDrift struct {
+	db.FetchBountyDriftQueryRow
+	Corrected bool
+}
+
+// Finds balances which drifted from the ledger, setting them to the ledger
+// total unless dryRun is set. Balances which changed since being read are
+// left for the next run.
+func ReconcileBounties(ctx context.Context, conn db.DBTX, q db.Querier,
+	dryRun bool) ([]BountyDrift, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	rows, err := q.FetchBountyDriftQuery(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	drifted := make([]BountyDrift, 0, len(rows))
+	for _, row := range rows {
+		d := BountyDrift{FetchBountyDriftQueryRow: row}
+		if !dryRun {
+			corrected, err := q.CorrectBountyQuery(ctx, conn, db.CorrectBountyQueryParams{
+				LedgerTotal: row.LedgerTotal,
+				Ghusername:  row.Ghusername,
+				Bounty:      row.Bounty,
+			})
+			if err != nil {
+				return drifted, err
+			}
+			d.Corrected = corrected > 0
+		}
+		drifted = append(drifted, d)
+	}
+	return drifted, nil
+}
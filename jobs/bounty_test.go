@@ -0,0 +1,119 @@
+package jobs
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"testing"
+
+	db "github.com/IAmRiteshKoushik/pulse/db/gen"
+)
+
+// Balances and ledger entries, queried the way FetchBountyDriftQuery and
+// CorrectBountyQuery query them
+type bountyBook struct {
+	balances map[string]int32
+	ledger   map[string][]int32
+}
+
+func (b *bountyBook) querier() *fakeQuerier {
+	return &fakeQuerier{
+		fetchBountyDrift: func() ([]db.FetchBountyDriftQueryRow, error) {
+			var rows []db.FetchBountyDriftQueryRow
+			for _, user := range slices.Sorted(maps.Keys(b.balances)) {
+				var total int32
+				for _, amount := range b.ledger[user] {
+					total += amount
+				}
+				if b.balances[user] != total {
+					rows = append(rows, db.FetchBountyDriftQueryRow{
+						Ghusername: user, Bounty: b.balances[user], LedgerTotal: total,
+					})
+				}
+			}
+			return rows, nil
+		},
+		correctBounty: func(arg db.CorrectBountyQueryParams) (int64, error) {
+			if b.balances[arg.Ghusername] != arg.Bounty {
+				return 0, nil
+			}
+			b.balances[arg.Ghusername] = arg.LedgerTotal
+			return 1, nil
+		},
+	}
+}
+
+func TestReconcileBounties(t *testing.T) {
+	tests := []struct {
+		name         string
+		balances     map[string]int32
+		ledger       map[string][]int32
+		dryRun       bool
+		awardBetween map[string]int32 // credited after the drift is read
+		wantDrift    []BountyDrift
+		wantBalances map[string]int32
+	}{
+		{
+			name:         "opening balances match",
+			balances:     map[string]int32{"octocat": 120, "hubot": 0},
+			ledger:       map[string][]int32{"octocat": {100, 50, -30}, "hubot": {0}},
+			wantBalances: map[string]int32{"octocat": 120, "hubot": 0},
+		},
+		{
+			name:     "drift on a dry run",
+			balances: map[string]int32{"octocat": 150, "hubot": 10},
+			ledger:   map[string][]int32{"octocat": {100, 20}, "hubot": {10}},
+			dryRun:   true,
+			wantDrift: []BountyDrift{
+				{FetchBountyDriftQueryRow: db.FetchBountyDriftQueryRow{Ghusername: "octocat", Bounty: 150, LedgerTotal: 120}},
+			},
+			wantBalances: map[string]int32{"octocat": 150, "hubot": 10},
+		},
+		{
+			name:     "drift corrected",
+			balances: map[string]int32{"octocat": 150, "hubot": 10},
+			ledger:   map[string][]int32{"octocat": {100, 20}},
+			wantDrift: []BountyDrift{
+				{FetchBountyDriftQueryRow: db.FetchBountyDriftQueryRow{Ghusername: "hubot", Bounty: 10, LedgerTotal: 0}, Corrected: true},
+				{FetchBountyDriftQueryRow: db.FetchBountyDriftQueryRow{Ghusername: "octocat", Bounty: 150, LedgerTotal: 120}, Corrected: true},
+			},
+			wantBalances: map[string]int32{"octocat": 120, "hubot": 0},
+		},
+		{
+			name:         "balance changed since read",
+			balances:     map[string]int32{"octocat": 150},
+			ledger:       map[string][]int32{"octocat": {100, 20}},
+			awardBetween: map[string]int32{"octocat": 5},
+			wantDrift: []BountyDrift{
+				{FetchBountyDriftQueryRow: db.FetchBountyDriftQueryRow{Ghusername: "octocat", Bounty: 150, LedgerTotal: 120}},
+			},
+			wantBalances: map[string]int32{"octocat": 155},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			book := &bountyBook{balances: maps.Clone(tt.balances), ledger: tt.ledger}
+			q := book.querier()
+			fetch := q.fetchBountyDrift
+			q.fetchBountyDrift = func() ([]db.FetchBountyDriftQueryRow, error) {
+				rows, err := fetch()
+				for user, amount := range tt.awardBetween {
+					book.balances[user] += amount
+				}
+				return rows, err
+			}
+
+			drifted, err := ReconcileBounties(context.Background(), nil, q, tt.dryRun)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(drifted, tt.wantDrift) {
+				t.Errorf("ReconcileBounties() = %+v, want %+v", drifted, tt.wantDrift)
+			}
+			if !maps.Equal(book.balances, tt.wantBalances) {
+				t.Errorf("balances = %v, want %v", book.balances, tt.wantBalances)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package jobs
+
+import (
+	"context"
+
+	db "github.com/IAmRiteshKoushik/pulse/db/gen"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Answers the queries a test sets up. Any other query panics, as the
+// embedded Querier is nil.
+type fakeQuerier struct {
+	db.Querier
+
+	fetchBountyDrift func() ([]db.FetchBountyDriftQueryRow, error)
+	correctBounty    func(db.CorrectBountyQueryParams) (int64, error)
+	purgeAbandoned   func(ttl pgtype.Interval) (int64, error)
+	createSnapshot   func(snapshotDate pgtype.Date) (int64, error)
+}
+
+func (q *fakeQuerier) FetchBountyDriftQuery(ctx context.Context, conn db.DBTX) ([]db.FetchBountyDriftQueryRow, error) {
+	return q.fetchBountyDrift()
+}
+
+func (q *fakeQuerier) CorrectBountyQuery(ctx context.Context, conn db.DBTX,
+	arg db.CorrectBountyQueryParams) (int64, error) {
+	return q.correctBounty(arg)
+}
+
+func (q *fakeQuerier) PurgeAbandonedRegistrationsQuery(ctx context.Context, conn db.DBTX,
+	ttl pgtype.Interval) (int64, error) {
+	return q.purgeAbandoned(ttl)
+}
+
+func (q *fakeQuerier) CreateLeaderboardSnapshotQuery(ctx context.Context, conn db.DBTX,
+	snapshotDate pgtype.Date) (int64, error) {
+	return q.createSnapshot(snapshotDate)
+}
+
+// Records the messages logged by a job
+type fakeLogger struct {
+	infos  []string
+	errors []string
+}
+
+func (l *fakeLogger) Debug(msg string)            {}
+func (l *fakeLogger) Info(msg string)             { l.infos = append(l.infos, msg) }
+func (l *fakeLogger) Warn(msg string)             {}
+func (l *fakeLogger) Error(msg string, err error) { l.errors = append(l.errors, msg) }
+func (l *fakeLogger) Fatal(msg string, err error) { l.errors = append(l.errors, msg) }
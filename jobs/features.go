@@ -0,0 +1,49 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/IAmRiteshKoushik/pulse/cmd"
+	db "github.com/IAmRiteshKoushik/pulse/db/gen"
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Feature flags stored in the database are reloaded periodically until ctx
+// is cancelled, so that toggling a row reaches every instance. Runs once
+// immediately on start.
+func RunFeatureFlagRefresh(ctx context.Context, pool *pgxpool.Pool, q db.Querier,
+	log cmd.Logger, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		// Flags keep their last known values when the refresh fails
+		if err := RefreshFeatureFlags(ctx, pool, q); err != nil {
+			log.Error("[JOB]: Failed to refresh feature flags", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func RefreshFeatureFlags(ctx context.Context, conn db.DBTX, q db.Querier) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	rows, err := q.FetchFeatureFlagsQuery(ctx, conn)
+	if err != nil {
+		return err
+	}
+	flags := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		flags[row.Name] = row.Enabled
+	}
+	pkg.SetDBFeatureFlags(flags)
+	return nil
+}
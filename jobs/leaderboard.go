@@ -0,0 +1,46 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/IAmRiteshKoushik/pulse/cmd"
+	db "github.com/IAmRiteshKoushik/pulse/db/gen"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// The leaderboard is copied once per UTC day, checking every interval until
+// ctx is cancelled. Days already captured, by this or another instance, are
+// left untouched.
+func RunLeaderboardSnapshot(ctx context.Context, pool *pgxpool.Pool, q db.Querier,
+	log cmd.Logger, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		day := time.Now().UTC().Truncate(24 * time.Hour)
+		created, err := CreateLeaderboardSnapshot(ctx, pool, q, day)
+		if err != nil {
+			log.Error("[JOB]: Failed to snapshot leaderboard", err)
+		} else if created > 0 {
+			log.Info(fmt.Sprintf("[JOB]: Snapshotted leaderboard of %s with %d user(s)",
+				day.Format(time.DateOnly), created))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func CreateLeaderboardSnapshot(ctx context.Context, conn db.DBTX, q db.Querier,
+	day time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	return q.CreateLeaderboardSnapshotQuery(ctx, conn, pgtype.Date{Time: day, Valid: true})
+}
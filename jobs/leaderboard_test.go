@@ -0,0 +1,135 @@
+package jobs
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type leaderboardUser struct {
+	ghUsername string
+	bounty     int32
+	active     bool
+}
+
+type snapshotEntry struct {
+	position   int32
+	rank       int32
+	ghUsername string
+	bounty     int32
+}
+
+// Rows of user_account and leaderboard_snapshots, copied the way
+// CreateLeaderboardSnapshotQuery copies them
+type leaderboardTable struct {
+	users     []leaderboardUser
+	snapshots map[string][]snapshotEntry // by snapshot date
+}
+
+func (l *leaderboardTable) querier() *fakeQuerier {
+	return &fakeQuerier{
+		createSnapshot: func(snapshotDate pgtype.Date) (int64, error) {
+			date := snapshotDate.Time.Format(time.DateOnly)
+			if _, ok := l.snapshots[date]; ok {
+				return 0, nil
+			}
+			var active []leaderboardUser
+			for _, u := range l.users {
+				if u.active {
+					active = append(active, u)
+				}
+			}
+			slices.SortFunc(active, func(a, b leaderboardUser) int {
+				return cmp.Or(cmp.Compare(b.bounty, a.bounty), cmp.Compare(a.ghUsername, b.ghUsername))
+			})
+			entries := make([]snapshotEntry, len(active))
+			for i, u := range active {
+				rank := int32(i + 1)
+				if i > 0 && u.bounty == active[i-1].bounty {
+					rank = entries[i-1].rank
+				}
+				entries[i] = snapshotEntry{position: int32(i + 1), rank: rank, ghUsername: u.ghUsername, bounty: u.bounty}
+			}
+			l.snapshots[date] = entries
+			return int64(len(entries)), nil
+		},
+	}
+}
+
+func TestCreateLeaderboardSnapshot(t *testing.T) {
+	table := &leaderboardTable{
+		users: []leaderboardUser{
+			{ghUsername: "hubot", bounty: 40, active: true},
+			{ghUsername: "octocat", bounty: 90, active: true},
+			{ghUsername: "monalisa", bounty: 40, active: true},
+			{ghUsername: "suspended", bounty: 500, active: false},
+		},
+		snapshots: map[string][]snapshotEntry{},
+	}
+	q := table.querier()
+	monday := time.Date(2026, time.October, 12, 0, 0, 0, 0, time.UTC)
+
+	created, err := CreateLeaderboardSnapshot(context.Background(), nil, q, monday)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created != 3 {
+		t.Errorf("created %d entries, want 3", created)
+	}
+	// Ties share a rank but keep a distinct position to page by
+	want := []snapshotEntry{
+		{position: 1, rank: 1, ghUsername: "octocat", bounty: 90},
+		{position: 2, rank: 2, ghUsername: "hubot", bounty: 40},
+		{position: 3, rank: 2, ghUsername: "monalisa", bounty: 40},
+	}
+	if got := table.snapshots["2026-10-12"]; !slices.Equal(got, want) {
+		t.Errorf("snapshot = %+v, want %+v", got, want)
+	}
+
+	// Later runs on the same day leave the snapshot as it was taken
+	table.users[1].bounty = 10
+	created, err = CreateLeaderboardSnapshot(context.Background(), nil, q, monday)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created != 0 || !slices.Equal(table.snapshots["2026-10-12"], want) {
+		t.Errorf("second run created %d, snapshot = %+v", created, table.snapshots["2026-10-12"])
+	}
+
+	// The next day is captured on its own
+	created, err = CreateLeaderboardSnapshot(context.Background(), nil, q, monday.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := table.snapshots["2026-10-13"]; created != 3 || got[0].ghUsername != "hubot" || got[2].ghUsername != "octocat" {
+		t.Errorf("next day created %d, snapshot = %+v", created, got)
+	}
+}
+
+func TestRunLeaderboardSnapshot(t *testing.T) {
+	table := &leaderboardTable{
+		users:     []leaderboardUser{{ghUsername: "octocat", bounty: 90, active: true}},
+		snapshots: map[string][]snapshotEntry{},
+	}
+	log := &fakeLogger{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Snapshots the current UTC day once on start, then returns as ctx is done
+	RunLeaderboardSnapshot(ctx, nil, table.querier(), log, time.Hour)
+
+	today := time.Now().UTC().Format(time.DateOnly)
+	if _, ok := table.snapshots[today]; !ok || len(table.snapshots) != 1 {
+		t.Errorf("snapshots taken of %v, want %s", slices.Collect(maps.Keys(table.snapshots)), today)
+	}
+	want := []string{fmt.Sprintf("[JOB]: Snapshotted leaderboard of %s with 1 user(s)", today)}
+	if !slices.Equal(log.infos, want) {
+		t.Errorf("logged %q, want %q", log.infos, want)
+	}
+}
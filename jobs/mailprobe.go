@@ -0,0 +1,35 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/IAmRiteshKoushik/pulse/cmd"
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+)
+
+// The mail provider is probed periodically until ctx is cancelled, feeding
+// the readiness check and the pulse_mail_provider_up gauge. Only changes in
+// reachability are logged. Runs once immediately on start.
+func RunMailProbe(ctx context.Context, log cmd.Logger, probe func() error,
+	interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := probe()
+		wasReachable := pkg.MailReachable()
+		pkg.SetMailReachable(err == nil)
+		if err != nil && wasReachable {
+			log.Error("[JOB]: Mail provider is unreachable", err)
+		} else if err == nil && !wasReachable {
+			log.Info("[JOB]: Mail provider is reachable again")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/IAmRiteshKoushik/pulse/cmd"
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRunMailProbe(t *testing.T) {
+	t.Cleanup(func() { pkg.SetMailReachable(true) })
+	pkg.SetMailReachable(true)
+
+	errDown := errors.New("dial tcp: connection refused")
+	results := []error{nil, errDown, errDown, nil, errDown}
+	var gauges []float64 // seen at each probe, as left by the one before
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	probe := func() error {
+		gauges = append(gauges, testutil.ToFloat64(cmd.MailProviderUp))
+		result := results[len(gauges)-1]
+		if len(gauges) == len(results) {
+			cancel()
+		}
+		return result
+	}
+	log := &fakeLogger{}
+
+	RunMailProbe(ctx, log, probe, time.Millisecond)
+
+	if want := []float64{1, 1, 0, 0, 1}; !slices.Equal(gauges, want) {
+		t.Errorf("gauge before each probe = %v, want %v", gauges, want)
+	}
+	if got := testutil.ToFloat64(cmd.MailProviderUp); got != 0 || pkg.MailReachable() {
+		t.Errorf("after the last probe gauge = %v, reachable = %v, want 0 and false", got, pkg.MailReachable())
+	}
+	// Only changes in reachability are logged
+	want := []string{"[JOB]: Mail provider is unreachable", "[JOB]: Mail provider is unreachable"}
+	if !slices.Equal(log.errors, want) {
+		t.Errorf("logged errors %q, want %q", log.errors, want)
+	}
+	if want := []string{"[JOB]: Mail provider is reachable again"}; !slices.Equal(log.infos, want) {
+		t.Errorf("logged %q, want %q", log.infos, want)
+	}
+}
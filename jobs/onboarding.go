@@ -0,0 +1,46 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/IAmRiteshKoushik/pulse/cmd"
+	db "github.com/IAmRiteshKoushik/pulse/db/gen"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Registrations which were never verified, and completed ones, are purged
+// periodically until ctx is cancelled. Runs once immediately on start.
+func RunOnboardingCleanup(ctx context.Context, pool *pgxpool.Pool, q db.Querier,
+	log cmd.Logger, ttl, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := PurgeAbandonedRegistrations(ctx, pool, q, ttl)
+		if err != nil {
+			log.Error("[JOB]: Failed to purge abandoned registrations", err)
+		} else {
+			log.Info(fmt.Sprintf("[JOB]: Purged %d abandoned or completed registration(s)", purged))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func PurgeAbandonedRegistrations(ctx context.Context, conn db.DBTX, q db.Querier,
+	ttl time.Duration) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	return q.PurgeAbandonedRegistrationsQuery(ctx, conn, pgtype.Interval{
+		Microseconds: ttl.Microseconds(),
+		Valid:        true,
+	})
+}
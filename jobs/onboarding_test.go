@@ -0,0 +1,131 @@
+package jobs
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type registration struct {
+	ghUsername string
+	state      string
+	createdAt  time.Time
+	verifiedAt *time.Time
+}
+
+// Rows of user_onboarding, purged the way PurgeAbandonedRegistrationsQuery
+// purges them
+type onboardingTable struct {
+	rows []registration
+}
+
+func (o *onboardingTable) querier() *fakeQuerier {
+	return &fakeQuerier{
+		purgeAbandoned: func(ttl pgtype.Interval) (int64, error) {
+			cutoff := time.Now().Add(-time.Duration(ttl.Microseconds) * time.Microsecond)
+			var purged int64
+			o.rows = slices.DeleteFunc(o.rows, func(r registration) bool {
+				at := r.createdAt
+				if r.state == "onboarded" && r.verifiedAt != nil {
+					at = *r.verifiedAt
+				}
+				if (r.state == "pending_otp" || r.state == "onboarded") && at.Before(cutoff) {
+					purged++
+					return true
+				}
+				return false
+			})
+			return purged, nil
+		},
+	}
+}
+
+func (o *onboardingTable) usernames() []string {
+	var usernames []string
+	for _, r := range o.rows {
+		usernames = append(usernames, r.ghUsername)
+	}
+	return usernames
+}
+
+func TestPurgeAbandonedRegistrations(t *testing.T) {
+	now := time.Now()
+	ago := func(d time.Duration) *time.Time {
+		at := now.Add(-d)
+		return &at
+	}
+	rows := []registration{
+		{ghUsername: "old-pending", state: "pending_otp", createdAt: *ago(25 * time.Hour)},
+		{ghUsername: "new-pending", state: "pending_otp", createdAt: *ago(23 * time.Hour)},
+		{ghUsername: "old-onboarded", state: "onboarded", createdAt: *ago(48 * time.Hour), verifiedAt: ago(25 * time.Hour)},
+		// Verified recently, from a registration started long ago
+		{ghUsername: "new-onboarded", state: "onboarded", createdAt: *ago(48 * time.Hour), verifiedAt: ago(time.Hour)},
+		{ghUsername: "just-started", state: "pending_otp", createdAt: now},
+	}
+
+	tests := []struct {
+		name       string
+		ttl        time.Duration
+		wantPurged int64
+		wantKept   []string
+	}{
+		{
+			name:       "day old",
+			ttl:        24 * time.Hour,
+			wantPurged: 2,
+			wantKept:   []string{"new-pending", "new-onboarded", "just-started"},
+		},
+		{
+			name:       "week old",
+			ttl:        7 * 24 * time.Hour,
+			wantPurged: 0,
+			wantKept:   []string{"old-pending", "new-pending", "old-onboarded", "new-onboarded", "just-started"},
+		},
+		{
+			name:       "hour and a half old",
+			ttl:        90 * time.Minute,
+			wantPurged: 3,
+			wantKept:   []string{"new-onboarded", "just-started"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := &onboardingTable{rows: slices.Clone(rows)}
+
+			purged, err := PurgeAbandonedRegistrations(context.Background(), nil, table.querier(), tt.ttl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if purged != tt.wantPurged {
+				t.Errorf("purged %d, want %d", purged, tt.wantPurged)
+			}
+			if kept := table.usernames(); !slices.Equal(kept, tt.wantKept) {
+				t.Errorf("kept %v, want %v", kept, tt.wantKept)
+			}
+		})
+	}
+}
+
+func TestRunOnboardingCleanup(t *testing.T) {
+	table := &onboardingTable{rows: []registration{
+		{ghUsername: "old-pending", state: "pending_otp", createdAt: time.Now().Add(-25 * time.Hour)},
+		{ghUsername: "new-pending", state: "pending_otp", createdAt: time.Now()},
+	}}
+	log := &fakeLogger{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Purges once on start, then returns as ctx is done
+	RunOnboardingCleanup(ctx, nil, table.querier(), log, 24*time.Hour, time.Hour)
+
+	if want := []string{"new-pending"}; !slices.Equal(table.usernames(), want) {
+		t.Errorf("kept %v, want %v", table.usernames(), want)
+	}
+	if want := []string{"[JOB]: Purged 1 abandoned or completed registration(s)"}; !slices.Equal(log.infos, want) {
+		t.Errorf("logged %q, want %q", log.infos, want)
+	}
+}
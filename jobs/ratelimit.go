@@ -0,0 +1,43 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/IAmRiteshKoushik/pulse/cmd"
+	db "github.com/IAmRiteshKoushik/pulse/db/gen"
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Rate limit windows older than retention are purged periodically until ctx
+// is cancelled. Retention must exceed the longest configured window.
+func RunRateLimitCleanup(ctx context.Context, pool *pgxpool.Pool, q db.Querier,
+	log cmd.Logger, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := PurgeRateLimits(ctx, pool, q, retention)
+		if err != nil {
+			log.Error("[JOB]: Failed to purge rate limit windows", err)
+		} else {
+			log.Info(fmt.Sprintf("[JOB]: Purged %d rate limit window(s)", purged))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func PurgeRateLimits(ctx context.Context, conn db.DBTX, q db.Querier,
+	retention time.Duration) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	return q.PurgeRateLimitsQuery(ctx, conn, pkg.NewTimestamp(time.Now().UTC().Add(-retention)))
+}
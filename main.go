@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"log"
@@ -11,22 +13,45 @@ import (
 
 	cmd "github.com/IAmRiteshKoushik/pulse/cmd"
 	c "github.com/IAmRiteshKoushik/pulse/controllers"
+	db "github.com/IAmRiteshKoushik/pulse/db/gen"
+	"github.com/IAmRiteshKoushik/pulse/jobs"
 	mw "github.com/IAmRiteshKoushik/pulse/middleware"
-	"github.com/gin-contrib/cors"
+	"github.com/IAmRiteshKoushik/pulse/pkg"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func StartApp() {
 	failMsg := "Could not initialize app\n%w"
 
-	// Initialize global environment variables
-	env, err := cmd.NewEnvConfig()
+	// Initialize global environment variables, failing on any invalid setting
+	env, err := cmd.ValidateConfig()
 	if err != nil {
 		panic(fmt.Errorf(failMsg, err))
 	}
 	cmd.EnvVars = env
 	log.Println("[OK]: Environment variables configured successfully")
 
+	// Initialize mail subject templates
+	if err := pkg.InitMailSubjects(cmd.EnvVars.AppName, cmd.EnvVars.MailSubjects); err != nil {
+		panic(fmt.Errorf(failMsg, err))
+	}
+	log.Println("[OK]: Mail subjects configured successfully")
+
+	// Initialize feature flags
+	if err := pkg.InitFeatureFlags(cmd.EnvVars.FeatureFlags); err != nil {
+		panic(fmt.Errorf(failMsg, err))
+	}
+	log.Println("[OK]: Feature flags configured successfully")
+
+	// Initialize GitHub OAuth
+	cmd.OAuthInit()
+	log.Println("[OK]: GitHub OAuth configured successfully")
+	cmd.GoogleOAuthInit()
+	if err := cmd.WebAuthnInit(); err != nil {
+		panic(fmt.Errorf(failMsg, err))
+	}
+
 	// Initialize logger
 	f, err := os.OpenFile("app.log", os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
 	if err != nil {
@@ -42,11 +67,44 @@ func StartApp() {
 		panic(fmt.Errorf(failMsg, err))
 	}
 	cmd.Log.Info("[OK]: DB initialized successfully.")
+	cmd.DBReplicaPool, err = cmd.InitReplicaDB()
+	if err != nil {
+		panic(fmt.Errorf(failMsg, err))
+	}
+	if cmd.DBReplicaPool != nil {
+		cmd.Log.Info("[OK]: DB replica initialized successfully.")
+	}
+
+	// Initialize metrics registry
+	cmd.MetricsRegistry = cmd.InitMetrics()
+	cmd.Log.Info("[OK]: Metrics registry configured successfully.")
+
+	// Background jobs stop once the server exits
+	jobCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	go jobs.RunOnboardingCleanup(jobCtx, cmd.DBPool, db.New(), cmd.Log,
+		cmd.EnvVars.OnboardingTTL, cmd.EnvVars.OnboardingCleanupInterval)
+	go jobs.RunFeatureFlagRefresh(jobCtx, cmd.DBPool, db.New(), cmd.Log,
+		cmd.EnvVars.FeatureFlagsRefresh)
+	if cmd.EnvVars.MailProbeInterval > 0 {
+		go jobs.RunMailProbe(jobCtx, cmd.Log, pkg.ProbeMail, cmd.EnvVars.MailProbeInterval)
+	}
+	go jobs.RunLeaderboardSnapshot(jobCtx, cmd.DBPool, db.New(), cmd.Log, time.Hour)
+	if cmd.EnvVars.BountyReconcileInterval > 0 {
+		go jobs.RunBountyReconcile(jobCtx, cmd.DBPool, db.New(), cmd.Log,
+			cmd.EnvVars.BountyReconcileInterval, cmd.EnvVars.BountyReconcileDryRun)
+	}
+	if cmd.EnvVars.RateLimitBackend == "postgres" {
+		// Windows are at most an hour long, and the previous one is still read
+		go jobs.RunRateLimitCleanup(jobCtx, cmd.DBPool, db.New(), cmd.Log,
+			2*time.Hour, 10*time.Minute)
+	}
+	cmd.Log.Info("[OK]: Background jobs started successfully.")
 
 	// Starting the server
 	ginLogs, err := os.Create("gin.log")
 	if err != nil {
-		cmd.Log.Fatal("Error creating log file for Gin", err)
+		panic(fmt.Errorf(failMsg, err))
 	}
 	defer ginLogs.Close()
 	multiWriter := io.MultiWriter(os.Stdout, ginLogs)
@@ -54,16 +112,10 @@ func StartApp() {
 	gin.DefaultErrorWriter = multiWriter
 	gin.SetMode(gin.ReleaseMode)
 
-	router := gin.New()
-	router.Use(mw.RecoveryMiddleware)
-	router.Use(gin.Logger())
-	router.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"*"},
-		AllowHeaders:     []string{"*"},
-		AllowCredentials: true,
-		MaxAge:           12 * time.Hour,
-	}))
+	router, adminRouter, err := c.NewRouters(cmd.EnvVars)
+	if err != nil {
+		panic(fmt.Errorf(failMsg, err))
+	}
 
 	router.GET("/test", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
@@ -76,25 +128,36 @@ func StartApp() {
 		return
 	})
 
-	v1 := router.Group("/api/v1")
+	adminRouter.GET("/metrics", gin.WrapH(
+		promhttp.HandlerFor(cmd.MetricsRegistry, promhttp.HandlerOpts{}),
+	))
 
-	v1.POST("/auth/github", c.InitiateGitHubOAuth)
-	v1.POST("/auth/github/callback", c.CompleteGitHubOAuth)
-	v1.POST("/auth/register", c.RegisterUserAccount)
-	v1.POST("/auth/register/otp/verify", mw.Auth, c.RegisterUserOtpVerify)
-	v1.GET("/auth/register/otp/resend", mw.Auth, c.RegisterUserOtpResend)
-	v1.GET("/auth/refresh", c.RegenerateToken)
+	mw.SetMaintenance(cmd.EnvVars.MaintenanceMode)
+	app := c.NewApp(cmd.EnvVars, cmd.Log, pkg.WrapPool(cmd.DBPool), pkg.WrapPool(cmd.DBReplicaPool),
+		cmd.GithubOAuthConfig, cmd.GoogleOAuthConfig, cmd.WebAuthnConfig, cmd.GithubHTTPClient,
+		pkg.NewSMSSender(cmd.EnvVars, &http.Client{Timeout: 10 * time.Second}))
+	app.RegisterRoutes(router, adminRouter)
 
-	v1.GET("/profile", mw.Auth, c.FetchUserAccount)
-	v1.GET("/leaderboard", mw.Auth, c.FetchLeaderboard)
-	v1.GET("/projects", mw.Auth, c.FetchProjects)
-	v1.GET("/issues/:projectId", mw.Auth, c.FetchIssues)
-	v1.GET("/updates/live", mw.Auth, c.FetchLiveUpdates)
+	var tlsConfig *tls.Config
+	if cmd.EnvVars.TLSEnabled() {
+		reloader, err := cmd.NewCertReloader(cmd.EnvVars.TLSCertFile, cmd.EnvVars.TLSKeyFile)
+		if err != nil {
+			panic(fmt.Errorf(failMsg, err))
+		}
+		go reloader.WatchSignals(jobCtx, cmd.Log)
+		tlsConfig = cmd.NewTLSConfig(reloader)
+	}
 
-	port := strconv.Itoa(cmd.EnvVars.Port)
-	cmd.Log.Info("[OK]: Server configured and starting on PORT " + port)
-	err = router.Run(":" + port)
-	if err != nil {
+	if cmd.EnvVars.AdminPort != 0 {
+		cmd.Log.Info("[OK]: Admin server configured and starting on PORT " + strconv.Itoa(cmd.EnvVars.AdminPort))
+		go func() {
+			if err := c.Serve(adminRouter, cmd.EnvVars.AdminPort, tlsConfig); err != nil {
+				panic(err)
+			}
+		}()
+	}
+	cmd.Log.Info("[OK]: Server configured and starting on PORT " + strconv.Itoa(cmd.EnvVars.Port))
+	if err := c.Serve(router, cmd.EnvVars.Port, tlsConfig); err != nil {
 		panic(err)
 	}
 }
@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/IAmRiteshKoushik/pulse/cmd"
+	db "github.com/IAmRiteshKoushik/pulse/db/gen"
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	"github.com/gin-gonic/gin"
+)
+
+// Admin must be chained after Auth. Maintainers are the only administrators,
+// and only with an access token: temp tokens are issued mid-registration for
+// whichever username was entered, before anything about it is verified.
+func Admin(env *cmd.EnvConfig, pool pkg.Pool, q db.Querier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		admin(c, env, pool, q)
+	}
+}
+
+func admin(c *gin.Context, env *cmd.EnvConfig, pool pkg.Pool, q db.Querier) {
+	if claims, ok := pkg.GrabClaims(c); !ok || claims.Subject != "access_token" {
+		cmd.Log.Warn(
+			fmt.Sprintf("Non-access token attempted admin access at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"message": "Server refused to process the request",
+		})
+		return
+	}
+
+	username, ok := pkg.GrabUsername(c)
+	if !ok {
+		cmd.Log.Warn(
+			fmt.Sprintf("Failed to extract username from token at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"message": "Oops! Something happened. Please try again later.",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), env.TimeoutFor("admin"))
+	defer cancel()
+
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		pkg.DbAcquireError(c, err)
+		c.Abort()
+		return
+	}
+	defer conn.Release()
+
+	isMaintainer, err := q.CheckIsMaintainerQuery(ctx, conn, username)
+	if err != nil {
+		pkg.DbError(c, err)
+		c.Abort()
+		return
+	}
+	if !isMaintainer {
+		cmd.Log.Warn(
+			fmt.Sprintf("Non-admin user attempted access at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"message": "Server refused to process the request",
+		})
+		return
+	}
+
+	c.Next()
+}
@@ -9,8 +9,22 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func Auth(c *gin.Context) {
+// Auth accepts access and temp tokens, from the Authorization header or the
+// access token cookie
+func Auth(env *cmd.EnvConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		auth(c, env)
+	}
+}
+
+func auth(c *gin.Context, env *cmd.EnvConfig) {
 	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		// Browser clients logged in via OAuth redirect carry the token in a cookie
+		if cookie, err := c.Cookie(pkg.AccessTokenCookie); err == nil && cookie != "" {
+			authHeader = "Bearer " + cookie
+		}
+	}
 	if authHeader == "" {
 		cmd.Log.Warn(fmt.Sprintf("Authorization failed at %s %s", c.Request.Method, c.FullPath()))
 		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
@@ -30,19 +44,15 @@ func Auth(c *gin.Context) {
 		return
 	}
 
-	claims, err := pkg.VerifyToken(tokenString)
+	claims, err := pkg.VerifyToken(env, tokenString)
 	if err != nil {
-		cmd.Log.Error(fmt.Sprintf("Authorization failed at %s %s", c.Request.Method, c.FullPath()),
-			err)
-		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-			"message": "Invalid or expired token",
-		})
+		pkg.TokenError(c, err)
 		return
 	}
 
 	validIssuer := claims.Issuer == "api.season-of-code"
 	validSub := claims.Subject == "access_token" || claims.Subject == "temp_token"
-	validAudience := len(claims.Audience) == 1
+	validAudience := claims.ValidAudience(env)
 	if !validIssuer || !validSub || !validAudience {
 		cmd.Log.Error(
 			fmt.Sprintf("Tampered token sent at %s %s", c.Request.Method, c.FullPath()),
@@ -53,7 +63,9 @@ func Auth(c *gin.Context) {
 		return
 	}
 
-	c.Set("email", claims.ID)
-	c.Set("username", claims.Audience[0])
+	// Read back with pkg.GrabClaims, pkg.GrabEmail and pkg.GrabUsername
+	c.Set("claims", claims)
+	// Tokens issued before usernames were normalized may carry any casing
+	c.Set("username", pkg.NormalizeGhUsername(claims.Audience[0]))
 	c.Next()
 }
@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type etagWriter struct {
+	gin.ResponseWriter
+	buf bytes.Buffer
+}
+
+func (w *etagWriter) Write(data []byte) (int, error) {
+	return w.buf.Write(data)
+}
+
+func (w *etagWriter) WriteString(s string) (int, error) {
+	return w.buf.WriteString(s)
+}
+
+// Cache buffers successful GET responses to tag them with an ETag, answering
+// 304 when the client already holds the same body, and sets Cache-Control
+// with maxAge. Responses specific to the signed in user must not pass
+// public, so that shared caches keep them out.
+func Cache(maxAge time.Duration, public bool) gin.HandlerFunc {
+	visibility := "private"
+	if public {
+		visibility = "public"
+	}
+
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			c.Next()
+			return
+		}
+
+		original := c.Writer
+		w := &etagWriter{ResponseWriter: original}
+		c.Writer = w
+		c.Next()
+		c.Writer = original
+
+		if w.Status() != http.StatusOK || w.buf.Len() == 0 {
+			original.Write(w.buf.Bytes())
+			return
+		}
+
+		// Weak as the compression middleware may re-encode the same body
+		sum := sha256.Sum256(w.buf.Bytes())
+		etag := `W/"` + hex.EncodeToString(sum[:16]) + `"`
+		header := original.Header()
+		header.Set("ETag", etag)
+		if seconds := int(maxAge.Seconds()); seconds > 0 {
+			header.Set("Cache-Control", fmt.Sprintf("%s, max-age=%d", visibility, seconds))
+		} else {
+			header.Set("Cache-Control", visibility+", no-cache")
+		}
+
+		if etagMatches(c.GetHeader("If-None-Match"), etag) {
+			header.Del("Content-Type")
+			header.Del("Content-Length")
+			original.WriteHeader(http.StatusNotModified)
+			return
+		}
+		original.Write(w.buf.Bytes())
+	}
+}
+
+// Weak comparison as per RFC 9110, which is what If-None-Match calls for
+func etagMatches(ifNoneMatch string, etag string) bool {
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCache(t *testing.T) {
+	body := `{"leaderboard":[{"github_username":"octocat","bounty":40}]}`
+	router := gin.New()
+	router.GET("/leaderboard", Cache(time.Minute, false), func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json", []byte(body))
+	})
+	serve := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/leaderboard", nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	first := serve("")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || first.Body.String() != body {
+		t.Fatalf("first response = %d %s", first.Code, first.Body.String())
+	}
+	if !strings.HasPrefix(etag, `W/"`) || !strings.HasSuffix(etag, `"`) {
+		t.Fatalf("ETag = %q, want a weak tag", etag)
+	}
+
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		wantStatus  int
+	}{
+		{name: "matching", ifNoneMatch: etag, wantStatus: http.StatusNotModified},
+		{name: "matching without weak prefix", ifNoneMatch: strings.TrimPrefix(etag, "W/"), wantStatus: http.StatusNotModified},
+		{name: "among others", ifNoneMatch: `W/"stale", ` + etag, wantStatus: http.StatusNotModified},
+		{name: "any", ifNoneMatch: "*", wantStatus: http.StatusNotModified},
+		{name: "stale", ifNoneMatch: `W/"0123456789abcdef"`, wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(tt.ifNoneMatch)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("ETag"); got != etag {
+				t.Errorf("ETag = %q, want %q", got, etag)
+			}
+			if got := w.Header().Get("Cache-Control"); got != "private, max-age=60" {
+				t.Errorf("Cache-Control = %q", got)
+			}
+			wantBody := body
+			if tt.wantStatus == http.StatusNotModified {
+				wantBody = ""
+			}
+			if w.Body.String() != wantBody {
+				t.Errorf("body = %q, want %q", w.Body.String(), wantBody)
+			}
+		})
+	}
+
+	// A changed body gets a fresh tag, so the one held by the client no
+	// longer matches
+	body = `{"leaderboard":[{"github_username":"octocat","bounty":50}]}`
+	w := serve(etag)
+	if w.Code != http.StatusOK || w.Body.String() != body {
+		t.Fatalf("changed body: %d %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("ETag") == etag {
+		t.Error("changed body kept the same ETag")
+	}
+}
+
+func TestCacheControl(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxAge   time.Duration
+		public   bool
+		status   int
+		want     string
+		wantETag bool
+	}{
+		{name: "public", maxAge: 5 * time.Minute, public: true, status: http.StatusOK,
+			want: "public, max-age=300", wantETag: true},
+		{name: "private", maxAge: time.Minute, status: http.StatusOK,
+			want: "private, max-age=60", wantETag: true},
+		{name: "zero max age revalidates", status: http.StatusOK,
+			want: "public, no-cache", public: true, wantETag: true},
+		{name: "errors are not cached", maxAge: time.Minute, public: true, status: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/users/octocat", Cache(tt.maxAge, tt.public), func(c *gin.Context) {
+				c.String(tt.status, "octocat")
+			})
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/octocat", nil))
+
+			if w.Code != tt.status || w.Body.String() != "octocat" {
+				t.Fatalf("response = %d %q", w.Code, w.Body.String())
+			}
+			if got := w.Header().Get("Cache-Control"); got != tt.want {
+				t.Errorf("Cache-Control = %q, want %q", got, tt.want)
+			}
+			if got := w.Header().Get("ETag") != ""; got != tt.wantETag {
+				t.Errorf("ETag set = %v, want %v", got, tt.wantETag)
+			}
+		})
+	}
+}
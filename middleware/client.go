@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/IAmRiteshKoushik/pulse/cmd"
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	"github.com/gin-gonic/gin"
+)
+
+// RequireClient must be chained after Auth. Only tokens issued to one of the
+// given client applications are let through, or any token when none are given.
+func RequireClient(clientIds ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if len(clientIds) == 0 {
+			c.Next()
+			return
+		}
+		claims, ok := pkg.GrabClaims(c)
+		if !ok || !slices.Contains(clientIds, claims.ClientId()) {
+			cmd.Log.Warn(
+				fmt.Sprintf("Token not issued to an allowed client at %s %s",
+					c.Request.Method, c.FullPath()))
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"message": "Token was not issued for this client application",
+			})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/IAmRiteshKoushik/pulse/cmd"
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequireClient(t *testing.T) {
+	env := &cmd.EnvConfig{TokenSecret: "test-secret", ClientIds: []string{"web", "mobile", "cli"}}
+
+	tests := []struct {
+		name       string
+		clientId   string // the token was issued to
+		required   []string
+		wantStatus int
+	}{
+		{name: "required client", clientId: "web", required: []string{"web"}, wantStatus: http.StatusOK},
+		{name: "one of the required clients", clientId: "cli", required: []string{"web", "cli"}, wantStatus: http.StatusOK},
+		{name: "another client", clientId: "mobile", required: []string{"web"}, wantStatus: http.StatusForbidden},
+		{name: "not bound to a client", required: []string{"web"}, wantStatus: http.StatusForbidden},
+		{name: "none required", clientId: "mobile", wantStatus: http.StatusOK},
+		{name: "none required, not bound", wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := pkg.CreateToken(env, "octocat", "octo@example.com", "access_token",
+				pkg.WithClient(tt.clientId))
+			if err != nil {
+				t.Fatal(err)
+			}
+			router := gin.New()
+			router.GET("/", Auth(env), RequireClient(tt.required...), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestAuthWithdrawnClient(t *testing.T) {
+	env := &cmd.EnvConfig{TokenSecret: "test-secret", ClientIds: []string{"web", "mobile"}}
+	token, err := pkg.CreateToken(env, "octocat", "octo@example.com", "access_token", pkg.WithClient("mobile"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Tokens of a client no longer registered are refused on any route
+	env.ClientIds = []string{"web"}
+	router := gin.New()
+	router.GET("/", Auth(env), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
@@ -0,0 +1,153 @@
+package middleware
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type compressWriter struct {
+	gin.ResponseWriter
+	encoding string
+	minSize  int
+	buf      []byte
+	enc      io.WriteCloser // set once compression has started
+	decided  bool
+}
+
+// Compress encodes responses of at least minSize bytes with gzip or deflate
+// as negotiated through Accept-Encoding. Responses which already carry a
+// Content-Encoding or are not text-like are passed through untouched.
+func Compress(minSize int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Writer.Header().Add("Vary", "Accept-Encoding")
+		encoding := negotiateEncoding(c.GetHeader("Accept-Encoding"))
+		if encoding == "" || c.Request.Method == "HEAD" {
+			c.Next()
+			return
+		}
+
+		w := &compressWriter{
+			ResponseWriter: c.Writer,
+			encoding:       encoding,
+			minSize:        minSize,
+		}
+		c.Writer = w
+		defer w.finish()
+		c.Next()
+	}
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if w.decided {
+		if w.enc != nil {
+			return w.enc.Write(data)
+		}
+		return w.ResponseWriter.Write(data)
+	}
+	w.buf = append(w.buf, data...)
+	if len(w.buf) >= w.minSize {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Streaming handlers flush early, in which case small buffered output is sent
+// as is
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.decide(false)
+	}
+	if f, ok := w.enc.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *compressWriter) decide(compress bool) error {
+	w.decided = true
+	header := w.Header()
+	if compress && header.Get("Content-Encoding") == "" && isCompressible(header.Get("Content-Type")) {
+		header.Set("Content-Encoding", w.encoding)
+		header.Del("Content-Length")
+		if w.encoding == "gzip" {
+			w.enc = gzip.NewWriter(w.ResponseWriter)
+		} else {
+			w.enc, _ = flate.NewWriter(w.ResponseWriter, flate.DefaultCompression)
+		}
+		_, err := w.enc.Write(w.buf)
+		w.buf = nil
+		return err
+	}
+	_, err := w.ResponseWriter.Write(w.buf)
+	w.buf = nil
+	return err
+}
+
+func (w *compressWriter) finish() {
+	if !w.decided {
+		if len(w.buf) == 0 {
+			return
+		}
+		w.decide(false)
+	}
+	if w.enc != nil {
+		w.enc.Close()
+	}
+}
+
+// Picks gzip over deflate when both are acceptable. Entries with q=0 are
+// treated as refused, and * only stands for encodings not listed explicitly.
+func negotiateEncoding(acceptEncoding string) string {
+	accepts := map[string]bool{}
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		accepted := true
+		if q, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			if weight, err := strconv.ParseFloat(q, 64); err != nil || weight == 0 {
+				accepted = false
+			}
+		}
+		accepts[name] = accepted
+	}
+	acceptable := func(encoding string) bool {
+		if accepted, listed := accepts[encoding]; listed {
+			return accepted
+		}
+		return accepts["*"]
+	}
+	switch {
+	case acceptable("gzip"):
+		return "gzip"
+	case acceptable("deflate"):
+		return "deflate"
+	}
+	return ""
+}
+
+// Event streams are excluded, compressing them would hold events back until
+// the encoder fills a block
+func isCompressible(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	if strings.HasPrefix(contentType, "text/event-stream") {
+		return false
+	}
+	return strings.HasPrefix(contentType, "application/json") ||
+		strings.HasPrefix(contentType, "text/") ||
+		strings.HasPrefix(contentType, "application/javascript") ||
+		strings.HasPrefix(contentType, "application/xml")
+}
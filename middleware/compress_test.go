@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		acceptEncoding string
+		want           string
+	}{
+		{acceptEncoding: "", want: ""},
+		{acceptEncoding: "gzip", want: "gzip"},
+		{acceptEncoding: "deflate", want: "deflate"},
+		{acceptEncoding: "deflate, gzip", want: "gzip"},
+		{acceptEncoding: "GZIP;q=0.5", want: "gzip"},
+		{acceptEncoding: "gzip;q=0, deflate", want: "deflate"},
+		{acceptEncoding: "gzip;q=0.0, deflate;q=0", want: ""},
+		{acceptEncoding: "br", want: ""},
+		{acceptEncoding: "*", want: "gzip"},
+		{acceptEncoding: "gzip;q=0, *", want: "deflate"},
+		{acceptEncoding: "*;q=0", want: ""},
+		{acceptEncoding: "identity", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.acceptEncoding, func(t *testing.T) {
+			if got := negotiateEncoding(tt.acceptEncoding); got != tt.want {
+				t.Errorf("negotiateEncoding(%q) = %q, want %q", tt.acceptEncoding, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompress(t *testing.T) {
+	large := strings.Repeat("pulse ", 200)
+	tests := []struct {
+		name         string
+		contentType  string
+		body         string
+		wantEncoding string
+	}{
+		{name: "large json", contentType: "application/json", body: large, wantEncoding: "gzip"},
+		{name: "below min size", contentType: "application/json", body: "{}"},
+		{name: "not text-like", contentType: "image/png", body: large},
+		{name: "event stream", contentType: "text/event-stream", body: large},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(Compress(512))
+			router.GET("/", func(c *gin.Context) {
+				c.Data(http.StatusOK, tt.contentType, []byte(tt.body))
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if got := w.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Fatalf("Content-Encoding = %q, want %q", got, tt.wantEncoding)
+			}
+			var body io.Reader = w.Body
+			if tt.wantEncoding == "gzip" {
+				zr, err := gzip.NewReader(w.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				body = zr
+			}
+			got, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.body {
+				t.Errorf("body = %q, want %q", got, tt.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"context"
+
+	db "github.com/IAmRiteshKoushik/pulse/db/gen"
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+)
+
+// Answers the queries a test sets up. Any other query panics, as the
+// embedded Querier is nil.
+type fakeQuerier struct {
+	db.Querier
+
+	checkUserExist func(string) (db.CheckUserExistQueryRow, error)
+	hitRateLimit   func(db.HitRateLimitQueryParams) (db.HitRateLimitQueryRow, error)
+}
+
+func (q *fakeQuerier) CheckUserExistQuery(ctx context.Context, conn db.DBTX,
+	ghusername string) (db.CheckUserExistQueryRow, error) {
+	return q.checkUserExist(ghusername)
+}
+
+func (q *fakeQuerier) HitRateLimitQuery(ctx context.Context, conn db.DBTX,
+	arg db.HitRateLimitQueryParams) (db.HitRateLimitQueryRow, error) {
+	return q.hitRateLimit(arg)
+}
+
+// Queries never reach the database, so connections only need to be released
+type fakeConn struct {
+	pkg.Conn
+}
+
+func (fakeConn) Release() {}
+
+type fakePool struct {
+	pkg.Pool
+}
+
+func (fakePool) Acquire(ctx context.Context) (pkg.Conn, error) { return fakeConn{}, nil }
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/IAmRiteshKoushik/pulse/cmd"
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	"github.com/gin-gonic/gin"
+)
+
+// Feature hides the route behind the named feature flag. Disabled routes
+// respond exactly like unknown ones.
+func Feature(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if pkg.FeatureEnabled(name) {
+			c.Next()
+			return
+		}
+
+		cmd.Log.Warn(
+			fmt.Sprintf("Disabled feature %s requested at %s %s",
+				name, c.Request.Method, c.Request.URL.Path))
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"message":    "Requested resource does not exist",
+			"request_id": c.GetString("request_id"),
+		})
+	}
+}
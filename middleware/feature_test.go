@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	"github.com/gin-gonic/gin"
+)
+
+func TestFeature(t *testing.T) {
+	tests := []struct {
+		name       string
+		flags      map[string]bool
+		dbFlags    map[string]bool
+		wantStatus int
+	}{
+		{name: "enabled by default", wantStatus: http.StatusOK},
+		{name: "disabled", flags: map[string]bool{pkg.FeatureBountyBatch: false}, wantStatus: http.StatusNotFound},
+		{name: "enabled from the database", flags: map[string]bool{pkg.FeatureBountyBatch: false},
+			dbFlags: map[string]bool{pkg.FeatureBountyBatch: true}, wantStatus: http.StatusOK},
+		{name: "disabled from the database", dbFlags: map[string]bool{pkg.FeatureBountyBatch: false},
+			wantStatus: http.StatusNotFound},
+		{name: "other feature disabled", flags: map[string]bool{pkg.FeatureExport: false}, wantStatus: http.StatusOK},
+	}
+
+	t.Cleanup(func() { pkg.InitFeatureFlags(nil) })
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := pkg.InitFeatureFlags(tt.flags); err != nil {
+				t.Fatal(err)
+			}
+			if tt.dbFlags != nil {
+				pkg.SetDBFeatureFlags(tt.dbFlags)
+			}
+
+			reached := false
+			router := gin.New()
+			router.POST("/admin/bounty/batch", Feature(pkg.FeatureBountyBatch), func(c *gin.Context) {
+				reached = true
+				c.Status(http.StatusOK)
+			})
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/bounty/batch", nil))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if reached != (tt.wantStatus == http.StatusOK) {
+				t.Errorf("handler reached = %v", reached)
+			}
+			// Indistinguishable from a route that does not exist
+			if tt.wantStatus == http.StatusNotFound &&
+				w.Body.String() != `{"message":"Requested resource does not exist","request_id":""}` {
+				t.Errorf("body = %s", w.Body.String())
+			}
+		})
+	}
+}
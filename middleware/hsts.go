@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HSTS tells browsers to only reach the server over HTTPS for maxAge. It is
+// only registered when the server terminates TLS itself, as browsers ignore
+// the header on plain HTTP responses anyway. includeSubDomains locks every
+// subdomain out of plain HTTP as well, so it has to be asked for.
+func HSTS(maxAge time.Duration, includeSubdomains bool) gin.HandlerFunc {
+	value := "max-age=" + strconv.FormatInt(int64(maxAge.Seconds()), 10)
+	if includeSubdomains {
+		value += "; includeSubDomains"
+	}
+	return func(c *gin.Context) {
+		if c.Request.TLS != nil {
+			c.Header("Strict-Transport-Security", value)
+		}
+		c.Next()
+	}
+}
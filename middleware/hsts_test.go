@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestHSTS(t *testing.T) {
+	tests := []struct {
+		name              string
+		maxAge            time.Duration
+		includeSubdomains bool
+		tls               bool
+		want              string
+	}{
+		{name: "max age only", maxAge: 365 * 24 * time.Hour, tls: true, want: "max-age=31536000"},
+		{name: "subdomains included", maxAge: time.Hour, includeSubdomains: true, tls: true,
+			want: "max-age=3600; includeSubDomains"},
+		{name: "not sent over plain HTTP", maxAge: time.Hour, includeSubdomains: true, want: ""},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(HSTS(tt.maxAge, tt.includeSubdomains))
+			router.GET("/", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if got := w.Header().Get("Strict-Transport-Security"); got != tt.want {
+				t.Errorf("Strict-Transport-Security = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/IAmRiteshKoushik/pulse/cmd"
+	"github.com/gin-gonic/gin"
+)
+
+// Bodies larger than this are not peeked into
+const maxPeekedBody = 64 * 1024
+
+// PeekJSON decodes the JSON body into dst for middleware running ahead of
+// the handler, which still sees the complete body
+func PeekJSON(c *gin.Context, dst any) error {
+	if c.Request.Body == nil {
+		return io.EOF
+	}
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxPeekedBody+1))
+	c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), c.Request.Body))
+	if err != nil {
+		return err
+	}
+	if len(body) > maxPeekedBody {
+		return fmt.Errorf("body larger than %d bytes", maxPeekedBody)
+	}
+	return json.Unmarshal(body, dst)
+}
+
+// RequireJSON rejects requests to JSON endpoints whose body is not declared
+// as JSON, rather than letting them fail later with an unmarshal error
+func RequireJSON(c *gin.Context) {
+	mediaType, _, err := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")) {
+		c.Next()
+		return
+	}
+
+	cmd.Log.Warn(
+		fmt.Sprintf("Unsupported content type at %s %s", c.Request.Method, c.FullPath()))
+	c.AbortWithStatusJSON(http.StatusUnsupportedMediaType, gin.H{
+		"message": "Content-Type must be application/json",
+	})
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequireJSON(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		wantStatus  int
+	}{
+		{name: "json", contentType: "application/json", wantStatus: http.StatusOK},
+		{name: "json with charset", contentType: "application/json; charset=utf-8", wantStatus: http.StatusOK},
+		{name: "mixed case", contentType: "Application/JSON", wantStatus: http.StatusOK},
+		{name: "structured syntax suffix", contentType: "application/merge-patch+json", wantStatus: http.StatusOK},
+		{name: "plain text", contentType: "text/plain", wantStatus: http.StatusUnsupportedMediaType},
+		{name: "form", contentType: "application/x-www-form-urlencoded", wantStatus: http.StatusUnsupportedMediaType},
+		{name: "missing", wantStatus: http.StatusUnsupportedMediaType},
+		{name: "malformed", contentType: "application/json; charset", wantStatus: http.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reached := false
+			router := gin.New()
+			router.POST("/", RequireJSON, func(c *gin.Context) {
+				reached = true
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"otp":"123456"}`))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if reached != (tt.wantStatus == http.StatusOK) {
+				t.Errorf("handler reached = %v", reached)
+			}
+			if tt.wantStatus == http.StatusUnsupportedMediaType &&
+				!strings.Contains(w.Body.String(), "Content-Type must be application/json") {
+				t.Errorf("body = %s", w.Body.String())
+			}
+		})
+	}
+}
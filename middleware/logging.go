@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/IAmRiteshKoushik/pulse/cmd"
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	"github.com/gin-gonic/gin"
+)
+
+// Bodies larger than this are not logged
+const maxLoggedBody = 64 * 1024
+
+// RequestLogger logs a summary of each request with sensitive body fields
+// redacted. Headers are never logged as they carry credentials.
+func RequestLogger(c *gin.Context) {
+	start := time.Now()
+
+	var body []byte
+	if c.Request.Body != nil {
+		body, _ = io.ReadAll(io.LimitReader(c.Request.Body, maxLoggedBody+1))
+		// Handlers must still see the complete body
+		c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), c.Request.Body))
+	}
+
+	c.Next()
+
+	loggedBody := "-"
+	if len(body) > maxLoggedBody {
+		loggedBody = "[body too large]"
+	} else if len(body) > 0 {
+		if redactedBody, ok := pkg.RedactJSON(body); ok {
+			loggedBody = redactedBody
+		} else {
+			loggedBody = "[non-json body omitted]"
+		}
+	}
+
+	cmd.Log.Info(fmt.Sprintf(
+		"[REQUEST]: id=%s ip=%s %s %s status=%d latency=%s req_bytes=%d resp_bytes=%d body=%s",
+		c.GetString("request_id"),
+		pkg.ClientIP(c),
+		c.Request.Method,
+		c.Request.URL.Path,
+		c.Writer.Status(),
+		time.Since(start),
+		c.Request.ContentLength,
+		c.Writer.Size(),
+		loggedBody,
+	))
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/IAmRiteshKoushik/pulse/cmd"
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	"github.com/gin-gonic/gin"
+)
+
+// How long clients are asked to wait while writes are frozen
+const maintenanceRetryAfter = 5 * time.Minute
+
+// Kept in memory, so toggling at runtime only affects this instance
+var maintenance atomic.Bool
+
+func SetMaintenance(enabled bool) {
+	maintenance.Store(enabled)
+}
+
+func InMaintenance() bool {
+	return maintenance.Load()
+}
+
+// Maintenance rejects every request while maintenance mode is on, except for
+// routes listed in readOnly as "<METHOD> <route pattern>". Routes are listed
+// explicitly as the method alone does not tell whether a route writes, e.g.
+// OAuth callbacks are GETs which create sessions.
+func Maintenance(readOnly ...string) gin.HandlerFunc {
+	allowed := make(map[string]bool, len(readOnly))
+	for _, route := range readOnly {
+		allowed[route] = true
+	}
+
+	return func(c *gin.Context) {
+		if !maintenance.Load() || c.Request.Method == http.MethodOptions ||
+			allowed[c.Request.Method+" "+c.FullPath()] {
+			c.Next()
+			return
+		}
+
+		cmd.Log.Warn(
+			fmt.Sprintf("Write rejected during maintenance at %s %s",
+				c.Request.Method, c.FullPath()))
+		pkg.RespondUnavailableMessage(c, maintenanceRetryAfter,
+			"Server is under maintenance. Please try again later.")
+	}
+}
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMaintenance(t *testing.T) {
+	tests := []struct {
+		name        string
+		maintenance bool
+		method      string
+		path        string
+		wantStatus  int
+	}{
+		{name: "write while off", method: http.MethodPost, path: "/items", wantStatus: http.StatusOK},
+		{name: "read while off", method: http.MethodGet, path: "/items", wantStatus: http.StatusOK},
+		{name: "write while on", maintenance: true, method: http.MethodPost, path: "/items",
+			wantStatus: http.StatusServiceUnavailable},
+		{name: "listed read while on", maintenance: true, method: http.MethodGet, path: "/items",
+			wantStatus: http.StatusOK},
+		{name: "listed read with a path parameter while on", maintenance: true, method: http.MethodGet,
+			path: "/items/42", wantStatus: http.StatusOK},
+		// GETs are only let through when listed, as some of them write
+		{name: "unlisted read while on", maintenance: true, method: http.MethodGet, path: "/callback",
+			wantStatus: http.StatusServiceUnavailable},
+		{name: "preflight while on", maintenance: true, method: http.MethodOptions, path: "/items",
+			wantStatus: http.StatusOK},
+	}
+
+	router := gin.New()
+	router.Use(Maintenance("GET /items", "GET /items/:id"))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	router.GET("/items", ok)
+	router.POST("/items", ok)
+	router.OPTIONS("/items", ok)
+	router.GET("/items/:id", ok)
+	router.GET("/callback", ok)
+
+	t.Cleanup(func() { SetMaintenance(false) })
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetMaintenance(tt.maintenance)
+			if InMaintenance() != tt.maintenance {
+				t.Fatalf("InMaintenance() = %v, want %v", InMaintenance(), tt.maintenance)
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus == http.StatusServiceUnavailable && w.Header().Get("Retry-After") != "300" {
+				t.Errorf("Retry-After = %q, want %q", w.Header().Get("Retry-After"), "300")
+			}
+		})
+	}
+}
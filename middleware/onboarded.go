@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/IAmRiteshKoushik/pulse/cmd"
+	db "github.com/IAmRiteshKoushik/pulse/db/gen"
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// RequireOnboarded must be chained after Auth. Rejects temp tokens issued
+// mid-registration and users whose account is missing or deactivated.
+func RequireOnboarded(env *cmd.EnvConfig, pool pkg.Pool, q db.Querier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requireOnboarded(c, env, pool, q)
+	}
+}
+
+func requireOnboarded(c *gin.Context, env *cmd.EnvConfig, pool pkg.Pool, q db.Querier) {
+	if claims, ok := pkg.GrabClaims(c); !ok || claims.Subject != "access_token" {
+		cmd.Log.Warn(
+			fmt.Sprintf("Non-onboarded token attempted access at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"message": "Please complete registration to access this resource",
+		})
+		return
+	}
+
+	username, ok := pkg.GrabUsername(c)
+	if !ok {
+		cmd.Log.Warn(
+			fmt.Sprintf("Failed to extract username from token at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"message": "Oops! Something happened. Please try again later.",
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), env.TimeoutFor("onboarded"))
+	defer cancel()
+
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		pkg.DbAcquireError(c, err)
+		c.Abort()
+		return
+	}
+	defer conn.Release()
+
+	user, err := q.CheckUserExistQuery(ctx, conn, username)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		pkg.DbError(c, err)
+		c.Abort()
+		return
+	}
+	if user.Ghusername == "" {
+		cmd.Log.Warn(
+			fmt.Sprintf("Non-onboarded user attempted access at %s %s",
+				c.Request.Method, c.FullPath()))
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"message": "Please complete registration to access this resource",
+		})
+		return
+	}
+
+	c.Next()
+}
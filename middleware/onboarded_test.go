@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/IAmRiteshKoushik/pulse/cmd"
+	db "github.com/IAmRiteshKoushik/pulse/db/gen"
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+func TestRequireOnboarded(t *testing.T) {
+	tests := []struct {
+		name        string
+		username    string
+		tokenType   string
+		wantStatus  int
+		wantLookups int // of the user's account
+	}{
+		// A temp token is held between registering and verifying the OTP
+		{name: "temp token", username: "octocat", tokenType: "temp_token", wantStatus: http.StatusForbidden},
+		{name: "access token", username: "octocat", tokenType: "access_token", wantStatus: http.StatusOK, wantLookups: 1},
+		{name: "access token of a mixed case username", username: "OctoCat", tokenType: "access_token",
+			wantStatus: http.StatusOK, wantLookups: 1},
+		{name: "access token without an account", username: "hubot", tokenType: "access_token",
+			wantStatus: http.StatusForbidden, wantLookups: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := &cmd.EnvConfig{TokenSecret: "test-secret", RequestTimeout: 5 * time.Second}
+			lookups := 0
+			q := &fakeQuerier{
+				checkUserExist: func(username string) (db.CheckUserExistQueryRow, error) {
+					lookups++
+					if username != "octocat" {
+						return db.CheckUserExistQueryRow{}, pgx.ErrNoRows
+					}
+					return db.CheckUserExistQueryRow{Ghusername: username, Email: "octo@example.com"}, nil
+				},
+			}
+			router := gin.New()
+			router.GET("/api/v1/me/sessions", Auth(env), RequireOnboarded(env, fakePool{}, q), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			token, err := pkg.CreateToken(env, tt.username, "octo@example.com", tt.tokenType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/me/sessions", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if lookups != tt.wantLookups {
+				t.Errorf("account looked up %d times, want %d", lookups, tt.wantLookups)
+			}
+		})
+	}
+}
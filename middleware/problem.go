@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const problemContentType = "application/problem+json"
+
+type problemWriter struct {
+	gin.ResponseWriter
+	instance  string
+	buf       []byte
+	buffering bool
+}
+
+// ProblemJSON rewrites JSON error responses into RFC 7807 problem details.
+// The "message" (or "error") of the default envelope becomes the detail, and
+// every other field is kept as an extension member, so handlers keep
+// responding with the envelope regardless of the configured format.
+func ProblemJSON(c *gin.Context) {
+	w := &problemWriter{
+		ResponseWriter: c.Writer,
+		instance:       c.Request.URL.Path,
+	}
+	c.Writer = w
+	// Responses written after a panic unwound past here go out unconverted
+	defer func() {
+		w.finish()
+		c.Writer = w.ResponseWriter
+	}()
+	c.Next()
+}
+
+func (w *problemWriter) Write(data []byte) (int, error) {
+	if !w.buffering && !w.Written() && w.Status() >= http.StatusBadRequest &&
+		strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		w.buffering = true
+	}
+	if w.buffering {
+		w.buf = append(w.buf, data...)
+		return len(data), nil
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *problemWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *problemWriter) Flush() {
+	w.finish()
+	w.ResponseWriter.Flush()
+}
+
+func (w *problemWriter) finish() {
+	if !w.buffering {
+		return
+	}
+	w.buffering = false
+	body := w.buf
+	w.buf = nil
+
+	if problem, ok := toProblem(body, w.Status(), w.instance); ok {
+		w.Header().Set("Content-Type", problemContentType)
+		body = problem
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.ResponseWriter.Write(body)
+}
+
+// Bodies which are not a JSON object are left as they are
+func toProblem(body []byte, status int, instance string) ([]byte, bool) {
+	var fields map[string]any
+	if err := json.Unmarshal(body, &fields); err != nil || fields == nil {
+		return nil, false
+	}
+
+	detail, _ := fields["message"].(string)
+	if detail == "" {
+		detail, _ = fields["error"].(string)
+	}
+	delete(fields, "message")
+	delete(fields, "error")
+
+	fields["type"] = "about:blank"
+	fields["title"] = http.StatusText(status)
+	fields["status"] = status
+	if detail != "" {
+		fields["detail"] = detail
+	}
+	fields["instance"] = instance
+
+	problem, err := json.Marshal(fields)
+	if err != nil {
+		return nil, false
+	}
+	return problem, true
+}
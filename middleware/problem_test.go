@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestProblemJSON(t *testing.T) {
+	tests := []struct {
+		name        string
+		handler     gin.HandlerFunc
+		wantType    string
+		wantProblem map[string]any // nil when the body is left as is
+		wantBody    string
+	}{
+		{
+			name: "message envelope",
+			handler: func(c *gin.Context) {
+				c.JSON(http.StatusNotFound, gin.H{"message": "User not registered"})
+			},
+			wantType: "application/problem+json",
+			wantProblem: map[string]any{
+				"type":     "about:blank",
+				"title":    "Not Found",
+				"status":   float64(404),
+				"detail":   "User not registered",
+				"instance": "/api/v1/users/octocat",
+			},
+		},
+		{
+			name: "error envelope",
+			handler: func(c *gin.Context) {
+				c.JSON(http.StatusRequestTimeout, gin.H{"error": "The server is experiencing delays. Try again later."})
+			},
+			wantType: "application/problem+json",
+			wantProblem: map[string]any{
+				"type":     "about:blank",
+				"title":    "Request Timeout",
+				"status":   float64(408),
+				"detail":   "The server is experiencing delays. Try again later.",
+				"instance": "/api/v1/users/octocat",
+			},
+		},
+		{
+			name: "extension members kept",
+			handler: func(c *gin.Context) {
+				c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+					"message":             "Too many requests. Please try again later.",
+					"retry_after_seconds": 30,
+				})
+			},
+			wantType: "application/problem+json",
+			wantProblem: map[string]any{
+				"type":                "about:blank",
+				"title":               "Too Many Requests",
+				"status":              float64(429),
+				"detail":              "Too many requests. Please try again later.",
+				"instance":            "/api/v1/users/octocat",
+				"retry_after_seconds": float64(30),
+			},
+		},
+		{
+			name: "no detail",
+			handler: func(c *gin.Context) {
+				c.JSON(http.StatusForbidden, gin.H{"github_username": "octocat"})
+			},
+			wantType: "application/problem+json",
+			wantProblem: map[string]any{
+				"type":            "about:blank",
+				"title":           "Forbidden",
+				"status":          float64(403),
+				"instance":        "/api/v1/users/octocat",
+				"github_username": "octocat",
+			},
+		},
+		{
+			name: "success",
+			handler: func(c *gin.Context) {
+				c.JSON(http.StatusOK, gin.H{"message": "Profile retrieved successfully"})
+			},
+			wantType: "application/json; charset=utf-8",
+			wantBody: `{"message":"Profile retrieved successfully"}`,
+		},
+		{
+			name: "not JSON",
+			handler: func(c *gin.Context) {
+				c.String(http.StatusBadGateway, "upstream down")
+			},
+			wantType: "text/plain; charset=utf-8",
+			wantBody: "upstream down",
+		},
+		{
+			name: "JSON but not an object",
+			handler: func(c *gin.Context) {
+				c.JSON(http.StatusBadRequest, []string{"email", "github_username"})
+			},
+			wantType: "application/json; charset=utf-8",
+			wantBody: `["email","github_username"]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(ProblemJSON)
+			router.GET("/api/v1/users/:username", tt.handler)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/users/octocat?fields=all", nil))
+
+			if got := w.Header().Get("Content-Type"); got != tt.wantType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantType)
+			}
+			if got := w.Header().Get("Content-Length"); got != "" && got != strconv.Itoa(w.Body.Len()) {
+				t.Errorf("Content-Length = %s for a body of %d bytes", got, w.Body.Len())
+			}
+			if tt.wantProblem == nil {
+				if w.Body.String() != tt.wantBody {
+					t.Errorf("body = %s, want %s", w.Body.String(), tt.wantBody)
+				}
+				return
+			}
+			var problem map[string]any
+			if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+				t.Fatalf("body %s: %v", w.Body.String(), err)
+			}
+			if !reflect.DeepEqual(problem, tt.wantProblem) {
+				t.Errorf("problem = %v, want %v", problem, tt.wantProblem)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/IAmRiteshKoushik/pulse/cmd"
+	db "github.com/IAmRiteshKoushik/pulse/db/gen"
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	"github.com/gin-gonic/gin"
+)
+
+type rateWindow struct {
+	count   int
+	resetAt time.Time
+}
+
+// RateLimit allows at most limit requests per client IP in each window. State
+// is kept in memory unless RATE_LIMIT_BACKEND is "postgres", in which case
+// the limit holds across every instance sharing pool.
+func RateLimit(env *cmd.EnvConfig, pool pkg.Pool, q db.Querier, limit int, window time.Duration) gin.HandlerFunc {
+	return RateLimitBy(env, pool, q, limit, window, pkg.ClientIP)
+}
+
+// RateLimitBy counts requests per key rather than per client IP, e.g. per
+// phone number. Requests for which key returns "" are not limited.
+func RateLimitBy(env *cmd.EnvConfig, pool pkg.Pool, q db.Querier, limit int, window time.Duration,
+	key func(c *gin.Context) string) gin.HandlerFunc {
+	if env.RateLimitBackend == "postgres" {
+		return dbRateLimit(env.TimeoutFor("rate_limit"), pool, q, limit, window, key)
+	}
+	return memoryRateLimit(limit, window, key)
+}
+
+// Fixed windows local to a single instance
+func memoryRateLimit(limit int, window time.Duration, keyOf func(c *gin.Context) string) gin.HandlerFunc {
+	var mu sync.Mutex
+	windows := make(map[string]*rateWindow)
+
+	return func(c *gin.Context) {
+		key := keyOf(c)
+		if key == "" {
+			c.Next()
+			return
+		}
+		now := time.Now()
+
+		mu.Lock()
+		w, ok := windows[key]
+		if !ok || now.After(w.resetAt) {
+			// Dropping stale windows while holding the lock keeps the map bounded
+			for k, stale := range windows {
+				if now.After(stale.resetAt) {
+					delete(windows, k)
+				}
+			}
+			w = &rateWindow{resetAt: now.Add(window)}
+			windows[key] = w
+		}
+		w.count++
+		exceeded := w.count > limit
+		retryAfter := w.resetAt.Sub(now)
+		mu.Unlock()
+
+		if exceeded {
+			rateLimited(c, retryAfter)
+			return
+		}
+		c.Next()
+	}
+}
+
+// Sliding window approximated from the counts of the current and previous
+// fixed windows, the latter weighted by how much of it still overlaps.
+// Windows are aligned to the epoch so that every instance agrees on them.
+func dbRateLimit(timeout time.Duration, pool pkg.Pool, q db.Querier, limit int, window time.Duration,
+	keyOf func(c *gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := keyOf(c)
+		if key == "" {
+			c.Next()
+			return
+		}
+		// Routes share the table, so the key is scoped to the route
+		key = c.FullPath() + "|" + key
+		now := time.Now().UTC()
+		start := now.Truncate(window)
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+
+		hits, err := q.HitRateLimitQuery(ctx, pool, db.HitRateLimitQueryParams{
+			Key:           key,
+			WindowStart:   pkg.NewTimestamp(start),
+			PreviousStart: pkg.NewTimestamp(start.Add(-window)),
+		})
+		if err != nil {
+			// Failing open keeps sign-in available while the database struggles,
+			// the handler itself will surface the outage if it needs the database
+			cmd.Log.Error("Failed to record rate limit hit", err)
+			c.Next()
+			return
+		}
+
+		overlap := 1 - float64(now.Sub(start))/float64(window)
+		estimate := float64(hits.PreviousCount)*overlap + float64(hits.CurrentCount)
+		if estimate > float64(limit) {
+			rateLimited(c, start.Add(window).Sub(now))
+			return
+		}
+		c.Next()
+	}
+}
+
+func rateLimited(c *gin.Context, retryAfter time.Duration) {
+	cmd.Log.Warn(
+		fmt.Sprintf("Rate limit exceeded at %s %s",
+			c.Request.Method, c.FullPath()))
+	pkg.RespondRateLimited(c, retryAfter)
+}
@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IAmRiteshKoushik/pulse/cmd"
+	db "github.com/IAmRiteshKoushik/pulse/db/gen"
+	"github.com/gin-gonic/gin"
+)
+
+// The rate_limit table, shared by every instance pointed at it
+type rateTable struct {
+	mu     sync.Mutex
+	counts map[string]map[time.Time]int32
+	err    error // returned instead of recording the hit
+}
+
+func (r *rateTable) querier() *fakeQuerier {
+	return &fakeQuerier{
+		hitRateLimit: func(arg db.HitRateLimitQueryParams) (db.HitRateLimitQueryRow, error) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			if r.err != nil {
+				return db.HitRateLimitQueryRow{}, r.err
+			}
+			if r.counts == nil {
+				r.counts = map[string]map[time.Time]int32{}
+			}
+			if r.counts[arg.Key] == nil {
+				r.counts[arg.Key] = map[time.Time]int32{}
+			}
+			r.counts[arg.Key][arg.WindowStart.Time]++
+			return db.HitRateLimitQueryRow{
+				CurrentCount:  r.counts[arg.Key][arg.WindowStart.Time],
+				PreviousCount: r.counts[arg.Key][arg.PreviousStart.Time],
+			}, nil
+		},
+	}
+}
+
+// An instance of the app with its own process memory
+func newInstance(backend string, table *rateTable, limit int) *gin.Engine {
+	env := &cmd.EnvConfig{RateLimitBackend: backend, RequestTimeout: 5 * time.Second}
+	router := gin.New()
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	router.POST("/otp", RateLimit(env, fakePool{}, table.querier(), limit, time.Hour), ok)
+	router.POST("/login", RateLimit(env, fakePool{}, table.querier(), limit, time.Hour), ok)
+	return router
+}
+
+func hit(router *gin.Engine, path, remoteAddr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, nil)
+	req.RemoteAddr = remoteAddr
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimitAcrossInstances(t *testing.T) {
+	const limit = 4
+	tests := []struct {
+		name        string
+		backend     string
+		wantAllowed int // of 2*limit requests spread over both instances
+	}{
+		{name: "postgres", backend: "postgres", wantAllowed: limit},
+		// Each instance only sees its own half of the requests
+		{name: "memory", backend: "memory", wantAllowed: 2 * limit},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := &rateTable{}
+			instances := []*gin.Engine{newInstance(tt.backend, table, limit), newInstance(tt.backend, table, limit)}
+
+			allowed := 0
+			for i := range 2 * limit {
+				w := hit(instances[i%2], "/otp", "203.0.113.7:52100")
+				switch w.Code {
+				case http.StatusOK:
+					allowed++
+				case http.StatusTooManyRequests:
+					var body struct {
+						RetryAfterSeconds int `json:"retry_after_seconds"`
+					}
+					json.Unmarshal(w.Body.Bytes(), &body)
+					if w.Header().Get("Retry-After") == "" || w.Header().Get("Retry-After") != strconv.Itoa(body.RetryAfterSeconds) {
+						t.Errorf("429 with Retry-After %q, body %s", w.Header().Get("Retry-After"), w.Body.String())
+					}
+				default:
+					t.Fatalf("status = %d", w.Code)
+				}
+			}
+			if allowed != tt.wantAllowed {
+				t.Errorf("allowed %d requests, want %d", allowed, tt.wantAllowed)
+			}
+		})
+	}
+}
+
+func TestDbRateLimitKeys(t *testing.T) {
+	const limit = 2
+	table := &rateTable{}
+	first, second := newInstance("postgres", table, limit), newInstance("postgres", table, limit)
+
+	for range limit {
+		if w := hit(first, "/otp", "203.0.113.7:52100"); w.Code != http.StatusOK {
+			t.Fatalf("status = %d within the limit", w.Code)
+		}
+	}
+	if w := hit(second, "/otp", "203.0.113.7:52100"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("over the limit on the other instance: status = %d", w.Code)
+	}
+	// Limits are kept per route and per client
+	if w := hit(second, "/login", "203.0.113.7:52100"); w.Code != http.StatusOK {
+		t.Errorf("other route: status = %d", w.Code)
+	}
+	if w := hit(second, "/otp", "198.51.100.1:40000"); w.Code != http.StatusOK {
+		t.Errorf("other client: status = %d", w.Code)
+	}
+
+	// Requests are let through while the table cannot be reached
+	table.err = errors.New("connection reset")
+	if w := hit(first, "/otp", "203.0.113.7:52100"); w.Code != http.StatusOK {
+		t.Errorf("database down: status = %d", w.Code)
+	}
+}
@@ -11,7 +11,7 @@ import (
 func RecoveryMiddleware(c *gin.Context) {
 	defer func() {
 		if err := recover(); err != nil {
-			cmd.Log.Fatal(
+			cmd.Log.Error(
 				fmt.Sprintf("[PANIC-RECOVERED]: Panic occured at %s %s", c.Request.Method, c.FullPath()),
 				fmt.Errorf("%v\n", err),
 			)
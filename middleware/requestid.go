@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const RequestIdHeader = "X-Request-ID"
+
+// Incoming IDs are only trusted when short and free of odd characters as
+// they end up in logs
+var validRequestId = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestId tags every request with an ID, reusing the one sent by an
+// upstream proxy when present, and echoes it back in the response.
+func RequestId(c *gin.Context) {
+	requestId := c.GetHeader(RequestIdHeader)
+	if !validRequestId.MatchString(requestId) {
+		requestId = uuid.NewString()
+	}
+	c.Set("request_id", requestId)
+	c.Header(RequestIdHeader, requestId)
+	c.Next()
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/IAmRiteshKoushik/pulse/cmd"
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	"github.com/gin-gonic/gin"
+)
+
+// RequireScope must be chained after Auth, which populates the token scopes
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := pkg.GrabClaims(c)
+		if !ok || !slices.Contains(claims.GrantedScopes(), scope) {
+			cmd.Log.Warn(
+				fmt.Sprintf("Token missing scope %s at %s %s",
+					scope, c.Request.Method, c.FullPath()))
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"message": "Token does not grant access to this resource",
+			})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestRequireScope(t *testing.T) {
+	tests := []struct {
+		name       string
+		claims     *pkg.Claims
+		scope      string
+		wantStatus int
+	}{
+		{name: "scope granted", scope: pkg.ScopeBountyWrite, wantStatus: http.StatusOK,
+			claims: accessClaims(pkg.ScopeProfileRead, pkg.ScopeBountyWrite)},
+		{name: "scope missing", scope: pkg.ScopeBountyWrite, wantStatus: http.StatusForbidden,
+			claims: accessClaims(pkg.DefaultUserScopes...)},
+		{name: "issued before scopes, base scope", scope: pkg.ScopeProfileRead, wantStatus: http.StatusOK,
+			claims: &pkg.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "access_token"}}},
+		{name: "issued before scopes, maintainer scope", scope: pkg.ScopeBountyWrite, wantStatus: http.StatusForbidden,
+			claims: &pkg.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "access_token"}}},
+		{name: "temp token", scope: pkg.ScopeProfileRead, wantStatus: http.StatusForbidden,
+			claims: &pkg.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "temp_token"}}},
+		{name: "no claims", scope: pkg.ScopeProfileRead, wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/", func(c *gin.Context) {
+				if tt.claims != nil {
+					c.Set("claims", tt.claims)
+				}
+			}, RequireScope(tt.scope), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func accessClaims(scopes ...string) *pkg.Claims {
+	return &pkg.Claims{
+		Scopes:           scopes,
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "access_token"},
+	}
+}
@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const ResponseTimeHeader = "X-Response-Time-ms"
+
+type timingWriter struct {
+	gin.ResponseWriter
+	start     time.Time
+	queueTime time.Duration // zero unless a proxy sent X-Request-Start
+	stamped   bool
+}
+
+// Timing reports how long the request took to process through Server-Timing
+// and X-Response-Time-ms. Headers have to be set before the first byte is
+// written, so the measured duration ends there. When a proxy sends
+// X-Request-Start the time spent queueing before the app is reported too.
+func Timing(c *gin.Context) {
+	start := time.Now()
+	w := &timingWriter{
+		ResponseWriter: c.Writer,
+		start:          start,
+		queueTime:      queueTime(c.GetHeader("X-Request-Start"), start),
+	}
+	c.Writer = w
+	c.Next()
+	// Bodyless responses are written by gin after the chain returns
+	w.stamp()
+}
+
+func (w *timingWriter) stamp() {
+	if w.stamped || w.ResponseWriter.Written() {
+		return
+	}
+	w.stamped = true
+
+	elapsed := float64(time.Since(w.start).Microseconds()) / 1000
+	timing := fmt.Sprintf("app;dur=%.3f", elapsed)
+	if w.queueTime > 0 {
+		timing = fmt.Sprintf("queue;dur=%.3f, %s",
+			float64(w.queueTime.Microseconds())/1000, timing)
+	}
+	w.Header().Set("Server-Timing", timing)
+	w.Header().Set(ResponseTimeHeader, strconv.FormatFloat(elapsed, 'f', 3, 64))
+}
+
+func (w *timingWriter) WriteHeaderNow() {
+	w.stamp()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *timingWriter) Write(data []byte) (int, error) {
+	w.stamp()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *timingWriter) WriteString(s string) (int, error) {
+	w.stamp()
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *timingWriter) Flush() {
+	w.stamp()
+	w.ResponseWriter.Flush()
+}
+
+// Parses X-Request-Start as sent by common proxies, i.e. "t=<epoch>" or a bare
+// epoch, in seconds, milliseconds or microseconds
+func queueTime(header string, now time.Time) time.Duration {
+	header = strings.TrimPrefix(strings.TrimSpace(header), "t=")
+	if header == "" {
+		return 0
+	}
+	value, err := strconv.ParseFloat(header, 64)
+	if err != nil || value <= 0 {
+		return 0
+	}
+
+	var sent time.Time
+	switch {
+	case value > 1e15:
+		sent = time.UnixMicro(int64(value))
+	case value > 1e12:
+		sent = time.UnixMilli(int64(value))
+	default:
+		sent = time.Unix(0, int64(value*float64(time.Second)))
+	}
+	// Clocks of the proxy and the app may disagree
+	if queued := now.Sub(sent); queued > 0 && queued < time.Minute {
+		return queued
+	}
+	return 0
+}
@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTiming(t *testing.T) {
+	const delay = 20 * time.Millisecond
+	tests := []struct {
+		name    string
+		handler gin.HandlerFunc
+	}{
+		{name: "JSON body", handler: func(c *gin.Context) {
+			time.Sleep(delay)
+			c.JSON(http.StatusOK, gin.H{"message": "ok"})
+		}},
+		{name: "no body", handler: func(c *gin.Context) {
+			time.Sleep(delay)
+			c.Status(http.StatusNoContent)
+		}},
+		{name: "aborted", handler: func(c *gin.Context) {
+			time.Sleep(delay)
+			c.AbortWithStatus(http.StatusForbidden)
+		}},
+		{name: "streamed", handler: func(c *gin.Context) {
+			time.Sleep(delay)
+			c.Writer.WriteString("data: 1\n\n")
+			c.Writer.Flush()
+			time.Sleep(delay)
+			c.Writer.WriteString("data: 2\n\n")
+		}},
+	}
+
+	serverTiming := regexp.MustCompile(`^app;dur=([0-9]+\.[0-9]{3})$`)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(Timing)
+			router.GET("/", tt.handler)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			header := w.Header().Get(ResponseTimeHeader)
+			elapsed, err := strconv.ParseFloat(header, 64)
+			if err != nil {
+				t.Fatalf("%s = %q, want a number", ResponseTimeHeader, header)
+			}
+			// Measured up to the first byte written
+			if elapsed < float64(delay.Milliseconds()) || elapsed > 2*float64(delay.Milliseconds())+500 {
+				t.Errorf("%s = %v, want about %v", ResponseTimeHeader, elapsed, delay.Milliseconds())
+			}
+			match := serverTiming.FindStringSubmatch(w.Header().Get("Server-Timing"))
+			if match == nil || match[1] != header {
+				t.Errorf("Server-Timing = %q, want app;dur=%s", w.Header().Get("Server-Timing"), header)
+			}
+		})
+	}
+}
+
+func TestTimingQueue(t *testing.T) {
+	router := gin.New()
+	router.Use(Timing)
+	router.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	sent := time.Now().Add(-150 * time.Millisecond)
+	req.Header.Set("X-Request-Start", "t="+strconv.FormatInt(sent.UnixMicro(), 10))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	match := regexp.MustCompile(`^queue;dur=([0-9.]+), app;dur=[0-9.]+$`).
+		FindStringSubmatch(w.Header().Get("Server-Timing"))
+	if match == nil {
+		t.Fatalf("Server-Timing = %q", w.Header().Get("Server-Timing"))
+	}
+	if queued, _ := strconv.ParseFloat(match[1], 64); queued < 150 || queued > 1000 {
+		t.Errorf("queue duration = %s, want about 150", match[1])
+	}
+}
+
+func TestQueueTime(t *testing.T) {
+	now := time.Unix(1_760_000_000, 0)
+	tests := []struct {
+		name   string
+		header string
+		want   time.Duration
+	}{
+		{name: "seconds", header: "1759999999.750", want: 250 * time.Millisecond},
+		{name: "milliseconds", header: "t=1759999999750", want: 250 * time.Millisecond},
+		{name: "microseconds", header: "t=1759999999750000", want: 250 * time.Millisecond},
+		{name: "missing", header: "", want: 0},
+		{name: "malformed", header: "t=soon", want: 0},
+		{name: "ahead of the app clock", header: "t=1760000001000", want: 0},
+		{name: "implausibly old", header: "t=1759990000000", want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := queueTime(tt.header, now)
+			if diff := got - tt.want; diff < -time.Millisecond || diff > time.Millisecond {
+				t.Errorf("queueTime(%q) = %s, want %s", tt.header, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package pkg
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// Backoff produces exponentially growing delays with full jitter, capped at
+// Max. Attempts bounds the number of times Retry invokes the operation.
+type Backoff struct {
+	Base     time.Duration
+	Max      time.Duration
+	Attempts int
+}
+
+func NewBackoff(base, max time.Duration, attempts int) *Backoff {
+	return &Backoff{
+		Base:     base,
+		Max:      max,
+		Attempts: attempts,
+	}
+}
+
+// Delay returns a random duration in [0, min(Max, Base * 2^attempt)] where
+// attempt is zero based
+func (b *Backoff) Delay(attempt int) time.Duration {
+	ceiling := b.Base
+	for range attempt {
+		ceiling *= 2
+		if ceiling >= b.Max || ceiling <= 0 {
+			ceiling = b.Max
+			break
+		}
+	}
+	if ceiling > b.Max {
+		ceiling = b.Max
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling + 1)
+}
+
+// Retry calls fn until it succeeds, attempts are exhausted or the context is
+// cancelled. The last error from fn (or the context error) is returned.
+func (b *Backoff) Retry(ctx context.Context, fn func() error) error {
+	var err error
+	for attempt := range b.Attempts {
+		if err = fn(); err == nil {
+			return nil
+		}
+		if attempt == b.Attempts-1 {
+			break
+		}
+
+		timer := time.NewTimer(b.Delay(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return err
+}
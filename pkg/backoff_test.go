@@ -0,0 +1,97 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	b := NewBackoff(10*time.Millisecond, 100*time.Millisecond, 10)
+	tests := []struct {
+		attempt int
+		ceiling time.Duration
+	}{
+		{attempt: 0, ceiling: 10 * time.Millisecond},
+		{attempt: 1, ceiling: 20 * time.Millisecond},
+		{attempt: 3, ceiling: 80 * time.Millisecond},
+		{attempt: 4, ceiling: 100 * time.Millisecond},
+		{attempt: 100, ceiling: 100 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		var largest time.Duration
+		for range 1000 {
+			d := b.Delay(tt.attempt)
+			if d < 0 || d > tt.ceiling {
+				t.Fatalf("Delay(%d) = %s, want within [0, %s]", tt.attempt, d, tt.ceiling)
+			}
+			largest = max(largest, d)
+		}
+		// Jitter spreads delays over the whole range
+		if largest < tt.ceiling/2 {
+			t.Errorf("Delay(%d) never exceeded %s of %s", tt.attempt, largest, tt.ceiling)
+		}
+	}
+}
+
+func TestBackoffRetry(t *testing.T) {
+	errFlaky := errors.New("flaky")
+	tests := []struct {
+		name      string
+		failures  int // before fn succeeds
+		wantCalls int
+		wantErr   error
+	}{
+		{name: "first try", failures: 0, wantCalls: 1},
+		{name: "after failures", failures: 2, wantCalls: 3},
+		{name: "attempts exhausted", failures: 10, wantCalls: 4, wantErr: errFlaky},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBackoff(time.Millisecond, 5*time.Millisecond, 4)
+			calls := 0
+			err := b.Retry(context.Background(), func() error {
+				calls++
+				if calls <= tt.failures {
+					return errFlaky
+				}
+				return nil
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Retry() error = %v, want %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("fn called %d times, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestBackoffRetryCancelled(t *testing.T) {
+	b := NewBackoff(time.Hour, time.Hour, 5)
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	done := make(chan error)
+	go func() {
+		done <- b.Retry(ctx, func() error {
+			calls++
+			cancel()
+			return errors.New("unavailable")
+		})
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Retry() error = %v, want context.Canceled", err)
+		}
+		if calls != 1 {
+			t.Errorf("fn called %d times after cancellation, want 1", calls)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Retry() kept waiting after the context was cancelled")
+	}
+}
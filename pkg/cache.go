@@ -0,0 +1,60 @@
+package pkg
+
+import (
+	"sync"
+	"time"
+)
+
+// TTLCache is a small in-memory cache for short-lived lookups. Expired
+// entries are evicted lazily on read.
+type TTLCache[K comparable, V any] struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[K]ttlEntry[V]
+}
+
+type ttlEntry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+func NewTTLCache[K comparable, V any](ttl time.Duration) *TTLCache[K, V] {
+	return &TTLCache[K, V]{
+		ttl:     ttl,
+		entries: make(map[K]ttlEntry[V]),
+	}
+}
+
+func (t *TTLCache[K, V]) Get(key K) (V, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entry, ok := t.entries[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(t.entries, key)
+		var zero V
+		return zero, false
+	}
+	return entry.value, true
+}
+
+func (t *TTLCache[K, V]) Set(key K, value V) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.entries[key] = ttlEntry[V]{
+		value:     value,
+		expiresAt: time.Now().Add(t.ttl),
+	}
+}
+
+func (t *TTLCache[K, V]) Delete(key K) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.entries, key)
+}
@@ -0,0 +1,39 @@
+package pkg
+
+import (
+	"net/http"
+
+	"github.com/IAmRiteshKoushik/pulse/cmd"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	AccessTokenCookie  = "access_token"
+	RefreshTokenCookie = "refresh_token"
+	OAuthStateCookie   = "oauth_state"
+)
+
+func SetTokenCookies(c *gin.Context, accessToken, refreshToken string) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(AccessTokenCookie, accessToken,
+		int(AccessTokenTTL.Seconds()), "/", "", true, true)
+	SetRefreshTokenCookie(c, refreshToken)
+}
+
+func SetRefreshTokenCookie(c *gin.Context, refreshToken string) {
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(RefreshTokenCookie, refreshToken,
+		int(cmd.EnvVars.RefreshTokenMaxAge.Seconds()), "/", "", true, true)
+}
+
+// Holds the nonce of the OAuth state for the callback to compare against. Lax
+// so that it is sent on the top-level redirect back from the provider.
+func SetOAuthStateCookie(c *gin.Context, nonce string) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(OAuthStateCookie, nonce, int(oauthStateTTL.Seconds()), "/", "", true, true)
+}
+
+func ClearOAuthStateCookie(c *gin.Context) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(OAuthStateCookie, "", -1, "/", "", true, true)
+}
@@ -0,0 +1,36 @@
+package pkg
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cursors are opaque to clients and encode the (timestamp, id) pair of the
+// last row on a page for keyset pagination
+func EncodeCursor(at time.Time, id int32) string {
+	raw := fmt.Sprintf("%d|%d", at.UnixNano(), id)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeCursor(cursor string) (time.Time, int32, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("Invalid cursor encoding")
+	}
+	at, id, found := strings.Cut(string(raw), "|")
+	if !found {
+		return time.Time{}, 0, fmt.Errorf("Invalid cursor format")
+	}
+	nanos, err := strconv.ParseInt(at, 10, 64)
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("Invalid cursor timestamp")
+	}
+	rowId, err := strconv.ParseInt(id, 10, 32)
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("Invalid cursor id")
+	}
+	return time.Unix(0, nanos).UTC(), int32(rowId), nil
+}
@@ -0,0 +1,25 @@
+package pkg
+
+import (
+	"strings"
+)
+
+var gmailDomains = []string{"gmail.com", "googlemail.com"}
+
+// NormalizeEmail trims and lowercases an email address so that equivalent
+// addresses are stored and matched identically. Gmail ignores dots in the
+// local part, which may optionally be collapsed as well.
+func NormalizeEmail(email string, collapseGmailDots bool) string {
+	email = strings.ToLower(strings.TrimSpace(email))
+
+	local, domain, found := strings.Cut(email, "@")
+	if !found || !collapseGmailDots {
+		return email
+	}
+	for _, gmail := range gmailDomains {
+		if domain == gmail {
+			return strings.ReplaceAll(local, ".", "") + "@" + domain
+		}
+	}
+	return email
+}
@@ -0,0 +1,29 @@
+package pkg
+
+import "testing"
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		name              string
+		email             string
+		collapseGmailDots bool
+		want              string
+	}{
+		{name: "normalized already", email: "octo@example.com", want: "octo@example.com"},
+		{name: "upper case", email: "Octo.Cat@Example.COM", want: "octo.cat@example.com"},
+		{name: "surrounding whitespace", email: "  octo@example.com\t\n", want: "octo@example.com"},
+		{name: "gmail dots kept", email: "Octo.Cat@Gmail.com", want: "octo.cat@gmail.com"},
+		{name: "gmail dots collapsed", email: " Octo.Cat@Gmail.com ", collapseGmailDots: true, want: "octocat@gmail.com"},
+		{name: "googlemail dots collapsed", email: "o.c.t.o@googlemail.com", collapseGmailDots: true, want: "octo@googlemail.com"},
+		{name: "other domain dots kept", email: "octo.cat@example.com", collapseGmailDots: true, want: "octo.cat@example.com"},
+		{name: "not an address", email: " Octo.Cat ", collapseGmailDots: true, want: "octo.cat"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeEmail(tt.email, tt.collapseGmailDots); got != tt.want {
+				t.Errorf("NormalizeEmail(%q, %v) = %q, want %q", tt.email, tt.collapseGmailDots, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package pkg
+
+import (
+	_ "embed"
+	"errors"
+	"strings"
+
+	"github.com/IAmRiteshKoushik/pulse/cmd"
+)
+
+var (
+	ErrEmailDomainNotAllowed = errors.New("email domain is not allowed to register")
+	ErrEmailDomainDenied     = errors.New("email domain is blocked from registering")
+	ErrEmailDomainDisposable = errors.New("disposable email addresses are not allowed")
+)
+
+//go:embed disposable_domains.txt
+var disposableDomainList string
+
+var disposableDomains = parseDomainList(disposableDomainList)
+
+func parseDomainList(list string) []string {
+	var domains []string
+	for _, line := range strings.Split(list, "\n") {
+		line = strings.ToLower(strings.TrimSpace(line))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		domains = append(domains, line)
+	}
+	return domains
+}
+
+// An entry covers the domain itself and every subdomain of it
+func domainListed(domain string, entries []string) bool {
+	for _, entry := range entries {
+		if domain == entry || strings.HasSuffix(domain, "."+entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckEmailDomain applies the configured domain lists to a normalized email.
+// Denied and disposable domains are rejected even when also allowlisted.
+func CheckEmailDomain(env *cmd.EnvConfig, email string) error {
+	_, domain, found := strings.Cut(email, "@")
+	if !found {
+		return ErrEmailDomainNotAllowed
+	}
+
+	if domainListed(domain, env.EmailDomainDenylist) {
+		return ErrEmailDomainDenied
+	}
+	if env.EmailBlockDisposable && domainListed(domain, disposableDomains) {
+		return ErrEmailDomainDisposable
+	}
+	if len(env.EmailDomainAllowlist) > 0 &&
+		!domainListed(domain, env.EmailDomainAllowlist) {
+		return ErrEmailDomainNotAllowed
+	}
+	return nil
+}
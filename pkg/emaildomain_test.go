@@ -0,0 +1,62 @@
+package pkg
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/IAmRiteshKoushik/pulse/cmd"
+)
+
+func TestCheckEmailDomain(t *testing.T) {
+	campus := []string{"am.students.amrita.edu", "amrita.edu"}
+	tests := []struct {
+		name       string
+		email      string
+		allowlist  []string
+		denylist   []string
+		disposable bool
+		want       error
+	}{
+		{name: "no lists", email: "octo@example.com"},
+		{name: "allowed", email: "octo@amrita.edu", allowlist: campus},
+		{name: "allowed subdomain", email: "octo@cb.amrita.edu", allowlist: campus},
+		{name: "not allowed", email: "octo@gmail.com", allowlist: campus, want: ErrEmailDomainNotAllowed},
+		// Only whole labels match, not any suffix
+		{name: "lookalike of allowed", email: "octo@notamrita.edu", allowlist: campus, want: ErrEmailDomainNotAllowed},
+		{name: "denied", email: "octo@example.org", denylist: []string{"example.org"}, want: ErrEmailDomainDenied},
+		{name: "denied subdomain", email: "octo@mail.example.org", denylist: []string{"example.org"}, want: ErrEmailDomainDenied},
+		{name: "denied over allowed", email: "octo@amrita.edu", allowlist: campus,
+			denylist: []string{"amrita.edu"}, want: ErrEmailDomainDenied},
+		{name: "disposable", email: "octo@10minutemail.com", disposable: true, want: ErrEmailDomainDisposable},
+		{name: "disposable subdomain", email: "octo@x.burnermail.io", disposable: true, want: ErrEmailDomainDisposable},
+		{name: "disposable over allowed", email: "octo@10minutemail.com", allowlist: []string{"10minutemail.com"},
+			disposable: true, want: ErrEmailDomainDisposable},
+		{name: "disposable when not blocked", email: "octo@10minutemail.com"},
+		{name: "no domain", email: "octo", want: ErrEmailDomainNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := &cmd.EnvConfig{
+				EmailDomainAllowlist: tt.allowlist,
+				EmailDomainDenylist:  tt.denylist,
+				EmailBlockDisposable: tt.disposable,
+			}
+			if err := CheckEmailDomain(env, tt.email); !errors.Is(err, tt.want) {
+				t.Errorf("CheckEmailDomain(%q) = %v, want %v", tt.email, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDisposableDomains(t *testing.T) {
+	if len(disposableDomains) == 0 {
+		t.Fatal("bundled disposable domain list is empty")
+	}
+	for _, domain := range disposableDomains {
+		if strings.ContainsAny(domain, "@/ #") || !strings.Contains(domain, ".") {
+			t.Errorf("malformed entry %q", domain)
+		}
+	}
+}
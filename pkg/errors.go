@@ -2,15 +2,25 @@ package pkg
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/IAmRiteshKoushik/pulse/cmd"
 	"github.com/gin-gonic/gin"
+	v "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/puddle/v2"
 )
 
 func DbError(c *gin.Context, err error) {
-	if err == context.DeadlineExceeded {
+	if errors.Is(err, puddle.ErrClosedPool) {
+		dbUnavailableError(c, err)
+	} else if err == context.DeadlineExceeded || isStatementTimeout(err) {
 		cmd.Log.Warn(
 			fmt.Sprintf("[CONTEXT-DEADLINE-EXCEEDED]: Server is experiencing delays at %s %s",
 				c.Request.Method,
@@ -21,7 +31,7 @@ func DbError(c *gin.Context, err error) {
 			"error": "The server is experiencing delays. Try again later.",
 		})
 	} else {
-		cmd.Log.Fatal(
+		cmd.Log.Error(
 			fmt.Sprintf(
 				"[INTERNAL-SERVER-ERROR]: DB Error at %s %s.\n",
 				c.Request.Method,
@@ -34,6 +44,89 @@ func DbError(c *gin.Context, err error) {
 	}
 }
 
+// Reports whether Postgres cancelled the query on hitting statement_timeout
+func isStatementTimeout(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "57014"
+}
+
+// Reports whether err is a Postgres unique constraint violation
+func IsUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// DbAcquireError handles failures to acquire a connection or begin a
+// transaction. A timeout at this stage means the pool is exhausted rather
+// than a slow query, and a failed connect means the database is down, so
+// clients are asked to retry instead.
+func DbAcquireError(c *gin.Context, err error) {
+	if isDbUnavailable(err) {
+		dbUnavailableError(c, err)
+		return
+	}
+	DbError(c, err)
+}
+
+func isDbUnavailable(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) ||
+		errors.Is(err, puddle.ErrClosedPool) {
+		return true
+	}
+	var connectErr *pgconn.ConnectError
+	if errors.As(err, &connectErr) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+func dbUnavailableError(c *gin.Context, err error) {
+	cmd.Log.Error(
+		fmt.Sprintf("[DB-UNAVAILABLE]: Connection pool exhausted, closed or unreachable at %s %s",
+			c.Request.Method,
+			c.FullPath(),
+		), err)
+	RespondUnavailable(c, cmd.EnvVars.UnavailableRetryAfter)
+}
+
+// Every 429 response carries the wait both as a Retry-After header and as
+// retry_after_seconds in the body, rounded up to at least a second so that
+// clients never retry in a tight loop
+func RespondRateLimited(c *gin.Context, retryAfter time.Duration) {
+	RespondRateLimitedMessage(c, retryAfter, "Too many requests. Please try again later.")
+}
+
+func RespondRateLimitedMessage(c *gin.Context, retryAfter time.Duration, message string) {
+	seconds := SetRetryAfter(c, retryAfter)
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+		"message":             message,
+		"retry_after_seconds": seconds,
+	})
+}
+
+// 503s caused by a dependency outage carry the same backoff hint as 429s, so
+// that clients and load balancers back off instead of hammering the server
+func RespondUnavailable(c *gin.Context, retryAfter time.Duration) {
+	RespondUnavailableMessage(c, retryAfter, "The server is temporarily unavailable. Try again later.")
+}
+
+func RespondUnavailableMessage(c *gin.Context, retryAfter time.Duration, message string) {
+	seconds := SetRetryAfter(c, retryAfter)
+	c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+		"message":             message,
+		"retry_after_seconds": seconds,
+	})
+}
+
+// Sets the Retry-After header, rounded up to whole seconds, and returns the
+// seconds sent for responses which build their own body
+func SetRetryAfter(c *gin.Context, retryAfter time.Duration) int {
+	seconds := max(int(math.Ceil(retryAfter.Seconds())), 1)
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	return seconds
+}
+
 func JSONUnmarshallError(c *gin.Context, err error) {
 	cmd.Log.Error(
 		fmt.Sprintf(
@@ -51,8 +144,71 @@ func RequestValidatorError(c *gin.Context, err error) {
 			c.Request.Method,
 			c.FullPath(),
 		), err)
+	// Field level errors are safe to surface and help clients fix input
+	if fieldErrs, ok := err.(v.Errors); ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  "The request is malformed.",
+			"fields": fieldErrs,
+		})
+		return
+	}
 	c.JSON(http.StatusBadRequest, gin.H{
 		"error": "The request is malformed.",
 	})
 	return
 }
+
+// TokenError aborts with a response telling expired tokens, which can be
+// refreshed, apart from forged or malformed ones
+func TokenError(c *gin.Context, err error) {
+	cmd.Log.Error(
+		fmt.Sprintf("[TOKEN-ERROR]: Token verification failed at %s %s",
+			c.Request.Method,
+			c.FullPath(),
+		), err)
+	switch {
+	case errors.Is(err, ErrTokenExpired):
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"message": "Token expired",
+			"hint":    "Refresh the access token or login again",
+		})
+	case errors.Is(err, ErrTokenSignature):
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"message": "Invalid token signature",
+		})
+	default:
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"message": "Malformed token",
+		})
+	}
+}
+
+// MailError maps a failure of SendMail to a response. Undeliverable addresses
+// are the user's to fix, everything else is operational.
+func MailError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrMailRecipientRejected):
+		cmd.Log.Warn(
+			fmt.Sprintf("[MAIL-UNDELIVERABLE]: Recipient rejected at %s %s: %s",
+				c.Request.Method, c.FullPath(), err.Error()))
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message": "Email undeliverable. Please check the address and try again.",
+		})
+	case errors.Is(err, ErrMailConnection):
+		cmd.Log.Error(
+			fmt.Sprintf("[MAIL-UNAVAILABLE]: Mail server unreachable at %s %s",
+				c.Request.Method, c.FullPath()), err)
+		NotifyStaff(StaffMailFailure, "Mail server is unreachable: "+err.Error())
+		RespondUnavailableMessage(c, cmd.EnvVars.UnavailableRetryAfter,
+			"Could not send email right now. Please try again later.")
+	default:
+		// Includes authentication failures, which need an operator
+		cmd.Log.Error(
+			fmt.Sprintf("[MAIL-ERROR]: Failed to send email at %s %s",
+				c.Request.Method, c.FullPath()), err)
+		NotifyStaff(StaffMailFailure, "Sending mail failed: "+err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"message": "Oops! Something happened. Please try again later.",
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/IAmRiteshKoushik/pulse/cmd"
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/puddle/v2"
+)
+
+// Address nothing listens on, so that connecting to it fails right away
+func closedAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+func TestDbAcquireError(t *testing.T) {
+	addr := closedAddr(t)
+	_, dialErr := net.DialTimeout("tcp", addr, time.Second)
+	if dialErr == nil {
+		t.Fatal("dial to a closed port succeeded")
+	}
+	_, connectErr := pgconn.Connect(context.Background(),
+		fmt.Sprintf("postgres://pulse@%s/pulse?connect_timeout=1", addr))
+	if connectErr == nil {
+		t.Fatal("connect to a closed port succeeded")
+	}
+
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+	}{
+		{name: "pool timeout", err: context.DeadlineExceeded, wantStatus: http.StatusServiceUnavailable},
+		{name: "request canceled", err: context.Canceled, wantStatus: http.StatusServiceUnavailable},
+		{name: "closed pool", err: puddle.ErrClosedPool, wantStatus: http.StatusServiceUnavailable},
+		{name: "connect error", err: connectErr, wantStatus: http.StatusServiceUnavailable},
+		{name: "dial error", err: fmt.Errorf("acquire: %w", dialErr), wantStatus: http.StatusServiceUnavailable},
+		{name: "other error", err: errors.New("syntax error"), wantStatus: http.StatusInternalServerError},
+	}
+
+	prev := cmd.EnvVars.UnavailableRetryAfter
+	cmd.EnvVars.UnavailableRetryAfter = 5 * time.Second
+	t.Cleanup(func() { cmd.EnvVars.UnavailableRetryAfter = prev })
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/profile", nil)
+
+			DbAcquireError(c, tt.err)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			retryAfter := w.Header().Get("Retry-After")
+			if tt.wantStatus == http.StatusServiceUnavailable && retryAfter != "5" {
+				t.Errorf("Retry-After = %q, want %q", retryAfter, "5")
+			}
+		})
+	}
+}
+
+func TestDbError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+	}{
+		{name: "context deadline", err: context.DeadlineExceeded, wantStatus: http.StatusRequestTimeout},
+		{
+			name:       "statement timeout",
+			err:        fmt.Errorf("fetch profile: %w", &pgconn.PgError{Code: "57014", Message: "canceling statement due to statement timeout"}),
+			wantStatus: http.StatusRequestTimeout,
+		},
+		{name: "closed pool", err: puddle.ErrClosedPool, wantStatus: http.StatusServiceUnavailable},
+		{name: "other Postgres error", err: &pgconn.PgError{Code: "42601"}, wantStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/profile", nil)
+
+			DbError(c, tt.err)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestTokenError(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		wantStatus  int
+		wantMessage string
+	}{
+		{name: "expired", err: fmt.Errorf("%w: token is expired", ErrTokenExpired),
+			wantStatus: http.StatusUnauthorized, wantMessage: "Token expired"},
+		{name: "signature", err: fmt.Errorf("%w: signature is invalid", ErrTokenSignature),
+			wantStatus: http.StatusUnauthorized, wantMessage: "Invalid token signature"},
+		{name: "malformed", err: fmt.Errorf("%w: token contains an invalid number of segments", ErrTokenMalformed),
+			wantStatus: http.StatusBadRequest, wantMessage: "Malformed token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/profile", nil)
+
+			TokenError(c, tt.err)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if !c.IsAborted() {
+				t.Error("request was not aborted")
+			}
+			var body struct {
+				Message string `json:"message"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Message != tt.wantMessage {
+				t.Errorf("message = %q (%v), want %q", body.Message, err, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestRespondRateLimited(t *testing.T) {
+	tests := []struct {
+		name       string
+		retryAfter time.Duration
+		want       int
+	}{
+		{name: "whole seconds", retryAfter: 30 * time.Second, want: 30},
+		{name: "rounded up", retryAfter: 1200 * time.Millisecond, want: 2},
+		{name: "under a second", retryAfter: 10 * time.Millisecond, want: 1},
+		{name: "already elapsed", retryAfter: -time.Second, want: 1},
+		{name: "minutes", retryAfter: 5 * time.Minute, want: 300},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/auth/register/otp/resend", nil)
+
+			RespondRateLimited(c, tt.retryAfter)
+			if w.Code != http.StatusTooManyRequests || !c.IsAborted() {
+				t.Fatalf("status = %d, aborted %v", w.Code, c.IsAborted())
+			}
+			if got := w.Header().Get("Retry-After"); got != fmt.Sprint(tt.want) {
+				t.Errorf("Retry-After = %q, want %d", got, tt.want)
+			}
+			var body struct {
+				Message           string `json:"message"`
+				RetryAfterSeconds *int   `json:"retry_after_seconds"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.RetryAfterSeconds == nil || *body.RetryAfterSeconds != tt.want {
+				t.Errorf("body = %s, want retry_after_seconds %d", w.Body.String(), tt.want)
+			}
+			if body.Message == "" {
+				t.Error("message is empty")
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package pkg
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Feature flag names. Every flag gates an optional endpoint.
+const (
+	FeatureMailWebhook   = "mail_webhook"
+	FeatureBountyBatch   = "bounty_batch"
+	FeatureExport        = "export"
+	FeatureGithubSync    = "github_sync"
+	FeaturePublicProfile = "public_profile"
+)
+
+// Features shipped before flags existed stay enabled unless turned off
+var defaultFeatures = map[string]bool{
+	FeatureMailWebhook:   true,
+	FeatureBountyBatch:   true,
+	FeatureExport:        true,
+	FeatureGithubSync:    true,
+	FeaturePublicProfile: true,
+}
+
+var (
+	featureMu   sync.RWMutex
+	envFeatures = map[string]bool{}
+	features    = defaultFeatures
+)
+
+// Applies flags from the environment on top of the defaults. Must be called
+// once at startup.
+func InitFeatureFlags(flags map[string]bool) error {
+	for name := range flags {
+		if _, ok := defaultFeatures[name]; !ok {
+			return fmt.Errorf("Unknown feature flag: %s", name)
+		}
+	}
+
+	featureMu.Lock()
+	defer featureMu.Unlock()
+	envFeatures = flags
+	features = mergeFeatures(flags, nil)
+	return nil
+}
+
+// Applies flags stored in the database, which take precedence over the
+// environment. Unknown names are ignored so that rows for flags of a newer
+// release do not break older instances.
+func SetDBFeatureFlags(flags map[string]bool) {
+	featureMu.Lock()
+	defer featureMu.Unlock()
+	features = mergeFeatures(envFeatures, flags)
+}
+
+// Reports whether the named feature is enabled. Unknown features are off.
+func FeatureEnabled(name string) bool {
+	featureMu.RLock()
+	defer featureMu.RUnlock()
+	return features[name]
+}
+
+func mergeFeatures(layers ...map[string]bool) map[string]bool {
+	merged := make(map[string]bool, len(defaultFeatures))
+	for name, enabled := range defaultFeatures {
+		merged[name] = enabled
+		for _, layer := range layers {
+			if value, ok := layer[name]; ok {
+				merged[name] = value
+			}
+		}
+	}
+	return merged
+}
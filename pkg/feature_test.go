@@ -0,0 +1,69 @@
+package pkg
+
+import "testing"
+
+func TestFeatureEnabled(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]bool
+		db      map[string]bool // nil when no rows were loaded
+		want    map[string]bool
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			want: map[string]bool{FeatureBountyBatch: true, FeatureExport: true, "unknown": false},
+		},
+		{
+			name: "disabled by the environment",
+			env:  map[string]bool{FeatureBountyBatch: false},
+			want: map[string]bool{FeatureBountyBatch: false, FeatureExport: true},
+		},
+		{
+			name: "database over the environment",
+			env:  map[string]bool{FeatureBountyBatch: false, FeatureExport: false},
+			db:   map[string]bool{FeatureBountyBatch: true},
+			want: map[string]bool{FeatureBountyBatch: true, FeatureExport: false},
+		},
+		{
+			// Rows for flags of a newer release are ignored
+			name: "unknown database flag",
+			db:   map[string]bool{"google_oauth": true, FeatureMailWebhook: false},
+			want: map[string]bool{"google_oauth": false, FeatureMailWebhook: false},
+		},
+		{
+			name:    "unknown environment flag",
+			env:     map[string]bool{"google_oauth": true},
+			wantErr: true,
+		},
+	}
+
+	t.Cleanup(func() { InitFeatureFlags(nil) })
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := InitFeatureFlags(tt.env)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.db != nil {
+				SetDBFeatureFlags(tt.db)
+			}
+			for name, want := range tt.want {
+				if got := FeatureEnabled(name); got != want {
+					t.Errorf("FeatureEnabled(%q) = %v, want %v", name, got, want)
+				}
+			}
+		})
+	}
+
+	// Removing a row falls back to the environment
+	if err := InitFeatureFlags(map[string]bool{FeatureExport: false}); err != nil {
+		t.Fatal(err)
+	}
+	SetDBFeatureFlags(map[string]bool{FeatureExport: true})
+	SetDBFeatureFlags(map[string]bool{})
+	if FeatureEnabled(FeatureExport) {
+		t.Error("export enabled after its row was removed")
+	}
+}
@@ -0,0 +1,45 @@
+package pkg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Coarse description of a client. The IP is reduced to its network (/16 for
+// IPv4, /48 for IPv6) so that ordinary address changes within a provider do
+// not alter it.
+type Fingerprint struct {
+	UaHash  string
+	IpClass string
+}
+
+func ClientFingerprint(c *gin.Context) Fingerprint {
+	sum := sha256.Sum256([]byte(c.Request.UserAgent()))
+	return Fingerprint{
+		UaHash:  hex.EncodeToString(sum[:]),
+		IpClass: ipClass(ClientIP(c)),
+	}
+}
+
+// Only a change of both the user agent and the network is treated as a
+// theft signal. Fingerprints which were never recorded always match.
+func (f Fingerprint) Matches(other Fingerprint) bool {
+	if f.UaHash == "" || other.UaHash == "" {
+		return true
+	}
+	return f.UaHash == other.UaHash || f.IpClass == other.IpClass
+}
+
+func ipClass(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(16, 32)).String() + "/16"
+	}
+	return parsed.Mask(net.CIDRMask(48, 128)).String() + "/48"
+}
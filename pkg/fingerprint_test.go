@@ -0,0 +1,93 @@
+package pkg
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	firefox = "Mozilla/5.0 (X11; Linux x86_64; rv:131.0) Gecko/20100101 Firefox/131.0"
+	curl    = "curl/8.10.1"
+)
+
+func fingerprintOf(t *testing.T, userAgent, remoteAddr string) Fingerprint {
+	t.Helper()
+	router := gin.New()
+	if err := router.SetTrustedProxies(nil); err != nil {
+		t.Fatal(err)
+	}
+	var fingerprint Fingerprint
+	router.GET("/", func(c *gin.Context) {
+		fingerprint = ClientFingerprint(c)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("User-Agent", userAgent)
+	req.RemoteAddr = remoteAddr
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	return fingerprint
+}
+
+func TestClientFingerprint(t *testing.T) {
+	tests := []struct {
+		name        string
+		remoteAddr  string
+		wantIpClass string
+	}{
+		{name: "IPv4", remoteAddr: "203.0.113.7:52100", wantIpClass: "203.0.0.0/16"},
+		{name: "IPv4-mapped IPv6", remoteAddr: "[::ffff:203.0.113.7]:52100", wantIpClass: "203.0.0.0/16"},
+		{name: "IPv6", remoteAddr: "[2001:db8:1234:5678::1]:52100", wantIpClass: "2001:db8:1234::/48"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fingerprint := fingerprintOf(t, firefox, tt.remoteAddr)
+			if fingerprint.IpClass != tt.wantIpClass {
+				t.Errorf("IpClass = %q, want %q", fingerprint.IpClass, tt.wantIpClass)
+			}
+			if len(fingerprint.UaHash) != 64 {
+				t.Errorf("UaHash = %q, want a hex SHA-256", fingerprint.UaHash)
+			}
+		})
+	}
+
+	// Only a hash of the user agent is kept
+	if got := fingerprintOf(t, firefox, "203.0.113.7:52100").UaHash; got == firefox {
+		t.Error("UaHash holds the user agent itself")
+	}
+}
+
+func TestFingerprintMatches(t *testing.T) {
+	issuedTo := fingerprintOf(t, firefox, "203.0.113.7:52100")
+
+	tests := []struct {
+		name       string
+		userAgent  string
+		remoteAddr string
+		want       bool
+	}{
+		{name: "same client", userAgent: firefox, remoteAddr: "203.0.113.7:52100", want: true},
+		{name: "new address on the same network", userAgent: firefox, remoteAddr: "203.0.200.1:40000", want: true},
+		{name: "same browser on another network", userAgent: firefox, remoteAddr: "198.51.100.1:40000", want: true},
+		{name: "other client on the same network", userAgent: curl, remoteAddr: "203.0.113.8:40000", want: true},
+		{name: "other client on another network", userAgent: curl, remoteAddr: "198.51.100.1:40000", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			presented := fingerprintOf(t, tt.userAgent, tt.remoteAddr)
+			if got := issuedTo.Matches(presented); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Sessions issued before fingerprints were recorded keep working
+	presented := fingerprintOf(t, curl, "198.51.100.1:40000")
+	if !(Fingerprint{}).Matches(presented) {
+		t.Error("unrecorded fingerprint does not match")
+	}
+}
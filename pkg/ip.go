@@ -0,0 +1,23 @@
+package pkg
+
+import (
+	"net"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ClientIP returns the address of the client that made the request. Behind
+// a load balancer listed in TRUSTED_PROXIES, the right-most untrusted entry
+// of X-Forwarded-For is used; otherwise the peer address, so that clients
+// cannot spoof it. IPv4-mapped IPv6 addresses are reported as IPv4.
+func ClientIP(c *gin.Context) string {
+	ip := net.ParseIP(c.ClientIP())
+	if ip == nil {
+		host, _, err := net.SplitHostPort(c.Request.RemoteAddr)
+		if err != nil {
+			return c.Request.RemoteAddr
+		}
+		return host
+	}
+	return ip.String()
+}
@@ -0,0 +1,93 @@
+package pkg
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name           string
+		trustedProxies []string
+		remoteAddr     string
+		forwardedFor   string
+		want           string
+	}{
+		{
+			name:       "direct",
+			remoteAddr: "203.0.113.7:52100",
+			want:       "203.0.113.7",
+		},
+		{
+			name:         "forwarded header without trusted proxies",
+			remoteAddr:   "203.0.113.7:52100",
+			forwardedFor: "198.51.100.1",
+			want:         "203.0.113.7",
+		},
+		{
+			name:           "through a trusted proxy",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "10.1.2.3:443",
+			forwardedFor:   "198.51.100.1",
+			want:           "198.51.100.1",
+		},
+		{
+			name:           "spoofed entry before the client",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "10.1.2.3:443",
+			forwardedFor:   "192.0.2.99, 198.51.100.1",
+			want:           "198.51.100.1",
+		},
+		{
+			name:           "through two trusted proxies",
+			trustedProxies: []string{"10.0.0.0/8", "172.16.0.5"},
+			remoteAddr:     "10.1.2.3:443",
+			forwardedFor:   "198.51.100.1, 172.16.0.5",
+			want:           "198.51.100.1",
+		},
+		{
+			name:           "forwarded header from an untrusted peer",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "203.0.113.7:52100",
+			forwardedFor:   "198.51.100.1",
+			want:           "203.0.113.7",
+		},
+		{
+			name:       "IPv4-mapped IPv6 peer",
+			remoteAddr: "[::ffff:203.0.113.7]:52100",
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "IPv6 peer",
+			remoteAddr: "[2001:db8::1]:52100",
+			want:       "2001:db8::1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			if err := router.SetTrustedProxies(tt.trustedProxies); err != nil {
+				t.Fatal(err)
+			}
+			var got string
+			router.GET("/", func(c *gin.Context) {
+				got = ClientIP(c)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+			router.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got != tt.want {
+				t.Errorf("ClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package pkg
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	DefaultListLimit = 20
+	MaxListLimit     = 100
+)
+
+type ListParams struct {
+	Limit     int
+	Offset    int
+	Cursor    string
+	SortField string // always one of the allowed sort fields
+	SortDesc  bool
+}
+
+// ParseListParams reads limit, offset, cursor and sort from the query string.
+// Sort takes the form "field" or "-field" for descending order and must be
+// one of allowedSortFields, which keeps dynamic ORDER BY clauses safe. The
+// first allowed field is used when no sort is requested.
+func ParseListParams(c *gin.Context, allowedSortFields []string) (ListParams, error) {
+	params := ListParams{Limit: DefaultListLimit}
+
+	if limit := c.Query("limit"); limit != "" {
+		value, err := strconv.Atoi(limit)
+		if err != nil || value < 1 {
+			return params, fmt.Errorf("limit must be a positive integer")
+		}
+		params.Limit = min(value, MaxListLimit)
+	}
+
+	if offset := c.Query("offset"); offset != "" {
+		value, err := strconv.Atoi(offset)
+		if err != nil || value < 0 {
+			return params, fmt.Errorf("offset must be a non-negative integer")
+		}
+		params.Offset = value
+	}
+
+	params.Cursor = strings.TrimSpace(c.Query("cursor"))
+	if params.Cursor != "" && params.Offset > 0 {
+		return params, fmt.Errorf("offset and cursor cannot be used together")
+	}
+
+	sort := strings.TrimSpace(c.Query("sort"))
+	if sort == "" {
+		if len(allowedSortFields) > 0 {
+			params.SortField = allowedSortFields[0]
+		}
+		return params, nil
+	}
+	if strings.HasPrefix(sort, "-") {
+		params.SortDesc = true
+		sort = sort[1:]
+	}
+	if !slices.Contains(allowedSortFields, sort) {
+		return params, fmt.Errorf("sort must be one of: %s", strings.Join(allowedSortFields, ", "))
+	}
+	params.SortField = sort
+	return params, nil
+}
@@ -0,0 +1,50 @@
+package pkg
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestParseListParams(t *testing.T) {
+	allowed := []string{"bounty", "ghusername"}
+	tests := []struct {
+		name    string
+		query   string
+		want    ListParams
+		wantErr bool
+	}{
+		{name: "defaults", query: "", want: ListParams{Limit: DefaultListLimit, SortField: "bounty"}},
+		{name: "limit", query: "?limit=50", want: ListParams{Limit: 50, SortField: "bounty"}},
+		{name: "limit capped", query: "?limit=1000", want: ListParams{Limit: MaxListLimit, SortField: "bounty"}},
+		{name: "limit of one", query: "?limit=1", want: ListParams{Limit: 1, SortField: "bounty"}},
+		{name: "zero limit", query: "?limit=0", wantErr: true},
+		{name: "negative limit", query: "?limit=-5", wantErr: true},
+		{name: "non-numeric limit", query: "?limit=ten", wantErr: true},
+		{name: "offset", query: "?offset=40", want: ListParams{Limit: DefaultListLimit, Offset: 40, SortField: "bounty"}},
+		{name: "negative offset", query: "?offset=-1", wantErr: true},
+		{name: "cursor", query: "?cursor=abc", want: ListParams{Limit: DefaultListLimit, Cursor: "abc", SortField: "bounty"}},
+		{name: "offset with cursor", query: "?offset=10&cursor=abc", wantErr: true},
+		{name: "sort", query: "?sort=ghusername", want: ListParams{Limit: DefaultListLimit, SortField: "ghusername"}},
+		{name: "sort descending", query: "?sort=-bounty", want: ListParams{Limit: DefaultListLimit, SortField: "bounty", SortDesc: true}},
+		{name: "disallowed sort", query: "?sort=email", wantErr: true},
+		{name: "injected sort", query: "?sort=bounty%3BDROP%20TABLE%20user_account", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/leaderboard"+tt.query, nil)
+
+			got, err := ParseListParams(c, allowed)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseListParams() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParseListParams() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package pkg
+
+import (
+	"errors"
+	"strings"
+	"time"
+	_ "time/tzdata" // hosts without a zone database still validate zones
+
+	"golang.org/x/text/language"
+)
+
+// Used when a user does not give a time zone or locale at registration
+const (
+	DefaultTimezone = "UTC"
+	DefaultLocale   = "en"
+)
+
+var (
+	ErrInvalidTimezone = errors.New("must be an IANA time zone, e.g. Asia/Kolkata")
+	ErrInvalidLocale   = errors.New("must be a BCP 47 language tag, e.g. en-IN")
+)
+
+// "Local" is accepted by time.LoadLocation but names the server's zone
+func CheckTimezone(timezone string) error {
+	if timezone == "" || timezone == "Local" {
+		return ErrInvalidTimezone
+	}
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return ErrInvalidTimezone
+	}
+	return nil
+}
+
+func CheckLocale(locale string) error {
+	tag, err := language.Parse(locale)
+	if err != nil || tag == language.Und {
+		return ErrInvalidLocale
+	}
+	return nil
+}
+
+// Returns the canonical form of a language tag, e.g. "en-IN" for "en_in".
+// Invalid tags are returned trimmed, for CheckLocale to reject.
+func NormalizeLocale(locale string) string {
+	locale = strings.TrimSpace(locale)
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return locale
+	}
+	return tag.String()
+}
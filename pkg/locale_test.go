@@ -0,0 +1,57 @@
+package pkg
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckTimezone(t *testing.T) {
+	tests := []struct {
+		timezone string
+		wantErr  bool
+	}{
+		{timezone: "Asia/Kolkata"},
+		{timezone: "America/Argentina/Buenos_Aires"},
+		{timezone: "UTC"},
+		{timezone: "", wantErr: true},
+		{timezone: "Local", wantErr: true},
+		{timezone: "Asia/Bangalore", wantErr: true},
+		{timezone: "IST+5:30", wantErr: true},
+		{timezone: "../../etc/passwd", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		err := CheckTimezone(tt.timezone)
+		if tt.wantErr != (err != nil) || (err != nil && !errors.Is(err, ErrInvalidTimezone)) {
+			t.Errorf("CheckTimezone(%q) = %v, want error %v", tt.timezone, err, tt.wantErr)
+		}
+	}
+}
+
+func TestLocale(t *testing.T) {
+	tests := []struct {
+		locale  string
+		want    string // normalized
+		wantErr bool
+	}{
+		{locale: "en", want: "en"},
+		{locale: "en-IN", want: "en-IN"},
+		{locale: " en_in ", want: "en-IN"},
+		{locale: "zh-Hant-TW", want: "zh-Hant-TW"},
+		{locale: "", want: "", wantErr: true},
+		{locale: "und", want: "und", wantErr: true},
+		{locale: "english", want: "english", wantErr: true},
+		{locale: "en-IN-", want: "en-IN-", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got := NormalizeLocale(tt.locale)
+		if got != tt.want {
+			t.Errorf("NormalizeLocale(%q) = %q, want %q", tt.locale, got, tt.want)
+		}
+		err := CheckLocale(got)
+		if tt.wantErr != (err != nil) || (err != nil && !errors.Is(err, ErrInvalidLocale)) {
+			t.Errorf("CheckLocale(%q) = %v, want error %v", got, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package pkg
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/IAmRiteshKoushik/pulse/cmd"
+)
+
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half_open"
+)
+
+// Consecutive connection failures trip the mail breaker, mails then fail fast
+// until the cooldown has passed and a single trial send gets through
+const (
+	mailBreakerThreshold = 5
+	mailBreakerCooldown  = 30 * time.Second
+)
+
+var mailBreaker = NewBreaker("mail", mailBreakerThreshold, mailBreakerCooldown)
+
+// Breaker is a circuit breaker guarding calls to a flaky dependency. Every
+// change of state is logged and counted in pulse_breaker_transitions_total.
+type Breaker struct {
+	name      string
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	trial    bool // a half-open trial call is in flight
+}
+
+func NewBreaker(name string, threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{
+		name:      name,
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+		state:     BreakerClosed,
+	}
+}
+
+func (b *Breaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Allow reports whether a call may go ahead. Once the cooldown has passed an
+// open breaker lets exactly one trial call through.
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.transition(BreakerHalfOpen)
+		b.trial = true
+		return true
+	case BreakerHalfOpen:
+		if b.trial {
+			return false
+		}
+		b.trial = true
+		return true
+	}
+	return true
+}
+
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.trial = false
+	if b.state != BreakerClosed {
+		b.transition(BreakerClosed)
+	}
+}
+
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.trial = false
+	if b.state == BreakerHalfOpen || (b.state == BreakerClosed && b.failures >= b.threshold) {
+		b.openedAt = b.now()
+		b.transition(BreakerOpen)
+	}
+}
+
+// Called with b.mu held
+func (b *Breaker) transition(to string) {
+	from := b.state
+	b.state = to
+	cmd.BreakerTransitionsTotal.WithLabelValues(b.name, to).Inc()
+
+	switch to {
+	case BreakerOpen:
+		msg := fmt.Sprintf("[BREAKER]: %s breaker tripped after %d consecutive failures, retrying in %s",
+			b.name, b.failures, b.cooldown)
+		cmd.Log.Warn(msg)
+		if from == BreakerClosed {
+			NotifyStaff(StaffBreakerTripped, msg)
+		}
+	case BreakerHalfOpen:
+		cmd.Log.Info(fmt.Sprintf("[BREAKER]: %s breaker is half-open, sending a trial call", b.name))
+	case BreakerClosed:
+		cmd.Log.Info(fmt.Sprintf("[BREAKER]: %s breaker recovered", b.name))
+	}
+}
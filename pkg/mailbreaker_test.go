@@ -0,0 +1,170 @@
+package pkg
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/IAmRiteshKoushik/pulse/cmd"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Counter value of pulse_breaker_transitions_total for the given labels
+func breakerTransitions(t *testing.T, name string, state string) float64 {
+	t.Helper()
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(cmd.BreakerTransitionsTotal)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["breaker"] == name && labels["state"] == state {
+				return metric.GetCounter().GetValue()
+			}
+		}
+	}
+	return 0
+}
+
+func TestBreaker(t *testing.T) {
+	type step struct {
+		advance time.Duration
+		call    string // "allow", "success" or "failure"
+		allowed bool   // expected result of "allow"
+		state   string // expected state after the step
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "stays closed below the threshold",
+			steps: []step{
+				{call: "failure", state: BreakerClosed},
+				{call: "failure", state: BreakerClosed},
+				{call: "allow", allowed: true, state: BreakerClosed},
+			},
+		},
+		{
+			name: "success resets the failure count",
+			steps: []step{
+				{call: "failure", state: BreakerClosed},
+				{call: "failure", state: BreakerClosed},
+				{call: "success", state: BreakerClosed},
+				{call: "failure", state: BreakerClosed},
+				{call: "failure", state: BreakerClosed},
+			},
+		},
+		{
+			name: "trips at the threshold and fails fast",
+			steps: []step{
+				{call: "failure", state: BreakerClosed},
+				{call: "failure", state: BreakerClosed},
+				{call: "failure", state: BreakerOpen},
+				{call: "allow", allowed: false, state: BreakerOpen},
+				{advance: 59 * time.Second, call: "allow", allowed: false, state: BreakerOpen},
+			},
+		},
+		{
+			name: "lets a single trial through after the cooldown",
+			steps: []step{
+				{call: "failure", state: BreakerClosed},
+				{call: "failure", state: BreakerClosed},
+				{call: "failure", state: BreakerOpen},
+				{advance: time.Minute, call: "allow", allowed: true, state: BreakerHalfOpen},
+				{call: "allow", allowed: false, state: BreakerHalfOpen},
+				{call: "success", state: BreakerClosed},
+				{call: "allow", allowed: true, state: BreakerClosed},
+			},
+		},
+		{
+			name: "reopens when the trial fails",
+			steps: []step{
+				{call: "failure", state: BreakerClosed},
+				{call: "failure", state: BreakerClosed},
+				{call: "failure", state: BreakerOpen},
+				{advance: time.Minute, call: "allow", allowed: true, state: BreakerHalfOpen},
+				{call: "failure", state: BreakerOpen},
+				{call: "allow", allowed: false, state: BreakerOpen},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Unix(0, 0)
+			b := NewBreaker("test", 3, time.Minute)
+			b.now = func() time.Time { return now }
+
+			for i, s := range tt.steps {
+				now = now.Add(s.advance)
+				switch s.call {
+				case "allow":
+					if got := b.Allow(); got != s.allowed {
+						t.Fatalf("step %d: Allow() = %v, want %v", i, got, s.allowed)
+					}
+				case "success":
+					b.Success()
+				case "failure":
+					b.Failure()
+				}
+				if got := b.State(); got != s.state {
+					t.Fatalf("step %d: state = %s, want %s", i, got, s.state)
+				}
+			}
+		})
+	}
+}
+
+func TestBreakerTripNotifiesStaff(t *testing.T) {
+	received := make(chan staffNotification, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n staffNotification
+		if err := json.NewDecoder(r.Body).Decode(&n); err != nil {
+			t.Errorf("decoding notification: %v", err)
+		}
+		received <- n
+	}))
+	defer server.Close()
+
+	env := cmd.EnvVars
+	cmd.EnvVars = &cmd.EnvConfig{StaffNotifyWebhook: server.URL}
+	defer func() { cmd.EnvVars = env }()
+
+	name := "notify-test"
+	opened := breakerTransitions(t, name, BreakerOpen)
+	closed := breakerTransitions(t, name, BreakerClosed)
+
+	now := time.Unix(0, 0)
+	b := NewBreaker(name, 2, time.Minute)
+	b.now = func() time.Time { return now }
+	b.Failure()
+	b.Failure()
+
+	select {
+	case n := <-received:
+		if n.Event != StaffBreakerTripped {
+			t.Errorf("event = %q, want %q", n.Event, StaffBreakerTripped)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no staff notification after the breaker tripped")
+	}
+	if got := breakerTransitions(t, name, BreakerOpen); got != opened+1 {
+		t.Errorf("open transitions = %v, want %v", got, opened+1)
+	}
+
+	now = now.Add(time.Minute)
+	b.Allow()
+	b.Success()
+	if got := breakerTransitions(t, name, BreakerClosed); got != closed+1 {
+		t.Errorf("closed transitions = %v, want %v", got, closed+1)
+	}
+}
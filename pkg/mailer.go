@@ -1,34 +1,244 @@
 package pkg
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"html"
+	"io"
+	"net"
+	"net/textproto"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/IAmRiteshKoushik/pulse/cmd"
 	"gopkg.in/gomail.v2"
 )
 
-func SendMail(to []string, otp string) error {
+// Outcome of sending a mail to a single recipient. MessageId is the
+// Message-ID without angle brackets, used to match delivery events from the
+// provider.
+type MailResult struct {
+	Recipient string
+	MessageId string
+	Err       error
+}
 
-	subject := "OTP for Onboarding ACM's Season of Code 2025"
-	body := fmt.Sprintf("Your OTP for logging into the Season of Code is %s. This is valid for only 5 minutes.", otp)
+const (
+	MailFormatText      = "text"
+	MailFormatHtml      = "html"
+	MailFormatMultipart = "multipart"
+)
 
-	m := gomail.NewMessage()
-	m.SetHeader("From", cmd.EnvVars.GmailUser)
-	m.SetHeader("To", to...)
-	m.SetHeader("subject", subject)
-	m.SetBody("text/plain", body)
+// Content of a mail. Html is optional, mails without it are always sent as
+// plain text.
+type MailBody struct {
+	Text string
+	Html string
+}
+
+// Only OTP mails have a configurable format, as some SMTP relays mangle HTML
+func mailFormat(kind MailType) string {
+	if kind == MailOtp {
+		return cmd.EnvVars.OtpMailFormat
+	}
+	return MailFormatMultipart
+}
+
+// Sends a mail of the given type to each recipient separately, the subject
+// being rendered from its template, so that one undeliverable address does not
+// fail the others. Results are in the order of to.
+func SendMail(kind MailType, to []string, body MailBody) []MailResult {
+	results := make([]MailResult, len(to))
+	for i, recipient := range to {
+		results[i].Recipient = recipient
+	}
+	subject, err := MailSubject(kind)
+	if err != nil {
+		for i := range results {
+			results[i].Err = err
+		}
+		return results
+	}
+
+	// The connection is shared by every recipient
+	var sender gomail.SendCloser
+	defer func() {
+		if sender != nil {
+			sender.Close()
+		}
+	}()
+	for i := range results {
+		results[i].MessageId, results[i].Err = sendOne(&sender, results[i].Recipient, subject, body, mailFormat(kind))
+		if results[i].Err != nil {
+			continue
+		}
+		if kind == MailOtp {
+			cmd.OtpSentTotal.Inc()
+		}
+		cmd.Log.Info("[SUCCESS]: Email send successfully.")
+	}
+	return results
+}
+
+// Sends a single message over *sender, dialing when there is no connection.
+// A dropped connection is retried once with the same Message-ID so that
+// receivers can discard a duplicate if the first attempt did get through.
+func sendOne(sender *gomail.SendCloser, to string, subject string, body MailBody,
+	format string) (string, error) {
+	m, err := newMessage([]string{to}, subject, body, format)
+	if err != nil {
+		return "", err
+	}
+
+	for attempt := 1; ; attempt++ {
+		if !mailBreaker.Allow() {
+			return "", fmt.Errorf("%w: mail breaker is open", ErrMailConnection)
+		}
+		if *sender == nil {
+			*sender, err = newDialer().Dial()
+		}
+		if err == nil {
+			err = gomail.Send(*sender, m)
+			if err == nil {
+				mailBreaker.Success()
+				return strings.Trim(m.GetHeader("Message-ID")[0], "<>"), nil
+			}
+			// The SMTP session is in an unknown state after a failure
+			(*sender).Close()
+		}
+		*sender = nil
+
+		// Only an unreachable server counts against the breaker, a rejected
+		// recipient means it is up
+		err = classifyMailError(err)
+		if errors.Is(err, ErrMailConnection) {
+			mailBreaker.Failure()
+		} else {
+			mailBreaker.Success()
+		}
+		if attempt > 1 || !errors.Is(err, ErrMailConnection) {
+			return "", err
+		}
+	}
+}
+
+func OtpMailBody(otp string) MailBody {
+	return MailBody{
+		Text: fmt.Sprintf("Your OTP for logging into the Season of Code is %s. This is valid for only 5 minutes.", otp),
+		Html: fmt.Sprintf("<p>Your OTP for logging into the Season of Code is</p>"+
+			"<p style=\"font-size:24px;font-weight:bold;letter-spacing:4px\">%s</p>"+
+			"<p>This is valid for only 5 minutes.</p>", html.EscapeString(otp)),
+	}
+}
+
+// Notifies the user of a login from an unrecognised IP address
+func SendLoginAlertMail(to string, ip string, userAgent string) error {
+	body := LoginAlertMailBody(ip, userAgent, time.Now())
+	return SendMail(MailLoginAlert, []string{to}, body)[0].Err
+}
+
+func LoginAlertMailBody(ip string, userAgent string, at time.Time) MailBody {
+	return MailBody{
+		Text: fmt.Sprintf("A new login to your Season of Code account was detected.\n\n"+
+			"IP address: %s\nDevice: %s\nTime: %s\n\n"+
+			"If this was not you, please revoke your sessions and contact the organisers.",
+			ip, userAgent, at.UTC().Format(time.RFC1123)),
+	}
+}
+
+var (
+	ErrMailAuth              = errors.New("smtp authentication failed")
+	ErrMailConnection        = errors.New("smtp server unreachable")
+	ErrMailRecipientRejected = errors.New("smtp recipient rejected")
+)
+
+// gomail flattens errors raised while sending with %v, leaving only the SMTP
+// reply in the message, e.g. "gomail: could not send email 1: 550 5.1.1 ..."
+var smtpReplyCode = regexp.MustCompile(`(?:^|: )([2-5][0-9]{2}) `)
+
+// Flattened errors of a connection dropped mid-session
+var smtpDropped = regexp.MustCompile(`(?:: (?:unexpected )?EOF|broken pipe|connection reset by peer)$`)
+
+// Wraps err with one of the ErrMail* errors when its cause is recognised
+func classifyMailError(err error) error {
+	code := 0
+	var tpErr *textproto.Error
+	if errors.As(err, &tpErr) {
+		code = tpErr.Code
+	} else if match := smtpReplyCode.FindStringSubmatch(err.Error()); match != nil {
+		code, _ = strconv.Atoi(match[1])
+	}
+
+	switch code {
+	case 530, 534, 535:
+		return fmt.Errorf("%w: %s", ErrMailAuth, err)
+	case 550, 551, 553:
+		return fmt.Errorf("%w: %s", ErrMailRecipientRejected, err)
+	case 421:
+		return fmt.Errorf("%w: %s", ErrMailConnection, err)
+	}
 
-	d := gomail.NewDialer(
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		smtpDropped.MatchString(err.Error()) {
+		return fmt.Errorf("%w: %s", ErrMailConnection, err)
+	}
+	return err
+}
+
+func newDialer() *gomail.Dialer {
+	return gomail.NewDialer(
 		cmd.EnvVars.SmtpHost,
 		cmd.EnvVars.SmtpPort,
 		cmd.EnvVars.GmailUser,
 		cmd.EnvVars.AppPassword,
 	)
+}
+
+// Headers are set explicitly as mails missing them are more likely to be
+// marked as spam. gomail takes care of CRLF line endings. The multipart format
+// is sent as multipart/alternative, with the plain text part first.
+func newMessage(to []string, subject string, body MailBody, format string) (*gomail.Message, error) {
+	msgId, err := newMessageId(cmd.EnvVars.MailFromAddr)
+	if err != nil {
+		return nil, err
+	}
 
-	if err := d.DialAndSend(m); err != nil {
-		return err
+	m := gomail.NewMessage()
+	m.SetHeader("Mime-Version", "1.0")
+	m.SetHeader("Message-ID", msgId)
+	m.SetDateHeader("Date", time.Now())
+	m.SetAddressHeader("From", cmd.EnvVars.MailFromAddr, cmd.EnvVars.MailFromName)
+	if cmd.EnvVars.MailReplyTo != "" {
+		m.SetHeader("Reply-To", cmd.EnvVars.MailReplyTo)
+	}
+	m.SetHeader("To", to...)
+	m.SetHeader("Subject", subject)
+	switch {
+	case body.Html == "" || format == MailFormatText:
+		m.SetBody("text/plain", body.Text)
+	case format == MailFormatHtml:
+		m.SetBody("text/html", body.Html)
+	default:
+		m.SetBody("text/plain", body.Text)
+		m.AddAlternative("text/html", body.Html)
 	}
+	return m, nil
+}
 
-	cmd.Log.Info("[SUCCESS]: Email send successfully.")
-	return nil
+// Message-ID of the form <unix-nano.random@sender-domain> as per RFC 5322
+func newMessageId(from string) (string, error) {
+	random := make([]byte, 8)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	domain := "localhost"
+	if _, d, found := strings.Cut(from, "@"); found && d != "" {
+		domain = d
+	}
+	return fmt.Sprintf("<%d.%s@%s>", time.Now().UnixNano(), hex.EncodeToString(random), domain), nil
 }
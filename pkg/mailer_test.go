@@ -0,0 +1,569 @@
+package pkg
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"net/textproto"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IAmRiteshKoushik/pulse/cmd"
+	"github.com/gin-gonic/gin"
+)
+
+// Composes a message with the sender settings of env and parses it back
+func composeMail(t *testing.T, env cmd.EnvConfig, body MailBody, format string) *mail.Message {
+	t.Helper()
+	saved := cmd.EnvVars
+	cmd.EnvVars = &env
+	t.Cleanup(func() { cmd.EnvVars = saved })
+
+	m, err := newMessage([]string{"octocat@example.com"}, "Your OTP", body, format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var raw bytes.Buffer
+	if _, err := m.WriteTo(&raw); err != nil {
+		t.Fatal(err)
+	}
+	msg, err := mail.ReadMessage(&raw)
+	if err != nil {
+		t.Fatalf("composed message does not parse: %v\n%s", err, raw.String())
+	}
+	return msg
+}
+
+func TestNewMessageSender(t *testing.T) {
+	tests := []struct {
+		name        string
+		env         cmd.EnvConfig
+		wantFrom    mail.Address
+		wantReplyTo string // empty when the header must be absent
+	}{
+		{
+			name:     "from only",
+			env:      cmd.EnvConfig{MailFromName: "ACM Season of Code", MailFromAddr: "pulse@example.com"},
+			wantFrom: mail.Address{Name: "ACM Season of Code", Address: "pulse@example.com"},
+		},
+		{
+			name: "with reply-to",
+			env: cmd.EnvConfig{
+				MailFromName: "Pulse Team",
+				MailFromAddr: "noreply@example.com",
+				MailReplyTo:  "support@example.com",
+			},
+			wantFrom:    mail.Address{Name: "Pulse Team", Address: "noreply@example.com"},
+			wantReplyTo: "support@example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := composeMail(t, tt.env, OtpMailBody("123456"), MailFormatText)
+
+			from, err := msg.Header.AddressList("From")
+			if err != nil || len(from) != 1 || *from[0] != tt.wantFrom {
+				t.Errorf("From = %v (%v), want %v", from, err, tt.wantFrom)
+			}
+			if got := msg.Header.Get("Reply-To"); got != tt.wantReplyTo {
+				t.Errorf("Reply-To = %q, want %q", got, tt.wantReplyTo)
+			}
+			if got := msg.Header.Get("To"); got != "octocat@example.com" {
+				t.Errorf("To = %q", got)
+			}
+		})
+	}
+}
+
+func TestNewMessageFormat(t *testing.T) {
+	tests := []struct {
+		name          string
+		env           cmd.EnvConfig
+		format        string
+		wantMsgDomain string
+		wantType      string
+	}{
+		{
+			name:          "plain text",
+			env:           cmd.EnvConfig{MailFromAddr: "pulse@example.com"},
+			format:        MailFormatText,
+			wantMsgDomain: "example.com",
+			wantType:      "text/plain",
+		},
+		{
+			name:          "multipart",
+			env:           cmd.EnvConfig{MailFromAddr: "noreply@mail.example.org"},
+			format:        MailFormatMultipart,
+			wantMsgDomain: "mail.example.org",
+			wantType:      "multipart/alternative",
+		},
+		{
+			name:          "no sender domain",
+			format:        MailFormatText,
+			wantMsgDomain: "localhost",
+			wantType:      "text/plain",
+		},
+	}
+
+	messageId := regexp.MustCompile(`^<[0-9]+\.[0-9a-f]{16}@([^<>@\s]+)>$`)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := time.Now().Add(-time.Second)
+			msg := composeMail(t, tt.env, OtpMailBody("123456"), tt.format)
+
+			match := messageId.FindStringSubmatch(msg.Header.Get("Message-ID"))
+			if match == nil {
+				t.Fatalf("Message-ID = %q is malformed", msg.Header.Get("Message-ID"))
+			}
+			if match[1] != tt.wantMsgDomain {
+				t.Errorf("Message-ID domain = %q, want %q", match[1], tt.wantMsgDomain)
+			}
+			date, err := msg.Header.Date()
+			if err != nil || date.Before(before) || date.After(time.Now().Add(time.Second)) {
+				t.Errorf("Date = %q (%v)", msg.Header.Get("Date"), err)
+			}
+			if got := msg.Header.Get("Mime-Version"); got != "1.0" {
+				t.Errorf("MIME-Version = %q", got)
+			}
+			mediaType, _, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+			if err != nil || mediaType != tt.wantType {
+				t.Errorf("Content-Type = %q, want %s", msg.Header.Get("Content-Type"), tt.wantType)
+			}
+		})
+	}
+}
+
+func TestNewMessageLineEndings(t *testing.T) {
+	saved := cmd.EnvVars
+	cmd.EnvVars = &cmd.EnvConfig{MailFromAddr: "pulse@example.com"}
+	t.Cleanup(func() { cmd.EnvVars = saved })
+
+	m, err := newMessage([]string{"octocat@example.com"}, "Your OTP", OtpMailBody("123456"), MailFormatMultipart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var raw bytes.Buffer
+	if _, err := m.WriteTo(&raw); err != nil {
+		t.Fatal(err)
+	}
+	for i, line := range bytes.SplitAfter(raw.Bytes(), []byte("\n")) {
+		if len(line) > 0 && !bytes.HasSuffix(line, []byte("\r\n")) {
+			t.Fatalf("line %d does not end in CRLF: %q", i+1, line)
+		}
+	}
+	// Message-IDs must not repeat, or receivers discard the later mails
+	other, err := newMessage([]string{"octocat@example.com"}, "Your OTP", OtpMailBody("123456"), MailFormatMultipart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.GetHeader("Message-ID")[0] == other.GetHeader("Message-ID")[0] {
+		t.Errorf("Message-ID %s is reused", m.GetHeader("Message-ID")[0])
+	}
+}
+
+// SMTP server answering each command with a canned reply, so that every
+// failure SendMail has to tell apart can be played back
+type fakeSMTP struct {
+	addr      string
+	authReply string
+	mailReply string
+	rejected  map[string]bool // recipients answered with 550
+	dropData  int             // sessions hanging up after DATA without a reply
+
+	mu        sync.Mutex
+	sessions  int
+	delivered []string // DATA of every accepted message
+	dropped   []string // DATA of messages hung up on
+}
+
+func newFakeSMTP(t *testing.T, s *fakeSMTP) *fakeSMTP {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	s.addr = ln.Addr().String()
+	if s.authReply == "" {
+		s.authReply = "235 2.7.0 Accepted"
+	}
+	if s.mailReply == "" {
+		s.mailReply = "250 2.1.0 OK"
+	}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	s.mu.Lock()
+	s.sessions++
+	drop := s.sessions <= s.dropData
+	s.mu.Unlock()
+
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 smtp.example.com ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			tp.PrintfLine("250-smtp.example.com")
+			tp.PrintfLine("250 AUTH PLAIN")
+		case "AUTH":
+			tp.PrintfLine("%s", s.authReply)
+		case "MAIL":
+			tp.PrintfLine("%s", s.mailReply)
+		case "RCPT":
+			recipient := strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>")
+			if s.rejected[recipient] {
+				tp.PrintfLine("550 5.1.1 The email account that you tried to reach does not exist")
+			} else {
+				tp.PrintfLine("250 2.1.5 OK")
+			}
+		case "DATA":
+			tp.PrintfLine("354 Go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			if drop {
+				s.mu.Lock()
+				s.dropped = append(s.dropped, string(data))
+				s.mu.Unlock()
+				return
+			}
+			s.mu.Lock()
+			s.delivered = append(s.delivered, string(data))
+			s.mu.Unlock()
+			tp.PrintfLine("250 2.0.0 OK")
+		case "RSET", "NOOP":
+			tp.PrintfLine("250 2.0.0 OK")
+		case "QUIT":
+			tp.PrintfLine("221 2.0.0 Bye")
+			return
+		default:
+			tp.PrintfLine("502 5.5.1 Unrecognized command")
+		}
+	}
+}
+
+// Points the mailer at addr for the duration of the test
+func useSmtpServer(t *testing.T, addr string) {
+	t.Helper()
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	portNum, _ := strconv.Atoi(port)
+
+	savedEnv, savedBreaker := cmd.EnvVars, mailBreaker
+	savedSubjects, savedData := mailSubjects, mailSubjectData
+	cmd.EnvVars = &cmd.EnvConfig{
+		SmtpHost:      host,
+		SmtpPort:      portNum,
+		GmailUser:     "pulse@example.com",
+		AppPassword:   "password",
+		MailFromName:  "ACM Season of Code",
+		MailFromAddr:  "pulse@example.com",
+		OtpMailFormat: MailFormatText,
+	}
+	mailBreaker = NewBreaker("mail", mailBreakerThreshold, mailBreakerCooldown)
+	t.Cleanup(func() {
+		cmd.EnvVars, mailBreaker = savedEnv, savedBreaker
+		mailSubjects, mailSubjectData = savedSubjects, savedData
+	})
+	if err := InitMailSubjects("Season of Code", nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSendMailErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		server       *fakeSMTP // nil when nothing listens
+		wantErr      error
+		wantStatus   int // of the response MailError gives
+		wantSessions int
+	}{
+		{
+			name:         "delivered",
+			server:       &fakeSMTP{},
+			wantSessions: 1,
+		},
+		{
+			name:         "authentication failed",
+			server:       &fakeSMTP{authReply: "535 5.7.8 Username and Password not accepted"},
+			wantErr:      ErrMailAuth,
+			wantStatus:   http.StatusInternalServerError,
+			wantSessions: 1,
+		},
+		{
+			name:         "recipient rejected",
+			server:       &fakeSMTP{rejected: map[string]bool{"octocat@example.com": true}},
+			wantErr:      ErrMailRecipientRejected,
+			wantStatus:   http.StatusBadRequest,
+			wantSessions: 1,
+		},
+		{
+			// Retried once on a fresh connection
+			name:         "service unavailable",
+			server:       &fakeSMTP{mailReply: "421 4.7.0 Try again later, closing connection"},
+			wantErr:      ErrMailConnection,
+			wantStatus:   http.StatusServiceUnavailable,
+			wantSessions: 2,
+		},
+		{
+			name:       "server unreachable",
+			wantErr:    ErrMailConnection,
+			wantStatus: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := closedAddr(t)
+			if tt.server != nil {
+				addr = newFakeSMTP(t, tt.server).addr
+			}
+			useSmtpServer(t, addr)
+
+			result := SendMail(MailOtp, []string{"octocat@example.com"}, OtpMailBody("123456"))[0]
+			if tt.wantErr == nil && result.Err != nil {
+				t.Fatalf("error = %v", result.Err)
+			}
+			if tt.wantErr != nil && !errors.Is(result.Err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", result.Err, tt.wantErr)
+			}
+			if tt.server != nil {
+				tt.server.mu.Lock()
+				sessions, delivered := tt.server.sessions, tt.server.delivered
+				tt.server.mu.Unlock()
+				if sessions != tt.wantSessions {
+					t.Errorf("server saw %d sessions, want %d", sessions, tt.wantSessions)
+				}
+				if tt.wantErr == nil && (len(delivered) != 1 || !strings.Contains(delivered[0], result.MessageId)) {
+					t.Errorf("delivered %d messages, want the one with Message-ID %s", len(delivered), result.MessageId)
+				}
+			}
+			if tt.wantErr == nil {
+				return
+			}
+
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/auth/register", nil)
+			MailError(c, result.Err)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+		})
+	}
+}
+
+// One undeliverable address does not fail the others sharing the connection
+func TestSendMailPerRecipient(t *testing.T) {
+	server := newFakeSMTP(t, &fakeSMTP{rejected: map[string]bool{"ghost@example.com": true}})
+	useSmtpServer(t, server.addr)
+
+	to := []string{"octocat@example.com", "ghost@example.com", "hubot@example.com"}
+	results := SendMail(MailStaff, to, MailBody{Text: "Leaderboard frozen"})
+
+	for i, result := range results {
+		if result.Recipient != to[i] {
+			t.Errorf("result %d is for %s, want %s", i, result.Recipient, to[i])
+		}
+		wantRejected := to[i] == "ghost@example.com"
+		if errors.Is(result.Err, ErrMailRecipientRejected) != wantRejected || (!wantRejected && result.Err != nil) {
+			t.Errorf("%s: error = %v", result.Recipient, result.Err)
+		}
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if len(server.delivered) != 2 {
+		t.Errorf("delivered %d messages, want 2", len(server.delivered))
+	}
+	if server.sessions != 2 {
+		t.Errorf("server saw %d sessions, want 2 as a failure resets the connection", server.sessions)
+	}
+}
+
+func TestSendMailRetry(t *testing.T) {
+	tests := []struct {
+		name          string
+		dropData      int
+		wantErr       error
+		wantDelivered int
+	}{
+		{name: "dropped once", dropData: 1, wantDelivered: 1},
+		{name: "dropped twice", dropData: 2, wantErr: ErrMailConnection},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeSMTP(t, &fakeSMTP{dropData: tt.dropData})
+			useSmtpServer(t, server.addr)
+
+			result := SendMail(MailOtp, []string{"octocat@example.com"}, OtpMailBody("123456"))[0]
+			if tt.wantErr == nil && result.Err != nil {
+				t.Fatalf("error = %v", result.Err)
+			}
+			if tt.wantErr != nil && !errors.Is(result.Err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", result.Err, tt.wantErr)
+			}
+
+			server.mu.Lock()
+			defer server.mu.Unlock()
+			// Retried once on a fresh connection, never more
+			if server.sessions != 2 {
+				t.Errorf("server saw %d sessions, want 2", server.sessions)
+			}
+			if len(server.delivered) != tt.wantDelivered {
+				t.Fatalf("delivered %d messages, want %d", len(server.delivered), tt.wantDelivered)
+			}
+			// The retry carries the Message-ID of the first attempt, which may
+			// have got through, so that receivers can drop the duplicate
+			attempts := append(server.dropped, server.delivered...)
+			messageId := regexp.MustCompile(`Message-ID: (<[^>]+>)`)
+			first := messageId.FindStringSubmatch(attempts[0])
+			if first == nil {
+				t.Fatalf("first attempt has no Message-ID:\n%s", attempts[0])
+			}
+			for _, attempt := range attempts[1:] {
+				if !strings.Contains(attempt, "Message-ID: "+first[1]) {
+					t.Errorf("retry lacks Message-ID %s", first[1])
+				}
+			}
+			if tt.wantErr == nil && "<"+result.MessageId+">" != first[1] {
+				t.Errorf("MessageId = %s, want %s", result.MessageId, first[1])
+			}
+		})
+	}
+}
+
+// Content types of a message, with the parts of a multipart message in order,
+// and the decoded text of each
+func mimeParts(t *testing.T, raw string) (string, []string, []string) {
+	t.Helper()
+	msg, err := mail.ReadMessage(strings.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		return mediaType, []string{mediaType}, []string{decodePart(t, msg.Header.Get("Content-Transfer-Encoding"), msg.Body)}
+	}
+	var types, texts []string
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := reader.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		types = append(types, partType)
+		texts = append(texts, decodePart(t, part.Header.Get("Content-Transfer-Encoding"), part))
+	}
+	return mediaType, types, texts
+}
+
+func decodePart(t *testing.T, encoding string, r io.Reader) string {
+	t.Helper()
+	if strings.EqualFold(encoding, "quoted-printable") {
+		r = quotedprintable.NewReader(r)
+	}
+	body, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestSendMailFormat(t *testing.T) {
+	tests := []struct {
+		name      string
+		format    string
+		kind      MailType
+		body      MailBody
+		wantType  string
+		wantParts []string
+	}{
+		{name: "OTP as text", format: MailFormatText, kind: MailOtp, body: OtpMailBody("123456"),
+			wantType: "text/plain", wantParts: []string{"text/plain"}},
+		{name: "OTP as HTML", format: MailFormatHtml, kind: MailOtp, body: OtpMailBody("123456"),
+			wantType: "text/html", wantParts: []string{"text/html"}},
+		{name: "OTP as multipart", format: MailFormatMultipart, kind: MailOtp, body: OtpMailBody("123456"),
+			wantType: "multipart/alternative", wantParts: []string{"text/plain", "text/html"}},
+		// The setting only applies to OTP mails
+		{name: "other mail with HTML", format: MailFormatText, kind: MailLoginAlert,
+			body:     MailBody{Text: "New login", Html: "<p>New login</p>"},
+			wantType: "multipart/alternative", wantParts: []string{"text/plain", "text/html"}},
+		{name: "mail without HTML", format: MailFormatHtml, kind: MailStaff, body: MailBody{Text: "Leaderboard frozen"},
+			wantType: "text/plain", wantParts: []string{"text/plain"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeSMTP(t, &fakeSMTP{})
+			useSmtpServer(t, server.addr)
+			cmd.EnvVars.OtpMailFormat = tt.format
+
+			result := SendMail(tt.kind, []string{"octocat@example.com"}, tt.body)[0]
+			if result.Err != nil {
+				t.Fatal(result.Err)
+			}
+			server.mu.Lock()
+			defer server.mu.Unlock()
+			if len(server.delivered) != 1 {
+				t.Fatalf("delivered %d messages", len(server.delivered))
+			}
+			mediaType, types, texts := mimeParts(t, server.delivered[0])
+			if mediaType != tt.wantType {
+				t.Errorf("Content-Type = %s, want %s", mediaType, tt.wantType)
+			}
+			if !slices.Equal(types, tt.wantParts) {
+				t.Fatalf("parts = %v, want %v", types, tt.wantParts)
+			}
+			for i, partType := range types {
+				want := tt.body.Text
+				if partType == "text/html" {
+					want = tt.body.Html
+				}
+				if strings.TrimSpace(texts[i]) != want {
+					t.Errorf("%s part = %q, want %q", partType, texts[i], want)
+				}
+			}
+		})
+	}
+}
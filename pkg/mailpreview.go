@@ -0,0 +1,47 @@
+package pkg
+
+import (
+	"errors"
+	"html"
+	"time"
+)
+
+var ErrNoMailPreview = errors.New("no preview for mail type")
+
+// Sample data every preview is rendered with, so that previews only change
+// along with the templates
+const (
+	previewOtp       = "123456"
+	previewIp        = "203.0.113.7"
+	previewUserAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+)
+
+var previewTime = time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+var mailPreviews = map[MailType]func() MailBody{
+	MailOtp: func() MailBody { return OtpMailBody(previewOtp) },
+	MailLoginAlert: func() MailBody {
+		return LoginAlertMailBody(previewIp, previewUserAgent, previewTime)
+	},
+}
+
+// Renders a mail with sample data as an HTML page, without sending it. Mails
+// without an HTML part show their text part.
+func RenderMailPreview(kind MailType) (string, error) {
+	render, ok := mailPreviews[kind]
+	if !ok {
+		return "", ErrNoMailPreview
+	}
+	subject, err := MailSubject(kind)
+	if err != nil {
+		return "", err
+	}
+
+	body := render()
+	content := body.Html
+	if content == "" {
+		content = "<pre>" + html.EscapeString(body.Text) + "</pre>"
+	}
+	return "<!DOCTYPE html><html><head><meta charset=\"utf-8\"><title>" +
+		html.EscapeString(subject) + "</title></head><body>" + content + "</body></html>", nil
+}
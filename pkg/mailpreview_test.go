@@ -0,0 +1,46 @@
+package pkg
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestRenderMailPreview(t *testing.T) {
+	if err := InitMailSubjects("Pulse", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		kind     MailType
+		contains []string
+		wantErr  error
+	}{
+		{
+			name:     "html part",
+			kind:     MailOtp,
+			contains: []string{"<title>Your Pulse verification code</title>", previewOtp},
+		},
+		{
+			name:     "text part escaped",
+			kind:     MailLoginAlert,
+			contains: []string{"<title>New login to your Pulse account</title>", "<pre>", previewIp, "(KHTML, like Gecko)"},
+		},
+		{name: "no preview", kind: MailStaff, wantErr: ErrNoMailPreview},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RenderMailPreview(tt.kind)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RenderMailPreview() error = %v, want %v", err, tt.wantErr)
+			}
+			for _, want := range tt.contains {
+				if !strings.Contains(got, want) {
+					t.Errorf("RenderMailPreview() is missing %q", want)
+				}
+			}
+		})
+	}
+}
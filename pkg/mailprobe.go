@@ -0,0 +1,38 @@
+package pkg
+
+import (
+	"sync/atomic"
+
+	"github.com/IAmRiteshKoushik/pulse/cmd"
+)
+
+// Assumed reachable until a probe says otherwise, so that readiness does not
+// flap on startup
+var mailReachable atomic.Bool
+
+func init() {
+	SetMailReachable(true)
+}
+
+// ProbeMail connects and authenticates to the SMTP server without sending
+// anything, which is what every mail depends on
+func ProbeMail() error {
+	conn, err := newDialer().Dial()
+	if err != nil {
+		return classifyMailError(err)
+	}
+	return conn.Close()
+}
+
+func SetMailReachable(reachable bool) {
+	mailReachable.Store(reachable)
+	if reachable {
+		cmd.MailProviderUp.Set(1)
+	} else {
+		cmd.MailProviderUp.Set(0)
+	}
+}
+
+func MailReachable() bool {
+	return mailReachable.Load()
+}
@@ -0,0 +1,41 @@
+package pkg
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestProbeMail(t *testing.T) {
+	tests := []struct {
+		name      string
+		authReply string
+		closed    bool
+		want      error
+	}{
+		{name: "reachable"},
+		{name: "bad credentials", authReply: "535 5.7.8 Username and Password not accepted", want: ErrMailAuth},
+		{name: "unreachable", closed: true, want: ErrMailConnection},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFakeSMTP(t, &fakeSMTP{authReply: tt.authReply})
+			addr := server.addr
+			if tt.closed {
+				addr = closedAddr(t)
+			}
+			useSmtpServer(t, addr)
+
+			err := ProbeMail()
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("ProbeMail() = %v, want %v", err, tt.want)
+			}
+			// Nothing is sent by a probe
+			server.mu.Lock()
+			defer server.mu.Unlock()
+			if len(server.delivered) != 0 {
+				t.Errorf("delivered %d mail(s)", len(server.delivered))
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package pkg
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+type MailType string
+
+const (
+	MailOtp        MailType = "otp"
+	MailLoginAlert MailType = "login_alert"
+	MailStaff      MailType = "staff"
+)
+
+// Variables available to subject templates
+type MailSubjectData struct {
+	AppName string
+}
+
+var defaultMailSubjects = map[MailType]string{
+	MailOtp:        "Your {{.AppName}} verification code",
+	MailLoginAlert: "New login to your {{.AppName}} account",
+	MailStaff:      "[{{.AppName}}] Staff notification",
+}
+
+var (
+	mailSubjects    = map[MailType]*template.Template{}
+	mailSubjectData = MailSubjectData{}
+)
+
+// Parses the default subject templates along with any overrides keyed by
+// mail type. Must be called once at startup before any mail is sent.
+func InitMailSubjects(appName string, overrides map[string]string) error {
+	templates := map[MailType]*template.Template{}
+	for kind, text := range defaultMailSubjects {
+		if override, ok := overrides[string(kind)]; ok {
+			text = override
+		}
+		t, err := template.New(string(kind)).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return fmt.Errorf("Invalid subject template for %s: %w", kind, err)
+		}
+		templates[kind] = t
+	}
+	for kind := range overrides {
+		if _, ok := defaultMailSubjects[MailType(kind)]; !ok {
+			return fmt.Errorf("Unknown mail type in subject templates: %s", kind)
+		}
+	}
+
+	data := MailSubjectData{AppName: appName}
+	// Templates referring to unknown fields fail here rather than on send
+	for kind, t := range templates {
+		if _, err := renderSubject(t, data); err != nil {
+			return fmt.Errorf("Invalid subject template for %s: %w", kind, err)
+		}
+	}
+	mailSubjects = templates
+	mailSubjectData = data
+	return nil
+}
+
+// Returns the rendered subject for the given mail type
+func MailSubject(kind MailType) (string, error) {
+	t, ok := mailSubjects[kind]
+	if !ok {
+		return "", fmt.Errorf("No subject template for mail type %s", kind)
+	}
+	return renderSubject(t, mailSubjectData)
+}
+
+func renderSubject(t *template.Template, data MailSubjectData) (string, error) {
+	var sb strings.Builder
+	if err := t.Execute(&sb, data); err != nil {
+		return "", err
+	}
+	// A stray newline in the template would break the Subject header
+	return strings.Join(strings.Fields(sb.String()), " "), nil
+}
@@ -0,0 +1,107 @@
+package pkg
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMailSubject(t *testing.T) {
+	tests := []struct {
+		name      string
+		appName   string
+		overrides map[string]string
+		want      map[MailType]string
+		wantErr   string // from InitMailSubjects
+	}{
+		{
+			name:    "defaults",
+			appName: "Season of Code",
+			want: map[MailType]string{
+				MailOtp:        "Your Season of Code verification code",
+				MailLoginAlert: "New login to your Season of Code account",
+				MailStaff:      "[Season of Code] Staff notification",
+			},
+		},
+		{
+			name:      "override of one type",
+			appName:   "Pulse",
+			overrides: map[string]string{"otp": "{{.AppName}} code inside"},
+			want: map[MailType]string{
+				MailOtp:        "Pulse code inside",
+				MailLoginAlert: "New login to your Pulse account",
+				MailStaff:      "[Pulse] Staff notification",
+			},
+		},
+		{
+			// A newline would end the Subject header early
+			name:      "whitespace collapsed",
+			appName:   "Pulse",
+			overrides: map[string]string{"staff": "Staff\n  notice from {{.AppName}} "},
+			want:      map[MailType]string{MailStaff: "Staff notice from Pulse"},
+		},
+		{
+			name:      "unknown mail type",
+			overrides: map[string]string{"newsletter": "News from {{.AppName}}"},
+			wantErr:   "Unknown mail type in subject templates: newsletter",
+		},
+		{
+			name:      "malformed template",
+			overrides: map[string]string{"otp": "Your {{.AppName code"},
+			wantErr:   "Invalid subject template for otp",
+		},
+		{
+			name:      "unknown variable",
+			overrides: map[string]string{"login_alert": "Hello {{.Username}}"},
+			wantErr:   "Invalid subject template for login_alert",
+		},
+	}
+
+	savedSubjects, savedData := mailSubjects, mailSubjectData
+	t.Cleanup(func() { mailSubjects, mailSubjectData = savedSubjects, savedData })
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := InitMailSubjects(tt.appName, tt.overrides)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for kind, want := range tt.want {
+				got, err := MailSubject(kind)
+				if err != nil {
+					t.Fatalf("MailSubject(%s) error = %v", kind, err)
+				}
+				if got != want {
+					t.Errorf("MailSubject(%s) = %q, want %q", kind, got, want)
+				}
+			}
+		})
+	}
+
+	if err := InitMailSubjects("Pulse", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := MailSubject("newsletter"); err == nil {
+		t.Error("subject rendered for an unknown mail type")
+	}
+}
+
+func TestInitMailSubjectsKeepsPreviousOnError(t *testing.T) {
+	savedSubjects, savedData := mailSubjects, mailSubjectData
+	t.Cleanup(func() { mailSubjects, mailSubjectData = savedSubjects, savedData })
+
+	if err := InitMailSubjects("Pulse", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := InitMailSubjects("Other", map[string]string{"otp": "{{.Missing}}"}); err == nil {
+		t.Fatal("invalid template accepted")
+	}
+	if got, _ := MailSubject(MailOtp); got != "Your Pulse verification code" {
+		t.Errorf("MailSubject(otp) = %q after a failed init", got)
+	}
+}
@@ -0,0 +1,20 @@
+package pkg
+
+import (
+	"os"
+	"testing"
+
+	"github.com/IAmRiteshKoushik/pulse/cmd"
+	"github.com/gin-gonic/gin"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		panic(err)
+	}
+	cmd.Log = cmd.NewLoggerService("production", devNull)
+	cmd.EnvVars = &cmd.EnvConfig{}
+	os.Exit(m.Run())
+}
@@ -0,0 +1,27 @@
+package pkg
+
+// Mails users can opt out of, keyed by their mail type. Every notification
+// is on until the user turns it off.
+var defaultNotifications = map[MailType]bool{
+	MailLoginAlert: true,
+}
+
+func IsNotification(kind string) bool {
+	_, ok := defaultNotifications[MailType(kind)]
+	return ok
+}
+
+// Merges stored preferences over the defaults, dropping kinds which are no
+// longer sent
+func NotificationPreferences(stored map[string]bool) map[string]bool {
+	preferences := make(map[string]bool, len(defaultNotifications))
+	for kind, enabled := range defaultNotifications {
+		preferences[string(kind)] = enabled
+	}
+	for kind, enabled := range stored {
+		if IsNotification(kind) {
+			preferences[kind] = enabled
+		}
+	}
+	return preferences
+}
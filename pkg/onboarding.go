@@ -0,0 +1,63 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+)
+
+type OnboardingState string
+
+const (
+	StateUnregistered OnboardingState = "" // no account nor a live registration
+	StatePendingOtp   OnboardingState = "pending_otp"
+	StateOnboarded    OnboardingState = "onboarded"
+)
+
+type OnboardingEvent string
+
+const (
+	EventRegister     OnboardingEvent = "register"
+	EventResendOtp    OnboardingEvent = "resend_otp"
+	EventVerifyOtp    OnboardingEvent = "verify_otp"
+	EventForceOnboard OnboardingEvent = "force_onboard"
+)
+
+var (
+	ErrAlreadyOnboarded = errors.New("already onboarded")
+	ErrNotRegistered    = errors.New("no pending registration")
+)
+
+// Allowed transitions of the registration flow. Registering again while an
+// OTP is pending starts over with a fresh OTP.
+var onboardingTransitions = map[OnboardingState]map[OnboardingEvent]OnboardingState{
+	StateUnregistered: {
+		EventRegister: StatePendingOtp,
+	},
+	StatePendingOtp: {
+		EventRegister:     StatePendingOtp,
+		EventResendOtp:    StatePendingOtp,
+		EventVerifyOtp:    StateOnboarded,
+		EventForceOnboard: StateOnboarded,
+	},
+	StateOnboarded: {},
+}
+
+// Returns the state reached by applying event to from. Events on an onboarded
+// user fail with ErrAlreadyOnboarded and those which need a registration
+// fail with ErrNotRegistered.
+func OnboardingTransition(from OnboardingState, event OnboardingEvent) (OnboardingState, error) {
+	events, ok := onboardingTransitions[from]
+	if !ok {
+		return from, fmt.Errorf("unknown onboarding state %q", from)
+	}
+	if to, ok := events[event]; ok {
+		return to, nil
+	}
+	switch from {
+	case StateOnboarded:
+		return from, ErrAlreadyOnboarded
+	case StateUnregistered:
+		return from, ErrNotRegistered
+	}
+	return from, fmt.Errorf("%s is not allowed in onboarding state %q", event, from)
+}
@@ -0,0 +1,57 @@
+package pkg
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestOnboardingTransition(t *testing.T) {
+	tests := []struct {
+		from    OnboardingState
+		event   OnboardingEvent
+		want    OnboardingState
+		wantErr error // nil when allowed
+	}{
+		{from: StateUnregistered, event: EventRegister, want: StatePendingOtp},
+		{from: StateUnregistered, event: EventResendOtp, wantErr: ErrNotRegistered},
+		{from: StateUnregistered, event: EventVerifyOtp, wantErr: ErrNotRegistered},
+		{from: StateUnregistered, event: EventForceOnboard, wantErr: ErrNotRegistered},
+
+		// Registering again starts over with a fresh OTP
+		{from: StatePendingOtp, event: EventRegister, want: StatePendingOtp},
+		{from: StatePendingOtp, event: EventResendOtp, want: StatePendingOtp},
+		{from: StatePendingOtp, event: EventVerifyOtp, want: StateOnboarded},
+		{from: StatePendingOtp, event: EventForceOnboard, want: StateOnboarded},
+
+		{from: StateOnboarded, event: EventRegister, wantErr: ErrAlreadyOnboarded},
+		{from: StateOnboarded, event: EventResendOtp, wantErr: ErrAlreadyOnboarded},
+		{from: StateOnboarded, event: EventVerifyOtp, wantErr: ErrAlreadyOnboarded},
+		{from: StateOnboarded, event: EventForceOnboard, wantErr: ErrAlreadyOnboarded},
+
+		{from: StatePendingOtp, event: "delete", wantErr: errAny},
+		{from: "suspended", event: EventRegister, wantErr: errAny},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.event)+" from "+string(tt.from), func(t *testing.T) {
+			got, err := OnboardingTransition(tt.from, tt.event)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			if tt.wantErr != nil && (err == nil || tt.wantErr != errAny && !errors.Is(err, tt.wantErr)) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			// A rejected event leaves the state as it was
+			want := tt.want
+			if tt.wantErr != nil {
+				want = tt.from
+			}
+			if got != want {
+				t.Errorf("state = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+// Stands for any error, for rejections without a sentinel
+var errAny = errors.New("any error")
@@ -0,0 +1,150 @@
+package pkg
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RouteDoc describes what cannot be inferred from the router itself. Body and
+// Query are zero values of the request types, documented via json and form
+// struct tags respectively.
+type RouteDoc struct {
+	Summary string
+	Body    any
+	Query   any
+	Secured bool
+}
+
+var pathParam = regexp.MustCompile(`:(\w+)`)
+
+// BuildOpenAPISpec generates an OpenAPI 3 document from the registered routes
+// so that paths never drift from what is actually being served
+func BuildOpenAPISpec(title, version string, routes gin.RoutesInfo, docs map[string]RouteDoc) gin.H {
+	paths := gin.H{}
+	for _, route := range routes {
+		doc, ok := docs[route.Method+" "+route.Path]
+		if !ok {
+			continue
+		}
+
+		op := gin.H{
+			"summary": doc.Summary,
+			"responses": gin.H{
+				"200": gin.H{"description": "Successful response"},
+			},
+		}
+		params := []gin.H{}
+		for _, match := range pathParam.FindAllStringSubmatch(route.Path, -1) {
+			params = append(params, gin.H{
+				"name":     match[1],
+				"in":       "path",
+				"required": true,
+				"schema":   gin.H{"type": "string"},
+			})
+		}
+		if doc.Query != nil {
+			params = append(params, queryParams(reflect.TypeOf(doc.Query))...)
+		}
+		if len(params) > 0 {
+			op["parameters"] = params
+		}
+		if doc.Body != nil {
+			op["requestBody"] = gin.H{
+				"required": true,
+				"content": gin.H{
+					"application/json": gin.H{
+						"schema": schemaFor(reflect.TypeOf(doc.Body)),
+					},
+				},
+			}
+		}
+		if doc.Secured {
+			op["security"] = []gin.H{{"bearerAuth": []string{}}}
+		}
+
+		path := pathParam.ReplaceAllString(route.Path, "{$1}")
+		item, ok := paths[path].(gin.H)
+		if !ok {
+			item = gin.H{}
+			paths[path] = item
+		}
+		item[strings.ToLower(route.Method)] = op
+	}
+
+	return gin.H{
+		"openapi": "3.0.3",
+		"info": gin.H{
+			"title":   title,
+			"version": version,
+		},
+		"paths": paths,
+		"components": gin.H{
+			"securitySchemes": gin.H{
+				"bearerAuth": gin.H{
+					"type":         "http",
+					"scheme":       "bearer",
+					"bearerFormat": "JWT",
+				},
+			},
+		},
+	}
+}
+
+func queryParams(t reflect.Type) []gin.H {
+	params := []gin.H{}
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("form"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		params = append(params, gin.H{
+			"name":   name,
+			"in":     "query",
+			"schema": schemaFor(field.Type),
+		})
+	}
+	return params
+}
+
+func schemaFor(t reflect.Type) gin.H {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == reflect.TypeOf(time.Time{}) {
+		return gin.H{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return gin.H{"type": "string"}
+	case reflect.Bool:
+		return gin.H{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return gin.H{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return gin.H{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return gin.H{"type": "array", "items": schemaFor(t.Elem())}
+	case reflect.Map:
+		return gin.H{"type": "object"}
+	case reflect.Struct:
+		properties := gin.H{}
+		for i := range t.NumField() {
+			field := t.Field(i)
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if name == "" || name == "-" || !field.IsExported() {
+				continue
+			}
+			properties[name] = schemaFor(field.Type)
+		}
+		return gin.H{"type": "object", "properties": properties}
+	default:
+		return gin.H{}
+	}
+}
@@ -3,26 +3,48 @@ package pkg
 import (
 	"crypto/rand"
 	"math/big"
+	"strings"
 )
 
-func GenerateOTP() (string, error) {
-	const otpLength = 6
-	const digits = "0123456789"
+const otpLength = 6
+
+// Alphanumeric OTPs are generated in lowercase only so that matching them
+// case-insensitively does not reduce the alphabet (36^6 vs 10^6 for digits)
+const (
+	numericOtpChars      = "0123456789"
+	alphanumericOtpChars = "0123456789abcdefghijklmnopqrstuvwxyz"
+)
+
+func GenerateOTP(alphanumeric bool) (string, error) {
+	chars := numericOtpChars
+	if alphanumeric {
+		chars = alphanumericOtpChars
+	}
 	otp := make([]byte, otpLength)
 
 	for i := range otpLength {
 		randomIndex, err := rand.Int(
 			rand.Reader,
 			big.NewInt(
-				int64(len(digits)),
+				int64(len(chars)),
 			),
 		)
 
 		if err != nil {
 			return "", err
 		}
-		otp[i] = digits[randomIndex.Int64()]
+		otp[i] = chars[randomIndex.Int64()]
 	}
 
 	return string(otp), nil
 }
+
+// Brings a user supplied OTP to the stored form. Numeric OTPs are compared
+// as is.
+func NormalizeOTP(otp string, alphanumeric bool) string {
+	otp = strings.TrimSpace(otp)
+	if alphanumeric {
+		return strings.ToLower(otp)
+	}
+	return otp
+}
@@ -0,0 +1,61 @@
+package pkg
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateOTP(t *testing.T) {
+	tests := []struct {
+		name         string
+		alphanumeric bool
+		wantChars    string
+	}{
+		{name: "numeric", wantChars: numericOtpChars},
+		// Lowercase only, case-insensitive matching keeps all 36 symbols
+		{name: "alphanumeric", alphanumeric: true, wantChars: alphanumericOtpChars},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for range 100 {
+				otp, err := GenerateOTP(tt.alphanumeric)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(otp) != otpLength {
+					t.Fatalf("GenerateOTP() = %q, want %d characters", otp, otpLength)
+				}
+				if strings.Trim(otp, tt.wantChars) != "" {
+					t.Fatalf("GenerateOTP() = %q, want only %q", otp, tt.wantChars)
+				}
+				if NormalizeOTP(otp, tt.alphanumeric) != otp {
+					t.Fatalf("generated OTP %q is not in its normalized form", otp)
+				}
+			}
+		})
+	}
+}
+
+func TestNormalizeOTP(t *testing.T) {
+	tests := []struct {
+		name         string
+		otp          string
+		alphanumeric bool
+		want         string
+	}{
+		{name: "numeric", otp: "123456", want: "123456"},
+		{name: "numeric spaces", otp: " 123456\n", want: "123456"},
+		{name: "numeric kept as is", otp: "AbC123", want: "AbC123"},
+		{name: "alphanumeric mixed case", otp: "AbC123", alphanumeric: true, want: "abc123"},
+		{name: "alphanumeric spaces", otp: " ABC123 ", alphanumeric: true, want: "abc123"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeOTP(tt.otp, tt.alphanumeric); got != tt.want {
+				t.Errorf("NormalizeOTP(%q, %v) = %q, want %q", tt.otp, tt.alphanumeric, got, tt.want)
+			}
+		})
+	}
+}
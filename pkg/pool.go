@@ -0,0 +1,51 @@
+package pkg
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Conn is a connection held for the length of a request. Satisfied by
+// *pgxpool.Conn.
+type Conn interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Release()
+}
+
+// Pool is the database handlers and middleware work against, so that tests
+// can run them against a fake. WrapPool adapts a *pgxpool.Pool to it.
+type Pool interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	Begin(ctx context.Context) (pgx.Tx, error)
+	BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error)
+	Acquire(ctx context.Context) (Conn, error)
+	Ping(ctx context.Context) error
+}
+
+type pgxPool struct {
+	*pgxpool.Pool
+}
+
+// Returns nil for a nil pool, so that optional pools stay unset
+func WrapPool(pool *pgxpool.Pool) Pool {
+	if pool == nil {
+		return nil
+	}
+	return pgxPool{pool}
+}
+
+func (p pgxPool) Acquire(ctx context.Context) (Conn, error) {
+	conn, err := p.Pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
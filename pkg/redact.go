@@ -0,0 +1,58 @@
+package pkg
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// Keys are compared case-insensitively. Any key containing "token" is
+// treated as sensitive as well.
+var sensitiveKeys = map[string]bool{
+	"otp":           true,
+	"email":         true,
+	"password":      true,
+	"secret":        true,
+	"code":          true,
+	"state":         true,
+	"authorization": true,
+	"access_key":    true,
+	"accesskey":     true,
+}
+
+// RedactJSON returns the JSON body with the values of sensitive fields
+// replaced, at any depth. Bodies that are not valid JSON are not returned.
+func RedactJSON(body []byte) (string, bool) {
+	var data any
+	if err := json.Unmarshal(body, &data); err != nil {
+		return "", false
+	}
+	out, err := json.Marshal(redactValue(data))
+	if err != nil {
+		return "", false
+	}
+	return string(out), true
+}
+
+func redactValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, inner := range v {
+			lower := strings.ToLower(key)
+			if sensitiveKeys[lower] || strings.Contains(lower, "token") {
+				v[key] = redacted
+				continue
+			}
+			v[key] = redactValue(inner)
+		}
+		return v
+	case []any:
+		for i, inner := range v {
+			v[i] = redactValue(inner)
+		}
+		return v
+	default:
+		return v
+	}
+}
@@ -0,0 +1,71 @@
+package pkg
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestRedactJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		want   string
+		wantOk bool
+	}{
+		{
+			name:   "otp",
+			body:   `{"otp": "123456"}`,
+			want:   `{"otp": "[REDACTED]"}`,
+			wantOk: true,
+		},
+		{
+			name:   "keys in any case",
+			body:   `{"OTP": "123456", "Email": "octo@example.com", "github_username": "octocat"}`,
+			want:   `{"OTP": "[REDACTED]", "Email": "[REDACTED]", "github_username": "octocat"}`,
+			wantOk: true,
+		},
+		{
+			name:   "any key naming a token",
+			body:   `{"refresh_token": "eyJ", "accessToken": "eyJ", "amount": 10}`,
+			want:   `{"refresh_token": "[REDACTED]", "accessToken": "[REDACTED]", "amount": 10}`,
+			wantOk: true,
+		},
+		{
+			name:   "nested",
+			body:   `{"user": {"email": "octo@example.com"}, "codes": [{"otp": 123456}]}`,
+			want:   `{"user": {"email": "[REDACTED]"}, "codes": [{"otp": "[REDACTED]"}]}`,
+			wantOk: true,
+		},
+		{
+			name:   "not json",
+			body:   `otp=123456`,
+			wantOk: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := RedactJSON([]byte(tt.body))
+			if ok != tt.wantOk {
+				t.Fatalf("RedactJSON() ok = %v, want %v", ok, tt.wantOk)
+			}
+			if !ok {
+				if got != "" {
+					t.Errorf("RedactJSON() = %q for a body that is not JSON", got)
+				}
+				return
+			}
+			var gotValue, wantValue any
+			if err := json.Unmarshal([]byte(got), &gotValue); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(tt.want), &wantValue); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(gotValue, wantValue) {
+				t.Errorf("RedactJSON() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package pkg
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// Prefixes sealed secrets, so that the scheme can change without guessing
+const sealedSecretPrefix = "v1:"
+
+var ErrSecretUnreadable = errors.New("secret cannot be decrypted")
+
+// SealSecret encrypts a secret to be stored at rest with AES-256-GCM. The
+// random nonce is kept in front of the ciphertext. Format: v1:<base64>
+func SealSecret(key []byte, plaintext string) (string, error) {
+	aead, err := newSecretCipher(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return sealedSecretPrefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// OpenSecret reverses SealSecret. Values sealed with another key, or stored
+// before sealing was introduced, are reported as ErrSecretUnreadable.
+func OpenSecret(key []byte, sealed string) (string, error) {
+	aead, err := newSecretCipher(key)
+	if err != nil {
+		return "", err
+	}
+	encoded, ok := strings.CutPrefix(sealed, sealedSecretPrefix)
+	if !ok {
+		return "", ErrSecretUnreadable
+	}
+	data, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil || len(data) < aead.NonceSize() {
+		return "", ErrSecretUnreadable
+	}
+	plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return "", ErrSecretUnreadable
+	}
+	return string(plaintext), nil
+}
+
+func newSecretCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,63 @@
+package pkg
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSealSecret(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	otherKey := bytes.Repeat([]byte{2}, 32)
+	sealed, err := SealSecret(key, "gho_token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(sealed, sealedSecretPrefix) {
+		t.Fatalf("SealSecret() = %q, want the %q prefix", sealed, sealedSecretPrefix)
+	}
+	again, err := SealSecret(key, "gho_token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again == sealed {
+		t.Error("SealSecret() reused a nonce")
+	}
+
+	// Changes a character of the nonce, the last one may only carry padding bits
+	i := len(sealedSecretPrefix) + 4
+	tampered := sealed[:i] + "A" + sealed[i+1:]
+	if sealed[i] == 'A' {
+		tampered = sealed[:i] + "B" + sealed[i+1:]
+	}
+
+	tests := []struct {
+		name    string
+		key     []byte
+		sealed  string
+		want    string
+		wantErr error
+	}{
+		{name: "round trip", key: key, sealed: sealed, want: "gho_token"},
+		{name: "other key", key: otherKey, sealed: sealed, wantErr: ErrSecretUnreadable},
+		{name: "stored before sealing", key: key, sealed: "gho_token", wantErr: ErrSecretUnreadable},
+		{name: "tampered", key: key, sealed: tampered, wantErr: ErrSecretUnreadable},
+		{name: "truncated", key: key, sealed: sealedSecretPrefix + "AAAA", wantErr: ErrSecretUnreadable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := OpenSecret(tt.key, tt.sealed)
+			if err != tt.wantErr {
+				t.Fatalf("OpenSecret() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("OpenSecret() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := SealSecret([]byte("short"), "gho_token"); err == nil {
+		t.Error("SealSecret() accepted a key of invalid length")
+	}
+}
@@ -0,0 +1,144 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/IAmRiteshKoushik/pulse/cmd"
+)
+
+// Channels a registration can receive its OTP through
+const (
+	OtpChannelEmail = "email"
+	OtpChannelSms   = "sms"
+)
+
+var (
+	ErrInvalidPhone       = errors.New("must be an E.164 phone number, e.g. +919876543210")
+	ErrSmsUnavailable     = errors.New("SMS delivery is not configured")
+	ErrSmsCountryDisabled = errors.New("SMS is not sent to numbers of this country")
+)
+
+var e164Pattern = regexp.MustCompile(`^\+[1-9][0-9]{7,14}$`)
+
+// Phone numbers are accepted in E.164 only, so that the number stored is the
+// one the provider dials
+func CheckPhone(phone string) error {
+	if !e164Pattern.MatchString(phone) {
+		return ErrInvalidPhone
+	}
+	return nil
+}
+
+// SMS_COUNTRY_CODES restricts the countries SMS is sent to, as SMS pumping
+// mostly targets expensive destinations
+func CheckSmsCountry(env *cmd.EnvConfig, phone string) error {
+	if len(env.SmsCountryCodes) == 0 {
+		return nil
+	}
+	for _, code := range env.SmsCountryCodes {
+		if strings.HasPrefix(phone, code) {
+			return nil
+		}
+	}
+	return ErrSmsCountryDisabled
+}
+
+// Numbers sharing a prefix are usually one range of a single carrier, which
+// is what SMS pumping cycles through. The last four digits are dropped.
+func PhonePrefix(phone string) string {
+	if len(phone) <= 5 {
+		return phone
+	}
+	return phone[:len(phone)-4]
+}
+
+// SMS is only offered once a provider is configured
+func CheckOtpChannel(env *cmd.EnvConfig, channel string) error {
+	if channel == OtpChannelSms && env.SmsProvider == "" {
+		return ErrSmsUnavailable
+	}
+	return nil
+}
+
+// Spaces, dashes and brackets are commonly typed into phone fields
+func NormalizePhone(phone string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '(', ')', '.':
+			return -1
+		}
+		return r
+	}, phone)
+}
+
+// SMSSender delivers text messages. Implementations must be safe for
+// concurrent use.
+type SMSSender interface {
+	SendSMS(ctx context.Context, to string, body string) error
+}
+
+// Returns the sender for SMS_PROVIDER, or nil when SMS delivery is disabled
+func NewSMSSender(env *cmd.EnvConfig, client *http.Client) SMSSender {
+	switch env.SmsProvider {
+	case "twilio":
+		return NewTwilioSender(env.TwilioApiUrl, env.TwilioAccountSid, env.TwilioAuthToken, env.TwilioFromNumber, client)
+	}
+	return nil
+}
+
+type TwilioSender struct {
+	accountSid string
+	authToken  string
+	from       string
+	client     *http.Client
+	baseUrl    string
+}
+
+// baseUrl is the versioned API root, e.g. https://api.twilio.com/2010-04-01
+func NewTwilioSender(baseUrl, accountSid, authToken, from string, client *http.Client) *TwilioSender {
+	return &TwilioSender{
+		accountSid: accountSid,
+		authToken:  authToken,
+		from:       from,
+		client:     client,
+		baseUrl:    baseUrl,
+	}
+}
+
+func (t *TwilioSender) SendSMS(ctx context.Context, to string, body string) error {
+	form := url.Values{}
+	form.Set("To", to)
+	form.Set("From", t.from)
+	form.Set("Body", body)
+
+	endpoint := fmt.Sprintf("%s/Accounts/%s/Messages.json", t.baseUrl, url.PathEscape(t.accountSid))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(t.accountSid, t.authToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		// Error bodies carry Twilio's error code, which is needed to debug
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("Unexpected status from Twilio: %d %s", resp.StatusCode, detail)
+	}
+	return nil
+}
+
+func OtpSmsBody(otp string) string {
+	return fmt.Sprintf("Your OTP for logging into the Season of Code is %s. This is valid for only 5 minutes.", otp)
+}
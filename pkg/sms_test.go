@@ -0,0 +1,127 @@
+package pkg
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/IAmRiteshKoushik/pulse/cmd"
+)
+
+func TestCheckPhone(t *testing.T) {
+	tests := []struct {
+		phone string
+		valid bool
+	}{
+		{phone: "+919876543210", valid: true},
+		{phone: "+15005550006", valid: true},
+		{phone: "919876543210", valid: false},
+		{phone: "+09876543210", valid: false},
+		{phone: "+91 98765 43210", valid: false},
+		{phone: "+1234567", valid: false},
+		{phone: "+1234567890123456", valid: false},
+		{phone: "", valid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.phone, func(t *testing.T) {
+			if err := CheckPhone(tt.phone); (err == nil) != tt.valid {
+				t.Errorf("CheckPhone(%q) = %v, want valid = %v", tt.phone, err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestCheckSmsCountry(t *testing.T) {
+	tests := []struct {
+		name  string
+		codes []string
+		phone string
+		want  error
+	}{
+		{name: "every code allowed", phone: "+447911123456"},
+		{name: "listed code", codes: []string{"+91", "+1"}, phone: "+919876543210"},
+		{name: "unlisted code", codes: []string{"+91", "+1"}, phone: "+447911123456", want: ErrSmsCountryDisabled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := &cmd.EnvConfig{SmsCountryCodes: tt.codes}
+			if err := CheckSmsCountry(env, tt.phone); err != tt.want {
+				t.Errorf("CheckSmsCountry(%q) = %v, want %v", tt.phone, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPhonePrefix(t *testing.T) {
+	tests := []struct {
+		phone string
+		want  string
+	}{
+		{phone: "+919876543210", want: "+91987654"},
+		{phone: "+15005550006", want: "+1500555"},
+		{phone: "+1234", want: "+1234"},
+	}
+
+	for _, tt := range tests {
+		if got := PhonePrefix(tt.phone); got != tt.want {
+			t.Errorf("PhonePrefix(%q) = %q, want %q", tt.phone, got, tt.want)
+		}
+	}
+}
+
+func TestTwilioSender(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr string
+	}{
+		{name: "created", status: http.StatusCreated},
+		{name: "rejected", status: http.StatusBadRequest, wantErr: "21211"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *http.Request
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := r.ParseForm(); err != nil {
+					t.Error(err)
+				}
+				got = r
+				w.WriteHeader(tt.status)
+				if tt.status != http.StatusCreated {
+					w.Write([]byte(`{"code": 21211, "message": "Invalid 'To' Phone Number"}`))
+				}
+			}))
+			defer server.Close()
+
+			sender := NewTwilioSender(server.URL+"/2010-04-01", "AC123", "token", "+15005550006", server.Client())
+			err := sender.SendSMS(context.Background(), "+919876543210", "Your OTP is 123456")
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("SendSMS() = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("SendSMS() = %v, want an error containing %q", err, tt.wantErr)
+			}
+
+			if got.URL.Path != "/2010-04-01/Accounts/AC123/Messages.json" {
+				t.Errorf("path = %s", got.URL.Path)
+			}
+			if user, pass, ok := got.BasicAuth(); !ok || user != "AC123" || pass != "token" {
+				t.Errorf("basic auth = %q, %q, %v", user, pass, ok)
+			}
+			for field, want := range map[string]string{
+				"To":   "+919876543210",
+				"From": "+15005550006",
+				"Body": "Your OTP is 123456",
+			} {
+				if value := got.PostForm.Get(field); value != want {
+					t.Errorf("%s = %q, want %q", field, value, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/IAmRiteshKoushik/pulse/cmd"
+)
+
+// Staff notification events
+const (
+	StaffOtpFailures = "otp_failures"
+	StaffMailFailure = "mail_failure"
+	StaffFirstOfDay  = "first_user_of_day"
+
+	StaffBreakerTripped = "breaker_tripped"
+)
+
+var staffHTTPClient = &http.Client{Timeout: 5 * time.Second}
+
+var (
+	staffMu       sync.Mutex
+	staffLastSent = map[string]time.Time{}
+)
+
+type staffNotification struct {
+	Event   string `json:"event"`
+	Message string `json:"message"`
+	Time    string `json:"time"`
+}
+
+// NotifyStaff alerts maintainers through the configured mail addresses and
+// webhook. Repeats of an event within the debounce window are dropped, and
+// delivery happens in the background so that callers are never slowed down.
+// Returns whether the notification was sent out.
+func NotifyStaff(event string, message string) bool {
+	env := cmd.EnvVars
+	if len(env.StaffNotifyEmails) == 0 && env.StaffNotifyWebhook == "" {
+		return false
+	}
+
+	now := time.Now()
+	staffMu.Lock()
+	if last, ok := staffLastSent[event]; ok && now.Sub(last) < env.StaffNotifyDebounce {
+		staffMu.Unlock()
+		return false
+	}
+	staffLastSent[event] = now
+	staffMu.Unlock()
+
+	notification := staffNotification{
+		Event:   event,
+		Message: message,
+		Time:    FormatTime(now),
+	}
+	go deliverStaffNotification(notification)
+	return true
+}
+
+func deliverStaffNotification(n staffNotification) {
+	env := cmd.EnvVars
+	if len(env.StaffNotifyEmails) > 0 {
+		body := fmt.Sprintf("%s\n\nEvent: %s\nTime: %s", n.Message, n.Event, n.Time)
+		for _, result := range SendMail(MailStaff, env.StaffNotifyEmails, MailBody{Text: body}) {
+			if result.Err != nil {
+				cmd.Log.Error("Failed to mail staff notification", result.Err)
+			}
+		}
+	}
+	if env.StaffNotifyWebhook != "" {
+		if err := postStaffWebhook(env.StaffNotifyWebhook, n); err != nil {
+			cmd.Log.Error("Failed to post staff notification", err)
+		}
+	}
+}
+
+func postStaffWebhook(url string, n staffNotification) error {
+	payload, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	resp, err := staffHTTPClient.Post(url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("staff webhook responded with %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package pkg
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/IAmRiteshKoushik/pulse/cmd"
+)
+
+const oauthStateTTL = 10 * time.Minute
+
+// OAuth state is signed rather than stored so that it can be validated by any
+// instance on callback. It carries the redirect URI and client application the
+// flow was started with, empty for the defaults. Format:
+// <nonce>.<expiry-unix>.<base64 redirect uri>.<base64 client id>.<signature>
+//
+// The nonce is also returned to be set as the state cookie, tying the flow to
+// the browser which started it.
+func CreateOAuthState(redirectUri string, clientId string) (string, string, error) {
+	nonce, err := newStateNonce()
+	if err != nil {
+		return "", "", err
+	}
+	payload := strings.Join([]string{
+		nonce,
+		strconv.FormatInt(time.Now().Add(oauthStateTTL).Unix(), 10),
+		base64.RawURLEncoding.EncodeToString([]byte(redirectUri)),
+		base64.RawURLEncoding.EncodeToString([]byte(clientId)),
+	}, ".")
+	return payload + "." + signState(payload), nonce, nil
+}
+
+// Returns the redirect URI and client id the flow was started with. nonce is
+// the value of the state cookie sent along with the callback.
+func VerifyOAuthState(state string, nonce string) (string, string, error) {
+	parts := strings.Split(state, ".")
+	if len(parts) != 5 {
+		return "", "", fmt.Errorf("Invalid state format")
+	}
+	payload := strings.Join(parts[:4], ".")
+	if !hmac.Equal([]byte(signState(payload)), []byte(parts[4])) {
+		return "", "", fmt.Errorf("Invalid state signature")
+	}
+	if nonce == "" || !hmac.Equal([]byte(parts[0]), []byte(nonce)) {
+		return "", "", fmt.Errorf("State not issued to this browser")
+	}
+	expiry, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", "", fmt.Errorf("Invalid state expiry")
+	}
+	if time.Now().Unix() > expiry {
+		return "", "", fmt.Errorf("State expired")
+	}
+	redirectUri, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", "", fmt.Errorf("Invalid state redirect")
+	}
+	clientId, err := base64.RawURLEncoding.DecodeString(parts[3])
+	if err != nil {
+		return "", "", fmt.Errorf("Invalid state client")
+	}
+	return string(redirectUri), string(clientId), nil
+}
+
+func newStateNonce() (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(nonce), nil
+}
+
+func signState(payload string) string {
+	mac := hmac.New(sha256.New, []byte(cmd.EnvVars.TokenSecret))
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Account linking runs the same OAuth flow while signed in, so its state also
+// carries the provider and the user being linked. Like the login state, its
+// nonce is returned to be set as the state cookie. Format:
+// link.<provider>.<base64 username>.<nonce>.<expiry-unix>.<signature>
+func CreateLinkState(provider string, ghUsername string) (string, string, error) {
+	nonce, err := newStateNonce()
+	if err != nil {
+		return "", "", err
+	}
+	payload := strings.Join([]string{
+		"link",
+		provider,
+		base64.RawURLEncoding.EncodeToString([]byte(ghUsername)),
+		nonce,
+		strconv.FormatInt(time.Now().Add(oauthStateTTL).Unix(), 10),
+	}, ".")
+	return payload + "." + signState(payload), nonce, nil
+}
+
+func IsLinkState(state string) bool {
+	return strings.HasPrefix(state, "link.")
+}
+
+// Returns the username the link was started by. nonce is the value of the
+// state cookie sent along with the callback, without it a victim could be
+// made to complete a link started by someone else.
+func VerifyLinkState(state string, provider string, nonce string) (string, error) {
+	parts := strings.Split(state, ".")
+	if len(parts) != 6 || parts[0] != "link" {
+		return "", fmt.Errorf("Invalid state format")
+	}
+	payload := strings.Join(parts[:5], ".")
+	if !hmac.Equal([]byte(signState(payload)), []byte(parts[5])) {
+		return "", fmt.Errorf("Invalid state signature")
+	}
+	if parts[1] != provider {
+		return "", fmt.Errorf("State issued for another provider")
+	}
+	if nonce == "" || !hmac.Equal([]byte(parts[3]), []byte(nonce)) {
+		return "", fmt.Errorf("State not issued to this browser")
+	}
+	expiry, err := strconv.ParseInt(parts[4], 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("Invalid state expiry")
+	}
+	if time.Now().Unix() > expiry {
+		return "", fmt.Errorf("State expired")
+	}
+	ghUsername, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(ghUsername) == 0 {
+		return "", fmt.Errorf("Invalid state username")
+	}
+	return string(ghUsername), nil
+}
@@ -0,0 +1,31 @@
+package pkg
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Every timestamp in a response is RFC3339 in UTC
+func FormatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// sqlc maps TIMESTAMP columns to Timestamp (see sqlc.yaml). Columns hold UTC
+// without a zone, which pgtype.Timestamp serializes without an offset, so
+// rows returned as is would be read as local time by clients.
+type Timestamp struct {
+	pgtype.Timestamp
+}
+
+func NewTimestamp(t time.Time) Timestamp {
+	return Timestamp{pgtype.Timestamp{Time: t, Valid: true}}
+}
+
+func (ts Timestamp) MarshalJSON() ([]byte, error) {
+	if !ts.Valid || ts.InfinityModifier != pgtype.Finite {
+		return ts.Timestamp.MarshalJSON()
+	}
+	return json.Marshal(FormatTime(ts.Time))
+}
@@ -0,0 +1,54 @@
+package pkg
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestFormatTime(t *testing.T) {
+	ist := time.FixedZone("IST", 5*60*60+30*60)
+	tests := []struct {
+		name string
+		time time.Time
+		want string
+	}{
+		{name: "utc", time: time.Date(2024, time.March, 1, 9, 30, 0, 0, time.UTC), want: "2024-03-01T09:30:00Z"},
+		{name: "converted to utc", time: time.Date(2024, time.March, 1, 9, 30, 0, 0, ist), want: "2024-03-01T04:00:00Z"},
+		{name: "sub-second dropped", time: time.Date(2024, time.March, 1, 9, 30, 0, 999, time.UTC), want: "2024-03-01T09:30:00Z"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FormatTime(tt.time); got != tt.want {
+				t.Errorf("FormatTime() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimestampMarshalJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		ts   Timestamp
+		want string
+	}{
+		{name: "valid", ts: NewTimestamp(time.Date(2024, time.March, 1, 9, 30, 0, 0, time.UTC)), want: `"2024-03-01T09:30:00Z"`},
+		{name: "null", ts: Timestamp{}, want: `null`},
+		{name: "infinity", ts: Timestamp{pgtype.Timestamp{InfinityModifier: pgtype.Infinity, Valid: true}}, want: `"infinity"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := json.Marshal(tt.ts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("json.Marshal() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
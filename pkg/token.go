@@ -1,48 +1,216 @@
 package pkg
 
 import (
+	"errors"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/IAmRiteshKoushik/pulse/cmd"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
-func CreateToken(ghUsername, email, tokenType string) (string, error) {
+const (
+	TempTokenTTL   = 5 * time.Minute
+	AccessTokenTTL = time.Hour
+
+	// Clients are advised to rotate refresh tokens this close to expiry
+	RefreshRotationWindow = 7 * 24 * time.Hour
+)
+
+const (
+	ScopeProfileRead     = "profile:read"
+	ScopeProfileWrite    = "profile:write"
+	ScopeProjectsRead    = "projects:read"
+	ScopeLeaderboardRead = "leaderboard:read"
+	ScopeBountyWrite     = "bounty:write"
+	ScopeBountyTransfer  = "bounty:transfer"
+)
+
+// Base scopes granted to every onboarded user's access token
+var DefaultUserScopes = []string{
+	ScopeProfileRead,
+	ScopeProfileWrite,
+	ScopeProjectsRead,
+	ScopeLeaderboardRead,
+	ScopeBountyTransfer,
+}
+
+// Maintainers additionally manage bounties
+var MaintainerScopes = append(
+	[]string{ScopeBountyWrite}, DefaultUserScopes...,
+)
+
+// Claims carried by every token issued by the server. Optional claims are
+// attached through TokenOption(s) and omitted from the token when unset.
+type Claims struct {
+	Email    string   `json:"email"`
+	Role     string   `json:"role,omitempty"`
+	Scopes   []string `json:"scopes,omitempty"`
+	DeviceId string   `json:"device_id,omitempty"`
+	// When the user last signed in, carried over as tokens are refreshed
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
+	jwt.RegisteredClaims
+}
+
+type TokenOption func(*Claims)
+
+func WithRole(role string) TokenOption {
+	return func(c *Claims) {
+		c.Role = role
+	}
+}
+
+func WithScopes(scopes ...string) TokenOption {
+	return func(c *Claims) {
+		c.Scopes = append(c.Scopes, scopes...)
+	}
+}
+
+// Overrides the randomly generated jti, allowing callers to track the token
+func WithTokenId(jti string) TokenOption {
+	return func(c *Claims) {
+		c.ID = jti
+	}
+}
+
+// Overrides the expiry derived from the token type, used to keep the
+// absolute expiry of a rotated refresh token
+func WithExpiry(expiresAt time.Time) TokenOption {
+	return func(c *Claims) {
+		c.ExpiresAt = jwt.NewNumericDate(expiresAt)
+	}
+}
+
+func WithAuthTime(authTime time.Time) TokenOption {
+	return func(c *Claims) {
+		c.AuthTime = jwt.NewNumericDate(authTime)
+	}
+}
+
+// Access tokens issued before scopes existed carry no scopes claim and are
+// granted the base scopes, so that signed in users are not locked out until
+// they refresh. Such tokens are gone one AccessTokenTTL after the upgrade.
+func (c *Claims) GrantedScopes() []string {
+	if c.Scopes == nil && c.Subject == "access_token" {
+		return DefaultUserScopes
+	}
+	return c.Scopes
+}
+
+// Tokens without auth_time were issued before it was tracked and are never
+// considered fresh
+func (c *Claims) SignedInWithin(d time.Duration) bool {
+	return c.AuthTime != nil && time.Since(c.AuthTime.Time) <= d
+}
+
+func WithDeviceId(deviceId string) TokenOption {
+	return func(c *Claims) {
+		c.DeviceId = deviceId
+	}
+}
+
+// Tokens issued to a registered client application carry its id as a second
+// audience, after the username
+func WithClient(clientId string) TokenOption {
+	return func(c *Claims) {
+		if clientId != "" {
+			c.Audience = append(c.Audience, clientId)
+		}
+	}
+}
+
+// Returns the client application the token was issued to, empty for tokens
+// not bound to one
+func (c *Claims) ClientId() string {
+	if len(c.Audience) < 2 {
+		return ""
+	}
+	return c.Audience[1]
+}
+
+// The audience holds the username, optionally followed by a client that is
+// still registered
+func (c *Claims) ValidAudience(env *cmd.EnvConfig) bool {
+	switch len(c.Audience) {
+	case 1:
+		return true
+	case 2:
+		return slices.Contains(env.ClientIds, c.Audience[1])
+	default:
+		return false
+	}
+}
+
+var ErrClientIdUnknown = errors.New("client_id is not registered")
+
+// An empty client id requests a token not bound to any client
+func CheckClientId(env *cmd.EnvConfig, clientId string) error {
+	if clientId != "" && !slices.Contains(env.ClientIds, clientId) {
+		return ErrClientIdUnknown
+	}
+	return nil
+}
+
+var ErrNoTokenSecret = errors.New("token secret is not set")
+
+// Tokens are never signed with an empty secret, as anyone could forge them
+func CreateToken(env *cmd.EnvConfig, ghUsername, email, tokenType string, opts ...TokenOption) (string, error) {
+	if env.TokenSecret == "" {
+		return "", ErrNoTokenSecret
+	}
 	var expiryAt time.Time
 	switch tokenType {
 	case "temp_token":
-		expiryAt = time.Now().Add(5 * time.Minute)
+		expiryAt = time.Now().Add(TempTokenTTL)
 	case "access_token":
-		expiryAt = time.Now().Add(1 * time.Hour)
+		expiryAt = time.Now().Add(AccessTokenTTL)
 		break
 	case "refresh_token":
-		expiryAt = time.Now().Add(90 * 24 * time.Hour)
+		// Sliding sessions expire earlier when unused, which is enforced by
+		// their session row
+		expiryAt = time.Now().Add(env.RefreshTokenMaxAge)
 		break
 	default:
 		return "", fmt.Errorf("Invalid tokenType provided. Valid types: %s, %s or %s",
 			"temp_token", "access_token", "refresh_token")
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256,
-		jwt.RegisteredClaims{
-			ID:        email,
+	claims := &Claims{
+		Email: email,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
 			Audience:  []string{ghUsername},
 			Issuer:    "api.season-of-code",
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			ExpiresAt: jwt.NewNumericDate(expiryAt),
 			Subject:   tokenType,
-		})
+		},
+	}
+	for _, opt := range opts {
+		opt(claims)
+	}
 
-	tokenString, err := token.SignedString([]byte(cmd.EnvVars.TokenSecret))
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+
+	tokenString, err := token.SignedString([]byte(env.TokenSecret))
 	if err != nil {
 		return "", err
 	}
 	return tokenString, nil
 }
 
-func VerifyToken(tokenString string) (*jwt.RegisteredClaims, error) {
-	claims := &jwt.RegisteredClaims{}
+var (
+	ErrTokenExpired   = errors.New("Token expired")
+	ErrTokenMalformed = errors.New("Token malformed")
+	ErrTokenSignature = errors.New("Token signature invalid")
+)
+
+// Errors returned wrap one of ErrTokenExpired, ErrTokenMalformed or
+// ErrTokenSignature
+func VerifyToken(env *cmd.EnvConfig, tokenString string) (*Claims, error) {
+	claims := &Claims{}
 	token, err := jwt.ParseWithClaims(
 		tokenString,
 		claims,
@@ -50,21 +218,27 @@ func VerifyToken(tokenString string) (*jwt.RegisteredClaims, error) {
 			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 				return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
 			}
-			return []byte(cmd.EnvVars.TokenSecret), nil
+			return []byte(env.TokenSecret), nil
 		})
 
-	if err != nil {
-		return nil, fmt.Errorf("Token parsing error: %s", err)
+	switch {
+	case err == nil:
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return nil, fmt.Errorf("%w: %s", ErrTokenExpired, err)
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid), errors.Is(err, jwt.ErrTokenUnverifiable):
+		return nil, fmt.Errorf("%w: %s", ErrTokenSignature, err)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrTokenMalformed, err)
 	}
 
 	if !token.Valid {
-		return nil, fmt.Errorf("Invalid token")
+		return nil, ErrTokenMalformed
 	}
-	if claims, ok := token.Claims.(*jwt.RegisteredClaims); ok {
+	if claims, ok := token.Claims.(*Claims); ok {
 		if claims.ExpiresAt != nil && claims.ExpiresAt.Before(time.Now()) {
-			return nil, fmt.Errorf("Token expires")
+			return nil, ErrTokenExpired
 		}
 		return claims, nil
 	}
-	return nil, fmt.Errorf("Invalid token claims type")
+	return nil, fmt.Errorf("%w: Invalid token claims type", ErrTokenMalformed)
 }
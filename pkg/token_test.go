@@ -0,0 +1,273 @@
+package pkg
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IAmRiteshKoushik/pulse/cmd"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func testTokenEnv() *cmd.EnvConfig {
+	return &cmd.EnvConfig{
+		TokenSecret:        "test-secret",
+		RefreshTokenMaxAge: 30 * 24 * time.Hour,
+		ClientIds:          []string{"mobile"},
+	}
+}
+
+func TestCreateTokenClaims(t *testing.T) {
+	authTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	expiresAt := time.Now().Add(10 * time.Minute).Truncate(time.Second)
+
+	tests := []struct {
+		name  string
+		opts  []TokenOption
+		check func(t *testing.T, c *Claims)
+	}{
+		{
+			name: "no options",
+			check: func(t *testing.T, c *Claims) {
+				if c.Role != "" || c.Scopes != nil || c.DeviceId != "" || c.AuthTime != nil {
+					t.Errorf("optional claims set: %+v", c)
+				}
+				if c.ID == "" {
+					t.Error("jti is empty")
+				}
+			},
+		},
+		{
+			name: "role",
+			opts: []TokenOption{WithRole("maintainer")},
+			check: func(t *testing.T, c *Claims) {
+				if c.Role != "maintainer" {
+					t.Errorf("role = %q", c.Role)
+				}
+			},
+		},
+		{
+			name: "scopes",
+			opts: []TokenOption{WithScopes(ScopeProfileRead), WithScopes(ScopeBountyWrite)},
+			check: func(t *testing.T, c *Claims) {
+				if !slices.Equal(c.Scopes, []string{ScopeProfileRead, ScopeBountyWrite}) {
+					t.Errorf("scopes = %v", c.Scopes)
+				}
+			},
+		},
+		{
+			name: "device id",
+			opts: []TokenOption{WithDeviceId("device-1")},
+			check: func(t *testing.T, c *Claims) {
+				if c.DeviceId != "device-1" {
+					t.Errorf("device_id = %q", c.DeviceId)
+				}
+			},
+		},
+		{
+			name: "token id",
+			opts: []TokenOption{WithTokenId("4b2c7e1a-jti")},
+			check: func(t *testing.T, c *Claims) {
+				if c.ID != "4b2c7e1a-jti" {
+					t.Errorf("jti = %q", c.ID)
+				}
+			},
+		},
+		{
+			name: "expiry and auth time",
+			opts: []TokenOption{WithExpiry(expiresAt), WithAuthTime(authTime)},
+			check: func(t *testing.T, c *Claims) {
+				if !c.ExpiresAt.Time.Equal(expiresAt) {
+					t.Errorf("exp = %v, want %v", c.ExpiresAt.Time, expiresAt)
+				}
+				if c.AuthTime == nil || !c.AuthTime.Time.Equal(authTime) {
+					t.Errorf("auth_time = %v, want %v", c.AuthTime, authTime)
+				}
+			},
+		},
+		{
+			name: "client",
+			opts: []TokenOption{WithClient("mobile")},
+			check: func(t *testing.T, c *Claims) {
+				if c.ClientId() != "mobile" {
+					t.Errorf("client = %q", c.ClientId())
+				}
+			},
+		},
+	}
+
+	env := testTokenEnv()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := CreateToken(env, "octocat", "octo@example.com", "access_token", tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			claims, err := VerifyToken(env, token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims.Email != "octo@example.com" || claims.Subject != "access_token" ||
+				len(claims.Audience) == 0 || claims.Audience[0] != "octocat" {
+				t.Errorf("standard claims = %s, %s, %v", claims.Email, claims.Subject, claims.Audience)
+			}
+			tt.check(t, claims)
+		})
+	}
+}
+
+func TestVerifyTokenErrors(t *testing.T) {
+	env := testTokenEnv()
+	sign := func(method jwt.SigningMethod, key any, claims jwt.Claims) string {
+		t.Helper()
+		token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	claims := func(expiresAt time.Time) *Claims {
+		return &Claims{
+			Email: "octo@example.com",
+			RegisteredClaims: jwt.RegisteredClaims{
+				Audience:  []string{"octocat"},
+				Issuer:    "api.season-of-code",
+				Subject:   "access_token",
+				ExpiresAt: jwt.NewNumericDate(expiresAt),
+			},
+		}
+	}
+	valid := sign(jwt.SigningMethodHS256, []byte(env.TokenSecret), claims(time.Now().Add(time.Hour)))
+	parts := strings.Split(valid, ".") // header, payload and signature
+	other := strings.Split(sign(jwt.SigningMethodHS256, []byte(env.TokenSecret),
+		claims(time.Now().Add(2*time.Hour))), ".")
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{name: "valid", token: valid},
+		{
+			name:    "expired",
+			token:   sign(jwt.SigningMethodHS256, []byte(env.TokenSecret), claims(time.Now().Add(-time.Minute))),
+			wantErr: ErrTokenExpired,
+		},
+		{
+			name:    "signed with another secret",
+			token:   sign(jwt.SigningMethodHS256, []byte("other-secret"), claims(time.Now().Add(time.Hour))),
+			wantErr: ErrTokenSignature,
+		},
+		{
+			name:    "payload swapped",
+			token:   parts[0] + "." + other[1] + "." + parts[2],
+			wantErr: ErrTokenSignature,
+		},
+		{
+			name:    "unsigned",
+			token:   sign(jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, claims(time.Now().Add(time.Hour))),
+			wantErr: ErrTokenSignature,
+		},
+		{name: "not a token", token: "pulse", wantErr: ErrTokenMalformed},
+		{name: "missing signature", token: parts[0] + "." + parts[1], wantErr: ErrTokenMalformed},
+		{name: "corrupt payload", token: parts[0] + ".e30x." + parts[2], wantErr: ErrTokenMalformed},
+		{name: "empty", token: "", wantErr: ErrTokenMalformed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := VerifyToken(env, tt.token)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("VerifyToken() error = %v", err)
+				}
+				if got.Email != "octo@example.com" {
+					t.Errorf("VerifyToken() email = %q", got.Email)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("VerifyToken() error = %v, want %v", err, tt.wantErr)
+			}
+			// Exactly one kind, so that handlers can tell them apart
+			for _, other := range []error{ErrTokenExpired, ErrTokenSignature, ErrTokenMalformed} {
+				if other != tt.wantErr && errors.Is(err, other) {
+					t.Errorf("VerifyToken() error = %v, also %v", err, other)
+				}
+			}
+		})
+	}
+}
+
+func TestClientAudience(t *testing.T) {
+	env := &cmd.EnvConfig{TokenSecret: "test-secret", ClientIds: []string{"web", "mobile"}}
+
+	tests := []struct {
+		name       string
+		clientId   string
+		registered []string // client ids registered by the time the token is used
+		wantClient string
+		wantValid  bool
+	}{
+		{name: "not bound to a client", registered: []string{"web", "mobile"}, wantValid: true},
+		{name: "bound to a client", clientId: "mobile", registered: []string{"web", "mobile"},
+			wantClient: "mobile", wantValid: true},
+		{name: "client since withdrawn", clientId: "mobile", registered: []string{"web"},
+			wantClient: "mobile"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := CreateToken(env, "octocat", "octo@example.com", "access_token", WithClient(tt.clientId))
+			if err != nil {
+				t.Fatal(err)
+			}
+			claims, err := VerifyToken(env, token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := claims.ClientId(); got != tt.wantClient {
+				t.Errorf("ClientId() = %q, want %q", got, tt.wantClient)
+			}
+			later := &cmd.EnvConfig{ClientIds: tt.registered}
+			if got := claims.ValidAudience(later); got != tt.wantValid {
+				t.Errorf("ValidAudience() = %v, want %v", got, tt.wantValid)
+			}
+		})
+	}
+
+	// A username followed by more than one client is never issued
+	claims := &Claims{RegisteredClaims: jwt.RegisteredClaims{Audience: []string{"octocat", "web", "mobile"}}}
+	if claims.ValidAudience(env) {
+		t.Error("ValidAudience() accepted an audience of three")
+	}
+}
+
+func TestCheckClientId(t *testing.T) {
+	env := &cmd.EnvConfig{ClientIds: []string{"web", "mobile"}}
+	tests := []struct {
+		clientId string
+		wantErr  error
+	}{
+		{clientId: ""},
+		{clientId: "web"},
+		{clientId: "mobile"},
+		{clientId: "cli", wantErr: ErrClientIdUnknown},
+		{clientId: "Web", wantErr: ErrClientIdUnknown},
+	}
+
+	for _, tt := range tests {
+		if err := CheckClientId(env, tt.clientId); !errors.Is(err, tt.wantErr) {
+			t.Errorf("CheckClientId(%q) = %v, want %v", tt.clientId, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCreateTokenWithoutSecret(t *testing.T) {
+	_, err := CreateToken(&cmd.EnvConfig{}, "octocat", "octo@example.com", "temp_token")
+	if !errors.Is(err, ErrNoTokenSecret) {
+		t.Errorf("CreateToken() error = %v, want %v", err, ErrNoTokenSecret)
+	}
+}
@@ -0,0 +1,60 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// Returned by the function given to RunInTx once it has written a response
+// itself, rolling the transaction back without any further response
+var ErrTxAborted = errors.New("transaction aborted")
+
+// Satisfied by both *pgxpool.Pool and *pgxpool.Conn
+type TxBeginner interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+type txBeginError struct {
+	err error
+}
+
+func (e *txBeginError) Error() string {
+	return "begin transaction: " + e.err.Error()
+}
+
+func (e *txBeginError) Unwrap() error {
+	return e.err
+}
+
+// RunInTx runs fn in a transaction which is committed when fn returns nil and
+// rolled back otherwise, including when fn panics. Errors are returned as is,
+// for TxError to respond to.
+func RunInTx(ctx context.Context, db TxBeginner, fn func(tx pgx.Tx) error) error {
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return &txBeginError{err: err}
+	}
+	// No-op once the transaction is committed
+	defer tx.Rollback(ctx)
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// TxError responds to an error returned by RunInTx. Failing to begin is
+// treated like failing to acquire a connection.
+func TxError(c *gin.Context, err error) {
+	var beginErr *txBeginError
+	switch {
+	case errors.Is(err, ErrTxAborted):
+	case errors.As(err, &beginErr):
+		DbAcquireError(c, beginErr.err)
+	default:
+		DbError(c, err)
+	}
+}
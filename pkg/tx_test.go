@@ -0,0 +1,151 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/puddle/v2"
+)
+
+// Records how the transaction ended. Like pgx, a rollback after a commit
+// does nothing.
+type recordingTx struct {
+	pgx.Tx
+	commitErr error
+	committed bool
+	rolled    bool
+}
+
+func (tx *recordingTx) Commit(ctx context.Context) error {
+	if tx.commitErr != nil {
+		return tx.commitErr
+	}
+	tx.committed = true
+	return nil
+}
+
+func (tx *recordingTx) Rollback(ctx context.Context) error {
+	if !tx.committed {
+		tx.rolled = true
+	}
+	return nil
+}
+
+type recordingBeginner struct {
+	tx       *recordingTx
+	beginErr error
+}
+
+func (b *recordingBeginner) Begin(ctx context.Context) (pgx.Tx, error) {
+	if b.beginErr != nil {
+		return nil, b.beginErr
+	}
+	return b.tx, nil
+}
+
+func TestRunInTx(t *testing.T) {
+	fnErr := errors.New("insert failed")
+	commitErr := errors.New("serialization failure")
+
+	tests := []struct {
+		name         string
+		fnErr        error
+		commitErr    error
+		wantErr      error
+		wantCommit   bool
+		wantRollback bool
+	}{
+		{name: "success commits", wantCommit: true},
+		{name: "error rolls back", fnErr: fnErr, wantErr: fnErr, wantRollback: true},
+		{name: "aborted rolls back", fnErr: ErrTxAborted, wantErr: ErrTxAborted, wantRollback: true},
+		{name: "failed commit rolls back", commitErr: commitErr, wantErr: commitErr, wantRollback: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := &recordingTx{commitErr: tt.commitErr}
+			var ran pgx.Tx
+			err := RunInTx(context.Background(), &recordingBeginner{tx: tx}, func(fnTx pgx.Tx) error {
+				ran = fnTx
+				return tt.fnErr
+			})
+
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Errorf("RunInTx() error = %v, want %v", err, tt.wantErr)
+			}
+			if ran != tx {
+				t.Error("fn did not run in the transaction")
+			}
+			if tx.committed != tt.wantCommit || tx.rolled != tt.wantRollback {
+				t.Errorf("committed %v, rolled back %v, want %v, %v",
+					tx.committed, tx.rolled, tt.wantCommit, tt.wantRollback)
+			}
+		})
+	}
+}
+
+func TestRunInTxPanic(t *testing.T) {
+	tx := &recordingTx{}
+	defer func() {
+		if recover() == nil {
+			t.Error("panic was swallowed")
+		}
+		if tx.committed || !tx.rolled {
+			t.Errorf("committed %v, rolled back %v after a panic", tx.committed, tx.rolled)
+		}
+	}()
+	RunInTx(context.Background(), &recordingBeginner{tx: tx}, func(pgx.Tx) error {
+		panic("nil map")
+	})
+}
+
+func TestRunInTxBeginError(t *testing.T) {
+	ran := false
+	err := RunInTx(context.Background(), &recordingBeginner{beginErr: puddle.ErrClosedPool}, func(pgx.Tx) error {
+		ran = true
+		return nil
+	})
+	if ran {
+		t.Error("fn ran without a transaction")
+	}
+	if !errors.Is(err, puddle.ErrClosedPool) {
+		t.Errorf("RunInTx() error = %v, want %v", err, puddle.ErrClosedPool)
+	}
+}
+
+func TestTxError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+	}{
+		// The function already responded, which is left as is
+		{name: "aborted", err: ErrTxAborted, wantStatus: http.StatusConflict},
+		{name: "begin timeout", err: &txBeginError{err: context.DeadlineExceeded}, wantStatus: http.StatusServiceUnavailable},
+		{name: "query error", err: errors.New("insert failed"), wantStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/auth/register", nil)
+			if errors.Is(tt.err, ErrTxAborted) {
+				c.JSON(http.StatusConflict, gin.H{"message": "User already registered."})
+			}
+
+			TxError(c, tt.err)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if errors.Is(tt.err, ErrTxAborted) && w.Body.String() != `{"message":"User already registered."}` {
+				t.Errorf("body = %s, want the response written in the transaction", w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package pkg
+
+import "strings"
+
+// Tokens are matched in order, as most browsers also claim to be the ones
+// they are based on (every Chromium browser sends "Chrome/" and "Safari/")
+var uaBrowsers = []struct{ token, name string }{
+	{"Edg/", "Edge"},
+	{"EdgA/", "Edge"},
+	{"EdgiOS/", "Edge"},
+	{"OPR/", "Opera"},
+	{"SamsungBrowser/", "Samsung Internet"},
+	{"Firefox/", "Firefox"},
+	{"FxiOS/", "Firefox"},
+	{"CriOS/", "Chrome"},
+	{"Chrome/", "Chrome"},
+	{"Safari/", "Safari"},
+}
+
+// iOS and Android user agents also mention Mac OS X and Linux respectively
+var uaSystems = []struct{ token, name string }{
+	{"iPhone", "iOS"},
+	{"iPad", "iPadOS"},
+	{"Android", "Android"},
+	{"CrOS", "ChromeOS"},
+	{"Windows", "Windows"},
+	{"Mac OS X", "macOS"},
+	{"Macintosh", "macOS"},
+	{"Linux", "Linux"},
+}
+
+// Derives a label such as "Chrome on macOS" from a user agent, for users to
+// recognize their sessions by. Only meant for display, never for decisions.
+func ParseUserAgent(userAgent string) string {
+	browser := ""
+	for _, b := range uaBrowsers {
+		if strings.Contains(userAgent, b.token) {
+			browser = b.name
+			break
+		}
+	}
+	system := ""
+	for _, s := range uaSystems {
+		if strings.Contains(userAgent, s.token) {
+			system = s.name
+			break
+		}
+	}
+
+	switch {
+	case browser != "" && system != "":
+		return browser + " on " + system
+	case browser != "":
+		return browser
+	case system != "":
+		return "Unknown browser on " + system
+	}
+	return "Unknown device"
+}
@@ -0,0 +1,59 @@
+package pkg
+
+import "testing"
+
+func TestParseUserAgent(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent string
+		want      string
+	}{
+		{
+			name:      "chrome on macos",
+			userAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			want:      "Chrome on macOS",
+		},
+		{
+			name:      "edge on windows",
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.0.0",
+			want:      "Edge on Windows",
+		},
+		{
+			name:      "firefox on linux",
+			userAgent: "Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0",
+			want:      "Firefox on Linux",
+		},
+		{
+			name:      "safari on ios",
+			userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Mobile/15E148 Safari/604.1",
+			want:      "Safari on iOS",
+		},
+		{
+			name:      "chrome on ios",
+			userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/120.0.6099.119 Mobile/15E148 Safari/604.1",
+			want:      "Chrome on iOS",
+		},
+		{
+			name:      "samsung internet on android",
+			userAgent: "Mozilla/5.0 (Linux; Android 14; SM-S918B) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/23.0 Chrome/115.0.0.0 Mobile Safari/537.36",
+			want:      "Samsung Internet on Android",
+		},
+		{
+			name:      "opera on chromeos",
+			userAgent: "Mozilla/5.0 (X11; CrOS x86_64 14541.0.0) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 OPR/105.0.0.0",
+			want:      "Opera on ChromeOS",
+		},
+		{name: "browser only", userAgent: "Firefox/121.0", want: "Firefox"},
+		{name: "system only", userAgent: "curl/8.4.0 (Windows)", want: "Unknown browser on Windows"},
+		{name: "unknown", userAgent: "curl/8.4.0", want: "Unknown device"},
+		{name: "empty", userAgent: "", want: "Unknown device"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseUserAgent(tt.userAgent); got != tt.want {
+				t.Errorf("ParseUserAgent() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,19 +1,44 @@
 package pkg
 
 import (
-	"fmt"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
+// NormalizeGhUsername trims and lowercases a GitHub username, which GitHub
+// treats case-insensitively, so that lookups match regardless of casing
+func NormalizeGhUsername(ghUsername string) string {
+	return strings.ToLower(strings.TrimSpace(ghUsername))
+}
+
+// Returns the normalized username of the verified token, set by the Auth
+// middleware
 func GrabUsername(c *gin.Context) (string, bool) {
-	username, ok := c.Get("username")
-	if ok != true {
-		return "", ok
+	value, ok := c.Get("username")
+	if !ok {
+		return "", false
 	}
-	if fmt.Sprintf("%T", username) == "string" {
-		return fmt.Sprintf("%s", username), true
+	username, ok := value.(string)
+	return username, ok && username != ""
+}
+
+// Returns the email of the verified token, set by the Auth middleware
+func GrabEmail(c *gin.Context) (string, bool) {
+	claims, ok := GrabClaims(c)
+	if !ok || claims.Email == "" {
+		return "", false
+	}
+	return claims.Email, true
+}
+
+// Returns all claims of the verified token, set by the Auth middleware, so
+// that handlers need not look up what the token already carries
+func GrabClaims(c *gin.Context) (*Claims, bool) {
+	value, ok := c.Get("claims")
+	if !ok {
+		return nil, false
 	}
-	fmt.Printf("%T is the type of %s", username, username)
-	return "", false
+	claims, ok := value.(*Claims)
+	return claims, ok && claims != nil
 }
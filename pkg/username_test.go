@@ -0,0 +1,77 @@
+package pkg
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestGrabClaims(t *testing.T) {
+	claims := &Claims{
+		Email:            "user@example.com",
+		Role:             "admin",
+		RegisteredClaims: jwt.RegisteredClaims{ID: "3f1c2a9e-0000-4000-8000-000000000000"},
+	}
+	tests := []struct {
+		name      string
+		set       map[string]any
+		wantOk    bool
+		wantEmail string
+		wantUser  string
+	}{
+		{
+			name:      "claims present",
+			set:       map[string]any{"claims": claims, "username": "octocat"},
+			wantOk:    true,
+			wantEmail: "user@example.com",
+			wantUser:  "octocat",
+		},
+		{
+			name: "claims missing",
+			set:  map[string]any{},
+		},
+		{
+			name: "claims of the wrong type",
+			set:  map[string]any{"claims": "not claims", "username": 42},
+		},
+		{
+			name: "nil claims",
+			set:  map[string]any{"claims": (*Claims)(nil)},
+		},
+		{
+			name:     "claims without an email",
+			set:      map[string]any{"claims": &Claims{Role: "user"}, "username": "octocat"},
+			wantOk:   true,
+			wantUser: "octocat",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			for key, value := range tt.set {
+				c.Set(key, value)
+			}
+
+			got, ok := GrabClaims(c)
+			if ok != tt.wantOk {
+				t.Fatalf("GrabClaims() ok = %v, want %v", ok, tt.wantOk)
+			}
+			if ok && got.Role != tt.set["claims"].(*Claims).Role {
+				t.Errorf("GrabClaims() role = %q", got.Role)
+			}
+
+			email, ok := GrabEmail(c)
+			if email != tt.wantEmail || ok != (tt.wantEmail != "") {
+				t.Errorf("GrabEmail() = %q, %v, want %q", email, ok, tt.wantEmail)
+			}
+
+			username, ok := GrabUsername(c)
+			if username != tt.wantUser || ok != (tt.wantUser != "") {
+				t.Errorf("GrabUsername() = %q, %v, want %q", username, ok, tt.wantUser)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package pkg
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Webhook payloads are signed as "sha256=<hex hmac of body>" using the
+// secret shared with the provider
+func VerifyWebhookSignature(body []byte, signature, secret string) error {
+	if secret == "" {
+		return fmt.Errorf("Webhook secret is not configured")
+	}
+	given, found := strings.CutPrefix(signature, "sha256=")
+	if !found {
+		return fmt.Errorf("Invalid signature format")
+	}
+	givenMac, err := hex.DecodeString(given)
+	if err != nil {
+		return fmt.Errorf("Invalid signature encoding")
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	if !hmac.Equal(mac.Sum(nil), givenMac) {
+		return fmt.Errorf("Invalid signature")
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	v "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 100
+	maxAuditRange        = 90 * 24 * time.Hour
+)
+
+type FetchAuditLogsRequest struct {
+	Actor    string    `form:"actor"`
+	Action   string    `form:"action"`
+	From     time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To       time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Cursor   string    `form:"cursor"`
+	PageSize int       `form:"page_size"`
+}
+
+func (r *FetchAuditLogsRequest) Validate() error {
+	r.Actor = strings.TrimSpace(r.Actor)
+	r.Action = strings.TrimSpace(r.Action)
+	r.Cursor = strings.TrimSpace(r.Cursor)
+
+	// Defaults to the latest 30 days when no range is provided
+	if r.To.IsZero() {
+		r.To = time.Now()
+	}
+	if r.From.IsZero() {
+		r.From = r.To.Add(-30 * 24 * time.Hour)
+	}
+	r.From = r.From.UTC()
+	r.To = r.To.UTC()
+	if r.PageSize == 0 {
+		r.PageSize = defaultAuditPageSize
+	}
+
+	return v.ValidateStruct(r,
+		v.Field(&r.Actor, v.Length(0, 50)),
+		v.Field(&r.Action, v.Length(0, 50)),
+		v.Field(&r.To,
+			v.Min(r.From).Error("must not be before from"),
+			v.By(func(any) error {
+				if r.To.Sub(r.From) > maxAuditRange {
+					return fmt.Errorf("time range must not exceed 90 days")
+				}
+				return nil
+			}),
+		),
+		v.Field(&r.PageSize, v.Min(1), v.Max(maxAuditPageSize)),
+	)
+}
@@ -6,6 +6,8 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/IAmRiteshKoushik/pulse/cmd"
+	"github.com/IAmRiteshKoushik/pulse/pkg"
 	v "github.com/go-ozzo/ozzo-validation/v4"
 	"github.com/go-ozzo/ozzo-validation/v4/is"
 )
@@ -16,42 +18,70 @@ type RegisterUserRequest struct {
 	FirstName  string `json:"first_name"`
 	MiddleName string `json:"middle_name"`
 	LastName   string `json:"last_name"`
+	Timezone   string `json:"timezone"`    // optional, IANA time zone
+	Locale     string `json:"locale"`      // optional, BCP 47 language tag
+	Phone      string `json:"phone"`       // optional, E.164
+	OtpChannel string `json:"otp_channel"` // "email" (default) or "sms"
 }
 
-func (r *RegisterUserRequest) Validate() error {
-	r.Email = strings.TrimSpace(r.Email)
-	r.GhUsername = strings.TrimSpace(r.GhUsername)
+func (r *RegisterUserRequest) Validate(env *cmd.EnvConfig) error {
+	r.Email = pkg.NormalizeEmail(r.Email, env.CollapseGmailDots)
+	r.GhUsername = pkg.NormalizeGhUsername(r.GhUsername)
 	r.FirstName = strings.TrimSpace(r.FirstName)
 	r.MiddleName = strings.TrimSpace(r.MiddleName)
 	r.LastName = strings.TrimSpace(r.LastName)
+	r.Timezone = strings.TrimSpace(r.Timezone)
+	if r.Timezone == "" {
+		r.Timezone = pkg.DefaultTimezone
+	}
+	r.Locale = pkg.NormalizeLocale(r.Locale)
+	if r.Locale == "" {
+		r.Locale = pkg.DefaultLocale
+	}
+	r.Phone = pkg.NormalizePhone(strings.TrimSpace(r.Phone))
+	r.OtpChannel = strings.ToLower(strings.TrimSpace(r.OtpChannel))
+	if r.OtpChannel == "" {
+		r.OtpChannel = pkg.OtpChannelEmail
+	}
 
 	err := v.ValidateStruct(r,
 		v.Field(
 			&r.Email,
 			v.Required,
 			is.EmailFormat,
-			v.Match(regexp.MustCompile(`@cb.students.amrita.edu$`)),
+			v.By(func(any) error { return pkg.CheckEmailDomain(env, r.Email) }),
 		),
 		v.Field(&r.GhUsername, v.Required, v.Length(3, 50)),
 		v.Field(&r.FirstName, v.Required, v.Length(2, 50), is.Alpha),
 		v.Field(&r.MiddleName, v.Required, v.Length(2, 50), is.Alpha),
 		v.Field(&r.LastName, v.Required, v.Length(1, 50), is.Alpha),
+		v.Field(&r.Timezone, v.By(func(any) error { return pkg.CheckTimezone(r.Timezone) })),
+		v.Field(&r.Locale, v.By(func(any) error { return pkg.CheckLocale(r.Locale) })),
+		v.Field(&r.Phone,
+			v.When(r.OtpChannel == pkg.OtpChannelSms, v.Required),
+			v.When(r.Phone != "", v.By(func(any) error { return pkg.CheckPhone(r.Phone) })),
+			v.When(r.OtpChannel == pkg.OtpChannelSms, v.By(func(any) error { return pkg.CheckSmsCountry(env, r.Phone) })),
+		),
+		v.Field(&r.OtpChannel,
+			v.In(pkg.OtpChannelEmail, pkg.OtpChannelSms),
+			v.By(func(any) error { return pkg.CheckOtpChannel(env, r.OtpChannel) }),
+		),
 	)
 	if err != nil {
 		return err
 	}
 
 	// Check for Valid GitHub username
-	url := fmt.Sprintf("https://api.github.com/users/%s", r.GhUsername)
+	url := fmt.Sprintf("%s/users/%s", env.GhApiUrl, r.GhUsername)
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return err
 	}
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := cmd.GithubHTTPClient.Do(req)
 	if err != nil {
 		return err
 	}
+	defer resp.Body.Close()
 	switch resp.StatusCode {
 	case http.StatusOK:
 		return nil
@@ -62,14 +92,54 @@ func (r *RegisterUserRequest) Validate() error {
 	}
 }
 
+type UsernameAvailabilityRequest struct {
+	GhUsername string `form:"ghusername"`
+}
+
+func (r *UsernameAvailabilityRequest) Validate() error {
+	r.GhUsername = pkg.NormalizeGhUsername(r.GhUsername)
+
+	return v.ValidateStruct(r,
+		v.Field(&r.GhUsername, v.Required, v.Length(3, 50)),
+	)
+}
+
+// The OTP proves the caller received it, knowing the email and username of a
+// registration is not enough to take it over
+type ReissueTempTokenRequest struct {
+	Email      string `json:"email"`
+	GhUsername string `json:"github_username"`
+	Otp        string `json:"otp"`
+}
+
+func (r *ReissueTempTokenRequest) Validate(env *cmd.EnvConfig) error {
+	r.Email = pkg.NormalizeEmail(r.Email, env.CollapseGmailDots)
+	r.GhUsername = pkg.NormalizeGhUsername(r.GhUsername)
+	r.Otp = pkg.NormalizeOTP(r.Otp, env.OtpAlphanumeric)
+
+	return v.ValidateStruct(r,
+		v.Field(&r.Email, v.Required, is.EmailFormat),
+		v.Field(&r.GhUsername, v.Required, v.Length(3, 50)),
+		v.Field(&r.Otp, v.Required, otpRule(env.OtpAlphanumeric)),
+	)
+}
+
 type RegisterUserOtpVerifyRequest struct {
 	Otp string `json:"otp"`
 }
 
-func (r *RegisterUserOtpVerifyRequest) Validate() error {
-	r.Otp = strings.TrimSpace(r.Otp)
+func (r *RegisterUserOtpVerifyRequest) Validate(env *cmd.EnvConfig) error {
+	r.Otp = pkg.NormalizeOTP(r.Otp, env.OtpAlphanumeric)
 
 	return v.ValidateStruct(r,
-		v.Field(&r.Otp, v.Required, v.Length(6, 6), is.Digit),
+		v.Field(&r.Otp, v.Required, otpRule(env.OtpAlphanumeric)),
 	)
 }
+
+func otpRule(alphanumeric bool) v.Rule {
+	if alphanumeric {
+		return v.Match(regexp.MustCompile(`^[a-z0-9]{6}$`)).
+			Error("must be exactly 6 letters or digits")
+	}
+	return v.Match(regexp.MustCompile(`^[0-9]{6}$`)).Error("must be exactly 6 digits")
+}
@@ -0,0 +1,190 @@
+package types
+
+import (
+	"errors"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/IAmRiteshKoushik/pulse/cmd"
+	v "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+func TestOtpRule(t *testing.T) {
+	tests := []struct {
+		name         string
+		otp          string
+		alphanumeric bool
+		wantErr      bool
+	}{
+		{name: "valid", otp: "123456"},
+		{name: "surrounding spaces", otp: " 123456 "},
+		{name: "missing", otp: "", wantErr: true},
+		{name: "too short", otp: "12345", wantErr: true},
+		{name: "too long", otp: "1234567", wantErr: true},
+		{name: "non-numeric", otp: "12a456", wantErr: true},
+		{name: "inner space", otp: "123 456", wantErr: true},
+		{name: "alphanumeric", otp: "a1b2c3", alphanumeric: true},
+		{name: "alphanumeric upper case", otp: "A1B2C3", alphanumeric: true},
+		{name: "alphanumeric too short", otp: "a1b2c", alphanumeric: true, wantErr: true},
+		{name: "alphanumeric too long", otp: "a1b2c3d", alphanumeric: true, wantErr: true},
+		{name: "alphanumeric symbol", otp: "a1b2c!", alphanumeric: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := &cmd.EnvConfig{OtpAlphanumeric: tt.alphanumeric}
+			r := RegisterUserOtpVerifyRequest{Otp: tt.otp}
+			err := r.Validate(env)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate(%q) error = %v, want error %v", tt.otp, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRegisterUserEmailDomain(t *testing.T) {
+	github := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/users/octocat" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer github.Close()
+	env := &cmd.EnvConfig{
+		GhApiUrl:             github.URL,
+		EmailDomainAllowlist: []string{"amrita.edu"},
+		EmailDomainDenylist:  []string{"alumni.amrita.edu"},
+		EmailBlockDisposable: true,
+	}
+
+	tests := []struct {
+		name      string
+		email     string
+		wantError string // on the email field
+	}{
+		{name: "allowed", email: "Octo@CB.Amrita.edu"},
+		{name: "not allowed", email: "octo@gmail.com", wantError: "email domain is not allowed to register"},
+		{name: "denied", email: "octo@alumni.amrita.edu", wantError: "email domain is blocked from registering"},
+		{name: "disposable", email: "octo@10minutemail.com", wantError: "disposable email addresses are not allowed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := RegisterUserRequest{
+				Email:      tt.email,
+				GhUsername: "octocat",
+				FirstName:  "Octo",
+				MiddleName: "The",
+				LastName:   "Cat",
+			}
+			err := r.Validate(env)
+			if tt.wantError == "" {
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+				return
+			}
+			var errs v.Errors
+			if !errors.As(err, &errs) {
+				t.Fatalf("Validate() error = %v, want field errors", err)
+			}
+			if got := errs["email"]; got == nil || got.Error() != tt.wantError {
+				t.Errorf("email error = %v, want %q", got, tt.wantError)
+			}
+			if len(errs) != 1 {
+				t.Errorf("errors = %v, want only the email", errs)
+			}
+		})
+	}
+}
+
+func TestRegisterUserGhUsernameCase(t *testing.T) {
+	var searched []string
+	github := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		searched = append(searched, r.URL.Path)
+	}))
+	defer github.Close()
+	env := &cmd.EnvConfig{GhApiUrl: github.URL}
+
+	for _, ghUsername := range []string{"octocat", "OctoCat", " OCTOCAT "} {
+		r := RegisterUserRequest{
+			Email:      "octo@example.com",
+			GhUsername: ghUsername,
+			FirstName:  "Octo",
+			MiddleName: "The",
+			LastName:   "Cat",
+		}
+		if err := r.Validate(env); err != nil {
+			t.Fatalf("Validate(%q) error = %v", ghUsername, err)
+		}
+		if r.GhUsername != "octocat" {
+			t.Errorf("Validate(%q) left the username as %q, want octocat", ghUsername, r.GhUsername)
+		}
+	}
+	if len(searched) != 3 {
+		t.Errorf("searched GitHub %d times, want 3", len(searched))
+	}
+	for _, path := range searched {
+		if path != "/users/octocat" {
+			t.Errorf("searched GitHub for %s", path)
+		}
+	}
+}
+
+func TestRegisterUserLocale(t *testing.T) {
+	github := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer github.Close()
+	env := &cmd.EnvConfig{GhApiUrl: github.URL}
+
+	tests := []struct {
+		name         string
+		timezone     string
+		locale       string
+		wantTimezone string
+		wantLocale   string
+		wantErrors   []string // fields with an error
+	}{
+		{name: "defaults", wantTimezone: "UTC", wantLocale: "en"},
+		{name: "given", timezone: "Asia/Kolkata", locale: "en-IN", wantTimezone: "Asia/Kolkata", wantLocale: "en-IN"},
+		{name: "normalized", timezone: " Europe/Berlin ", locale: "de_de", wantTimezone: "Europe/Berlin", wantLocale: "de-DE"},
+		{name: "invalid timezone", timezone: "Mars/Olympus_Mons", locale: "en", wantErrors: []string{"timezone"}},
+		{name: "server timezone", timezone: "Local", wantErrors: []string{"timezone"}},
+		{name: "invalid locale", locale: "klingon!", wantErrors: []string{"locale"}},
+		{name: "both invalid", timezone: "GMT+25", locale: "not a locale", wantErrors: []string{"locale", "timezone"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := RegisterUserRequest{
+				Email:      "octo@example.com",
+				GhUsername: "octocat",
+				FirstName:  "Octo",
+				MiddleName: "The",
+				LastName:   "Cat",
+				Timezone:   tt.timezone,
+				Locale:     tt.locale,
+			}
+			err := r.Validate(env)
+			if len(tt.wantErrors) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+				if r.Timezone != tt.wantTimezone || r.Locale != tt.wantLocale {
+					t.Errorf("timezone, locale = %q, %q, want %q, %q",
+						r.Timezone, r.Locale, tt.wantTimezone, tt.wantLocale)
+				}
+				return
+			}
+			var errs v.Errors
+			if !errors.As(err, &errs) {
+				t.Fatalf("Validate() error = %v, want field errors", err)
+			}
+			fields := slices.Sorted(maps.Keys(errs))
+			if !slices.Equal(fields, tt.wantErrors) {
+				t.Errorf("errors = %v, want on %v", errs, tt.wantErrors)
+			}
+		})
+	}
+}
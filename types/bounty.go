@@ -0,0 +1,123 @@
+package types
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	v "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+)
+
+type BountyTransferRequest struct {
+	ToGhUsername string `json:"to_ghusername"`
+	Amount       int32  `json:"amount"`
+}
+
+func (r *BountyTransferRequest) Validate() error {
+	r.ToGhUsername = pkg.NormalizeGhUsername(r.ToGhUsername)
+
+	return v.ValidateStruct(r,
+		v.Field(&r.ToGhUsername, v.Required, v.Length(3, 50)),
+		v.Field(&r.Amount, v.Required, v.Min(int32(1))),
+	)
+}
+
+type BountyAwardRequest struct {
+	GhUsername string `json:"ghusername"`
+	Amount     int32  `json:"amount"`
+	ProofUrl   string `json:"proof_url"`
+	RepoId     string `json:"repo_id"`
+}
+
+func (r *BountyAwardRequest) Validate() error {
+	r.GhUsername = pkg.NormalizeGhUsername(r.GhUsername)
+	r.ProofUrl = strings.TrimSpace(r.ProofUrl)
+
+	return v.ValidateStruct(r,
+		v.Field(&r.GhUsername, v.Required, v.Length(3, 50)),
+		v.Field(&r.Amount, v.Required, v.Min(int32(1))),
+		v.Field(&r.ProofUrl, v.Required, is.URL),
+		v.Field(&r.RepoId, v.Required, is.UUID),
+	)
+}
+
+// Rows beyond this are rejected to keep a batch within one request timeout
+const MaxBountyBatchRows = 1000
+
+// A row of a bounty batch CSV. Err is set when the row could not be parsed
+// so that it is reported rather than failing the whole batch.
+type BountyBatchRow struct {
+	Line       int
+	GhUsername string
+	Amount     int32
+	Reason     string
+	Err        error
+}
+
+type BountyBatchResult struct {
+	Line       int    `json:"line"`
+	GhUsername string `json:"ghusername"`
+	Amount     int32  `json:"amount"`
+	Status     string `json:"status"` // "awarded" or "failed"
+	Error      string `json:"error,omitempty"`
+}
+
+// Parses CSV of the form "ghusername,amount,reason". A leading header row is
+// skipped.
+func ParseBountyBatchCSV(r io.Reader) ([]BountyBatchRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var rows []BountyBatchRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Invalid CSV: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+		if len(rows) == 0 && len(record) > 0 &&
+			strings.EqualFold(strings.TrimSpace(record[0]), "ghusername") {
+			continue
+		}
+		if len(rows) == MaxBountyBatchRows {
+			return nil, fmt.Errorf("CSV must not exceed %d rows", MaxBountyBatchRows)
+		}
+		rows = append(rows, parseBountyBatchRecord(line, record))
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("CSV contains no rows")
+	}
+	return rows, nil
+}
+
+func parseBountyBatchRecord(line int, record []string) BountyBatchRow {
+	row := BountyBatchRow{Line: line, GhUsername: pkg.NormalizeGhUsername(record[0])}
+	if len(record) != 3 {
+		row.Err = fmt.Errorf("expected 3 fields, found %d", len(record))
+		return row
+	}
+	row.Reason = strings.TrimSpace(record[2])
+
+	amount, err := strconv.ParseInt(strings.TrimSpace(record[1]), 10, 32)
+	if err != nil {
+		row.Err = fmt.Errorf("invalid amount")
+		return row
+	}
+	row.Amount = int32(amount)
+
+	row.Err = v.Errors{
+		"ghusername": v.Validate(row.GhUsername, v.Required, v.Length(3, 50)),
+		"amount":     v.Validate(row.Amount, v.Required, v.Min(int32(1))),
+		"reason":     v.Validate(row.Reason, v.Required, v.Length(1, 200)),
+	}.Filter()
+	return row
+}
@@ -0,0 +1,89 @@
+package types
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseBountyBatchCSV(t *testing.T) {
+	type wantRow struct {
+		line       int
+		ghusername string
+		amount     int32
+		reason     string
+		wantErr    bool
+	}
+	tests := []struct {
+		name     string
+		csv      string
+		wantRows []wantRow
+		wantErr  bool // for the whole CSV
+	}{
+		{
+			name: "header and valid rows",
+			csv:  "ghusername,amount,reason\nOctoCat, 20, Fixed the leaderboard\nhubot,5,Reviewed a pull request\n",
+			wantRows: []wantRow{
+				{line: 2, ghusername: "octocat", amount: 20, reason: "Fixed the leaderboard"},
+				{line: 3, ghusername: "hubot", amount: 5, reason: "Reviewed a pull request"},
+			},
+		},
+		{
+			name:     "without a header",
+			csv:      "octocat,20,Fixed the leaderboard",
+			wantRows: []wantRow{{line: 1, ghusername: "octocat", amount: 20, reason: "Fixed the leaderboard"}},
+		},
+		{
+			// Whether a user exists is only known when awarding, so parsing
+			// keeps every well formed row and flags the rest one by one
+			name: "mixed rows",
+			csv: strings.Join([]string{
+				"ghusername,amount,reason",
+				"octocat,20,Fixed the leaderboard",
+				"no-such-user,10,Unknown user",
+				"hubot,ten,Not a number",
+				"hubot,0,Nothing awarded",
+				"hubot,10",
+				"mona,10,",
+				"ab,10,Username too short",
+			}, "\n"),
+			wantRows: []wantRow{
+				{line: 2, ghusername: "octocat", amount: 20, reason: "Fixed the leaderboard"},
+				{line: 3, ghusername: "no-such-user", amount: 10, reason: "Unknown user"},
+				{line: 4, ghusername: "hubot", wantErr: true},
+				{line: 5, ghusername: "hubot", wantErr: true},
+				{line: 6, ghusername: "hubot", wantErr: true},
+				{line: 7, ghusername: "mona", wantErr: true},
+				{line: 8, ghusername: "ab", wantErr: true},
+			},
+		},
+		{name: "header only", csv: "ghusername,amount,reason\n", wantErr: true},
+		{name: "empty", csv: "", wantErr: true},
+		{name: "unterminated quote", csv: "octocat,20,\"Fixed the leaderboard\n", wantErr: true},
+		{name: "too many rows", csv: strings.Repeat("octocat,1,Typo\n", MaxBountyBatchRows+1), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := ParseBountyBatchCSV(strings.NewReader(tt.csv))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if len(rows) != len(tt.wantRows) {
+				t.Fatalf("parsed %d rows, want %d: %+v", len(rows), len(tt.wantRows), rows)
+			}
+			for i, want := range tt.wantRows {
+				row := rows[i]
+				if row.Line != want.line || row.GhUsername != want.ghusername {
+					t.Errorf("row %d is line %d for %q, want line %d for %q",
+						i, row.Line, row.GhUsername, want.line, want.ghusername)
+				}
+				if (row.Err != nil) != want.wantErr {
+					t.Errorf("line %d error = %v, want error %v", row.Line, row.Err, want.wantErr)
+				}
+				if !want.wantErr && (row.Amount != want.amount || row.Reason != want.reason) {
+					t.Errorf("line %d = %d %q, want %d %q", row.Line, row.Amount, row.Reason, want.amount, want.reason)
+				}
+			}
+		})
+	}
+}
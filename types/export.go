@@ -0,0 +1,22 @@
+package types
+
+import (
+	"strings"
+
+	v "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+type ExportUsersRequest struct {
+	Format string `form:"format"`
+}
+
+func (r *ExportUsersRequest) Validate() error {
+	r.Format = strings.ToLower(strings.TrimSpace(r.Format))
+	if r.Format == "" {
+		r.Format = "ndjson"
+	}
+
+	return v.ValidateStruct(r,
+		v.Field(&r.Format, v.In("ndjson", "csv")),
+	)
+}
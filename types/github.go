@@ -1,8 +1,42 @@
 package types
 
+import (
+	"strings"
+
+	v "github.com/go-ozzo/ozzo-validation/v4"
+)
+
 type GithubUser struct {
-	ID        string `json:"id"`
+	ID        int64  `json:"id"`
 	Username  string `json:"login"`
+	Name      string `json:"name"`
 	Email     string `json:"email"`
 	AvatarUrl string `json:"avatar_url"`
 }
+
+// Missing fields decode to zero values, so a partial response from GitHub
+// is only caught by checking the fields every login depends on
+func (u *GithubUser) Validate() error {
+	u.Username = strings.TrimSpace(u.Username)
+
+	return v.ValidateStruct(u,
+		v.Field(&u.ID, v.Required),
+		v.Field(&u.Username, v.Required),
+	)
+}
+
+type GithubEmail struct {
+	Email    string `json:"email"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
+}
+
+type GithubCallbackQuery struct {
+	Code  string `form:"code"`
+	State string `form:"state"`
+}
+
+type GithubAuthorizeQuery struct {
+	RedirectUri string `form:"redirect_uri"`
+	ClientId    string `form:"client_id"`
+}
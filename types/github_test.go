@@ -0,0 +1,40 @@
+package types
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestGithubUserId(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantId  int64
+		wantErr bool // from decoding or validation
+	}{
+		{name: "small", body: `{"id": 583231, "login": "octocat"}`, wantId: 583231},
+		{name: "beyond int32", body: `{"id": 4294967297, "login": "octocat"}`, wantId: 4294967297},
+		// Not representable as a float64, so it must not pass through one
+		{name: "beyond float precision", body: `{"id": 9007199254740993, "login": "octocat"}`, wantId: 9007199254740993},
+		{name: "int64 max", body: `{"id": 9223372036854775807, "login": "octocat"}`, wantId: math.MaxInt64},
+		{name: "beyond int64", body: `{"id": 9223372036854775808, "login": "octocat"}`, wantErr: true},
+		{name: "missing", body: `{"login": "octocat"}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var user GithubUser
+			err := json.Unmarshal([]byte(tt.body), &user)
+			if err == nil {
+				err = user.Validate()
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && user.ID != tt.wantId {
+				t.Errorf("ID = %d, want %d", user.ID, tt.wantId)
+			}
+		})
+	}
+}
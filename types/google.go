@@ -0,0 +1,9 @@
+package types
+
+// Claims returned by Google's OpenID Connect userinfo endpoint
+type GoogleUser struct {
+	Sub           string `json:"sub"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+}
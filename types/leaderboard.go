@@ -0,0 +1,37 @@
+package types
+
+import (
+	"strings"
+	"time"
+
+	v "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+const (
+	defaultLeaderboardPageSize = 50
+	maxLeaderboardPageSize     = 100
+)
+
+type FetchLeaderboardSnapshotRequest struct {
+	Search   string `form:"search"`
+	After    int    `form:"after"`
+	PageSize int    `form:"page_size"`
+}
+
+func (r *FetchLeaderboardSnapshotRequest) Validate() error {
+	r.Search = strings.TrimSpace(r.Search)
+	if r.PageSize == 0 {
+		r.PageSize = defaultLeaderboardPageSize
+	}
+
+	return v.ValidateStruct(r,
+		v.Field(&r.Search, v.Length(0, 39)),
+		v.Field(&r.After, v.Min(0)),
+		v.Field(&r.PageSize, v.Min(1), v.Max(maxLeaderboardPageSize)),
+	)
+}
+
+// Snapshot dates are calendar days in UTC
+func ParseSnapshotDate(date string) (time.Time, error) {
+	return time.ParseInLocation(time.DateOnly, strings.TrimSpace(date), time.UTC)
+}
@@ -0,0 +1,32 @@
+package types
+
+import (
+	"strings"
+
+	v "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// Delivery event posted by the mail provider
+type MailEvent struct {
+	Event      string `json:"event"`
+	MessageId  string `json:"message_id"`
+	Recipient  string `json:"recipient"`
+	BounceType string `json:"bounce_type"` // "hard" or "soft", bounces only
+}
+
+func (e *MailEvent) Validate() error {
+	e.Event = strings.ToLower(strings.TrimSpace(e.Event))
+	e.MessageId = strings.Trim(strings.TrimSpace(e.MessageId), "<>")
+	e.BounceType = strings.ToLower(strings.TrimSpace(e.BounceType))
+
+	return v.ValidateStruct(e,
+		v.Field(&e.Event, v.Required, v.In("delivered", "bounced", "complained")),
+		v.Field(&e.MessageId, v.Required),
+		v.Field(&e.BounceType, v.In("hard", "soft")),
+	)
+}
+
+// Template is a mail type such as "otp", defaulting to the OTP mail
+type MailPreviewQuery struct {
+	Template string `form:"template"`
+}
@@ -0,0 +1,5 @@
+package types
+
+type MaintenanceRequest struct {
+	Enabled bool `json:"enabled"`
+}
@@ -0,0 +1,26 @@
+package types
+
+import (
+	"fmt"
+
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	v "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// Only the notifications present are changed, the rest keep their setting
+type NotificationPreferencesRequest struct {
+	Notifications map[string]bool `json:"notifications"`
+}
+
+func (r *NotificationPreferencesRequest) Validate() error {
+	return v.ValidateStruct(r,
+		v.Field(&r.Notifications, v.Required, v.By(func(value any) error {
+			for kind := range value.(map[string]bool) {
+				if !pkg.IsNotification(kind) {
+					return fmt.Errorf("unknown notification %q", kind)
+				}
+			}
+			return nil
+		})),
+	)
+}
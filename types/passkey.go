@@ -0,0 +1,49 @@
+package types
+
+import (
+	"strings"
+
+	"github.com/IAmRiteshKoushik/pulse/cmd"
+	"github.com/IAmRiteshKoushik/pulse/pkg"
+	v "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+)
+
+type PasskeyLoginRequest struct {
+	GhUsername string `json:"github_username"`
+}
+
+func (r *PasskeyLoginRequest) Validate() error {
+	r.GhUsername = pkg.NormalizeGhUsername(r.GhUsername)
+
+	return v.ValidateStruct(r,
+		v.Field(&r.GhUsername, v.Required, v.Length(3, 50)),
+	)
+}
+
+// The ceremony id is the one returned along with the login options
+type PasskeyLoginVerifyRequest struct {
+	CeremonyId string `form:"ceremony_id"`
+	ClientId   string `form:"client_id"`
+}
+
+func (r *PasskeyLoginVerifyRequest) Validate(env *cmd.EnvConfig) error {
+	return v.ValidateStruct(r,
+		v.Field(&r.CeremonyId, v.Required, is.UUID),
+		v.Field(&r.ClientId, v.By(func(any) error { return pkg.CheckClientId(env, r.ClientId) })),
+	)
+}
+
+type PasskeyRegisterRequest struct {
+	CeremonyId string `form:"ceremony_id"`
+	Name       string `form:"name"`
+}
+
+func (r *PasskeyRegisterRequest) Validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+
+	return v.ValidateStruct(r,
+		v.Field(&r.CeremonyId, v.Required, is.UUID),
+		v.Field(&r.Name, v.Length(0, 50)),
+	)
+}